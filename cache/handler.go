@@ -0,0 +1,257 @@
+package cache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/valyala/fasthttp"
+
+	"github.com/cash-track/gateway/config"
+	"github.com/cash-track/gateway/headers"
+	"github.com/cash-track/gateway/token"
+	"github.com/cash-track/gateway/traces"
+)
+
+const (
+	metricsNamespace   = "gateway"
+	metricsCacheSubsys = "cache"
+)
+
+var cacheRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: metricsNamespace,
+	Subsystem: metricsCacheSubsys,
+	Name:      "requests_total",
+	Help:      "Requests matching a cache rule by rule and result: hit or miss.",
+}, []string{"rule", "result"})
+
+var cacheStoreErrorsTotal = promauto.NewCounter(prometheus.CounterOpts{
+	Namespace: metricsNamespace,
+	Subsystem: metricsCacheSubsys,
+	Name:      "store_errors_total",
+	Help:      "Cache store reads and writes that failed, the requests were forwarded instead.",
+})
+
+// storedHeaders are kept with an entry. The others are specific to one client, like
+// Set-Cookie, or set by the gateway on every answer.
+var storedHeaders = []string{
+	headers.CacheControl,
+	headers.ContentDisposition,
+	headers.ContentLanguage,
+	headers.ContentType,
+	headers.ETag,
+	headers.XCtApiSha,
+	headers.XCtApiVersion,
+}
+
+type Handler struct {
+	store Store
+	now   func() time.Time
+}
+
+func NewHandler(store Store) *Handler {
+	return &Handler{
+		store: store,
+		now:   time.Now,
+	}
+}
+
+// Handler answers the GET requests matching a rule of config.Global.CacheRules from the
+// store, and stores the answers the upstream allows to. A request whose If-None-Match
+// names the ETag of the answer gets a 304 without the body. Must run after the access
+// token is verified and the access policies are checked: a cached answer skips the upstream.
+func (c *Handler) Handler(h fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		if !ctx.IsGet() {
+			h(ctx)
+
+			return
+		}
+
+		rule, ok := config.Global.FindCacheRule(string(ctx.Path()))
+		if !ok {
+			h(ctx)
+
+			return
+		}
+
+		key := requestKey(ctx, rule)
+
+		if entry, ok := c.lookup(ctx, key); ok {
+			cacheRequestsTotal.WithLabelValues(rule.Name, "hit").Inc()
+			c.write(ctx, entry)
+			headers.NotModified(ctx)
+
+			return
+		}
+
+		cacheRequestsTotal.WithLabelValues(rule.Name, "miss").Inc()
+
+		// the full answer is needed to store it, the 304 is answered below
+		ifNoneMatch := bytes.Clone(ctx.Request.Header.Peek(headers.IfNoneMatch))
+		ctx.Request.Header.Del(headers.IfNoneMatch)
+		h(ctx)
+		if len(ifNoneMatch) > 0 {
+			ctx.Request.Header.SetBytesV(headers.IfNoneMatch, ifNoneMatch)
+		}
+
+		if ttl, ok := freshness(ctx, rule); ok {
+			if err := c.store.Set(traces.FindParentContext(ctx), key, c.newEntry(ctx), ttl); err != nil {
+				c.storeFailed(ctx, err)
+			}
+		}
+
+		headers.NotModified(ctx)
+	}
+}
+
+// lookup skips the store when the client asks for a fresh answer.
+func (c *Handler) lookup(ctx *fasthttp.RequestCtx, key string) (Entry, bool) {
+	directives := parseCacheControl(ctx.Request.Header.Peek(headers.CacheControl))
+	if directives.has("no-cache") || directives.has("no-store") {
+		return Entry{}, false
+	}
+
+	entry, ok, err := c.store.Get(traces.FindParentContext(ctx), key)
+	if err != nil {
+		c.storeFailed(ctx, err)
+
+		return Entry{}, false
+	}
+
+	if !ok {
+		return Entry{}, false
+	}
+
+	for name, value := range entry.Vary {
+		if string(ctx.Request.Header.Peek(name)) != value {
+			return Entry{}, false
+		}
+	}
+
+	return entry, true
+}
+
+func (c *Handler) storeFailed(ctx *fasthttp.RequestCtx, err error) {
+	cacheStoreErrorsTotal.Inc()
+	slog.Warn("cache store unreachable, forwarding", "trace_id", traces.FindTraceId(ctx), "error", err)
+}
+
+func (c *Handler) write(ctx *fasthttp.RequestCtx, entry Entry) {
+	ctx.SetStatusCode(fasthttp.StatusOK)
+
+	for name, value := range entry.Header {
+		ctx.Response.Header.Set(name, value)
+	}
+
+	ctx.Response.Header.Set(headers.Age, strconv.Itoa(int(c.now().Sub(entry.Stored).Seconds())))
+	ctx.SetBody(entry.Body)
+}
+
+func (c *Handler) newEntry(ctx *fasthttp.RequestCtx) Entry {
+	entry := Entry{
+		Header: make(map[string]string),
+		Body:   bytes.Clone(ctx.Response.Body()),
+		Stored: c.now(),
+	}
+
+	for _, name := range storedHeaders {
+		if value := ctx.Response.Header.Peek(name); len(value) > 0 {
+			entry.Header[name] = string(value)
+		}
+	}
+
+	for _, name := range headers.SplitList(headers.UpstreamVary(ctx)) {
+		// the gateway compresses the answers itself
+		if strings.EqualFold(name, fasthttp.HeaderAcceptEncoding) {
+			continue
+		}
+
+		if entry.Vary == nil {
+			entry.Vary = make(map[string]string)
+		}
+
+		entry.Vary[name] = string(ctx.Request.Header.Peek(name))
+	}
+
+	return entry
+}
+
+// requestKey hashes what tells the answers of the rule apart: the path, the query, the
+// language and, when the rule is per user, the user of the verified access token.
+func requestKey(ctx *fasthttp.RequestCtx, rule config.CacheRule) string {
+	user := ""
+	if rule.PerUser {
+		claims, _ := token.ClaimsFromContext(ctx)
+		user = claims.UserId
+	}
+
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		string(ctx.Method()),
+		string(ctx.Path()),
+		string(ctx.URI().QueryString()),
+		string(ctx.Request.Header.Peek(headers.AcceptLanguage)),
+		user,
+	}, "\n")))
+
+	return rule.Name + ":" + hex.EncodeToString(sum[:])
+}
+
+// freshness tells how long the answer can be stored: s-maxage or max-age of its
+// Cache-Control, or the TTL of the rule. Only full 200 answers are, and neither the ones
+// varying on every request nor those the upstream marks no-store, no-cache or, unless the
+// rule is per user, private.
+func freshness(ctx *fasthttp.RequestCtx, rule config.CacheRule) (time.Duration, bool) {
+	if ctx.Response.StatusCode() != fasthttp.StatusOK || ctx.Response.IsBodyStream() || ctx.Hijacked() {
+		return 0, false
+	}
+
+	for _, name := range headers.SplitList(headers.UpstreamVary(ctx)) {
+		if name == "*" {
+			return 0, false
+		}
+	}
+
+	directives := parseCacheControl(ctx.Response.Header.Peek(headers.CacheControl))
+	if directives.has("no-store") || directives.has("no-cache") || (directives.has("private") && !rule.PerUser) {
+		return 0, false
+	}
+
+	for _, name := range []string{"s-maxage", "max-age"} {
+		if v, ok := directives[name]; ok {
+			seconds, err := strconv.Atoi(v)
+			if err != nil || seconds <= 0 {
+				return 0, false
+			}
+
+			return time.Duration(seconds) * time.Second, true
+		}
+	}
+
+	return rule.TTL, true
+}
+
+type cacheControl map[string]string
+
+func (c cacheControl) has(directive string) bool {
+	_, ok := c[directive]
+
+	return ok
+}
+
+func parseCacheControl(value []byte) cacheControl {
+	directives := cacheControl{}
+
+	for _, directive := range headers.SplitList(value) {
+		name, arg, _ := strings.Cut(directive, "=")
+		directives[strings.ToLower(strings.TrimSpace(name))] = strings.Trim(strings.TrimSpace(arg), `"`)
+	}
+
+	return directives
+}
@@ -0,0 +1,261 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+
+	"github.com/cash-track/gateway/config"
+	"github.com/cash-track/gateway/headers"
+	"github.com/cash-track/gateway/headers/cookie"
+	"github.com/cash-track/gateway/session"
+	"github.com/cash-track/gateway/token"
+)
+
+var testSecret = []byte("test-secret")
+
+var testRules = []config.CacheRule{
+	{Name: "currencies", Prefix: "/api/currencies", TTL: time.Minute},
+	{Name: "categories", Prefix: "/api/categories", TTL: time.Minute, PerUser: true},
+}
+
+type storeFunc struct {
+	get func(key string) (Entry, bool, error)
+	set func(key string, entry Entry, ttl time.Duration) error
+}
+
+func (s storeFunc) Get(_ context.Context, key string) (Entry, bool, error) {
+	return s.get(key)
+}
+
+func (s storeFunc) Set(_ context.Context, key string, entry Entry, ttl time.Duration) error {
+	return s.set(key, entry, ttl)
+}
+
+func accessToken(userId int) string {
+	s, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": userId,
+		"iat": time.Now().Unix(),
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}).SignedString(testSecret)
+
+	return s
+}
+
+func withCacheRules(t *testing.T) {
+	original := config.Global.CacheRules
+	config.Global.CacheRules = testRules
+	t.Cleanup(func() { config.Global.CacheRules = original })
+}
+
+// upstream answers like forwardResponse with the given Cache-Control and Vary, counting
+// the requests it gets.
+func upstream(calls *int, cacheControl, vary string) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		*calls++
+
+		resp := fasthttp.Response{}
+		resp.Header.Set(headers.Vary, vary)
+		headers.KeepUpstreamVary(ctx, &resp)
+
+		ctx.SetStatusCode(fasthttp.StatusOK)
+		ctx.SetContentType("application/json")
+		ctx.Response.Header.Set(headers.ETag, `"v1"`)
+		c := fasthttp.AcquireCookie()
+		c.SetKey("visited")
+		c.SetValue("1")
+		ctx.Response.Header.SetCookie(c)
+		fasthttp.ReleaseCookie(c)
+		if cacheControl != "" {
+			ctx.Response.Header.Set(headers.CacheControl, cacheControl)
+		}
+		ctx.SetBodyString(`[{"code":"USD"}]`)
+	}
+}
+
+func request(h fasthttp.RequestHandler, path string, header map[string]string) *fasthttp.RequestCtx {
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod(fasthttp.MethodGet)
+	ctx.Request.SetRequestURI(path)
+	for k, v := range header {
+		ctx.Request.Header.Set(k, v)
+	}
+
+	h(ctx)
+
+	return ctx
+}
+
+func TestHandler(t *testing.T) {
+	withCacheRules(t)
+
+	calls := 0
+	h := NewHandler(NewMemoryStore()).Handler(upstream(&calls, "", ""))
+	hits := testutil.ToFloat64(cacheRequestsTotal.WithLabelValues("currencies", "hit"))
+
+	ctx := request(h, "/api/currencies?page=1", nil)
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, fasthttp.StatusOK, ctx.Response.StatusCode())
+
+	ctx = request(h, "/api/currencies?page=1", nil)
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, fasthttp.StatusOK, ctx.Response.StatusCode())
+	assert.Equal(t, `[{"code":"USD"}]`, string(ctx.Response.Body()))
+	assert.Equal(t, "application/json", string(ctx.Response.Header.ContentType()))
+	assert.Equal(t, `"v1"`, string(ctx.Response.Header.Peek(headers.ETag)))
+	assert.Equal(t, "0", string(ctx.Response.Header.Peek(headers.Age)))
+	assert.Empty(t, ctx.Response.Header.PeekCookie("visited"))
+	assert.Equal(t, hits+1, testutil.ToFloat64(cacheRequestsTotal.WithLabelValues("currencies", "hit")))
+
+	// answered apart per query and language
+	request(h, "/api/currencies?page=2", nil)
+	request(h, "/api/currencies?page=1", map[string]string{headers.AcceptLanguage: "uk"})
+	assert.Equal(t, 3, calls)
+
+	// the client asks for a fresh answer
+	request(h, "/api/currencies?page=1", map[string]string{headers.CacheControl: "no-cache"})
+	assert.Equal(t, 4, calls)
+
+	// not cached: other methods and paths
+	ctx = &fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod(fasthttp.MethodPost)
+	ctx.Request.SetRequestURI("/api/currencies?page=1")
+	h(ctx)
+	request(h, "/api/wallets", nil)
+	request(h, "/api/wallets", nil)
+	assert.Equal(t, 7, calls)
+}
+
+func TestHandlerNotModified(t *testing.T) {
+	withCacheRules(t)
+
+	calls := 0
+	h := NewHandler(NewMemoryStore()).Handler(upstream(&calls, "", ""))
+
+	for name, ifNoneMatch := range map[string]string{
+		"Miss": `"v1"`,
+		"Hit":  `"v0", W/"v1"`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctx := request(h, "/api/currencies", map[string]string{headers.IfNoneMatch: ifNoneMatch})
+
+			assert.Equal(t, fasthttp.StatusNotModified, ctx.Response.StatusCode())
+			assert.Empty(t, ctx.Response.Body())
+			assert.Equal(t, `"v1"`, string(ctx.Response.Header.Peek(headers.ETag)))
+		})
+	}
+
+	ctx := request(h, "/api/currencies", map[string]string{headers.IfNoneMatch: `"v0"`})
+	assert.Equal(t, fasthttp.StatusOK, ctx.Response.StatusCode())
+	assert.Equal(t, 1, calls)
+}
+
+func TestHandlerHonorsCacheControl(t *testing.T) {
+	withCacheRules(t)
+
+	for name, test := range map[string]struct {
+		cacheControl string
+		vary         string
+		expectStored bool
+		expectTTL    time.Duration
+	}{
+		"RuleTTL": {
+			expectStored: true,
+			expectTTL:    time.Minute,
+		},
+		"MaxAge": {
+			cacheControl: "public, max-age=300",
+			expectStored: true,
+			expectTTL:    5 * time.Minute,
+		},
+		"SharedMaxAge": {
+			cacheControl: "max-age=60, s-maxage=600",
+			expectStored: true,
+			expectTTL:    10 * time.Minute,
+		},
+		"NoStore": {
+			cacheControl: "no-store",
+		},
+		"NoCache": {
+			cacheControl: "No-Cache",
+		},
+		"Private": {
+			cacheControl: "private, max-age=60",
+		},
+		"ZeroMaxAge": {
+			cacheControl: "max-age=0",
+		},
+		"VaryAll": {
+			vary: "*",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			stored := false
+			store := storeFunc{
+				get: func(string) (Entry, bool, error) { return Entry{}, false, nil },
+				set: func(_ string, _ Entry, ttl time.Duration) error {
+					stored = true
+					assert.Equal(t, test.expectTTL, ttl)
+
+					return nil
+				},
+			}
+
+			calls := 0
+			request(NewHandler(store).Handler(upstream(&calls, test.cacheControl, test.vary)), "/api/currencies", nil)
+
+			assert.Equal(t, test.expectStored, stored)
+		})
+	}
+}
+
+func TestHandlerVary(t *testing.T) {
+	withCacheRules(t)
+
+	calls := 0
+	h := NewHandler(NewMemoryStore()).Handler(upstream(&calls, "", "X-Client, Accept-Encoding"))
+
+	request(h, "/api/currencies", map[string]string{"X-Client": "web", fasthttp.HeaderAcceptEncoding: "gzip"})
+	request(h, "/api/currencies", map[string]string{"X-Client": "web"})
+	assert.Equal(t, 1, calls)
+
+	request(h, "/api/currencies", map[string]string{"X-Client": "ios"})
+	assert.Equal(t, 2, calls)
+}
+
+func TestHandlerPerUser(t *testing.T) {
+	withCacheRules(t)
+
+	calls := 0
+	h := token.NewHandler(token.NewHmacVerifier(testSecret), session.CookieStore{}).
+		Handler(NewHandler(NewMemoryStore()).Handler(upstream(&calls, "private", "")))
+
+	for _, userId := range []int{1, 2, 1} {
+		request(h, "/api/categories", map[string]string{"Cookie": cookie.AccessTokenCookieName + "=" + accessToken(userId)})
+	}
+
+	assert.Equal(t, 2, calls)
+}
+
+func TestHandlerStoreError(t *testing.T) {
+	withCacheRules(t)
+
+	store := storeFunc{
+		get: func(string) (Entry, bool, error) { return Entry{}, false, errors.New("connection refused") },
+		set: func(string, Entry, time.Duration) error { return errors.New("connection refused") },
+	}
+	errorsBefore := testutil.ToFloat64(cacheStoreErrorsTotal)
+
+	calls := 0
+	ctx := request(NewHandler(store).Handler(upstream(&calls, "", "")), "/api/currencies", nil)
+
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, fasthttp.StatusOK, ctx.Response.StatusCode())
+	assert.Equal(t, errorsBefore+2, testutil.ToFloat64(cacheStoreErrorsTotal))
+}
@@ -0,0 +1,72 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	// sweepThreshold is the number of entries above which expired ones are dropped.
+	sweepThreshold = 10000
+	// maxMemoryEntries caps the entries kept, new ones are not stored past it.
+	maxMemoryEntries = 50000
+)
+
+type memoryEntry struct {
+	entry   Entry
+	expires time.Time
+}
+
+// MemoryStore keeps the entries of this instance only, each replica fills its own.
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+	now     func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		entries: make(map[string]memoryEntry),
+		now:     time.Now,
+	}
+}
+
+func (s *MemoryStore) Get(_ context.Context, key string) (Entry, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[key]
+	if !ok || !s.now().Before(e.expires) {
+		return Entry{}, false, nil
+	}
+
+	return e.entry, true, nil
+}
+
+func (s *MemoryStore) Set(_ context.Context, key string, entry Entry, ttl time.Duration) error {
+	now := s.now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.entries) > sweepThreshold {
+		s.sweep(now)
+	}
+
+	if _, ok := s.entries[key]; !ok && len(s.entries) >= maxMemoryEntries {
+		return nil
+	}
+
+	s.entries[key] = memoryEntry{entry: entry, expires: now.Add(ttl)}
+
+	return nil
+}
+
+func (s *MemoryStore) sweep(now time.Time) {
+	for key, e := range s.entries {
+		if !now.Before(e.expires) {
+			delete(s.entries, key)
+		}
+	}
+}
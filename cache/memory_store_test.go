@@ -0,0 +1,47 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryStore(t *testing.T) {
+	now := time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC)
+
+	s := NewMemoryStore()
+	s.now = func() time.Time { return now }
+
+	_, ok, err := s.Get(context.Background(), "currencies")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	entry := Entry{Body: []byte(`[]`), Stored: now}
+	assert.NoError(t, s.Set(context.Background(), "currencies", entry, time.Minute))
+
+	found, ok, err := s.Get(context.Background(), "currencies")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, entry, found)
+
+	now = now.Add(time.Minute)
+
+	_, ok, _ = s.Get(context.Background(), "currencies")
+	assert.False(t, ok)
+}
+
+func TestMemoryStoreSweepsExpired(t *testing.T) {
+	now := time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC)
+
+	s := NewMemoryStore()
+	s.now = func() time.Time { return now }
+
+	for i := 0; i <= sweepThreshold; i++ {
+		s.entries[string(rune(i))] = memoryEntry{expires: now}
+	}
+
+	assert.NoError(t, s.Set(context.Background(), "currencies", Entry{}, time.Minute))
+	assert.Len(t, s.entries, 1)
+}
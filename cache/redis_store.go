@@ -0,0 +1,52 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const keyPrefix = "CT:cache"
+
+// RedisStore shares the entries across the replicas.
+type RedisStore struct {
+	client *redis.Client
+}
+
+func NewRedisStore(client *redis.Client) *RedisStore {
+	return &RedisStore{client: client}
+}
+
+func (s *RedisStore) Get(ctx context.Context, key string) (Entry, bool, error) {
+	data, err := s.client.Get(ctx, keyPrefix+":"+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return Entry{}, false, nil
+	}
+	if err != nil {
+		return Entry{}, false, fmt.Errorf("cache get: %w", err)
+	}
+
+	entry := Entry{}
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return Entry{}, false, fmt.Errorf("cache decode: %w", err)
+	}
+
+	return entry, true, nil
+}
+
+func (s *RedisStore) Set(ctx context.Context, key string, entry Entry, ttl time.Duration) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("cache encode: %w", err)
+	}
+
+	if err := s.client.Set(ctx, keyPrefix+":"+key, data, ttl).Err(); err != nil {
+		return fmt.Errorf("cache set: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,77 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redismock/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedisStoreGet(t *testing.T) {
+	stored := time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC)
+
+	for name, test := range map[string]struct {
+		reply    string
+		err      error
+		expected Entry
+		found    bool
+		wantErr  string
+	}{
+		"Found": {
+			reply:    `{"header":{"Content-Type":"application/json"},"body":"W10=","stored":"2024-05-01T10:30:00Z"}`,
+			expected: Entry{Header: map[string]string{"Content-Type": "application/json"}, Body: []byte(`[]`), Stored: stored},
+			found:    true,
+		},
+		"Missing": {},
+		"RedisError": {
+			err:     errors.New("connection refused"),
+			wantErr: "cache get: connection refused",
+		},
+		"Corrupted": {
+			reply:   `{`,
+			wantErr: "cache decode: unexpected end of JSON input",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			client, mock := redismock.NewClientMock()
+
+			expect := mock.ExpectGet("CT:cache:currencies:key")
+			switch {
+			case test.err != nil:
+				expect.SetErr(test.err)
+			case test.reply != "":
+				expect.SetVal(test.reply)
+			default:
+				expect.RedisNil()
+			}
+
+			entry, found, err := NewRedisStore(client).Get(context.Background(), "currencies:key")
+
+			if test.wantErr != "" {
+				assert.EqualError(t, err, test.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, test.found, found)
+			assert.Equal(t, test.expected, entry)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestRedisStoreSet(t *testing.T) {
+	client, mock := redismock.NewClientMock()
+	entry := Entry{Body: []byte(`[]`), Stored: time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC)}
+
+	mock.ExpectSet("CT:cache:currencies:key", []byte(`{"header":null,"body":"W10=","stored":"2024-05-01T10:30:00Z"}`), time.Minute).SetVal("OK")
+
+	assert.NoError(t, NewRedisStore(client).Set(context.Background(), "currencies:key", entry, time.Minute))
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	mock.ExpectSet("CT:cache:currencies:key", []byte(`{"header":null,"body":"W10=","stored":"2024-05-01T10:30:00Z"}`), time.Minute).SetErr(errors.New("connection refused"))
+
+	assert.EqualError(t, NewRedisStore(client).Set(context.Background(), "currencies:key", entry, time.Minute), "cache set: connection refused")
+}
@@ -0,0 +1,23 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// Entry is a cached answer: the headers of it that are not specific to one client, its body,
+// and the values of the request headers it varies on, which a request must have as well to
+// be answered with it.
+type Entry struct {
+	Header map[string]string `json:"header"`
+	Body   []byte            `json:"body"`
+	Vary   map[string]string `json:"vary,omitempty"`
+	Stored time.Time         `json:"stored"`
+}
+
+// Store keeps the entries until their ttl runs out. Get reports a missing or expired entry
+// with false and no error.
+type Store interface {
+	Get(ctx context.Context, key string) (Entry, bool, error)
+	Set(ctx context.Context, key string, entry Entry, ttl time.Duration) error
+}
@@ -1,22 +1,9 @@
 package captcha
 
 import (
-	"encoding/json"
-	"fmt"
-	"log/slog"
-	"strings"
 	"time"
 
-	"github.com/valyala/fasthttp"
-	"go.opentelemetry.io/otel/attribute"
-	"go.opentelemetry.io/otel/codes"
-	"go.opentelemetry.io/otel/trace"
-
-	"github.com/cash-track/gateway/config"
-	"github.com/cash-track/gateway/headers"
 	"github.com/cash-track/gateway/http/retryhttp"
-	"github.com/cash-track/gateway/traces"
-	"github.com/cash-track/gateway/traces/semconv"
 )
 
 const (
@@ -27,129 +14,21 @@ const (
 )
 
 type GoogleReCaptchaProvider struct {
-	client retryhttp.Client
-	secret string
-}
-
-type googleReCaptchaVerifyResponse struct {
-	Success     bool     `json:"success"`
-	ChallengeTS string   `json:"challenge_ts,omitempty"`
-	Hostname    string   `json:"hostname,omitempty"`
-	Score       float32  `json:"score,omitempty"`
-	Action      string   `json:"action,omitempty"`
-	ErrorCodes  []string `json:"error-codes,omitempty"`
-}
-
-func (r *googleReCaptchaVerifyResponse) GetOpenTelemetryAttributes() []attribute.KeyValue {
-	return []attribute.KeyValue{
-		attribute.Bool(semconv.CashTrackCaptchaSuccessKey, r.Success),
-		attribute.String(semconv.CashTrackCaptchaChallengeTSKey, r.ChallengeTS),
-		attribute.String(semconv.CashTrackCaptchaHostnameKey, r.Hostname),
-		attribute.Float64(semconv.CashTrackCaptchaScoreKey, float64(r.Score)),
-		attribute.String(semconv.CashTrackCaptchaActionKey, r.Action),
-		attribute.String(semconv.CashTrackCaptchaErrorCodesKey, strings.Join(r.ErrorCodes, ",")),
-	}
-}
-
-func NewGoogleReCaptchaProvider(httpClient retryhttp.Client, options config.Config) *GoogleReCaptchaProvider {
-	httpClient.WithReadTimeout(googleApiReadTimeout)
-	httpClient.WithWriteTimeout(googleApiWriteTimeout)
-	httpClient.WithRetryAttempts(googleApiRetryAttempts)
-
-	return &GoogleReCaptchaProvider{
-		client: httpClient,
-		secret: options.CaptchaSecret,
-	}
+	siteVerifyProvider
 }
 
-func (p *GoogleReCaptchaProvider) Verify(ctx *fasthttp.RequestCtx) (bool, error) {
-	clientIp := headers.GetClientIPFromContext(ctx)
-
-	_, span := traces.GetTracer().Start(
-		traces.FindParentContext(ctx),
-		fmt.Sprintf("google recaptcha %s %s", ctx.Request.Header.Method(), ctx.URI().PathOriginal()),
-		trace.WithAttributes(
-			traces.MergeAttributes(
-				traces.Attributes(attribute.String("http.request.real_ip", clientIp)),
-				traces.RequestAttributes(&ctx.Request),
-			)...,
+func NewGoogleReCaptchaProvider(httpClient retryhttp.Client) *GoogleReCaptchaProvider {
+	p := &GoogleReCaptchaProvider{
+		siteVerifyProvider: newSiteVerifyProvider(
+			"google recaptcha",
+			googleApiReCaptchaVerifyUrl,
+			httpClient,
+			googleApiReadTimeout,
+			googleApiWriteTimeout,
+			googleApiRetryAttempts,
 		),
-	)
-	defer span.End()
-
-	if p.secret == "" {
-		span.SetStatus(codes.Ok, "disabled")
-		slog.Info("captcha secret empty, skipping verify", "client_ip", clientIp)
-
-		return true, nil
-	}
-
-	if string(ctx.Request.Header.Method()) == fasthttp.MethodOptions {
-		span.SetStatus(codes.Ok, "unsupported method")
-
-		return true, nil
-	}
-
-	challenge := ctx.Request.Header.Peek(headers.XCtCaptchaChallenge)
-	if challenge == nil || string(challenge) == "" {
-		span.SetStatus(codes.Error, "empty challenge")
-		slog.Warn("captcha challenge empty", "client_ip", clientIp)
-
-		return false, nil
-	}
-
-	req := fasthttp.AcquireRequest()
-	resp := fasthttp.AcquireResponse()
-	defer func() {
-		fasthttp.ReleaseRequest(req)
-		fasthttp.ReleaseResponse(resp)
-	}()
-
-	p.buildReq(req, challenge, clientIp)
-
-	span.SetAttributes(traces.RequestAttributes(req)...)
-
-	if err := p.client.Do(req, resp); err != nil {
-		span.RecordError(err)
-		span.SetStatus(codes.Error, "request error")
-
-		return false, fmt.Errorf("captcha verify request error: %w", err)
-	}
-
-	span.SetAttributes(traces.ResponseAttributes(resp)...)
-
-	verifyResp := googleReCaptchaVerifyResponse{}
-	if err := json.Unmarshal(resp.Body(), &verifyResp); err != nil {
-		span.RecordError(err)
-		span.SetStatus(codes.Error, "read body error")
-
-		return false, fmt.Errorf("captcha verify response unexpected: %w", err)
 	}
+	p.checkScore = true
 
-	span.SetAttributes(traces.AttributesGetter(&verifyResp)...)
-
-	if !verifyResp.Success {
-		slog.Warn("captcha verify unsuccessful",
-			"client_ip", clientIp,
-			"score", verifyResp.Score,
-			"error", strings.Join(verifyResp.ErrorCodes, ", "),
-		)
-		span.SetStatus(codes.Error, "validation failed")
-
-		return false, nil
-	}
-
-	slog.Info("captcha verify ok", "client_ip", clientIp)
-	span.SetStatus(codes.Ok, "ok")
-
-	return true, nil
-}
-
-func (p *GoogleReCaptchaProvider) buildReq(req *fasthttp.Request, challenge []byte, clientIp string) {
-	req.SetRequestURI(googleApiReCaptchaVerifyUrl)
-	req.Header.SetMethod(fasthttp.MethodPost)
-	req.Header.SetContentTypeBytes(headers.ContentTypeForm)
-	req.PostArgs().Set("secret", p.secret)
-	req.PostArgs().Set("remoteip", clientIp)
-	req.PostArgs().SetBytesV("response", challenge)
+	return p
 }
@@ -14,6 +14,15 @@ import (
 	"github.com/cash-track/gateway/mocks"
 )
 
+func withCaptchaSecret(t *testing.T, secret string) {
+	t.Helper()
+
+	original := config.Global
+	t.Cleanup(func() { config.Global = original })
+
+	config.Global.CaptchaSecret = secret
+}
+
 func TestVerify(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	c := mocks.NewHttpRetryClientMock(ctrl)
@@ -40,9 +49,8 @@ func TestVerify(t *testing.T) {
 		return nil
 	})
 
-	p := NewGoogleReCaptchaProvider(c, config.Config{
-		CaptchaSecret: "captcha_secret_1",
-	})
+	withCaptchaSecret(t, "captcha_secret_1")
+	p := NewGoogleReCaptchaProvider(c)
 	state, err := p.Verify(&ctx)
 
 	assert.True(t, state)
@@ -75,9 +83,8 @@ func TestVerifyUnsuccessful(t *testing.T) {
 		return nil
 	})
 
-	p := NewGoogleReCaptchaProvider(c, config.Config{
-		CaptchaSecret: "captcha_secret_1",
-	})
+	withCaptchaSecret(t, "captcha_secret_1")
+	p := NewGoogleReCaptchaProvider(c)
 	state, err := p.Verify(&ctx)
 
 	assert.False(t, state)
@@ -96,9 +103,8 @@ func TestVerifyEmptySecret(t *testing.T) {
 	c.EXPECT().WithWriteTimeout(gomock.Eq(googleApiWriteTimeout))
 	c.EXPECT().WithRetryAttempts(gomock.Eq(googleApiRetryAttempts))
 
-	p := NewGoogleReCaptchaProvider(c, config.Config{
-		CaptchaSecret: "",
-	})
+	withCaptchaSecret(t, "")
+	p := NewGoogleReCaptchaProvider(c)
 	state, err := p.Verify(&ctx)
 
 	assert.True(t, state)
@@ -118,9 +124,8 @@ func TestVerifyOptions(t *testing.T) {
 	c.EXPECT().WithWriteTimeout(gomock.Eq(googleApiWriteTimeout))
 	c.EXPECT().WithRetryAttempts(gomock.Eq(googleApiRetryAttempts))
 
-	p := NewGoogleReCaptchaProvider(c, config.Config{
-		CaptchaSecret: "captcha_secret_1",
-	})
+	withCaptchaSecret(t, "captcha_secret_1")
+	p := NewGoogleReCaptchaProvider(c)
 	state, err := p.Verify(&ctx)
 
 	assert.True(t, state)
@@ -139,9 +144,8 @@ func TestVerifyEmptyChallenge(t *testing.T) {
 	c.EXPECT().WithWriteTimeout(gomock.Eq(googleApiWriteTimeout))
 	c.EXPECT().WithRetryAttempts(gomock.Eq(googleApiRetryAttempts))
 
-	p := NewGoogleReCaptchaProvider(c, config.Config{
-		CaptchaSecret: "captcha_secret_1",
-	})
+	withCaptchaSecret(t, "captcha_secret_1")
+	p := NewGoogleReCaptchaProvider(c)
 	state, err := p.Verify(&ctx)
 
 	assert.False(t, state)
@@ -161,9 +165,8 @@ func TestVerifyRequestFail(t *testing.T) {
 	c.EXPECT().WithRetryAttempts(gomock.Eq(googleApiRetryAttempts))
 	c.EXPECT().Do(gomock.Any(), gomock.Any()).Return(fmt.Errorf("broken pipe"))
 
-	p := NewGoogleReCaptchaProvider(c, config.Config{
-		CaptchaSecret: "captcha_secret_1",
-	})
+	withCaptchaSecret(t, "captcha_secret_1")
+	p := NewGoogleReCaptchaProvider(c)
 	state, err := p.Verify(&ctx)
 
 	assert.False(t, state)
@@ -187,9 +190,8 @@ func TestVerifyBadResponse(t *testing.T) {
 		return nil
 	})
 
-	p := NewGoogleReCaptchaProvider(c, config.Config{
-		CaptchaSecret: "captcha_secret_1",
-	})
+	withCaptchaSecret(t, "captcha_secret_1")
+	p := NewGoogleReCaptchaProvider(c)
 	state, err := p.Verify(&ctx)
 
 	assert.False(t, state)
@@ -0,0 +1,31 @@
+package captcha
+
+import (
+	"time"
+
+	"github.com/cash-track/gateway/http/retryhttp"
+)
+
+const (
+	hCaptchaVerifyUrl     = "https://api.hcaptcha.com/siteverify"
+	hCaptchaReadTimeout   = 500 * time.Millisecond
+	hCaptchaWriteTimeout  = time.Second
+	hCaptchaRetryAttempts = uint(2)
+)
+
+type HCaptchaProvider struct {
+	siteVerifyProvider
+}
+
+func NewHCaptchaProvider(httpClient retryhttp.Client) *HCaptchaProvider {
+	return &HCaptchaProvider{
+		siteVerifyProvider: newSiteVerifyProvider(
+			"hcaptcha",
+			hCaptchaVerifyUrl,
+			httpClient,
+			hCaptchaReadTimeout,
+			hCaptchaWriteTimeout,
+			hCaptchaRetryAttempts,
+		),
+	}
+}
@@ -0,0 +1,71 @@
+package captcha
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+	"go.uber.org/mock/gomock"
+
+	"github.com/cash-track/gateway/headers"
+	"github.com/cash-track/gateway/mocks"
+)
+
+func TestHCaptchaVerify(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	c := mocks.NewHttpRetryClientMock(ctrl)
+
+	ctx := fasthttp.RequestCtx{}
+	ctx.SetRemoteAddr(&net.TCPAddr{IP: []byte{0xA, 0x0, 0x0, 0x1}})
+	ctx.Request.Header.Set(headers.XCtCaptchaChallenge, "captcha_challenge_2")
+
+	c.EXPECT().WithReadTimeout(gomock.Eq(hCaptchaReadTimeout))
+	c.EXPECT().WithWriteTimeout(gomock.Eq(hCaptchaWriteTimeout))
+	c.EXPECT().WithRetryAttempts(gomock.Eq(hCaptchaRetryAttempts))
+	c.EXPECT().Do(gomock.Any(), gomock.Any()).DoAndReturn(func(req *fasthttp.Request, resp *fasthttp.Response) error {
+		resp.SetStatusCode(fasthttp.StatusOK)
+		resp.SetBodyString(`{"success":true,"challenge_ts":"2024-05-01T10:30:00.000000Z","hostname":"cash-track.app","credit":false}`)
+
+		assert.Equal(t, fasthttp.MethodPost, string(req.Header.Method()))
+		assert.Equal(t, hCaptchaVerifyUrl, req.URI().String())
+		assert.Equal(t, string(headers.ContentTypeForm), string(req.Header.ContentType()))
+		assert.Equal(t, "captcha_secret_1", string(req.PostArgs().Peek("secret")))
+		assert.Equal(t, "10.0.0.1", string(req.PostArgs().Peek("remoteip")))
+		assert.Equal(t, "captcha_challenge_2", string(req.PostArgs().Peek("response")))
+
+		return nil
+	})
+
+	withCaptchaSecret(t, "captcha_secret_1")
+	p := NewHCaptchaProvider(c)
+	state, err := p.Verify(&ctx)
+
+	assert.True(t, state)
+	assert.NoError(t, err)
+}
+
+func TestHCaptchaVerifyUnsuccessful(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	c := mocks.NewHttpRetryClientMock(ctrl)
+
+	ctx := fasthttp.RequestCtx{}
+	ctx.Request.Header.Set(headers.XCtCaptchaChallenge, "captcha_challenge_2")
+
+	c.EXPECT().WithReadTimeout(gomock.Any())
+	c.EXPECT().WithWriteTimeout(gomock.Any())
+	c.EXPECT().WithRetryAttempts(gomock.Any())
+	c.EXPECT().Do(gomock.Any(), gomock.Any()).DoAndReturn(func(req *fasthttp.Request, resp *fasthttp.Response) error {
+		resp.SetStatusCode(fasthttp.StatusOK)
+		resp.SetBodyString(`{"success":false,"error-codes":["invalid-input-response"]}`)
+
+		return nil
+	})
+
+	withCaptchaSecret(t, "captcha_secret_1")
+	p := NewHCaptchaProvider(c)
+	state, err := p.Verify(&ctx)
+
+	assert.False(t, state)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,75 @@
+package captcha
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/valyala/fasthttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/cash-track/gateway/headers"
+	"github.com/cash-track/gateway/traces"
+	"github.com/cash-track/gateway/traces/semconv"
+)
+
+const (
+	// LocalPassChallenge is the only challenge the local provider accepts.
+	LocalPassChallenge = "local-pass"
+	// LocalErrorChallenge makes the local provider fail as if the verify API was down.
+	LocalErrorChallenge = "local-error"
+)
+
+var errLocalProvider = errors.New("local captcha provider error")
+
+// LocalProvider verifies challenges without any network call, for end-to-end tests. It
+// never skips verification, whatever CAPTCHA_SECRET is: a test gets exactly the outcome
+// the challenge asks for.
+type LocalProvider struct{}
+
+func NewLocalProvider() *LocalProvider {
+	return &LocalProvider{}
+}
+
+func (p *LocalProvider) Verify(ctx *fasthttp.RequestCtx) (bool, error) {
+	clientIp := headers.GetClientIPFromContext(ctx)
+
+	_, span := traces.GetTracer().Start(
+		traces.FindParentContext(ctx),
+		fmt.Sprintf("local captcha %s %s", ctx.Request.Header.Method(), ctx.URI().PathOriginal()),
+		trace.WithAttributes(
+			traces.MergeAttributes(
+				traces.Attributes(attribute.String("http.request.real_ip", clientIp)),
+				traces.RequestAttributes(&ctx.Request),
+			)...,
+		),
+	)
+	defer span.End()
+
+	if string(ctx.Request.Header.Method()) == fasthttp.MethodOptions {
+		span.SetStatus(codes.Ok, "unsupported method")
+
+		return true, nil
+	}
+
+	switch string(ctx.Request.Header.Peek(headers.XCtCaptchaChallenge)) {
+	case LocalPassChallenge:
+		span.SetAttributes(attribute.Bool(semconv.CashTrackCaptchaSuccessKey, true))
+		span.SetStatus(codes.Ok, "ok")
+
+		return true, nil
+	case LocalErrorChallenge:
+		span.RecordError(errLocalProvider)
+		span.SetStatus(codes.Error, "request error")
+
+		return false, fmt.Errorf("captcha verify request error: %w", errLocalProvider)
+	default:
+		span.SetAttributes(attribute.Bool(semconv.CashTrackCaptchaSuccessKey, false))
+		span.SetStatus(codes.Error, "validation failed")
+		slog.Warn("captcha verify unsuccessful", "provider", "local", "client_ip", clientIp)
+
+		return false, nil
+	}
+}
@@ -0,0 +1,60 @@
+package captcha
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+
+	"github.com/cash-track/gateway/headers"
+)
+
+func TestLocalVerify(t *testing.T) {
+	for name, test := range map[string]struct {
+		method      string
+		challenge   string
+		secret      string
+		expectState bool
+		expectErr   bool
+	}{
+		"Pass": {
+			challenge:   LocalPassChallenge,
+			expectState: true,
+		},
+		"PassWithSecret": {
+			challenge:   LocalPassChallenge,
+			secret:      "captcha_secret_1",
+			expectState: true,
+		},
+		"FailEvenWithoutSecret": {
+			challenge: "anything-else",
+		},
+		"EmptyChallenge": {},
+		"Error": {
+			challenge: LocalErrorChallenge,
+			expectErr: true,
+		},
+		"Options": {
+			method:      fasthttp.MethodOptions,
+			expectState: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctx := fasthttp.RequestCtx{}
+			if test.method != "" {
+				ctx.Request.Header.SetMethod(test.method)
+			}
+			ctx.Request.Header.Set(headers.XCtCaptchaChallenge, test.challenge)
+
+			withCaptchaSecret(t, test.secret)
+			state, err := NewLocalProvider().Verify(&ctx)
+
+			assert.Equal(t, test.expectState, state)
+			if test.expectErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
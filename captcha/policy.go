@@ -0,0 +1,86 @@
+package captcha
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/cash-track/gateway/config"
+)
+
+// Reasons of a RejectedError.
+const (
+	RejectedScore    = "score"
+	RejectedAction   = "action"
+	RejectedHostname = "hostname"
+	RejectedReplay   = "replay"
+)
+
+// RejectedError is returned by Verify along with false when the provider accepted the
+// token but the endpoint policy did not. It is a client problem, not a provider failure.
+type RejectedError struct {
+	Reason string
+	// Fallback tells the client to retry with an interactive challenge: a low score may
+	// come from a real user, while a token minted for another action or site may not.
+	Fallback bool
+	Detail   string
+}
+
+func (e *RejectedError) Error() string {
+	return fmt.Sprintf("captcha rejected by %s: %s", e.Reason, e.Detail)
+}
+
+// checkPolicy applies the policy of the request path and the allowed hostnames to a
+// successful provider response. A response without the action or score the policy asks
+// for is rejected, it cannot prove the token was minted for the endpoint.
+func checkPolicy(path string, resp *siteVerifyResponse, checkScore bool) *RejectedError {
+	if resp.Hostname != "" && len(config.Global.CaptchaHostnames) > 0 &&
+		!slices.Contains(config.Global.CaptchaHostnames, resp.Hostname) {
+		return &RejectedError{
+			Reason: RejectedHostname,
+			Detail: fmt.Sprintf("token solved on unexpected hostname %q", resp.Hostname),
+		}
+	}
+
+	policy, ok := config.Global.FindCaptchaPolicy(path)
+	if !ok {
+		return nil
+	}
+
+	if policy.Action != "" && resp.Action == "" {
+		return &RejectedError{
+			Reason: RejectedAction,
+			Detail: fmt.Sprintf("token without action, expected %q", policy.Action),
+		}
+	}
+
+	if policy.Action != "" && resp.Action != policy.Action {
+		return &RejectedError{
+			Reason: RejectedAction,
+			Detail: fmt.Sprintf("token action %q, expected %q", resp.Action, policy.Action),
+		}
+	}
+
+	if checkScore && policy.MinScore > 0 && resp.Score == nil {
+		return &RejectedError{
+			Reason: RejectedScore,
+			Detail: fmt.Sprintf("token without score, expected at least %.2f", policy.MinScore),
+		}
+	}
+
+	if checkScore && policy.MinScore > 0 && *resp.Score < policy.MinScore {
+		return &RejectedError{
+			Reason:   RejectedScore,
+			Fallback: true,
+			Detail:   fmt.Sprintf("score %.2f below %.2f", *resp.Score, policy.MinScore),
+		}
+	}
+
+	return nil
+}
+
+func isRejected(err error) bool {
+	var rejected *RejectedError
+
+	return errors.As(err, &rejected)
+}
@@ -0,0 +1,133 @@
+package captcha
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+	"go.uber.org/mock/gomock"
+
+	"github.com/cash-track/gateway/config"
+	"github.com/cash-track/gateway/headers"
+	"github.com/cash-track/gateway/mocks"
+)
+
+func withCaptchaPolicies(t *testing.T, hostnames []string, policies ...config.CaptchaPolicy) {
+	t.Helper()
+
+	original := config.Global
+	t.Cleanup(func() { config.Global = original })
+
+	config.Global.CaptchaHostnames = hostnames
+	config.Global.CaptchaPolicies = policies
+}
+
+func score(v float32) *float32 {
+	return &v
+}
+
+func TestCheckPolicy(t *testing.T) {
+	withCaptchaPolicies(t, []string{"cash-track.app", "my.cash-track.app"},
+		config.CaptchaPolicy{Path: "/api/auth/login", Action: "login", MinScore: 0.5},
+		config.CaptchaPolicy{Path: "/api/auth/register", MinScore: 0.7},
+	)
+
+	for name, test := range map[string]struct {
+		path       string
+		resp       siteVerifyResponse
+		checkScore bool
+		expected   *RejectedError
+	}{
+		"Accepted": {
+			path:       "/api/auth/login",
+			resp:       siteVerifyResponse{Hostname: "my.cash-track.app", Action: "login", Score: score(0.9)},
+			checkScore: true,
+		},
+		"NoPolicyForPath": {
+			path:       "/api/auth/login/passkey/init",
+			resp:       siteVerifyResponse{Action: "anything", Score: score(0.1)},
+			checkScore: true,
+		},
+		"UnexpectedHostname": {
+			path:     "/api/auth/login",
+			resp:     siteVerifyResponse{Hostname: "phishing.example"},
+			expected: &RejectedError{Reason: RejectedHostname, Detail: `token solved on unexpected hostname "phishing.example"`},
+		},
+		"WrongAction": {
+			path:       "/api/auth/login",
+			resp:       siteVerifyResponse{Hostname: "cash-track.app", Action: "register", Score: score(0.9)},
+			checkScore: true,
+			expected:   &RejectedError{Reason: RejectedAction, Detail: `token action "register", expected "login"`},
+		},
+		"LowScore": {
+			path:       "/api/auth/register",
+			resp:       siteVerifyResponse{Score: score(0.3)},
+			checkScore: true,
+			expected:   &RejectedError{Reason: RejectedScore, Fallback: true, Detail: "score 0.30 below 0.70"},
+		},
+		"ScoreNotCheckedForProvider": {
+			path: "/api/auth/register",
+			resp: siteVerifyResponse{Score: score(0.3)},
+		},
+		"MissingAction": {
+			// reCAPTCHA v2 tokens carry neither score nor action
+			path:       "/api/auth/login",
+			resp:       siteVerifyResponse{Hostname: "cash-track.app", Score: score(0.9)},
+			checkScore: true,
+			expected:   &RejectedError{Reason: RejectedAction, Detail: `token without action, expected "login"`},
+		},
+		"MissingScore": {
+			path:       "/api/auth/register",
+			resp:       siteVerifyResponse{Hostname: "cash-track.app"},
+			checkScore: true,
+			expected:   &RejectedError{Reason: RejectedScore, Detail: "token without score, expected at least 0.70"},
+		},
+		"MissingScoreNotCheckedForProvider": {
+			path: "/api/auth/register",
+			resp: siteVerifyResponse{Hostname: "cash-track.app"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.expected, checkPolicy(test.path, &test.resp, test.checkScore))
+		})
+	}
+}
+
+func TestCheckPolicyWithoutHostnames(t *testing.T) {
+	withCaptchaPolicies(t, nil)
+
+	assert.Nil(t, checkPolicy("/api/auth/login", &siteVerifyResponse{Hostname: "localhost"}, true))
+}
+
+func TestVerifyLowScoreRejected(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	c := mocks.NewHttpRetryClientMock(ctrl)
+
+	ctx := fasthttp.RequestCtx{}
+	ctx.SetRemoteAddr(&net.TCPAddr{IP: []byte{0xA, 0x0, 0x0, 0x1}})
+	ctx.Request.SetRequestURI("/api/auth/login")
+	ctx.Request.Header.Set(headers.XCtCaptchaChallenge, "captcha_challenge_2")
+
+	c.EXPECT().WithReadTimeout(gomock.Any())
+	c.EXPECT().WithWriteTimeout(gomock.Any())
+	c.EXPECT().WithRetryAttempts(gomock.Any())
+	c.EXPECT().Do(gomock.Any(), gomock.Any()).DoAndReturn(func(req *fasthttp.Request, resp *fasthttp.Response) error {
+		resp.SetStatusCode(fasthttp.StatusOK)
+		resp.SetBodyString(`{"success":true,"score":0.1,"action":"login","hostname":"cash-track.app"}`)
+
+		return nil
+	})
+
+	withCaptchaPolicies(t, []string{"cash-track.app"}, config.CaptchaPolicy{Path: "/api/auth/login", Action: "login", MinScore: 0.5})
+	withCaptchaSecret(t, "captcha_secret_1")
+	p := NewGoogleReCaptchaProvider(c)
+	state, err := p.Verify(&ctx)
+
+	assert.False(t, state)
+
+	var rejected *RejectedError
+	assert.ErrorAs(t, err, &rejected)
+	assert.Equal(t, RejectedScore, rejected.Reason)
+	assert.True(t, rejected.Fallback)
+}
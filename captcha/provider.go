@@ -1,7 +1,32 @@
 package captcha
 
-import "github.com/valyala/fasthttp"
+import (
+	"fmt"
+
+	"github.com/valyala/fasthttp"
+
+	"github.com/cash-track/gateway/config"
+	"github.com/cash-track/gateway/http/retryhttp"
+)
 
 type Provider interface {
 	Verify(ctx *fasthttp.RequestCtx) (bool, error)
 }
+
+// NewProvider creates the provider selected by CAPTCHA_PROVIDER. All of them read the
+// challenge from the X-Ct-Captcha-Challenge header, so the web app can switch providers
+// without gateway changes.
+func NewProvider(name string, httpClient retryhttp.Client) (Provider, error) {
+	switch name {
+	case config.CaptchaProviderReCaptcha:
+		return NewGoogleReCaptchaProvider(httpClient), nil
+	case config.CaptchaProviderHCaptcha:
+		return NewHCaptchaProvider(httpClient), nil
+	case config.CaptchaProviderTurnstile:
+		return NewTurnstileProvider(httpClient), nil
+	case config.CaptchaProviderLocal:
+		return NewLocalProvider(), nil
+	default:
+		return nil, fmt.Errorf("unknown captcha provider %q", name)
+	}
+}
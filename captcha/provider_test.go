@@ -0,0 +1,39 @@
+package captcha
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+
+	"github.com/cash-track/gateway/mocks"
+)
+
+func TestNewProvider(t *testing.T) {
+	for name, expected := range map[string]Provider{
+		"recaptcha": &GoogleReCaptchaProvider{},
+		"hcaptcha":  &HCaptchaProvider{},
+		"turnstile": &TurnstileProvider{},
+		"local":     &LocalProvider{},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			c := mocks.NewHttpRetryClientMock(ctrl)
+			c.EXPECT().WithReadTimeout(gomock.Any()).AnyTimes()
+			c.EXPECT().WithWriteTimeout(gomock.Any()).AnyTimes()
+			c.EXPECT().WithRetryAttempts(gomock.Any()).AnyTimes()
+
+			p, err := NewProvider(name, c)
+
+			assert.NoError(t, err)
+			assert.IsType(t, expected, p)
+		})
+	}
+}
+
+func TestNewProviderUnknown(t *testing.T) {
+	p, err := NewProvider("captcha.example", nil)
+
+	assert.Nil(t, p)
+	assert.EqualError(t, err, `unknown captcha provider "captcha.example"`)
+}
@@ -0,0 +1,104 @@
+package captcha
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/redis/go-redis/v9"
+	"github.com/valyala/fasthttp"
+
+	"github.com/cash-track/gateway/config"
+	"github.com/cash-track/gateway/headers"
+	"github.com/cash-track/gateway/traces"
+)
+
+const (
+	replayKeyPrefix = "CT:captcha"
+	// Outlives every provider token: reCAPTCHA and hCaptcha tokens expire after 2 minutes,
+	// Turnstile ones after 5.
+	replayTtl = 10 * time.Minute
+
+	metricsNamespace     = "gateway"
+	metricsCaptchaSubsys = "captcha"
+)
+
+var captchaReplayRejectedTotal = promauto.NewCounter(prometheus.CounterOpts{
+	Namespace: metricsNamespace,
+	Subsystem: metricsCaptchaSubsys,
+	Name:      "replay_rejected_total",
+	Help:      "Captcha challenges rejected because they were already used.",
+})
+
+var captchaReplayCheckFailedOpenTotal = promauto.NewCounter(prometheus.CounterOpts{
+	Namespace: metricsNamespace,
+	Subsystem: metricsCaptchaSubsys,
+	Name:      "replay_check_failed_open_total",
+	Help:      "Captcha challenges verified without replay protection because Redis was unreachable.",
+})
+
+// ReplayGuard makes every challenge single use across the gateway instances. The hash of
+// the challenge is claimed in Redis before the wrapped provider verifies it, so the same
+// token sent concurrently to two endpoints passes at most once.
+type ReplayGuard struct {
+	provider Provider
+	client   *redis.Client
+}
+
+func NewReplayGuard(provider Provider, client *redis.Client) *ReplayGuard {
+	return &ReplayGuard{
+		provider: provider,
+		client:   client,
+	}
+}
+
+func (g *ReplayGuard) Verify(ctx *fasthttp.RequestCtx) (bool, error) {
+	challenge := ctx.Request.Header.Peek(headers.XCtCaptchaChallenge)
+
+	// nothing to claim: the provider skips or fails these on its own
+	if len(challenge) == 0 || config.Live().CaptchaSecret == "" ||
+		string(ctx.Request.Header.Method()) == fasthttp.MethodOptions {
+		return g.provider.Verify(ctx)
+	}
+
+	key := replayKey(challenge)
+
+	claimed, err := g.client.SetNX(traces.FindParentContext(ctx), key, 1, replayTtl).Result()
+	if err != nil {
+		// fail open: the provider still rejects tokens it verified before, just later
+		slog.Error("captcha replay check failed open, redis unreachable",
+			"trace_id", traces.FindTraceId(ctx), "error", err)
+		captchaReplayCheckFailedOpenTotal.Inc()
+
+		return g.provider.Verify(ctx)
+	}
+
+	if !claimed {
+		slog.Warn("captcha challenge replayed",
+			"trace_id", traces.FindTraceId(ctx), "client_ip", headers.GetClientIPFromContext(ctx))
+		captchaReplayRejectedTotal.Inc()
+
+		return false, &RejectedError{Reason: RejectedReplay, Detail: "challenge already used"}
+	}
+
+	ok, err := g.provider.Verify(ctx)
+	if err != nil && !isRejected(err) {
+		// the provider could not tell: let the client retry with the same token
+		if delErr := g.client.Del(traces.FindParentContext(ctx), key).Err(); delErr != nil {
+			slog.Warn("captcha replay claim release failed",
+				"trace_id", traces.FindTraceId(ctx), "error", delErr)
+		}
+	}
+
+	return ok, err
+}
+
+func replayKey(challenge []byte) string {
+	sum := sha256.Sum256(challenge)
+
+	return fmt.Sprintf("%s:%s", replayKeyPrefix, hex.EncodeToString(sum[:]))
+}
@@ -0,0 +1,146 @@
+package captcha
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/go-redis/redismock/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+	"go.uber.org/mock/gomock"
+
+	"github.com/cash-track/gateway/headers"
+	"github.com/cash-track/gateway/mocks"
+)
+
+// sha256("captcha_challenge_2")
+const replayTestKey = "CT:captcha:d0f5aaf4ce51c46886a3193e73379999424d82e0799c33c56cc84b666ca221f1"
+
+func newReplayTestCtx(challenge string) *fasthttp.RequestCtx {
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod(fasthttp.MethodPost)
+	ctx.Request.Header.Set(headers.XCtCaptchaChallenge, challenge)
+
+	return ctx
+}
+
+func TestReplayGuardFirstUseVerified(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	p := mocks.NewCaptchaProviderMock(ctrl)
+	client, mock := redismock.NewClientMock()
+
+	mock.ExpectSetNX(replayTestKey, 1, replayTtl).SetVal(true)
+	p.EXPECT().Verify(gomock.Any()).Return(true, nil)
+
+	withCaptchaSecret(t, "captcha_secret_1")
+	state, err := NewReplayGuard(p, client).Verify(newReplayTestCtx("captcha_challenge_2"))
+
+	assert.True(t, state)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReplayGuardReuseRejected(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	p := mocks.NewCaptchaProviderMock(ctrl)
+	client, mock := redismock.NewClientMock()
+
+	// no Verify expected: a replayed token never reaches the provider
+	mock.ExpectSetNX(replayTestKey, 1, replayTtl).SetVal(false)
+
+	withCaptchaSecret(t, "captcha_secret_1")
+	state, err := NewReplayGuard(p, client).Verify(newReplayTestCtx("captcha_challenge_2"))
+
+	assert.False(t, state)
+
+	var rejected *RejectedError
+	assert.ErrorAs(t, err, &rejected)
+	assert.Equal(t, &RejectedError{Reason: RejectedReplay, Detail: "challenge already used"}, rejected)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReplayGuardProviderErrorReleasesClaim(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	p := mocks.NewCaptchaProviderMock(ctrl)
+	client, mock := redismock.NewClientMock()
+
+	key := replayTestKey
+	mock.ExpectSetNX(key, 1, replayTtl).SetVal(true)
+	p.EXPECT().Verify(gomock.Any()).Return(false, errors.New("captcha api down"))
+	mock.ExpectDel(key).SetVal(1)
+
+	withCaptchaSecret(t, "captcha_secret_1")
+	state, err := NewReplayGuard(p, client).Verify(newReplayTestCtx("captcha_challenge_2"))
+
+	assert.False(t, state)
+	assert.EqualError(t, err, "captcha api down")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReplayGuardUnsuccessfulKeepsClaim(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	p := mocks.NewCaptchaProviderMock(ctrl)
+	client, mock := redismock.NewClientMock()
+
+	mock.ExpectSetNX(replayTestKey, 1, replayTtl).SetVal(true)
+	p.EXPECT().Verify(gomock.Any()).Return(false, &RejectedError{Reason: RejectedScore, Fallback: true})
+
+	withCaptchaSecret(t, "captcha_secret_1")
+	state, err := NewReplayGuard(p, client).Verify(newReplayTestCtx("captcha_challenge_2"))
+
+	assert.False(t, state)
+	assert.Error(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReplayGuardRedisDownFailsOpen(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	p := mocks.NewCaptchaProviderMock(ctrl)
+	client, mock := redismock.NewClientMock()
+
+	mock.ExpectSetNX(replayTestKey, 1, replayTtl).SetErr(errors.New("connection refused"))
+	p.EXPECT().Verify(gomock.Any()).Return(true, nil)
+
+	withCaptchaSecret(t, "captcha_secret_1")
+	state, err := NewReplayGuard(p, client).Verify(newReplayTestCtx("captcha_challenge_2"))
+
+	assert.True(t, state)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReplayGuardSkipped(t *testing.T) {
+	for name, test := range map[string]struct {
+		challenge string
+		secret    string
+		method    string
+	}{
+		"EmptyChallenge": {secret: "captcha_secret_1"},
+		"EmptySecret":    {challenge: "captcha_challenge_2"},
+		"Options":        {challenge: "captcha_challenge_2", secret: "captcha_secret_1", method: fasthttp.MethodOptions},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			p := mocks.NewCaptchaProviderMock(ctrl)
+			client, mock := redismock.NewClientMock()
+
+			p.EXPECT().Verify(gomock.Any()).Return(true, nil)
+
+			ctx := newReplayTestCtx(test.challenge)
+			if test.method != "" {
+				ctx.Request.Header.SetMethod(test.method)
+			}
+
+			withCaptchaSecret(t, test.secret)
+			state, err := NewReplayGuard(p, client).Verify(ctx)
+
+			assert.True(t, state)
+			assert.NoError(t, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestReplayKey(t *testing.T) {
+	assert.Equal(t, replayTestKey, replayKey([]byte("captcha_challenge_2")))
+}
@@ -0,0 +1,185 @@
+package captcha
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/cash-track/gateway/config"
+	"github.com/cash-track/gateway/headers"
+	"github.com/cash-track/gateway/http/retryhttp"
+	"github.com/cash-track/gateway/traces"
+	"github.com/cash-track/gateway/traces/semconv"
+)
+
+// siteVerifyProvider verifies the X-Ct-Captcha-Challenge token with a siteverify API.
+// reCAPTCHA, hCaptcha and Turnstile share the same form request and JSON response, and
+// all of them read the secret from config.Live, so a rotated CAPTCHA_SECRET applies on
+// reload.
+type siteVerifyProvider struct {
+	name      string
+	verifyUrl string
+	client    retryhttp.Client
+	// Only a reCAPTCHA score is a likelihood of a human, hCaptcha's is a risk score.
+	checkScore bool
+}
+
+// siteVerifyResponse holds the fields of the three APIs; the ones a provider does not
+// send are left empty.
+type siteVerifyResponse struct {
+	Success     bool     `json:"success"`
+	ChallengeTS string   `json:"challenge_ts,omitempty"`
+	Hostname    string   `json:"hostname,omitempty"`
+	Score       *float32 `json:"score,omitempty"`
+	Action      string   `json:"action,omitempty"`
+	ErrorCodes  []string `json:"error-codes,omitempty"`
+}
+
+func (r *siteVerifyResponse) GetOpenTelemetryAttributes() []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.Bool(semconv.CashTrackCaptchaSuccessKey, r.Success),
+		attribute.String(semconv.CashTrackCaptchaChallengeTSKey, r.ChallengeTS),
+		attribute.String(semconv.CashTrackCaptchaHostnameKey, r.Hostname),
+		attribute.Float64(semconv.CashTrackCaptchaScoreKey, float64(r.score())),
+		attribute.String(semconv.CashTrackCaptchaActionKey, r.Action),
+		attribute.String(semconv.CashTrackCaptchaErrorCodesKey, strings.Join(r.ErrorCodes, ",")),
+	}
+}
+
+func (r *siteVerifyResponse) score() float32 {
+	if r.Score == nil {
+		return 0
+	}
+
+	return *r.Score
+}
+
+func newSiteVerifyProvider(
+	name, verifyUrl string,
+	httpClient retryhttp.Client,
+	readTimeout, writeTimeout time.Duration,
+	retryAttempts uint,
+) siteVerifyProvider {
+	httpClient.WithReadTimeout(readTimeout)
+	httpClient.WithWriteTimeout(writeTimeout)
+	httpClient.WithRetryAttempts(retryAttempts)
+
+	return siteVerifyProvider{
+		name:      name,
+		verifyUrl: verifyUrl,
+		client:    httpClient,
+	}
+}
+
+func (p *siteVerifyProvider) Verify(ctx *fasthttp.RequestCtx) (bool, error) {
+	clientIp := headers.GetClientIPFromContext(ctx)
+
+	_, span := traces.GetTracer().Start(
+		traces.FindParentContext(ctx),
+		fmt.Sprintf("%s %s %s", p.name, ctx.Request.Header.Method(), ctx.URI().PathOriginal()),
+		trace.WithAttributes(
+			traces.MergeAttributes(
+				traces.Attributes(attribute.String("http.request.real_ip", clientIp)),
+				traces.RequestAttributes(&ctx.Request),
+			)...,
+		),
+	)
+	defer span.End()
+
+	secret := config.Live().CaptchaSecret
+	if secret == "" {
+		span.SetStatus(codes.Ok, "disabled")
+		slog.Info("captcha secret empty, skipping verify", "provider", p.name, "client_ip", clientIp)
+
+		return true, nil
+	}
+
+	if string(ctx.Request.Header.Method()) == fasthttp.MethodOptions {
+		span.SetStatus(codes.Ok, "unsupported method")
+
+		return true, nil
+	}
+
+	challenge := ctx.Request.Header.Peek(headers.XCtCaptchaChallenge)
+	if challenge == nil || string(challenge) == "" {
+		span.SetStatus(codes.Error, "empty challenge")
+		slog.Warn("captcha challenge empty", "provider", p.name, "client_ip", clientIp)
+
+		return false, nil
+	}
+
+	req := fasthttp.AcquireRequest()
+	resp := fasthttp.AcquireResponse()
+	defer func() {
+		fasthttp.ReleaseRequest(req)
+		fasthttp.ReleaseResponse(resp)
+	}()
+
+	p.buildReq(req, secret, challenge, clientIp)
+
+	span.SetAttributes(traces.RequestAttributes(req)...)
+
+	if err := p.client.Do(req, resp); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "request error")
+
+		return false, fmt.Errorf("captcha verify request error: %w", err)
+	}
+
+	span.SetAttributes(traces.ResponseAttributes(resp)...)
+
+	verifyResp := siteVerifyResponse{}
+	if err := json.Unmarshal(resp.Body(), &verifyResp); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "read body error")
+
+		return false, fmt.Errorf("captcha verify response unexpected: %w", err)
+	}
+
+	span.SetAttributes(traces.AttributesGetter(&verifyResp)...)
+
+	if !verifyResp.Success {
+		slog.Warn("captcha verify unsuccessful",
+			"provider", p.name,
+			"client_ip", clientIp,
+			"score", verifyResp.score(),
+			"error", strings.Join(verifyResp.ErrorCodes, ", "),
+		)
+		span.SetStatus(codes.Error, "validation failed")
+
+		return false, nil
+	}
+
+	if rejected := checkPolicy(string(ctx.Path()), &verifyResp, p.checkScore); rejected != nil {
+		slog.Warn("captcha verify rejected by policy",
+			"provider", p.name,
+			"client_ip", clientIp,
+			"reason", rejected.Reason,
+			"error", rejected.Detail,
+		)
+		span.SetStatus(codes.Error, "rejected by "+rejected.Reason)
+
+		return false, rejected
+	}
+
+	slog.Info("captcha verify ok", "provider", p.name, "client_ip", clientIp)
+	span.SetStatus(codes.Ok, "ok")
+
+	return true, nil
+}
+
+func (p *siteVerifyProvider) buildReq(req *fasthttp.Request, secret string, challenge []byte, clientIp string) {
+	req.SetRequestURI(p.verifyUrl)
+	req.Header.SetMethod(fasthttp.MethodPost)
+	req.Header.SetContentTypeBytes(headers.ContentTypeForm)
+	req.PostArgs().Set("secret", secret)
+	req.PostArgs().Set("remoteip", clientIp)
+	req.PostArgs().SetBytesV("response", challenge)
+}
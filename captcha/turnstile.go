@@ -0,0 +1,32 @@
+package captcha
+
+import (
+	"time"
+
+	"github.com/cash-track/gateway/http/retryhttp"
+)
+
+const (
+	turnstileVerifyUrl     = "https://challenges.cloudflare.com/turnstile/v0/siteverify"
+	turnstileReadTimeout   = 500 * time.Millisecond
+	turnstileWriteTimeout  = time.Second
+	turnstileRetryAttempts = uint(2)
+)
+
+// TurnstileProvider verifies Cloudflare Turnstile tokens.
+type TurnstileProvider struct {
+	siteVerifyProvider
+}
+
+func NewTurnstileProvider(httpClient retryhttp.Client) *TurnstileProvider {
+	return &TurnstileProvider{
+		siteVerifyProvider: newSiteVerifyProvider(
+			"cloudflare turnstile",
+			turnstileVerifyUrl,
+			httpClient,
+			turnstileReadTimeout,
+			turnstileWriteTimeout,
+			turnstileRetryAttempts,
+		),
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/cash-track/gateway/config"
+)
+
+const secretMask = "***"
+
+// configCheck loads the configuration the way the server does, prints the effective
+// values with secrets masked and reports every problem found. It returns the exit code.
+func configCheck(stdout, stderr io.Writer) int {
+	c := config.Config{}
+	problems := make([]string, 0)
+
+	for _, err := range c.Load() {
+		problems = append(problems, err.Error())
+	}
+
+	if err := c.LoadRoutes(); err != nil {
+		problems = append(problems, fmt.Sprintf("ROUTES_FILE: %v", err))
+	}
+
+	live, err := config.NewReloader(c).Check()
+	if err != nil {
+		problems = append(problems, fmt.Sprintf("CONFIG_FILE: %v", err))
+	} else {
+		c.Reloadable = live
+	}
+
+	printConfig(stdout, c)
+
+	if len(problems) == 0 {
+		_, _ = fmt.Fprintln(stderr, "configuration OK")
+
+		return 0
+	}
+
+	for _, problem := range problems {
+		_, _ = fmt.Fprintln(stderr, "error:", problem)
+	}
+
+	return 1
+}
+
+func printConfig(w io.Writer, c config.Config) {
+	origins := make([]string, 0, len(c.CorsAllowedOrigins))
+	for origin := range c.CorsAllowedOrigins {
+		origins = append(origins, origin)
+	}
+	sort.Strings(origins)
+
+	proxies := make([]string, 0, len(c.TrustedProxies))
+	for _, proxy := range c.TrustedProxies {
+		proxies = append(proxies, proxy.String())
+	}
+
+	for _, kv := range [][2]string{
+		{"GATEWAY_ADDRESS", c.Address},
+		{"GATEWAY_COMPRESS", fmt.Sprint(c.Compress)},
+		{"ADMIN_ADDRESS", c.AdminAddress},
+		{"ADMIN_TOKEN", maskSecret(c.AdminToken)},
+		{"GATEWAY_URL", c.GatewayUrl},
+		{"API_URL", c.ApiUrl},
+		{"WEBSITE_URL", c.WebsiteUrl},
+		{"WEBAPP_URL", c.WebAppUrl},
+		{"ROUTES_FILE", c.RoutesFile},
+		{"CONFIG_FILE", c.ConfigFile},
+		{"HTTPS_ENABLED", fmt.Sprint(c.HttpsEnabled)},
+		{"HTTPS_KEY", c.HttpsKey},
+		{"HTTPS_CRT", c.HttpsCrt},
+		{"COOKIE_DOMAIN", c.CookieDomain},
+		{"COOKIE_SECURE", fmt.Sprint(c.CookieSecure)},
+		{"CORS_ALLOWED_ORIGINS", strings.Join(origins, ",")},
+		{"TRUSTED_PROXIES", strings.Join(proxies, ",")},
+		{"CAPTCHA_PROVIDER", c.CaptchaProvider},
+		{"CAPTCHA_SECRET", maskSecret(c.CaptchaSecret)},
+		{"GATEWAY_SECRET", maskSecret(c.GatewaySecret)},
+		{"CSRF_ENABLED", fmt.Sprint(c.CsrfEnabled)},
+		{"REDIS_CONNECTION", c.RedisConnection},
+		{"RATE_LIMIT_ENABLED", fmt.Sprint(c.RateLimitEnabled)},
+		{"CACHE_STORE", c.CacheStore},
+		{"IDEMPOTENCY_TTL", c.IdempotencyTTL.String()},
+		{"SESSION_ENABLED", fmt.Sprint(c.SessionEnabled)},
+		{"REFRESH_AHEAD_WINDOW", c.RefreshAheadWindow.String()},
+		{"MAX_REQUEST_BODY_SIZE", c.MaxRequestBodySize.String()},
+		{"ALLOWED_CONTENT_TYPES", strings.Join(c.AllowedContentTypes, ",")},
+		{"JWT_HMAC_SECRET", maskSecret(c.JwtHmacSecret)},
+		{"JWT_PUBLIC_KEY_FILE", c.JwtPublicKeyFile},
+		{"JWT_JWKS_URL", c.JwtJwksUrl},
+		{"JWT_JWKS_REFRESH", c.JwtJwksRefresh.String()},
+		{"DEBUG_HTTP", fmt.Sprint(c.DebugHttp)},
+		{"TRACE_CAPTURE_BODY", fmt.Sprint(c.TraceCaptureBody)},
+		{"SHUTDOWN_DRAIN_PERIOD", c.ShutdownDrainPeriod.String()},
+		{"SHUTDOWN_TIMEOUT", c.ShutdownTimeout.String()},
+	} {
+		_, _ = fmt.Fprintf(w, "%s=%s\n", kv[0], kv[1])
+	}
+
+	for _, u := range c.Upstreams {
+		_, _ = fmt.Fprintf(w, "upstream %s: %s/* -> %s%s/*\n", u.Name, u.Prefix, u.Url, u.Rewrite)
+
+		for _, g := range u.BreakerGroups {
+			_, _ = fmt.Fprintf(w, "breaker %s/%s: %s\n", u.Name, g.Name, g.Prefix)
+		}
+	}
+
+	for _, r := range c.RateLimits {
+		methods := "*"
+		if len(r.Methods) > 0 {
+			methods = strings.Join(r.Methods, ",")
+		}
+
+		_, _ = fmt.Fprintf(w, "rate limit %s: %s %s -> %d per %s\n", r.Name, methods, r.Prefix, r.Limit, r.Window)
+	}
+
+	for _, b := range c.BodyLimits {
+		methods := "*"
+		if len(b.Methods) > 0 {
+			methods = strings.Join(b.Methods, ",")
+		}
+
+		size, contentTypes := "default", "default"
+		if b.MaxSize > 0 {
+			size = b.MaxSize.String()
+		}
+		if len(b.ContentTypes) > 0 {
+			contentTypes = strings.Join(b.ContentTypes, ",")
+		}
+
+		_, _ = fmt.Fprintf(w, "body limit %s: %s %s -> %s of %s\n", b.Name, methods, b.Prefix, size, contentTypes)
+	}
+
+	for _, st := range c.Streams {
+		methods := "*"
+		if len(st.Methods) > 0 {
+			methods = strings.Join(st.Methods, ",")
+		}
+
+		_, _ = fmt.Fprintf(w, "stream %s: %s %s\n", st.Name, methods, st.Prefix)
+	}
+
+	for _, r := range c.Realtime {
+		_, _ = fmt.Fprintf(w, "realtime %s: %s -> idle %s\n", r.Name, r.Prefix, r.IdleTimeout)
+	}
+
+	for _, r := range c.CacheRules {
+		scope := "shared"
+		if r.PerUser {
+			scope = "per user"
+		}
+
+		_, _ = fmt.Fprintf(w, "cache %s: GET %s -> %s %s\n", r.Name, r.Prefix, r.TTL, scope)
+	}
+
+	for _, p := range c.AccessPolicies {
+		methods := "*"
+		if len(p.Methods) > 0 {
+			methods = strings.Join(p.Methods, ",")
+		}
+
+		access := p.Access
+		if len(p.Roles) > 0 {
+			access += " with role " + strings.Join(p.Roles, "|")
+		}
+
+		_, _ = fmt.Fprintf(w, "policy %s: %s %s -> %s\n", p.Name, methods, p.Path, access)
+	}
+}
+
+// maskSecret hides the value but keeps whether it is set visible.
+func maskSecret(v string) string {
+	if v == "" {
+		return ""
+	}
+
+	return secretMask
+}
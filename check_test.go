@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func setCheckEnv(t *testing.T, env map[string]string) {
+	for _, key := range []string{
+		"API_URL", "GATEWAY_URL", "WEBSITE_URL", "WEBAPP_URL", "HTTPS_ENABLED", "HTTPS_KEY", "HTTPS_CRT",
+		"CORS_ALLOWED_ORIGINS", "CAPTCHA_SECRET", "GATEWAY_SECRET", "ROUTES_FILE", "CONFIG_FILE",
+		"JWT_HMAC_SECRET", "JWT_PUBLIC_KEY_FILE", "JWT_JWKS_URL", "MAX_REQUEST_BODY_SIZE", "ALLOWED_CONTENT_TYPES",
+		"CACHE_STORE", "IDEMPOTENCY_TTL", "ADMIN_ADDRESS", "ADMIN_TOKEN", "TRUSTED_PROXIES", "SHUTDOWN_TIMEOUT",
+	} {
+		t.Setenv(key, env[key])
+	}
+}
+
+func TestConfigCheckValidMasksSecrets(t *testing.T) {
+	setCheckEnv(t, map[string]string{
+		"API_URL":              "http://api:80",
+		"GATEWAY_URL":          "https://gateway.cash-track.app",
+		"CORS_ALLOWED_ORIGINS": "https://cash-track.app",
+		"CAPTCHA_SECRET":       "captcha-secret-value",
+		"GATEWAY_SECRET":       "gateway-secret-value",
+		"ADMIN_ADDRESS":        "127.0.0.1:8083",
+		"ADMIN_TOKEN":          "admin-secret-value",
+	})
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+
+	assert.Equal(t, 0, configCheck(stdout, stderr))
+	assert.Contains(t, stdout.String(), "API_URL=http://api:80\n")
+	assert.Contains(t, stdout.String(), "COOKIE_DOMAIN=gateway.cash-track.app\n")
+	assert.Contains(t, stdout.String(), "CORS_ALLOWED_ORIGINS=https://cash-track.app\n")
+	assert.Contains(t, stdout.String(), "CAPTCHA_SECRET=***\n")
+	assert.Contains(t, stdout.String(), "GATEWAY_SECRET=***\n")
+	assert.Contains(t, stdout.String(), "ADMIN_ADDRESS=127.0.0.1:8083\n")
+	assert.Contains(t, stdout.String(), "ADMIN_TOKEN=***\n")
+	assert.Contains(t, stdout.String(), "upstream API: /api/* -> http://api:80/v1/*\n")
+	assert.Contains(t, stdout.String(), "rate limit auth: POST /api/auth -> 20 per 1m0s\n")
+	assert.Contains(t, stdout.String(), "MAX_REQUEST_BODY_SIZE=4MB\n")
+	assert.Contains(t, stdout.String(), "ALLOWED_CONTENT_TYPES=application/json\n")
+	assert.Contains(t, stdout.String(), "IDEMPOTENCY_TTL=24h0m0s\n")
+	assert.NotContains(t, stdout.String(), "secret-value")
+	assert.Equal(t, "configuration OK\n", stderr.String())
+}
+
+func TestConfigCheckPrintsAccessPolicies(t *testing.T) {
+	routesFile := filepath.Join(t.TempDir(), "routes.yaml")
+	assert.NoError(t, os.WriteFile(routesFile, []byte(`
+policies:
+  - name: admin
+    path: /api/admin/{path:*}
+    roles: [admin, support]
+  - name: register
+    path: /api/auth/register
+    methods: [POST]
+    access: anonymous
+`), 0o600))
+
+	setCheckEnv(t, map[string]string{
+		"API_URL":         "http://api:80",
+		"ROUTES_FILE":     routesFile,
+		"JWT_HMAC_SECRET": "jwt-secret-value",
+	})
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+
+	assert.Equal(t, 0, configCheck(stdout, stderr))
+	assert.Contains(t, stdout.String(), "JWT_HMAC_SECRET=***\n")
+	assert.Contains(t, stdout.String(), "policy admin: * /api/admin/{path:*} -> authenticated with role admin|support\n")
+	assert.Contains(t, stdout.String(), "policy register: POST /api/auth/register -> anonymous\n")
+	assert.NotContains(t, stdout.String(), "secret-value")
+}
+
+func TestConfigCheckPrintsBodyLimits(t *testing.T) {
+	routesFile := filepath.Join(t.TempDir(), "routes.yaml")
+	assert.NoError(t, os.WriteFile(routesFile, []byte(`
+bodyLimits:
+  - name: attachments
+    prefix: /api/attachments
+    methods: [POST]
+    maxSize: 20MB
+    contentTypes: [multipart/form-data, image/*]
+  - name: auth
+    prefix: /api/auth
+    maxSize: 16KB
+streams:
+  - name: attachments
+    prefix: /api/attachments
+    methods: [GET, POST]
+realtime:
+  - name: balance
+    prefix: /api/realtime/balance
+upstreams:
+  - name: API
+    breakerGroups:
+      - name: exports
+        prefix: /api/exports
+cache:
+  - name: currencies
+    prefix: /api/currencies
+    ttl: 1h
+`), 0o600))
+
+	setCheckEnv(t, map[string]string{
+		"API_URL":     "http://api:80",
+		"ROUTES_FILE": routesFile,
+	})
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+
+	assert.Equal(t, 0, configCheck(stdout, stderr))
+	assert.Contains(t, stdout.String(), "body limit attachments: POST /api/attachments -> 20MB of multipart/form-data,image/*\n")
+	assert.Contains(t, stdout.String(), "body limit auth: * /api/auth -> 16KB of default\n")
+	assert.Contains(t, stdout.String(), "stream attachments: GET,POST /api/attachments\n")
+	assert.Contains(t, stdout.String(), "realtime balance: /api/realtime/balance -> idle 1m0s\n")
+	assert.Contains(t, stdout.String(), "cache currencies: GET /api/currencies -> 1h0m0s shared\n")
+	assert.Contains(t, stdout.String(), "breaker API/exports: /api/exports\n")
+}
+
+func TestConfigCheckReportsEveryProblem(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "gateway.yaml")
+	assert.NoError(t, os.WriteFile(configFile, []byte("gatewaySecret: from-file\ntrustedProxies: [nope]\n"), 0o600))
+
+	setCheckEnv(t, map[string]string{
+		"API_URL":              "",
+		"HTTPS_ENABLED":        "true",
+		"CORS_ALLOWED_ORIGINS": "https://cash-track.app/",
+		"ROUTES_FILE":          filepath.Join(dir, "missing.yaml"),
+		"CONFIG_FILE":          configFile,
+		"TRUSTED_PROXIES":      "10.0.0.0/8,not-a-cidr",
+		"SHUTDOWN_TIMEOUT":     "soon",
+	})
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+
+	assert.Equal(t, 1, configCheck(stdout, stderr))
+	assert.Contains(t, stdout.String(), "HTTPS_ENABLED=true\n")
+	assert.Contains(t, stderr.String(), "error: API_URL: is required\n")
+	assert.Contains(t, stderr.String(), "error: HTTPS_KEY: is required when HTTPS_ENABLED=true\n")
+	assert.Contains(t, stderr.String(), "error: HTTPS_CRT: is required when HTTPS_ENABLED=true\n")
+	assert.Contains(t, stderr.String(), `error: CORS_ALLOWED_ORIGINS: cors allowed origin "https://cash-track.app/" must be scheme://host[:port]`)
+	assert.Contains(t, stderr.String(), "error: ROUTES_FILE: read routes file:")
+	assert.Contains(t, stderr.String(), `error: CONFIG_FILE: trusted proxy "nope"`)
+	assert.Contains(t, stderr.String(), `error: TRUSTED_PROXIES: trusted proxy "not-a-cidr"`)
+	assert.Contains(t, stderr.String(), `error: SHUTDOWN_TIMEOUT: "soon" must be a non-negative duration, e.g. 30s`)
+	assert.NotContains(t, stderr.String(), "configuration OK")
+}
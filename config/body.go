@@ -0,0 +1,213 @@
+package config
+
+import (
+	"fmt"
+	"mime"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultMaxRequestBodySize  = 4 * MB
+	defaultAllowedContentTypes = "application/json"
+)
+
+// Units of ByteSize, powers of 1024.
+const (
+	KB ByteSize = 1 << (10 * (iota + 1))
+	MB
+	GB
+)
+
+// ByteSize is a size in bytes, written as a plain number or with a KB, MB or GB suffix.
+type ByteSize int
+
+func (s *ByteSize) UnmarshalYAML(unmarshal func(any) error) error {
+	var v string
+	if err := unmarshal(&v); err != nil {
+		return err
+	}
+
+	size, err := parseByteSize(v)
+	if err != nil {
+		return err
+	}
+
+	*s = size
+
+	return nil
+}
+
+func (s ByteSize) String() string {
+	for _, unit := range []struct {
+		size ByteSize
+		name string
+	}{{GB, "GB"}, {MB, "MB"}, {KB, "KB"}} {
+		if s >= unit.size && s%unit.size == 0 {
+			return strconv.Itoa(int(s/unit.size)) + unit.name
+		}
+	}
+
+	return strconv.Itoa(int(s))
+}
+
+func parseByteSize(v string) (ByteSize, error) {
+	number, unit := strings.ToUpper(strings.TrimSpace(v)), ByteSize(1)
+
+	for suffix, size := range map[string]ByteSize{"KB": KB, "MB": MB, "GB": GB} {
+		if trimmed, ok := strings.CutSuffix(number, suffix); ok {
+			number, unit = strings.TrimSpace(trimmed), size
+
+			break
+		}
+	}
+
+	n, err := strconv.Atoi(number)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("size %q must be a positive number of bytes, KB, MB or GB", v)
+	}
+
+	return ByteSize(n) * unit, nil
+}
+
+// BodyLimit overrides MAX_REQUEST_BODY_SIZE and ALLOWED_CONTENT_TYPES for the requests
+// whose path starts with Prefix and, when set, whose method is one of Methods. A zero
+// MaxSize or empty ContentTypes keep the global setting, 4MB and application/json unless
+// configured otherwise. The rule with the longest matching prefix applies.
+type BodyLimit struct {
+	Name         string   `yaml:"name"`
+	Prefix       string   `yaml:"prefix"`
+	Methods      []string `yaml:"methods"`
+	MaxSize      ByteSize `yaml:"maxSize"`
+	ContentTypes []string `yaml:"contentTypes"`
+}
+
+// Matches reports whether the rule applies to the request method and path.
+func (b BodyLimit) Matches(method, path string) bool {
+	return RateLimit{Prefix: b.Prefix, Methods: b.Methods}.Matches(method, path)
+}
+
+// FindBodyLimit returns the limit of the request: the rule with the longest prefix matching
+// it with the unset fields taken from the global settings, or the global settings alone
+// under the name "default".
+func (c *Config) FindBodyLimit(method, path string) BodyLimit {
+	found := BodyLimit{Name: "default"}
+
+	for _, b := range c.BodyLimits {
+		if b.Matches(method, path) && (found.Prefix == "" || len(b.Prefix) > len(found.Prefix)) {
+			found = b
+		}
+	}
+
+	if found.MaxSize == 0 {
+		found.MaxSize = c.MaxRequestBodySize
+	}
+
+	if len(found.ContentTypes) == 0 {
+		found.ContentTypes = c.AllowedContentTypes
+	}
+
+	return found
+}
+
+// AllowsContentType reports whether the media type of the Content-Type header value is
+// one of ContentTypes, where "type/*" allows every subtype. No ContentTypes allow any.
+func (b BodyLimit) AllowsContentType(contentType string) bool {
+	if len(b.ContentTypes) == 0 {
+		return true
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	for _, allowed := range b.ContentTypes {
+		allowed = strings.ToLower(allowed)
+
+		if allowed == mediaType {
+			return true
+		}
+
+		if prefix, ok := strings.CutSuffix(allowed, "/*"); ok && strings.HasPrefix(mediaType, prefix+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func getContentTypes(errs *ValidationErrors, val string) []string {
+	list := make([]string, 0)
+
+	for _, v := range strings.Split(val, ",") {
+		v = strings.ToLower(strings.TrimSpace(v))
+		if v == "" {
+			continue
+		}
+
+		if err := validateContentType(v); err != nil {
+			errs.add("ALLOWED_CONTENT_TYPES", "%v", err)
+
+			continue
+		}
+
+		list = append(list, v)
+	}
+
+	return list
+}
+
+func validateContentType(v string) error {
+	if _, _, err := mime.ParseMediaType(v); err != nil || !strings.Contains(v, "/") || strings.Contains(v, ";") {
+		return fmt.Errorf("content type %q must be a media type like application/json or image/*", v)
+	}
+
+	return nil
+}
+
+func buildBodyLimits(declared []BodyLimit) ([]BodyLimit, error) {
+	names := map[string]bool{}
+
+	for i := range declared {
+		b := &declared[i]
+
+		if err := b.validate(); err != nil {
+			return nil, err
+		}
+
+		for j, contentType := range b.ContentTypes {
+			b.ContentTypes[j] = strings.ToLower(strings.TrimSpace(contentType))
+		}
+
+		if names[b.Name] {
+			return nil, fmt.Errorf("duplicate body limit name %q", b.Name)
+		}
+
+		names[b.Name] = true
+	}
+
+	return declared, nil
+}
+
+func (b BodyLimit) validate() error {
+	if b.Name == "" {
+		return fmt.Errorf("body limit with prefix %q has no name", b.Prefix)
+	}
+
+	if !strings.HasPrefix(b.Prefix, "/") {
+		return fmt.Errorf("body limit %q: prefix %q must start with a slash", b.Name, b.Prefix)
+	}
+
+	if b.MaxSize < 0 {
+		return fmt.Errorf("body limit %q: maxSize must be positive", b.Name)
+	}
+
+	for _, contentType := range b.ContentTypes {
+		if err := validateContentType(strings.ToLower(strings.TrimSpace(contentType))); err != nil {
+			return fmt.Errorf("body limit %q: %w", b.Name, err)
+		}
+	}
+
+	return nil
+}
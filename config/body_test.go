@@ -0,0 +1,155 @@
+package config
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseByteSize(t *testing.T) {
+	for value, expected := range map[string]ByteSize{
+		"1024":   1024,
+		"16KB":   16 * KB,
+		"4mb":    4 * MB,
+		" 1 GB ": GB,
+	} {
+		size, err := parseByteSize(value)
+		assert.NoError(t, err, value)
+		assert.Equal(t, expected, size, value)
+	}
+
+	for _, value := range []string{"", "0", "-1", "4TB", "MB", "1.5MB"} {
+		_, err := parseByteSize(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestByteSizeString(t *testing.T) {
+	assert.Equal(t, "4MB", (4 * MB).String())
+	assert.Equal(t, "1536KB", (1536 * KB).String())
+	assert.Equal(t, "1000", ByteSize(1000).String())
+}
+
+func TestFindBodyLimit(t *testing.T) {
+	config := &Config{
+		MaxRequestBodySize:  MB,
+		AllowedContentTypes: []string{"application/json"},
+		BodyLimits: []BodyLimit{
+			{Name: "api", Prefix: "/api", MaxSize: 2 * MB},
+			{Name: "attachments", Prefix: "/api/attachments", Methods: []string{"POST"}, ContentTypes: []string{"multipart/form-data"}},
+		},
+	}
+
+	limit := config.FindBodyLimit("POST", "/api/attachments/1")
+	assert.Equal(t, "attachments", limit.Name)
+	assert.Equal(t, MB, limit.MaxSize)
+	assert.Equal(t, []string{"multipart/form-data"}, limit.ContentTypes)
+
+	limit = config.FindBodyLimit("PUT", "/api/attachments/1")
+	assert.Equal(t, "api", limit.Name)
+	assert.Equal(t, 2*MB, limit.MaxSize)
+	assert.Equal(t, []string{"application/json"}, limit.ContentTypes)
+
+	limit = config.FindBodyLimit("POST", "/csrf")
+	assert.Equal(t, "default", limit.Name)
+	assert.Equal(t, MB, limit.MaxSize)
+}
+
+func TestBodyLimitAllowsContentType(t *testing.T) {
+	limit := BodyLimit{ContentTypes: []string{"application/json", "image/*"}}
+
+	assert.True(t, limit.AllowsContentType("application/json"))
+	assert.True(t, limit.AllowsContentType("Application/JSON; charset=utf-8"))
+	assert.True(t, limit.AllowsContentType("image/png"))
+	assert.False(t, limit.AllowsContentType("multipart/form-data; boundary=xyz"))
+	assert.False(t, limit.AllowsContentType("imagery/png"))
+	assert.False(t, limit.AllowsContentType("not a type"))
+
+	assert.True(t, BodyLimit{}.AllowsContentType("text/plain"))
+}
+
+func TestConfigLoadBodyLimits(t *testing.T) {
+	t.Setenv("API_URL", "http://api:80")
+
+	t.Setenv("MAX_REQUEST_BODY_SIZE", "")
+	t.Setenv("ALLOWED_CONTENT_TYPES", "")
+	config := &Config{}
+	assert.Empty(t, config.Load())
+	assert.Equal(t, 4*MB, config.MaxRequestBodySize)
+	assert.Equal(t, []string{"application/json"}, config.AllowedContentTypes)
+
+	t.Setenv("MAX_REQUEST_BODY_SIZE", "512KB")
+	t.Setenv("ALLOWED_CONTENT_TYPES", "application/json, Text/Plain")
+	config = &Config{}
+	assert.Empty(t, config.Load())
+	assert.Equal(t, 512*KB, config.MaxRequestBodySize)
+	assert.Equal(t, []string{"application/json", "text/plain"}, config.AllowedContentTypes)
+
+	t.Setenv("MAX_REQUEST_BODY_SIZE", "big")
+	t.Setenv("ALLOWED_CONTENT_TYPES", "json")
+	config = &Config{}
+	errs := config.Load()
+	assert.Len(t, errs, 2)
+	assert.Equal(t, 4*MB, config.MaxRequestBodySize)
+}
+
+func TestLoadRoutesBodyLimits(t *testing.T) {
+	apiUri, _ := url.Parse("http://api:80")
+
+	config := &Config{ApiUrl: "http://api:80", ApiURI: apiUri}
+	config.RoutesFile = writeTempFile(t, "routes.yaml", `
+bodyLimits:
+  - name: attachments
+    prefix: /api/attachments
+    methods: [POST]
+    maxSize: 20MB
+    contentTypes: [Multipart/Form-Data]
+  - name: small
+    prefix: /api/auth
+    maxSize: 2048
+`)
+
+	assert.NoError(t, config.LoadRoutes())
+	assert.Equal(t, []BodyLimit{
+		{Name: "attachments", Prefix: "/api/attachments", Methods: []string{"POST"}, MaxSize: 20 * MB, ContentTypes: []string{"multipart/form-data"}},
+		{Name: "small", Prefix: "/api/auth", MaxSize: 2048},
+	}, config.BodyLimits)
+}
+
+func TestLoadRoutesInvalidBodyLimits(t *testing.T) {
+	apiUri, _ := url.Parse("http://api:80")
+
+	for name, test := range map[string]struct {
+		content string
+		err     string
+	}{
+		"NoName": {
+			content: "bodyLimits: [{prefix: /api, maxSize: 1MB}]",
+			err:     `body limit with prefix "/api" has no name`,
+		},
+		"Prefix": {
+			content: "bodyLimits: [{name: api, prefix: api}]",
+			err:     `body limit "api": prefix "api" must start with a slash`,
+		},
+		"MaxSize": {
+			content: "bodyLimits: [{name: api, prefix: /api, maxSize: 1TB}]",
+			err:     `size "1TB" must be a positive number of bytes, KB, MB or GB`,
+		},
+		"ContentType": {
+			content: "bodyLimits: [{name: api, prefix: /api, contentTypes: [json]}]",
+			err:     `body limit "api": content type "json" must be a media type like application/json or image/*`,
+		},
+		"DuplicateName": {
+			content: "bodyLimits: [{name: api, prefix: /api}, {name: api, prefix: /csrf}]",
+			err:     `duplicate body limit name "api"`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			config := &Config{ApiUrl: "http://api:80", ApiURI: apiUri}
+			config.RoutesFile = writeTempFile(t, "routes.yaml", test.content)
+
+			assert.ErrorContains(t, config.LoadRoutes(), test.err)
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// BreakerSettings tune the circuit breaker of an upstream. It opens once more than
+// FailureThreshold calls failed in a row or, with a FailureRatio, once that share of at
+// least MinRequests calls counted over Interval failed. It stays open for Timeout, then
+// lets MaxRequests calls through to probe the upstream. Calls fail on transport errors,
+// timeouts included, and on 5xx answers with CountServerErrors. Zero fields fall back to
+// the forwarder defaults.
+type BreakerSettings struct {
+	MaxRequests       uint32        `yaml:"maxRequests"`
+	Interval          time.Duration `yaml:"interval"`
+	Timeout           time.Duration `yaml:"timeout"`
+	FailureThreshold  uint32        `yaml:"failureThreshold"`
+	FailureRatio      float64       `yaml:"failureRatio"`
+	MinRequests       uint32        `yaml:"minRequests"`
+	CountServerErrors bool          `yaml:"countServerErrors"`
+}
+
+// BreakerGroup gives the requests of an upstream whose path starts with Prefix a breaker
+// of their own, so a failing endpoint does not cut off the others. Its settings default
+// to the ones of the upstream.
+type BreakerGroup struct {
+	Name    string          `yaml:"name"`
+	Prefix  string          `yaml:"prefix"`
+	Breaker BreakerSettings `yaml:"breaker"`
+}
+
+// Matches reports whether the group applies to the request path.
+func (g BreakerGroup) Matches(path string) bool {
+	return RateLimit{Prefix: g.Prefix}.Matches("", path)
+}
+
+// FindBreakerGroup returns the group with the longest prefix matching the request path.
+func (u Upstream) FindBreakerGroup(path string) (BreakerGroup, bool) {
+	var (
+		found BreakerGroup
+		ok    bool
+	)
+
+	for _, g := range u.BreakerGroups {
+		if g.Matches(path) && (!ok || len(g.Prefix) > len(found.Prefix)) {
+			found, ok = g, true
+		}
+	}
+
+	return found, ok
+}
+
+func (u *Upstream) buildBreakerGroups() error {
+	if err := u.Breaker.validate(); err != nil {
+		return fmt.Errorf("upstream %q: breaker: %w", u.Name, err)
+	}
+
+	names := map[string]bool{}
+
+	for i := range u.BreakerGroups {
+		g := &u.BreakerGroups[i]
+
+		if g.Name == "" {
+			return fmt.Errorf("upstream %q: breaker group with prefix %q has no name", u.Name, g.Prefix)
+		}
+
+		if !(RateLimit{Prefix: u.Prefix}).Matches("", g.Prefix) {
+			return fmt.Errorf("upstream %q: breaker group %q: prefix %q is not under %q", u.Name, g.Name, g.Prefix, u.Prefix)
+		}
+
+		if err := g.Breaker.validate(); err != nil {
+			return fmt.Errorf("upstream %q: breaker group %q: %w", u.Name, g.Name, err)
+		}
+
+		if names[g.Name] {
+			return fmt.Errorf("upstream %q: duplicate breaker group name %q", u.Name, g.Name)
+		}
+
+		names[g.Name] = true
+		g.Breaker = mergeBreaker(u.Breaker, g.Breaker)
+	}
+
+	return nil
+}
+
+func (b BreakerSettings) validate() error {
+	if b.FailureRatio < 0 || b.FailureRatio > 1 {
+		return fmt.Errorf("failureRatio %v must be between 0 and 1", b.FailureRatio)
+	}
+
+	if b.Interval < 0 || b.Timeout < 0 {
+		return fmt.Errorf("interval and timeout must be positive")
+	}
+
+	return nil
+}
+
+// mergeBreaker applies the non-zero fields of override on top of base.
+func mergeBreaker(base, override BreakerSettings) BreakerSettings {
+	if override.MaxRequests != 0 {
+		base.MaxRequests = override.MaxRequests
+	}
+
+	if override.Interval != 0 {
+		base.Interval = override.Interval
+	}
+
+	if override.Timeout != 0 {
+		base.Timeout = override.Timeout
+	}
+
+	if override.FailureThreshold != 0 {
+		base.FailureThreshold = override.FailureThreshold
+	}
+
+	if override.FailureRatio != 0 {
+		base.FailureRatio = override.FailureRatio
+	}
+
+	if override.MinRequests != 0 {
+		base.MinRequests = override.MinRequests
+	}
+
+	if override.CountServerErrors {
+		base.CountServerErrors = true
+	}
+
+	return base
+}
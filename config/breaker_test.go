@@ -0,0 +1,95 @@
+package config
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadRoutesBreakerGroups(t *testing.T) {
+	apiUri, _ := url.Parse("http://api:80")
+	config := &Config{ApiUrl: "http://api:80", ApiURI: apiUri}
+	config.RoutesFile = writeTempFile(t, "routes.yaml", `
+breaker:
+  timeout: 10s
+  countServerErrors: true
+upstreams:
+  - name: API
+    breaker:
+      failureRatio: 0.5
+      minRequests: 10
+    breakerGroups:
+      - name: exports
+        prefix: /api/exports
+        breaker:
+          interval: 30s
+      - name: auth
+        prefix: /api/auth
+  - name: reports
+    prefix: /reports
+    url: http://reports:8080
+`)
+
+	assert.NoError(t, config.LoadRoutes())
+
+	api := config.Upstreams[0]
+	apiBreaker := BreakerSettings{Timeout: 10 * time.Second, FailureRatio: 0.5, MinRequests: 10, CountServerErrors: true}
+	assert.Equal(t, apiBreaker, api.Breaker)
+	assert.Equal(t, []BreakerGroup{
+		{Name: "exports", Prefix: "/api/exports", Breaker: BreakerSettings{
+			Interval: 30 * time.Second, Timeout: 10 * time.Second, FailureRatio: 0.5, MinRequests: 10, CountServerErrors: true,
+		}},
+		{Name: "auth", Prefix: "/api/auth", Breaker: apiBreaker},
+	}, api.BreakerGroups)
+	assert.Equal(t, BreakerSettings{Timeout: 10 * time.Second, CountServerErrors: true}, config.Upstreams[1].Breaker)
+
+	group, ok := api.FindBreakerGroup("/api/exports/42")
+	assert.True(t, ok)
+	assert.Equal(t, "exports", group.Name)
+
+	_, ok = api.FindBreakerGroup("/api/exportsv2")
+	assert.False(t, ok)
+}
+
+func TestLoadRoutesInvalidBreakers(t *testing.T) {
+	apiUri, _ := url.Parse("http://api:80")
+
+	for name, test := range map[string]struct {
+		content string
+		err     string
+	}{
+		"Ratio": {
+			content: "breaker: {failureRatio: 1.5}",
+			err:     `upstream "API": breaker: failureRatio 1.5 must be between 0 and 1`,
+		},
+		"Timeout": {
+			content: "upstreams: [{name: API, breaker: {timeout: -1s}}]",
+			err:     `upstream "API": breaker: interval and timeout must be positive`,
+		},
+		"GroupNoName": {
+			content: "upstreams: [{name: API, breakerGroups: [{prefix: /api/exports}]}]",
+			err:     `upstream "API": breaker group with prefix "/api/exports" has no name`,
+		},
+		"GroupPrefix": {
+			content: "upstreams: [{name: API, breakerGroups: [{name: exports, prefix: /exports}]}]",
+			err:     `upstream "API": breaker group "exports": prefix "/exports" is not under "/api"`,
+		},
+		"GroupRatio": {
+			content: "upstreams: [{name: API, breakerGroups: [{name: exports, prefix: /api/exports, breaker: {failureRatio: -1}}]}]",
+			err:     `upstream "API": breaker group "exports": failureRatio -1 must be between 0 and 1`,
+		},
+		"GroupDuplicate": {
+			content: "upstreams: [{name: API, breakerGroups: [{name: exports, prefix: /api/a}, {name: exports, prefix: /api/b}]}]",
+			err:     `upstream "API": duplicate breaker group name "exports"`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			config := &Config{ApiUrl: "http://api:80", ApiURI: apiUri}
+			config.RoutesFile = writeTempFile(t, "routes.yaml", test.content)
+
+			assert.ErrorContains(t, config.LoadRoutes(), test.err)
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Values of CACHE_STORE.
+const (
+	CacheStoreMemory = "memory"
+	CacheStoreRedis  = "redis"
+)
+
+const defaultCacheTTL = time.Minute
+
+// CacheRule caches the 200 answers to the GET requests whose path starts with Prefix, per
+// path, query and Accept-Language, and per user when PerUser. The Cache-Control of the
+// answer decides whether it is stored and for how long, TTL when it names no max-age. The
+// rule with the longest matching prefix applies.
+type CacheRule struct {
+	Name    string        `yaml:"name"`
+	Prefix  string        `yaml:"prefix"`
+	TTL     time.Duration `yaml:"ttl"`
+	PerUser bool          `yaml:"perUser"`
+}
+
+// Matches reports whether the rule applies to the request path.
+func (r CacheRule) Matches(path string) bool {
+	return RateLimit{Prefix: r.Prefix}.Matches("", path)
+}
+
+// FindCacheRule returns the rule with the longest prefix matching the request path.
+func (c *Config) FindCacheRule(path string) (CacheRule, bool) {
+	var (
+		found CacheRule
+		ok    bool
+	)
+
+	for _, r := range c.CacheRules {
+		if r.Matches(path) && (!ok || len(r.Prefix) > len(found.Prefix)) {
+			found, ok = r, true
+		}
+	}
+
+	return found, ok
+}
+
+func (c *Config) buildCacheRules(declared []CacheRule) ([]CacheRule, error) {
+	names := map[string]bool{}
+
+	for i := range declared {
+		r := &declared[i]
+
+		if err := r.validate(); err != nil {
+			return nil, err
+		}
+
+		if r.PerUser && !c.JwtVerifyEnabled() {
+			return nil, fmt.Errorf("cache %q: perUser needs verified access tokens, set JWT_HMAC_SECRET, JWT_PUBLIC_KEY_FILE or JWT_JWKS_URL", r.Name)
+		}
+
+		if r.TTL == 0 {
+			r.TTL = defaultCacheTTL
+		}
+
+		if names[r.Name] {
+			return nil, fmt.Errorf("duplicate cache name %q", r.Name)
+		}
+
+		names[r.Name] = true
+	}
+
+	return declared, nil
+}
+
+func (r CacheRule) validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("cache with prefix %q has no name", r.Prefix)
+	}
+
+	if !strings.HasPrefix(r.Prefix, "/") {
+		return fmt.Errorf("cache %q: prefix %q must start with a slash", r.Name, r.Prefix)
+	}
+
+	if r.TTL < 0 {
+		return fmt.Errorf("cache %q: ttl must be positive", r.Name)
+	}
+
+	return nil
+}
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindCacheRule(t *testing.T) {
+	config := &Config{
+		CacheRules: []CacheRule{
+			{Name: "reference", Prefix: "/api/reference"},
+			{Name: "currencies", Prefix: "/api/reference/currencies"},
+		},
+	}
+
+	rule, ok := config.FindCacheRule("/api/reference/currencies/USD")
+	assert.True(t, ok)
+	assert.Equal(t, "currencies", rule.Name)
+
+	rule, ok = config.FindCacheRule("/api/reference/categories")
+	assert.True(t, ok)
+	assert.Equal(t, "reference", rule.Name)
+
+	_, ok = config.FindCacheRule("/api/wallets")
+	assert.False(t, ok)
+}
+
+func TestLoadRoutesCacheRules(t *testing.T) {
+	apiUri, _ := url.Parse("http://api:80")
+
+	config := &Config{ApiUrl: "http://api:80", ApiURI: apiUri, JwtHmacSecret: "secret"}
+	config.RoutesFile = writeTempFile(t, "routes.yaml", `
+cache:
+  - name: currencies
+    prefix: /api/currencies
+    ttl: 1h
+  - name: categories
+    prefix: /api/categories
+    perUser: true
+`)
+
+	assert.NoError(t, config.LoadRoutes())
+	assert.Equal(t, []CacheRule{
+		{Name: "currencies", Prefix: "/api/currencies", TTL: time.Hour},
+		{Name: "categories", Prefix: "/api/categories", TTL: time.Minute, PerUser: true},
+	}, config.CacheRules)
+}
+
+func TestLoadRoutesInvalidCacheRules(t *testing.T) {
+	apiUri, _ := url.Parse("http://api:80")
+
+	for name, test := range map[string]struct {
+		content string
+		err     string
+	}{
+		"NoName": {
+			content: "cache: [{prefix: /api/currencies}]",
+			err:     `cache with prefix "/api/currencies" has no name`,
+		},
+		"Prefix": {
+			content: "cache: [{name: currencies, prefix: api}]",
+			err:     `cache "currencies": prefix "api" must start with a slash`,
+		},
+		"TTL": {
+			content: "cache: [{name: currencies, prefix: /api/currencies, ttl: -1s}]",
+			err:     `cache "currencies": ttl must be positive`,
+		},
+		"PerUserUnverified": {
+			content: "cache: [{name: categories, prefix: /api/categories, perUser: true}]",
+			err:     `cache "categories": perUser needs verified access tokens, set JWT_HMAC_SECRET, JWT_PUBLIC_KEY_FILE or JWT_JWKS_URL`,
+		},
+		"DuplicateName": {
+			content: "cache: [{name: currencies, prefix: /api/a}, {name: currencies, prefix: /api/b}]",
+			err:     `duplicate cache name "currencies"`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			config := &Config{ApiUrl: "http://api:80", ApiURI: apiUri}
+			config.RoutesFile = writeTempFile(t, "routes.yaml", test.content)
+
+			assert.ErrorContains(t, config.LoadRoutes(), test.err)
+		})
+	}
+}
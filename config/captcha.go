@@ -0,0 +1,58 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CaptchaPolicy tightens captcha verification on one endpoint. Action must equal the
+// action the token was minted for, and MinScore is the lowest reCAPTCHA v3 score
+// accepted. Both checks are skipped when zero. A token whose provider response carries
+// no action or score (reCAPTCHA v2) is rejected, so hCaptcha, which reports neither, and
+// Turnstile, which reports no score, refuse the policies they cannot satisfy.
+type CaptchaPolicy struct {
+	Path     string  `yaml:"path"`
+	Action   string  `yaml:"action"`
+	MinScore float32 `yaml:"minScore"`
+}
+
+// FindCaptchaPolicy returns the policy of the exact request path.
+func (c *Config) FindCaptchaPolicy(path string) (CaptchaPolicy, bool) {
+	for _, p := range c.CaptchaPolicies {
+		if p.Path == path {
+			return p, true
+		}
+	}
+
+	return CaptchaPolicy{}, false
+}
+
+func (c *Config) buildCaptchaPolicies(declared []CaptchaPolicy) ([]CaptchaPolicy, error) {
+	paths := map[string]bool{}
+
+	for _, p := range declared {
+		if !strings.HasPrefix(p.Path, "/") {
+			return nil, fmt.Errorf("captcha policy path %q must start with a slash", p.Path)
+		}
+
+		if p.MinScore < 0 || p.MinScore > 1 {
+			return nil, fmt.Errorf("captcha policy %q: minScore %v must be between 0 and 1", p.Path, p.MinScore)
+		}
+
+		if p.Action != "" && c.CaptchaProvider == CaptchaProviderHCaptcha {
+			return nil, fmt.Errorf("captcha policy %q: action needs a provider reporting it, %s does not", p.Path, c.CaptchaProvider)
+		}
+
+		if p.MinScore > 0 && (c.CaptchaProvider == CaptchaProviderHCaptcha || c.CaptchaProvider == CaptchaProviderTurnstile) {
+			return nil, fmt.Errorf("captcha policy %q: minScore needs a provider reporting a score, %s does not", p.Path, c.CaptchaProvider)
+		}
+
+		if paths[p.Path] {
+			return nil, fmt.Errorf("duplicate captcha policy path %q", p.Path)
+		}
+
+		paths[p.Path] = true
+	}
+
+	return declared, nil
+}
@@ -0,0 +1,85 @@
+package config
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadRoutesCaptchaPolicies(t *testing.T) {
+	apiUri, _ := url.Parse("http://api:80")
+	config := &Config{ApiUrl: "http://api:80", ApiURI: apiUri}
+	config.RoutesFile = writeTempFile(t, "routes.yaml", `
+captcha:
+  - path: /api/auth/login
+    action: login
+    minScore: 0.5
+  - path: /api/auth/login/passkey/init
+    action: passkey_init
+`)
+
+	assert.NoError(t, config.LoadRoutes())
+	assert.Equal(t, []CaptchaPolicy{
+		{Path: "/api/auth/login", Action: "login", MinScore: 0.5},
+		{Path: "/api/auth/login/passkey/init", Action: "passkey_init"},
+	}, config.CaptchaPolicies)
+
+	policy, ok := config.FindCaptchaPolicy("/api/auth/login")
+	assert.True(t, ok)
+	assert.Equal(t, "login", policy.Action)
+
+	_, ok = config.FindCaptchaPolicy("/api/auth/register")
+	assert.False(t, ok)
+}
+
+func TestLoadRoutesInvalidCaptchaPolicies(t *testing.T) {
+	apiUri, _ := url.Parse("http://api:80")
+
+	for name, test := range map[string]struct {
+		provider string
+		content  string
+		err      string
+	}{
+		"RelativePath": {
+			content: "captcha: [{path: api/auth/login}]",
+			err:     `captcha policy path "api/auth/login" must start with a slash`,
+		},
+		"ScoreOutOfRange": {
+			content: "captcha: [{path: /api/auth/login, minScore: 1.5}]",
+			err:     `captcha policy "/api/auth/login": minScore 1.5 must be between 0 and 1`,
+		},
+		"DuplicatePath": {
+			content: "captcha: [{path: /api/auth/login}, {path: /api/auth/login, action: login}]",
+			err:     `duplicate captcha policy path "/api/auth/login"`,
+		},
+		"ActionWithoutProviderSupport": {
+			provider: CaptchaProviderHCaptcha,
+			content:  "captcha: [{path: /api/auth/login, action: login}]",
+			err:      `captcha policy "/api/auth/login": action needs a provider reporting it, hcaptcha does not`,
+		},
+		"ScoreWithoutProviderSupport": {
+			provider: CaptchaProviderTurnstile,
+			content:  "captcha: [{path: /api/auth/login, action: login, minScore: 0.5}]",
+			err:      `captcha policy "/api/auth/login": minScore needs a provider reporting a score, turnstile does not`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			config := &Config{ApiUrl: "http://api:80", ApiURI: apiUri, CaptchaProvider: test.provider}
+			config.RoutesFile = writeTempFile(t, "routes.yaml", test.content)
+
+			assert.ErrorContains(t, config.LoadRoutes(), test.err)
+		})
+	}
+}
+
+func TestConfigLoadCaptchaHostnames(t *testing.T) {
+	t.Setenv("API_URL", "http://api:80")
+	t.Setenv("WEBSITE_URL", "https://cash-track.app")
+	t.Setenv("WEBAPP_URL", "https://my.cash-track.app:3000")
+
+	config := &Config{}
+	config.Load()
+
+	assert.Equal(t, []string{"cash-track.app", "my.cash-track.app"}, config.CaptchaHostnames)
+}
@@ -2,21 +2,41 @@ package config
 
 import (
 	"fmt"
-	"log/slog"
 	"net/netip"
 	"net/url"
 	"os"
 	"strings"
+	"time"
 )
 
 // RFC1918 + loopback: covers Traefik on a Docker bridge network out of the box.
 const defaultTrustedProxies = "10.0.0.0/8,172.16.0.0/12,192.168.0.0/16,127.0.0.0/8,::1/128"
 
+// Values of CAPTCHA_PROVIDER.
+const (
+	CaptchaProviderReCaptcha = "recaptcha"
+	CaptchaProviderHCaptcha  = "hcaptcha"
+	CaptchaProviderTurnstile = "turnstile"
+	CaptchaProviderLocal     = "local"
+)
+
+const (
+	defaultRefreshAheadWindow  = 30 * time.Second
+	defaultJwtJwksRefresh      = 5 * time.Minute
+	defaultShutdownDrainPeriod = 5 * time.Second
+	defaultShutdownTimeout     = 30 * time.Second
+	defaultIdempotencyTTL      = 24 * time.Hour
+)
+
 type Config struct {
-	Address       string
-	Compress      bool
-	CaptchaSecret string
-	GatewaySecret string
+	Reloadable
+
+	Address  string
+	Compress bool
+
+	// Listener of the admin endpoints, disabled when empty, and the bearer token they require.
+	AdminAddress string
+	AdminToken   string
 
 	GatewayUrl string
 	ApiUrl     string
@@ -24,6 +44,10 @@ type Config struct {
 	WebsiteUrl string
 	WebAppUrl  string
 
+	// Route table source and the upstreams built from it by LoadRoutes.
+	RoutesFile string
+	Upstreams  []Upstream
+
 	HttpsEnabled bool
 	HttpsKey     string
 	HttpsCrt     string
@@ -31,10 +55,32 @@ type Config struct {
 	CookieDomain string
 	CookieSecure bool
 
-	CorsAllowedOrigins map[string]bool
-
-	// Peers allowed to set Cf-Connecting-IP (e.g. Traefik).
-	TrustedProxies []netip.Prefix
+	// Keep the token pair in Redis and give the browser an opaque session ID only.
+	SessionEnabled bool
+	// Refresh an access token expiring within this window before forwarding; 0 waits
+	// for the API to answer 401.
+	RefreshAheadWindow time.Duration
+
+	// Key source access tokens are verified against at the edge, one at most: the HMAC
+	// secret shared with the API, a PEM public key file, or a JWKS URL or file refetched
+	// every JwtJwksRefresh. None set leaves verification to the API.
+	JwtHmacSecret    string
+	JwtPublicKeyFile string
+	JwtJwksUrl       string
+	JwtJwksRefresh   time.Duration
+
+	// Who may reach which routes, from ROUTES_FILE via LoadRoutes, see AccessPolicy.
+	AccessPolicies []AccessPolicy
+
+	// One of the CaptchaProvider* values; the secret is Reloadable.CaptchaSecret.
+	CaptchaProvider string
+	// Per endpoint checks from ROUTES_FILE, see CaptchaPolicy.
+	CaptchaPolicies []CaptchaPolicy
+	// Hosts of WEBSITE_URL and WEBAPP_URL, the only ones captcha tokens may be solved on.
+	CaptchaHostnames []string
+
+	// Optional YAML file overriding the Reloadable settings, see Reloader.
+	ConfigFile string
 
 	DebugHttp        bool
 	TraceCaptureBody bool
@@ -42,46 +88,153 @@ type Config struct {
 	CsrfEnabled     bool
 	RedisConnection string
 
+	// Largest request body and the Content-Types a body may have, overridden per route by
+	// BodyLimits from ROUTES_FILE via LoadRoutes.
+	MaxRequestBodySize  ByteSize
+	AllowedContentTypes []string
+	BodyLimits          []BodyLimit
+	// Routes whose bodies are forwarded as they are read, from ROUTES_FILE via LoadRoutes.
+	Streams []Stream
+	// Routes proxying WebSocket and Server-Sent Events, from ROUTES_FILE via LoadRoutes.
+	Realtime []Realtime
+
+	// Where the answers of CacheRules from ROUTES_FILE are kept: CacheStoreMemory or
+	// CacheStoreRedis.
+	CacheStore string
+	CacheRules []CacheRule
+
+	// Per client IP limits, see RateLimit. Rules come from ROUTES_FILE via LoadRoutes.
+	RateLimitEnabled bool
+	RateLimits       []RateLimit
+
+	// How long the answer to a request with an Idempotency-Key is replayed to its duplicates.
+	IdempotencyTTL time.Duration
+
+	// How long /ready reports failing before the server stops accepting connections,
+	// so the load balancer stops routing to this instance first.
+	ShutdownDrainPeriod time.Duration
+	// Upper bound for in-flight requests to finish and dependencies to close.
+	ShutdownTimeout time.Duration
+
 	GitTag string
 	GitSha string
 }
 
 var Global Config
 
-func (c *Config) Load() {
+// Load reads the configuration from the environment and reports every invalid setting.
+// Fields keep a best-effort value even when invalid, so the result can still be printed.
+func (c *Config) Load() ValidationErrors {
+	var errs ValidationErrors
+
 	c.Address = getEnv("GATEWAY_ADDRESS", ":80")
 	c.Compress = getEnv("GATEWAY_COMPRESS", "true") == "true"
+	c.AdminAddress = getEnv("ADMIN_ADDRESS", "")
+	c.AdminToken = getEnv("ADMIN_TOKEN", "")
+	if c.AdminAddress != "" && c.AdminToken == "" {
+		errs.add("ADMIN_TOKEN", "is required when ADMIN_ADDRESS is set")
+	}
 	c.DebugHttp = getEnv("DEBUG_HTTP", "") == "true"
 	c.TraceCaptureBody = getEnv("TRACE_CAPTURE_BODY", "true") == "true"
 	c.CaptchaSecret = getEnv("CAPTCHA_SECRET", "")
+	c.CaptchaProvider = getEnv("CAPTCHA_PROVIDER", CaptchaProviderReCaptcha)
+
+	switch c.CaptchaProvider {
+	case CaptchaProviderReCaptcha, CaptchaProviderHCaptcha, CaptchaProviderTurnstile, CaptchaProviderLocal:
+	default:
+		errs.add("CAPTCHA_PROVIDER", "%q must be one of %s, %s, %s, %s", c.CaptchaProvider,
+			CaptchaProviderReCaptcha, CaptchaProviderHCaptcha, CaptchaProviderTurnstile, CaptchaProviderLocal)
+	}
 	c.GatewaySecret = getEnv("GATEWAY_SECRET", "")
 
 	c.ApiUrl = getEnv("API_URL", "")
-	if u, err := url.Parse(c.ApiUrl); err != nil {
-		panic(fmt.Sprintf("Unexpected API_URL: %s", c.ApiURI))
-	} else {
-		c.ApiURI = u
-	}
+	c.ApiURI = parseAbsoluteUrl(&errs, "API_URL", c.ApiUrl, true)
 
 	c.GatewayUrl = getEnv("GATEWAY_URL", "")
+	gatewayUri := parseAbsoluteUrl(&errs, "GATEWAY_URL", c.GatewayUrl, false)
 	c.WebsiteUrl = getEnv("WEBSITE_URL", "")
+	websiteUri := parseAbsoluteUrl(&errs, "WEBSITE_URL", c.WebsiteUrl, false)
 	c.WebAppUrl = getEnv("WEBAPP_URL", "")
+	webAppUri := parseAbsoluteUrl(&errs, "WEBAPP_URL", c.WebAppUrl, false)
+	c.RoutesFile = getEnv("ROUTES_FILE", "")
+	c.ConfigFile = getEnv("CONFIG_FILE", "")
 
 	c.HttpsEnabled = getEnv("HTTPS_ENABLED", "") == "true"
 	c.HttpsKey = getEnv("HTTPS_KEY", "")
 	c.HttpsCrt = getEnv("HTTPS_CRT", "")
 
-	c.CookieDomain = getCookieDomain(c.GatewayUrl)
-	c.CookieSecure = getCookieSecure(c.GatewayUrl)
+	if c.HttpsEnabled && c.HttpsKey == "" {
+		errs.add("HTTPS_KEY", "is required when HTTPS_ENABLED=true")
+	}
+
+	if c.HttpsEnabled && c.HttpsCrt == "" {
+		errs.add("HTTPS_CRT", "is required when HTTPS_ENABLED=true")
+	}
+
+	c.CaptchaHostnames = make([]string, 0, 2)
+	for _, u := range []*url.URL{websiteUri, webAppUri} {
+		if host := u.Hostname(); host != "" {
+			c.CaptchaHostnames = append(c.CaptchaHostnames, host)
+		}
+	}
+
+	c.CookieDomain = gatewayUri.Hostname()
+	c.CookieSecure = gatewayUri.Scheme == "https"
 
-	c.CorsAllowedOrigins = getCorsAllowedOrigins(getEnv("CORS_ALLOWED_ORIGINS", ""))
-	c.TrustedProxies = getTrustedProxies(getEnv("TRUSTED_PROXIES", defaultTrustedProxies))
+	c.CorsAllowedOrigins = getCorsAllowedOrigins(&errs, getEnv("CORS_ALLOWED_ORIGINS", ""))
+	c.TrustedProxies = getTrustedProxies(&errs, getEnv("TRUSTED_PROXIES", defaultTrustedProxies))
 
 	c.CsrfEnabled = getEnv("CSRF_ENABLED", "") == "true"
 	c.RedisConnection = getEnv("REDIS_CONNECTION", "localhost:6379")
+	c.RateLimitEnabled = getEnv("RATE_LIMIT_ENABLED", "true") == "true"
+	c.CacheStore = getEnv("CACHE_STORE", CacheStoreMemory)
+	if c.CacheStore != CacheStoreMemory && c.CacheStore != CacheStoreRedis {
+		errs.add("CACHE_STORE", "%q must be one of %s, %s", c.CacheStore, CacheStoreMemory, CacheStoreRedis)
+	}
+	c.IdempotencyTTL = getDuration(&errs, "IDEMPOTENCY_TTL", defaultIdempotencyTTL)
+	if c.IdempotencyTTL <= 0 {
+		errs.add("IDEMPOTENCY_TTL", "must be greater than 0")
+	}
+	c.SessionEnabled = getEnv("SESSION_ENABLED", "") == "true"
+	c.RefreshAheadWindow = getDuration(&errs, "REFRESH_AHEAD_WINDOW", defaultRefreshAheadWindow)
+
+	c.MaxRequestBodySize = defaultMaxRequestBodySize
+	if v := getEnv("MAX_REQUEST_BODY_SIZE", ""); v != "" {
+		size, err := parseByteSize(v)
+		if err != nil {
+			errs.add("MAX_REQUEST_BODY_SIZE", "%v", err)
+		} else {
+			c.MaxRequestBodySize = size
+		}
+	}
+	c.AllowedContentTypes = getContentTypes(&errs, getEnv("ALLOWED_CONTENT_TYPES", defaultAllowedContentTypes))
+
+	c.JwtHmacSecret = getEnv("JWT_HMAC_SECRET", "")
+	c.JwtPublicKeyFile = getEnv("JWT_PUBLIC_KEY_FILE", "")
+	c.JwtJwksUrl = getEnv("JWT_JWKS_URL", "")
+	c.JwtJwksRefresh = getDuration(&errs, "JWT_JWKS_REFRESH", defaultJwtJwksRefresh)
+
+	if sources := countSet(c.JwtHmacSecret, c.JwtPublicKeyFile, c.JwtJwksUrl); sources > 1 {
+		errs.add("JWT_HMAC_SECRET", "only one of JWT_HMAC_SECRET, JWT_PUBLIC_KEY_FILE, JWT_JWKS_URL can be set")
+	}
+
+	if c.JwtJwksUrl != "" && c.JwtJwksRefresh == 0 {
+		errs.add("JWT_JWKS_REFRESH", "must be greater than 0")
+	}
+
+	c.ShutdownDrainPeriod = getDuration(&errs, "SHUTDOWN_DRAIN_PERIOD", defaultShutdownDrainPeriod)
+	c.ShutdownTimeout = getDuration(&errs, "SHUTDOWN_TIMEOUT", defaultShutdownTimeout)
 
 	c.GitTag = getEnv("GIT_TAG", "")
 	c.GitSha = getEnv("GIT_COMMIT", "")
+
+	return errs
+}
+
+// JwtVerifyEnabled reports whether access tokens are verified at the edge, which is what
+// makes their claims trustworthy.
+func (c *Config) JwtVerifyEnabled() bool {
+	return c.JwtHmacSecret != "" || c.JwtPublicKeyFile != "" || c.JwtJwksUrl != ""
 }
 
 func getEnv(key, def string) string {
@@ -93,29 +246,53 @@ func getEnv(key, def string) string {
 	return v
 }
 
-func getCookieDomain(url string) string {
-	domain := strings.ReplaceAll(url, "http://", "")
-	domain = strings.ReplaceAll(domain, "https://", "")
-	domain = strings.ReplaceAll(domain, "/", "")
-
-	if strings.Contains(domain, ":") {
-		list := strings.Split(domain, ":")
-		if len(list) > 0 {
-			domain = list[0]
+func countSet(values ...string) int {
+	n := 0
+	for _, v := range values {
+		if v != "" {
+			n++
 		}
 	}
 
-	return domain
+	return n
 }
 
-func getCookieSecure(url string) bool {
-	return strings.Contains(url, "https")
+// getDuration parses a Go duration string (e.g. "5s"). An invalid or negative value is
+// reported and the default is kept.
+func getDuration(errs *ValidationErrors, key string, def time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		errs.add(key, "%q must be a non-negative duration, e.g. 30s", v)
+
+		return def
+	}
+
+	return d
 }
 
-func getCorsAllowedOrigins(val string) map[string]bool {
+// getCorsAllowedOrigins parses a comma-separated list of origins. Surrounding whitespace
+// and empty entries are dropped; an entry that can never equal an Origin header (a path,
+// a trailing slash) is reported.
+func getCorsAllowedOrigins(errs *ValidationErrors, val string) map[string]bool {
 	list := make(map[string]bool)
 
 	for _, v := range strings.Split(val, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+
+		if err := validateOrigin(v); err != nil {
+			errs.add("CORS_ALLOWED_ORIGINS", "%v", err)
+
+			continue
+		}
+
 		list[strings.ToLower(v)] = true
 	}
 
@@ -123,8 +300,8 @@ func getCorsAllowedOrigins(val string) map[string]bool {
 }
 
 // getTrustedProxies parses a comma-separated list of CIDRs and/or bare IPs.
-// Bare IPs are normalised to a /32 or /128 prefix. Invalid entries are logged and skipped.
-func getTrustedProxies(val string) []netip.Prefix {
+// Bare IPs are normalised to a /32 or /128 prefix. Invalid entries are reported.
+func getTrustedProxies(errs *ValidationErrors, val string) []netip.Prefix {
 	list := make([]netip.Prefix, 0)
 
 	for _, v := range strings.Split(val, ",") {
@@ -135,7 +312,7 @@ func getTrustedProxies(val string) []netip.Prefix {
 
 		prefix, err := parseTrustedProxy(v)
 		if err != nil {
-			slog.Warn("skipping invalid TRUSTED_PROXIES entry", "value", v, "error", err)
+			errs.add("TRUSTED_PROXIES", "trusted proxy %q: %v", v, err)
 
 			continue
 		}
@@ -4,6 +4,7 @@ import (
 	"net/netip"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -15,17 +16,20 @@ func TestConfigLoad(t *testing.T) {
 	_ = os.Setenv("TRACE_CAPTURE_BODY", "false")
 	_ = os.Setenv("API_URL", "http://api:80")
 	_ = os.Setenv("GATEWAY_URL", "https://gateway.dev.cash-track.app:8081")
-	_ = os.Setenv("HTTPS_ENABLED", "true")
+	t.Setenv("HTTPS_ENABLED", "true")
 	_ = os.Setenv("CORS_ALLOWED_ORIGINS", "https://My.dev.cash-track.app:3001,https://Dev.cash-track.app:3000")
 	_ = os.Setenv("CSRF_ENABLED", "true")
 	_ = os.Setenv("REDIS_CONNECTION", "redis:1234")
 	_ = os.Setenv("GIT_TAG", "v1.2.3")
 	_ = os.Setenv("GIT_COMMIT", "abc123def456")
+	t.Setenv("HTTPS_KEY", "/certs/gateway.key")
+	t.Setenv("HTTPS_CRT", "/certs/gateway.crt")
 	t.Setenv("TRUSTED_PROXIES", "")
 
 	config := &Config{}
-	config.Load()
+	errs := config.Load()
 
+	assert.Empty(t, errs)
 	assert.Equal(t, ":80", config.Address)
 	assert.Equal(t, true, config.Compress)
 	assert.Equal(t, false, config.DebugHttp)
@@ -64,6 +68,100 @@ func TestConfigLoad(t *testing.T) {
 
 	assert.Equal(t, "v1.2.3", config.GitTag)
 	assert.Equal(t, "abc123def456", config.GitSha)
+
+	assert.Equal(t, "recaptcha", config.CaptchaProvider)
+}
+
+func TestConfigLoadSessionEnabled(t *testing.T) {
+	t.Setenv("API_URL", "http://api:80")
+
+	t.Setenv("SESSION_ENABLED", "")
+	config := &Config{}
+	assert.Empty(t, config.Load())
+	assert.False(t, config.SessionEnabled)
+
+	t.Setenv("SESSION_ENABLED", "true")
+	config = &Config{}
+	assert.Empty(t, config.Load())
+	assert.True(t, config.SessionEnabled)
+}
+
+func TestConfigLoadRefreshAheadWindow(t *testing.T) {
+	t.Setenv("API_URL", "http://api:80")
+
+	t.Setenv("REFRESH_AHEAD_WINDOW", "")
+	config := &Config{}
+	assert.Empty(t, config.Load())
+	assert.Equal(t, 30*time.Second, config.RefreshAheadWindow)
+
+	t.Setenv("REFRESH_AHEAD_WINDOW", "0s")
+	config = &Config{}
+	assert.Empty(t, config.Load())
+	assert.Equal(t, time.Duration(0), config.RefreshAheadWindow)
+}
+
+func TestConfigLoadIdempotencyTTL(t *testing.T) {
+	t.Setenv("API_URL", "http://api:80")
+
+	t.Setenv("IDEMPOTENCY_TTL", "")
+	config := &Config{}
+	assert.Empty(t, config.Load())
+	assert.Equal(t, 24*time.Hour, config.IdempotencyTTL)
+
+	t.Setenv("IDEMPOTENCY_TTL", "1h")
+	config = &Config{}
+	assert.Empty(t, config.Load())
+	assert.Equal(t, time.Hour, config.IdempotencyTTL)
+
+	t.Setenv("IDEMPOTENCY_TTL", "0s")
+	config = &Config{}
+	assert.Equal(t, ValidationErrors{{Key: "IDEMPOTENCY_TTL", Message: "must be greater than 0"}}, config.Load())
+}
+
+func TestConfigLoadAdmin(t *testing.T) {
+	t.Setenv("API_URL", "http://api:80")
+	t.Setenv("ADMIN_ADDRESS", "")
+	t.Setenv("ADMIN_TOKEN", "")
+
+	config := &Config{}
+	assert.Empty(t, config.Load())
+	assert.Empty(t, config.AdminAddress)
+
+	t.Setenv("ADMIN_ADDRESS", "127.0.0.1:8083")
+	config = &Config{}
+	assert.Equal(t, ValidationErrors{{Key: "ADMIN_TOKEN", Message: "is required when ADMIN_ADDRESS is set"}}, config.Load())
+
+	t.Setenv("ADMIN_TOKEN", "secret")
+	config = &Config{}
+	assert.Empty(t, config.Load())
+	assert.Equal(t, "127.0.0.1:8083", config.AdminAddress)
+	assert.Equal(t, "secret", config.AdminToken)
+}
+
+func TestConfigLoadJwtKeySource(t *testing.T) {
+	t.Setenv("API_URL", "http://api:80")
+	t.Setenv("JWT_HMAC_SECRET", "secret")
+	t.Setenv("JWT_PUBLIC_KEY_FILE", "")
+	t.Setenv("JWT_JWKS_URL", "")
+	t.Setenv("JWT_JWKS_REFRESH", "")
+
+	config := &Config{}
+	assert.Empty(t, config.Load())
+	assert.Equal(t, "secret", config.JwtHmacSecret)
+	assert.Equal(t, 5*time.Minute, config.JwtJwksRefresh)
+
+	t.Setenv("JWT_JWKS_URL", "https://auth.test.com/jwks.json")
+	config = &Config{}
+	errs := config.Load()
+	assert.Len(t, errs, 1)
+	assert.Equal(t, "JWT_HMAC_SECRET", errs[0].Key)
+
+	t.Setenv("JWT_HMAC_SECRET", "")
+	t.Setenv("JWT_JWKS_REFRESH", "0s")
+	config = &Config{}
+	errs = config.Load()
+	assert.Len(t, errs, 1)
+	assert.Equal(t, "JWT_JWKS_REFRESH", errs[0].Key)
 }
 
 func TestConfigLoadGitInfoDefaultsEmpty(t *testing.T) {
@@ -80,13 +178,140 @@ func TestConfigLoadGitInfoDefaultsEmpty(t *testing.T) {
 }
 
 func TestConfigLoadUnexpectedApiUrl(t *testing.T) {
-	_ = os.Setenv("API_URL", "://api")
+	t.Setenv("API_URL", "://api")
 
 	config := &Config{}
 
-	assert.Panics(t, func() {
-		config.Load()
+	var errs ValidationErrors
+	assert.NotPanics(t, func() {
+		errs = config.Load()
 	})
+
+	assert.Contains(t, errs, ValidationError{Key: "API_URL", Message: `invalid URL: parse "://api": missing protocol scheme`})
+	assert.NotNil(t, config.ApiURI)
+}
+
+func TestConfigLoadValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want ValidationErrors
+	}{
+		{
+			name: "valid",
+			env:  map[string]string{"API_URL": "http://api:80"},
+		},
+		{
+			name: "empty api url",
+			env:  map[string]string{"API_URL": ""},
+			want: ValidationErrors{{Key: "API_URL", Message: "is required"}},
+		},
+		{
+			name: "relative api url",
+			env:  map[string]string{"API_URL": "api:80"},
+			want: ValidationErrors{{Key: "API_URL", Message: `"api:80" must be an absolute http(s) URL`}},
+		},
+		{
+			name: "malformed gateway url",
+			env:  map[string]string{"API_URL": "http://api:80", "GATEWAY_URL": "gateway.cash-track.app"},
+			want: ValidationErrors{{Key: "GATEWAY_URL", Message: `"gateway.cash-track.app" must be an absolute http(s) URL`}},
+		},
+		{
+			name: "relative website and webapp urls",
+			env:  map[string]string{"API_URL": "http://api:80", "WEBSITE_URL": "/site", "WEBAPP_URL": "ftp://my.cash-track.app"},
+			want: ValidationErrors{
+				{Key: "WEBSITE_URL", Message: `"/site" must be an absolute http(s) URL`},
+				{Key: "WEBAPP_URL", Message: `"ftp://my.cash-track.app" must be an absolute http(s) URL`},
+			},
+		},
+		{
+			name: "https without key and certificate",
+			env:  map[string]string{"API_URL": "http://api:80", "HTTPS_ENABLED": "true"},
+			want: ValidationErrors{
+				{Key: "HTTPS_KEY", Message: "is required when HTTPS_ENABLED=true"},
+				{Key: "HTTPS_CRT", Message: "is required when HTTPS_ENABLED=true"},
+			},
+		},
+		{
+			name: "cors origin with trailing slash",
+			env:  map[string]string{"API_URL": "http://api:80", "CORS_ALLOWED_ORIGINS": "https://cash-track.app/"},
+			want: ValidationErrors{{Key: "CORS_ALLOWED_ORIGINS", Message: `cors allowed origin "https://cash-track.app/" must be scheme://host[:port]`}},
+		},
+		{
+			name: "unknown captcha provider",
+			env:  map[string]string{"API_URL": "http://api:80", "CAPTCHA_PROVIDER": "friendlycaptcha"},
+			want: ValidationErrors{{Key: "CAPTCHA_PROVIDER", Message: `"friendlycaptcha" must be one of recaptcha, hcaptcha, turnstile, local`}},
+		},
+		{
+			name: "unknown cache store",
+			env:  map[string]string{"API_URL": "http://api:80", "CACHE_STORE": "memcached"},
+			want: ValidationErrors{{Key: "CACHE_STORE", Message: `"memcached" must be one of memory, redis`}},
+		},
+		{
+			name: "every problem is reported",
+			env:  map[string]string{"API_URL": "", "GATEWAY_URL": "nope", "HTTPS_ENABLED": "true", "HTTPS_KEY": "/key"},
+			want: ValidationErrors{
+				{Key: "API_URL", Message: "is required"},
+				{Key: "GATEWAY_URL", Message: `"nope" must be an absolute http(s) URL`},
+				{Key: "HTTPS_CRT", Message: "is required when HTTPS_ENABLED=true"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"API_URL", "GATEWAY_URL", "WEBSITE_URL", "WEBAPP_URL", "HTTPS_ENABLED", "HTTPS_KEY", "HTTPS_CRT", "CORS_ALLOWED_ORIGINS", "CAPTCHA_PROVIDER", "CACHE_STORE"} {
+				t.Setenv(key, tt.env[key])
+			}
+
+			config := &Config{}
+			assert.Equal(t, tt.want, config.Load())
+		})
+	}
+}
+
+func TestConfigLoadCorsAllowedOriginsTrimmed(t *testing.T) {
+	t.Setenv("API_URL", "http://api:80")
+	t.Setenv("CORS_ALLOWED_ORIGINS", " https://cash-track.app , ,https://My.cash-track.app,")
+
+	config := &Config{}
+	errs := config.Load()
+
+	assert.Empty(t, errs)
+	assert.Equal(t, map[string]bool{
+		"https://cash-track.app":    true,
+		"https://my.cash-track.app": true,
+	}, config.CorsAllowedOrigins)
+}
+
+func TestConfigLoadEmptyCorsAllowedOrigins(t *testing.T) {
+	t.Setenv("API_URL", "http://api:80")
+	t.Setenv("CORS_ALLOWED_ORIGINS", "")
+
+	config := &Config{}
+	config.Load()
+
+	assert.Empty(t, config.CorsAllowedOrigins)
+}
+
+func TestConfigLoadWithoutGatewayUrl(t *testing.T) {
+	t.Setenv("API_URL", "http://api:80")
+	t.Setenv("GATEWAY_URL", "")
+
+	config := &Config{}
+	config.Load()
+
+	assert.Equal(t, "", config.CookieDomain)
+	assert.Equal(t, false, config.CookieSecure)
+}
+
+func TestValidationErrorsError(t *testing.T) {
+	errs := ValidationErrors{
+		{Key: "API_URL", Message: "is required"},
+		{Key: "HTTPS_KEY", Message: "is required when HTTPS_ENABLED=true"},
+	}
+
+	assert.EqualError(t, errs, "API_URL: is required; HTTPS_KEY: is required when HTTPS_ENABLED=true")
 }
 
 func TestConfigLoadTrustedProxiesCustom(t *testing.T) {
@@ -95,11 +320,11 @@ func TestConfigLoadTrustedProxiesCustom(t *testing.T) {
 
 	config := &Config{}
 
-	assert.NotPanics(t, func() {
-		config.Load()
-	})
-
-	// bare IPs are normalised to /32 or /128, and the invalid entry is skipped, not fatal.
+	// bare IPs are normalised to /32 or /128, and the invalid entry is reported.
+	errs := config.Load()
+	assert.Len(t, errs, 1)
+	assert.Equal(t, "TRUSTED_PROXIES", errs[0].Key)
+	assert.Contains(t, errs[0].Message, `trusted proxy "not-a-cidr"`)
 	assert.Equal(t, []netip.Prefix{
 		netip.MustParsePrefix("10.10.0.0/16"),
 		netip.MustParsePrefix("172.20.0.5/32"),
@@ -128,8 +353,9 @@ func TestConfigLoadTrustedProxiesAllInvalidYieldsEmptyList(t *testing.T) {
 	t.Setenv("TRUSTED_PROXIES", "garbage, also-garbage")
 
 	config := &Config{}
-	config.Load()
+	errs := config.Load()
 
+	assert.Len(t, errs, 2)
 	assert.Empty(t, config.TrustedProxies)
 }
 
@@ -155,3 +381,38 @@ func TestConfigLoadGatewaySecret(t *testing.T) {
 		})
 	}
 }
+
+func TestConfigLoadShutdownDurations(t *testing.T) {
+	tests := []struct {
+		name      string
+		drain     string
+		timeout   string
+		wantDrain time.Duration
+		wantTotal time.Duration
+		wantErrs  ValidationErrors
+	}{
+		{name: "unset uses defaults", wantDrain: 5 * time.Second, wantTotal: 30 * time.Second},
+		{name: "custom values", drain: "0s", timeout: "1m", wantDrain: 0, wantTotal: time.Minute},
+		{
+			name: "invalid is reported", drain: "soon", timeout: "-3s", wantDrain: 5 * time.Second, wantTotal: 30 * time.Second,
+			wantErrs: ValidationErrors{
+				{Key: "SHUTDOWN_DRAIN_PERIOD", Message: `"soon" must be a non-negative duration, e.g. 30s`},
+				{Key: "SHUTDOWN_TIMEOUT", Message: `"-3s" must be a non-negative duration, e.g. 30s`},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_ = os.Setenv("API_URL", "http://api:80")
+			t.Setenv("SHUTDOWN_DRAIN_PERIOD", tt.drain)
+			t.Setenv("SHUTDOWN_TIMEOUT", tt.timeout)
+
+			config := &Config{}
+
+			assert.Equal(t, tt.wantErrs, config.Load())
+			assert.Equal(t, tt.wantDrain, config.ShutdownDrainPeriod)
+			assert.Equal(t, tt.wantTotal, config.ShutdownTimeout)
+		})
+	}
+}
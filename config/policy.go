@@ -0,0 +1,152 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Values of AccessPolicy.Access.
+const (
+	AccessPublic        = "public"
+	AccessAuthenticated = "authenticated"
+	AccessAnonymous     = "anonymous"
+)
+
+const catchAllSegment = "{path:*}"
+
+// AccessPolicy restricts who reaches the requests whose path matches Path and, when set,
+// whose method is one of Methods. Path segments in braces or "*" match any one segment, a
+// trailing {path:*} matches whatever follows. Trailing slashes are ignored, like the
+// upstreams do. Access is one of the Access* values; authenticated access and Roles, which
+// requires a signed-in user holding any one of them, read the verified access token.
+// The first declared policy matching a request applies; requests matching none are public.
+type AccessPolicy struct {
+	Name    string   `yaml:"name"`
+	Path    string   `yaml:"path"`
+	Methods []string `yaml:"methods"`
+	Access  string   `yaml:"access"`
+	Roles   []string `yaml:"roles"`
+}
+
+// Matches reports whether the policy applies to the request method and path.
+func (p AccessPolicy) Matches(method, path string) bool {
+	if !matchPattern(p.Path, path) {
+		return false
+	}
+
+	if len(p.Methods) == 0 {
+		return true
+	}
+
+	for _, m := range p.Methods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// FindAccessPolicy returns the first policy matching the request.
+func (c *Config) FindAccessPolicy(method, path string) (AccessPolicy, bool) {
+	for _, p := range c.AccessPolicies {
+		if p.Matches(method, path) {
+			return p, true
+		}
+	}
+
+	return AccessPolicy{}, false
+}
+
+func matchPattern(pattern, path string) bool {
+	patternSegments := strings.Split(strings.Trim(pattern, "/"), "/")
+	pathSegments := strings.Split(strings.Trim(path, "/"), "/")
+
+	for i, segment := range patternSegments {
+		if segment == catchAllSegment {
+			return true
+		}
+
+		if i >= len(pathSegments) {
+			return false
+		}
+
+		if isParamSegment(segment) {
+			if pathSegments[i] == "" {
+				return false
+			}
+
+			continue
+		}
+
+		if segment != pathSegments[i] {
+			return false
+		}
+	}
+
+	return len(patternSegments) == len(pathSegments)
+}
+
+func isParamSegment(segment string) bool {
+	return segment == "*" || (strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}"))
+}
+
+func (c *Config) buildAccessPolicies(declared []AccessPolicy) ([]AccessPolicy, error) {
+	names := map[string]bool{}
+
+	for i := range declared {
+		p := &declared[i]
+
+		if p.Access == "" {
+			p.Access = AccessAuthenticated
+		}
+
+		if err := p.validate(); err != nil {
+			return nil, err
+		}
+
+		if len(p.Roles) > 0 && !c.JwtVerifyEnabled() {
+			return nil, fmt.Errorf("policy %q: roles need verified access tokens, set JWT_HMAC_SECRET, JWT_PUBLIC_KEY_FILE or JWT_JWKS_URL", p.Name)
+		}
+
+		// an access token cookie alone can be made up by anyone
+		if p.Access == AccessAuthenticated && !c.JwtVerifyEnabled() {
+			return nil, fmt.Errorf("policy %q: access %s needs verified access tokens, set JWT_HMAC_SECRET, JWT_PUBLIC_KEY_FILE or JWT_JWKS_URL", p.Name, AccessAuthenticated)
+		}
+
+		if names[p.Name] {
+			return nil, fmt.Errorf("duplicate policy name %q", p.Name)
+		}
+
+		names[p.Name] = true
+	}
+
+	return declared, nil
+}
+
+func (p AccessPolicy) validate() error {
+	if p.Name == "" {
+		return fmt.Errorf("policy with path %q has no name", p.Path)
+	}
+
+	if !strings.HasPrefix(p.Path, "/") {
+		return fmt.Errorf("policy %q: path %q must start with a slash", p.Name, p.Path)
+	}
+
+	if i := strings.Index(p.Path, catchAllSegment); i >= 0 && i != len(p.Path)-len(catchAllSegment) {
+		return fmt.Errorf("policy %q: %s must end the path %q", p.Name, catchAllSegment, p.Path)
+	}
+
+	switch p.Access {
+	case AccessPublic, AccessAuthenticated, AccessAnonymous:
+	default:
+		return fmt.Errorf("policy %q: access %q must be one of %s, %s, %s", p.Name, p.Access,
+			AccessPublic, AccessAuthenticated, AccessAnonymous)
+	}
+
+	if len(p.Roles) > 0 && p.Access != AccessAuthenticated {
+		return fmt.Errorf("policy %q: roles can only be required with access %s", p.Name, AccessAuthenticated)
+	}
+
+	return nil
+}
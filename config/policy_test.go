@@ -0,0 +1,141 @@
+package config
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccessPolicyMatches(t *testing.T) {
+	cases := map[string]struct {
+		pattern string
+		path    string
+		matches bool
+	}{
+		"Exact":                {pattern: "/api/profile", path: "/api/profile", matches: true},
+		"ExactLonger":          {pattern: "/api/profile", path: "/api/profile/photo"},
+		"ExactShorter":         {pattern: "/api/profile", path: "/api"},
+		"ExactOtherSegment":    {pattern: "/api/profile", path: "/api/profiles"},
+		"Param":                {pattern: "/api/wallets/{id}/users", path: "/api/wallets/5/users", matches: true},
+		"ParamStar":            {pattern: "/api/wallets/*/users", path: "/api/wallets/5/users", matches: true},
+		"ParamEmpty":           {pattern: "/api/wallets/{id}/users", path: "/api/wallets//users"},
+		"ParamMissing":         {pattern: "/api/wallets/{id}", path: "/api/wallets"},
+		"CatchAll":             {pattern: "/api/admin/{path:*}", path: "/api/admin/users/5", matches: true},
+		"CatchAllOneSegment":   {pattern: "/api/admin/{path:*}", path: "/api/admin/users", matches: true},
+		"CatchAllOtherSegment": {pattern: "/api/admin/{path:*}", path: "/api/administrators"},
+		"TrailingSlash":        {pattern: "/api/profile", path: "/api/profile/", matches: true},
+		"TrailingSlashParam":   {pattern: "/api/wallets/{id}", path: "/api/wallets/5/", matches: true},
+		"TrailingSlashOnly":    {pattern: "/api/wallets/{id}", path: "/api/wallets/"},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			p := AccessPolicy{Name: "test", Path: c.pattern}
+
+			assert.Equal(t, c.matches, p.Matches("GET", c.path))
+		})
+	}
+
+	p := AccessPolicy{Name: "write", Path: "/api/wallets", Methods: []string{"post", "DELETE"}}
+	assert.True(t, p.Matches("POST", "/api/wallets"))
+	assert.True(t, p.Matches("DELETE", "/api/wallets"))
+	assert.False(t, p.Matches("GET", "/api/wallets"))
+}
+
+func TestFindAccessPolicyFirstMatch(t *testing.T) {
+	config := &Config{AccessPolicies: []AccessPolicy{
+		{Name: "health", Path: "/api/admin/health", Access: AccessPublic},
+		{Name: "admin", Path: "/api/admin/{path:*}", Access: AccessAuthenticated},
+	}}
+
+	p, ok := config.FindAccessPolicy("GET", "/api/admin/health")
+	assert.True(t, ok)
+	assert.Equal(t, "health", p.Name)
+
+	p, ok = config.FindAccessPolicy("GET", "/api/admin/users")
+	assert.True(t, ok)
+	assert.Equal(t, "admin", p.Name)
+
+	_, ok = config.FindAccessPolicy("GET", "/api/wallets")
+	assert.False(t, ok)
+}
+
+func TestLoadRoutesAccessPolicies(t *testing.T) {
+	apiUri, _ := url.Parse("http://api:80")
+	config := &Config{ApiUrl: "http://api:80", ApiURI: apiUri, JwtHmacSecret: "secret"}
+	config.RoutesFile = writeTempFile(t, "routes.yaml", `
+policies:
+  - name: register
+    path: /api/auth/register
+    methods: [POST]
+    access: anonymous
+  - name: admin
+    path: /api/admin/{path:*}
+    roles: [admin]
+  - name: profile
+    path: /api/profile
+`)
+
+	assert.NoError(t, config.LoadRoutes())
+	assert.Equal(t, []AccessPolicy{
+		{Name: "register", Path: "/api/auth/register", Methods: []string{"POST"}, Access: AccessAnonymous},
+		{Name: "admin", Path: "/api/admin/{path:*}", Access: AccessAuthenticated, Roles: []string{"admin"}},
+		{Name: "profile", Path: "/api/profile", Access: AccessAuthenticated},
+	}, config.AccessPolicies)
+}
+
+func TestLoadRoutesInvalidAccessPolicies(t *testing.T) {
+	apiUri, _ := url.Parse("http://api:80")
+
+	for name, test := range map[string]struct {
+		content string
+		verify  bool
+		err     string
+	}{
+		"NoName": {
+			content: "policies: [{path: /api/profile}]",
+			err:     `policy with path "/api/profile" has no name`,
+		},
+		"RelativePath": {
+			content: "policies: [{name: profile, path: api/profile}]",
+			err:     `policy "profile": path "api/profile" must start with a slash`,
+		},
+		"CatchAllNotLast": {
+			content: "policies: [{name: admin, path: '/api/{path:*}/admin'}]",
+			err:     `policy "admin": {path:*} must end the path "/api/{path:*}/admin"`,
+		},
+		"UnknownAccess": {
+			content: "policies: [{name: profile, path: /api/profile, access: private}]",
+			err:     `policy "profile": access "private" must be one of public, authenticated, anonymous`,
+		},
+		"RolesAnonymous": {
+			content: "policies: [{name: register, path: /api/auth/register, access: anonymous, roles: [admin]}]",
+			verify:  true,
+			err:     `policy "register": roles can only be required with access authenticated`,
+		},
+		"RolesUnverified": {
+			content: "policies: [{name: admin, path: /api/admin, roles: [admin]}]",
+			err:     `policy "admin": roles need verified access tokens`,
+		},
+		"AuthenticatedUnverified": {
+			content: "policies: [{name: profile, path: /api/profile}]",
+			err:     `policy "profile": access authenticated needs verified access tokens`,
+		},
+		"DuplicateName": {
+			content: "policies: [{name: profile, path: /api/profile}, {name: profile, path: /api/settings}]",
+			verify:  true,
+			err:     `duplicate policy name "profile"`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			config := &Config{ApiUrl: "http://api:80", ApiURI: apiUri}
+			if test.verify {
+				config.JwtHmacSecret = "secret"
+			}
+			config.RoutesFile = writeTempFile(t, "routes.yaml", test.content)
+
+			assert.ErrorContains(t, config.LoadRoutes(), test.err)
+		})
+	}
+}
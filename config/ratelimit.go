@@ -0,0 +1,105 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// RateLimit allows at most Limit requests per client IP within a sliding Window for the
+// requests whose path starts with Prefix and, when set, whose method is one of Methods.
+// The rule with the longest matching prefix applies; requests matching no rule are not
+// limited.
+type RateLimit struct {
+	Name    string        `yaml:"name"`
+	Prefix  string        `yaml:"prefix"`
+	Methods []string      `yaml:"methods"`
+	Limit   uint          `yaml:"limit"`
+	Window  time.Duration `yaml:"window"`
+}
+
+// defaultRateLimits protect the credential endpoints, which reach the API and the
+// captcha provider on every attempt, when ROUTES_FILE declares no rateLimits.
+func defaultRateLimits() []RateLimit {
+	return []RateLimit{
+		{Name: "auth", Prefix: "/api/auth", Methods: []string{"POST"}, Limit: 20, Window: time.Minute},
+	}
+}
+
+// Matches reports whether the rule applies to the request method and path.
+func (r RateLimit) Matches(method, path string) bool {
+	prefix := strings.TrimSuffix(r.Prefix, "/")
+	if path != prefix && !strings.HasPrefix(path, prefix+"/") {
+		return false
+	}
+
+	if len(r.Methods) == 0 {
+		return true
+	}
+
+	for _, m := range r.Methods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// FindRateLimit returns the rule with the longest prefix matching the request.
+func (c *Config) FindRateLimit(method, path string) (RateLimit, bool) {
+	var (
+		found RateLimit
+		ok    bool
+	)
+
+	for _, r := range c.RateLimits {
+		if r.Matches(method, path) && (!ok || len(r.Prefix) > len(found.Prefix)) {
+			found, ok = r, true
+		}
+	}
+
+	return found, ok
+}
+
+func buildRateLimits(declared []RateLimit) ([]RateLimit, error) {
+	if declared == nil {
+		return defaultRateLimits(), nil
+	}
+
+	names := map[string]bool{}
+
+	for _, r := range declared {
+		if err := r.validate(); err != nil {
+			return nil, err
+		}
+
+		if names[r.Name] {
+			return nil, fmt.Errorf("duplicate rate limit name %q", r.Name)
+		}
+
+		names[r.Name] = true
+	}
+
+	return declared, nil
+}
+
+func (r RateLimit) validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("rate limit with prefix %q has no name", r.Prefix)
+	}
+
+	if !strings.HasPrefix(r.Prefix, "/") || (r.Prefix != "/" && strings.HasSuffix(r.Prefix, "/")) {
+		return fmt.Errorf("rate limit %q: prefix %q must start and not end with a slash", r.Name, r.Prefix)
+	}
+
+	if r.Limit == 0 {
+		return fmt.Errorf("rate limit %q: limit must be positive", r.Name)
+	}
+
+	if r.Window < time.Second {
+		return fmt.Errorf("rate limit %q: window must be at least 1s", r.Name)
+	}
+
+	return nil
+}
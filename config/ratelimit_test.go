@@ -0,0 +1,109 @@
+package config
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimitMatches(t *testing.T) {
+	rule := RateLimit{Name: "auth", Prefix: "/api/auth", Methods: []string{"post"}}
+
+	assert.True(t, rule.Matches("POST", "/api/auth"))
+	assert.True(t, rule.Matches("POST", "/api/auth/login"))
+	assert.False(t, rule.Matches("GET", "/api/auth/login"))
+	assert.False(t, rule.Matches("POST", "/api/authors"))
+	assert.False(t, rule.Matches("POST", "/api"))
+
+	all := RateLimit{Name: "all", Prefix: "/"}
+	assert.True(t, all.Matches("GET", "/api/wallets"))
+}
+
+func TestFindRateLimitLongestPrefix(t *testing.T) {
+	config := &Config{RateLimits: []RateLimit{
+		{Name: "api", Prefix: "/api"},
+		{Name: "login", Prefix: "/api/auth/login"},
+		{Name: "auth", Prefix: "/api/auth"},
+	}}
+
+	rule, ok := config.FindRateLimit("POST", "/api/auth/login")
+	assert.True(t, ok)
+	assert.Equal(t, "login", rule.Name)
+
+	rule, ok = config.FindRateLimit("POST", "/api/auth/register")
+	assert.True(t, ok)
+	assert.Equal(t, "auth", rule.Name)
+
+	_, ok = config.FindRateLimit("GET", "/csrf")
+	assert.False(t, ok)
+}
+
+func TestLoadRoutesRateLimits(t *testing.T) {
+	apiUri, _ := url.Parse("http://api:80")
+
+	config := &Config{ApiUrl: "http://api:80", ApiURI: apiUri}
+	assert.NoError(t, config.LoadRoutes())
+	assert.Equal(t, defaultRateLimits(), config.RateLimits)
+
+	config.RoutesFile = writeTempFile(t, "routes.yaml", `
+rateLimits:
+  - name: login
+    prefix: /api/auth/login
+    methods: [POST]
+    limit: 5
+    window: 1m
+  - name: api
+    prefix: /api
+    limit: 600
+    window: 10s
+`)
+
+	assert.NoError(t, config.LoadRoutes())
+	assert.Equal(t, []RateLimit{
+		{Name: "login", Prefix: "/api/auth/login", Methods: []string{"POST"}, Limit: 5, Window: time.Minute},
+		{Name: "api", Prefix: "/api", Limit: 600, Window: 10 * time.Second},
+	}, config.RateLimits)
+
+	config.RoutesFile = writeTempFile(t, "routes.yaml", "rateLimits: []\n")
+	assert.NoError(t, config.LoadRoutes())
+	assert.Empty(t, config.RateLimits)
+}
+
+func TestLoadRoutesInvalidRateLimits(t *testing.T) {
+	apiUri, _ := url.Parse("http://api:80")
+
+	for name, test := range map[string]struct {
+		content string
+		err     string
+	}{
+		"NoName": {
+			content: "rateLimits: [{prefix: /api, limit: 1, window: 1m}]",
+			err:     `rate limit with prefix "/api" has no name`,
+		},
+		"PrefixTrailingSlash": {
+			content: "rateLimits: [{name: api, prefix: /api/, limit: 1, window: 1m}]",
+			err:     `rate limit "api": prefix "/api/" must start and not end with a slash`,
+		},
+		"ZeroLimit": {
+			content: "rateLimits: [{name: api, prefix: /api, window: 1m}]",
+			err:     `rate limit "api": limit must be positive`,
+		},
+		"ShortWindow": {
+			content: "rateLimits: [{name: api, prefix: /api, limit: 1, window: 10ms}]",
+			err:     `rate limit "api": window must be at least 1s`,
+		},
+		"DuplicateName": {
+			content: "rateLimits: [{name: api, prefix: /api, limit: 1, window: 1m}, {name: api, prefix: /csrf, limit: 1, window: 1m}]",
+			err:     `duplicate rate limit name "api"`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			config := &Config{ApiUrl: "http://api:80", ApiURI: apiUri}
+			config.RoutesFile = writeTempFile(t, "routes.yaml", test.content)
+
+			assert.ErrorContains(t, config.LoadRoutes(), test.err)
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const defaultRealtimeIdleTimeout = 60 * time.Second
+
+// Realtime proxies the WebSocket upgrades and Server-Sent Events subscriptions of the
+// requests whose path starts with Prefix to the upstream serving that prefix. Both ends of
+// the connection are closed once nothing was sent either way for IdleTimeout. The other
+// requests of the prefix are forwarded as usual. The rule with the longest matching prefix
+// applies.
+type Realtime struct {
+	Name        string        `yaml:"name"`
+	Prefix      string        `yaml:"prefix"`
+	IdleTimeout time.Duration `yaml:"idleTimeout"`
+}
+
+// Matches reports whether the rule applies to the request path.
+func (r Realtime) Matches(path string) bool {
+	return RateLimit{Prefix: r.Prefix}.Matches("", path)
+}
+
+// FindRealtime returns the rule with the longest prefix matching the request path.
+func (c *Config) FindRealtime(path string) (Realtime, bool) {
+	var (
+		found Realtime
+		ok    bool
+	)
+
+	for _, r := range c.Realtime {
+		if r.Matches(path) && (!ok || len(r.Prefix) > len(found.Prefix)) {
+			found, ok = r, true
+		}
+	}
+
+	return found, ok
+}
+
+func buildRealtime(declared []Realtime, upstreams []Upstream) ([]Realtime, error) {
+	names := map[string]bool{}
+
+	for i := range declared {
+		r := &declared[i]
+
+		if err := r.validate(upstreams); err != nil {
+			return nil, err
+		}
+
+		if r.IdleTimeout == 0 {
+			r.IdleTimeout = defaultRealtimeIdleTimeout
+		}
+
+		if names[r.Name] {
+			return nil, fmt.Errorf("duplicate realtime route name %q", r.Name)
+		}
+
+		names[r.Name] = true
+	}
+
+	return declared, nil
+}
+
+func (r Realtime) validate(upstreams []Upstream) error {
+	if r.Name == "" {
+		return fmt.Errorf("realtime route with prefix %q has no name", r.Prefix)
+	}
+
+	if !strings.HasPrefix(r.Prefix, "/") {
+		return fmt.Errorf("realtime route %q: prefix %q must start with a slash", r.Name, r.Prefix)
+	}
+
+	if r.IdleTimeout < 0 {
+		return fmt.Errorf("realtime route %q: idleTimeout must be positive", r.Name)
+	}
+
+	for _, u := range upstreams {
+		if strings.HasPrefix(r.Prefix, u.Prefix+"/") {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("realtime route %q: prefix %q is not under the prefix of any upstream", r.Name, r.Prefix)
+}
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindRealtime(t *testing.T) {
+	config := &Config{
+		Realtime: []Realtime{
+			{Name: "realtime", Prefix: "/api/realtime"},
+			{Name: "balance", Prefix: "/api/realtime/balance"},
+		},
+	}
+
+	route, ok := config.FindRealtime("/api/realtime/balance/1")
+	assert.True(t, ok)
+	assert.Equal(t, "balance", route.Name)
+
+	route, ok = config.FindRealtime("/api/realtime/wallets")
+	assert.True(t, ok)
+	assert.Equal(t, "realtime", route.Name)
+
+	_, ok = config.FindRealtime("/api/realtimeish")
+	assert.False(t, ok)
+}
+
+func TestLoadRoutesRealtime(t *testing.T) {
+	apiUri, _ := url.Parse("http://api:80")
+
+	config := &Config{ApiUrl: "http://api:80", ApiURI: apiUri}
+	config.RoutesFile = writeTempFile(t, "routes.yaml", `
+realtime:
+  - name: balance
+    prefix: /api/realtime/balance
+  - name: events
+    prefix: /api/realtime/events
+    idleTimeout: 5m
+`)
+
+	assert.NoError(t, config.LoadRoutes())
+	assert.Equal(t, []Realtime{
+		{Name: "balance", Prefix: "/api/realtime/balance", IdleTimeout: time.Minute},
+		{Name: "events", Prefix: "/api/realtime/events", IdleTimeout: 5 * time.Minute},
+	}, config.Realtime)
+}
+
+func TestLoadRoutesInvalidRealtime(t *testing.T) {
+	apiUri, _ := url.Parse("http://api:80")
+
+	for name, test := range map[string]struct {
+		content string
+		err     string
+	}{
+		"NoName": {
+			content: "realtime: [{prefix: /api/realtime}]",
+			err:     `realtime route with prefix "/api/realtime" has no name`,
+		},
+		"Prefix": {
+			content: "realtime: [{name: balance, prefix: api}]",
+			err:     `realtime route "balance": prefix "api" must start with a slash`,
+		},
+		"IdleTimeout": {
+			content: "realtime: [{name: balance, prefix: /api/realtime, idleTimeout: -1s}]",
+			err:     `realtime route "balance": idleTimeout must be positive`,
+		},
+		"NoUpstream": {
+			content: "realtime: [{name: balance, prefix: /realtime}]",
+			err:     `realtime route "balance": prefix "/realtime" is not under the prefix of any upstream`,
+		},
+		"DuplicateName": {
+			content: "realtime: [{name: balance, prefix: /api/a}, {name: balance, prefix: /api/b}]",
+			err:     `duplicate realtime route name "balance"`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			config := &Config{ApiUrl: "http://api:80", ApiURI: apiUri}
+			config.RoutesFile = writeTempFile(t, "routes.yaml", test.content)
+
+			assert.ErrorContains(t, config.LoadRoutes(), test.err)
+		})
+	}
+}
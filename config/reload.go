@@ -0,0 +1,238 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	configWatchInterval = 5 * time.Second
+	metricsNamespace    = "gateway"
+	metricsConfigSubsys = "config"
+)
+
+var configReloadsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: metricsNamespace,
+	Subsystem: metricsConfigSubsys,
+	Name:      "reloads_total",
+	Help:      "Configuration reloads by result: applied, or rejected and the previous configuration kept.",
+}, []string{"result"})
+
+// Reloadable is the part of the configuration that can change without a restart. Read
+// it through Live, never from a Config copy, to see reloads.
+type Reloadable struct {
+	CaptchaSecret string
+	GatewaySecret string
+
+	CorsAllowedOrigins map[string]bool
+
+	// Peers allowed to set Cf-Connecting-IP (e.g. Traefik).
+	TrustedProxies []netip.Prefix
+}
+
+// reloadableFile is the CONFIG_FILE document. Omitted keys keep the environment value.
+type reloadableFile struct {
+	CaptchaSecret      *string  `yaml:"captchaSecret"`
+	GatewaySecret      *string  `yaml:"gatewaySecret"`
+	CorsAllowedOrigins []string `yaml:"corsAllowedOrigins"`
+	TrustedProxies     []string `yaml:"trustedProxies"`
+}
+
+var live atomic.Pointer[Reloadable]
+
+// Live returns the reloadable settings last published, or those of Global before the
+// first publish.
+func Live() *Reloadable {
+	if r := live.Load(); r != nil {
+		return r
+	}
+
+	return &Global.Reloadable
+}
+
+// Publish atomically replaces the settings returned by Live.
+func Publish(r Reloadable) {
+	live.Store(&r)
+}
+
+// Reloader applies CONFIG_FILE on top of the environment configuration it was created
+// with. The file is re-read on SIGHUP and whenever its modification time changes; a
+// file that fails validation is rejected and the current settings stay in place.
+type Reloader struct {
+	base Config
+
+	mu sync.Mutex
+	// modTime and statErr are what the file last looked like, reloaded or not, so a file
+	// failing the same way is reported once rather than on every tick.
+	modTime time.Time
+	statErr string
+}
+
+func NewReloader(base Config) *Reloader {
+	return &Reloader{base: base}
+}
+
+// Reload reads, validates and publishes the configuration. Without a config file it
+// publishes the environment settings as they are.
+func (r *Reloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	next, modTime, err := r.read()
+	if err != nil {
+		configReloadsTotal.WithLabelValues("rejected").Inc()
+		slog.Error("configuration reload rejected, keeping current", "file", r.base.ConfigFile, "error", err)
+
+		return err
+	}
+
+	r.modTime, r.statErr = modTime, ""
+	Publish(next)
+
+	configReloadsTotal.WithLabelValues("applied").Inc()
+	slog.Info("configuration reloaded",
+		"file", r.base.ConfigFile,
+		"cors_allowed_origins", len(next.CorsAllowedOrigins),
+		"trusted_proxies", len(next.TrustedProxies),
+	)
+
+	return nil
+}
+
+// Check reads and validates the configuration file without publishing it.
+func (r *Reloader) Check() (Reloadable, error) {
+	next, _, err := r.read()
+
+	return next, err
+}
+
+// Watch reloads on every signal from hup and when the file changes, until ctx is done.
+func (r *Reloader) Watch(ctx context.Context, hup <-chan os.Signal) {
+	ticker := time.NewTicker(configWatchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			_ = r.Reload()
+		case <-ticker.C:
+			if r.changed() {
+				_ = r.Reload()
+			}
+		}
+	}
+}
+
+func (r *Reloader) changed() bool {
+	if r.base.ConfigFile == "" {
+		return false
+	}
+
+	info, err := os.Stat(r.base.ConfigFile)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err != nil {
+		// reported by the next reload, which fails on the same error, once per error
+		changed := err.Error() != r.statErr
+		r.statErr = err.Error()
+
+		return changed
+	}
+
+	changed := r.statErr != "" || !info.ModTime().Equal(r.modTime)
+	r.modTime, r.statErr = info.ModTime(), ""
+
+	return changed
+}
+
+func (r *Reloader) read() (Reloadable, time.Time, error) {
+	next := r.base.Reloadable
+
+	if r.base.ConfigFile == "" {
+		return next, time.Time{}, nil
+	}
+
+	info, err := os.Stat(r.base.ConfigFile)
+	if err != nil {
+		return next, time.Time{}, fmt.Errorf("stat config file: %w", err)
+	}
+
+	data, err := os.ReadFile(r.base.ConfigFile)
+	if err != nil {
+		return next, time.Time{}, fmt.Errorf("read config file: %w", err)
+	}
+
+	file := reloadableFile{}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return next, time.Time{}, fmt.Errorf("parse config file: %w", err)
+	}
+
+	if err := file.apply(&next); err != nil {
+		return next, time.Time{}, err
+	}
+
+	return next, info.ModTime(), nil
+}
+
+// apply validates the file values and overrides the matching fields of r. Unlike the
+// environment, an invalid entry rejects the whole file rather than being skipped.
+func (f reloadableFile) apply(r *Reloadable) error {
+	var errs []error
+
+	if f.CaptchaSecret != nil {
+		r.CaptchaSecret = *f.CaptchaSecret
+	}
+
+	if f.GatewaySecret != nil {
+		r.GatewaySecret = *f.GatewaySecret
+	}
+
+	if f.CorsAllowedOrigins != nil {
+		origins := make(map[string]bool, len(f.CorsAllowedOrigins))
+		for _, origin := range f.CorsAllowedOrigins {
+			if err := validateOrigin(origin); err != nil {
+				errs = append(errs, err)
+
+				continue
+			}
+
+			origins[strings.ToLower(origin)] = true
+		}
+
+		r.CorsAllowedOrigins = origins
+	}
+
+	if f.TrustedProxies != nil {
+		proxies := make([]netip.Prefix, 0, len(f.TrustedProxies))
+		for _, v := range f.TrustedProxies {
+			prefix, err := parseTrustedProxy(strings.TrimSpace(v))
+			if err != nil {
+				errs = append(errs, fmt.Errorf("trusted proxy %q: %w", v, err))
+
+				continue
+			}
+
+			proxies = append(proxies, prefix)
+		}
+
+		r.TrustedProxies = proxies
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,174 @@
+package config
+
+import (
+	"context"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func resetLive(t *testing.T) {
+	t.Helper()
+
+	live.Store(nil)
+	t.Cleanup(func() { live.Store(nil) })
+}
+
+func newReloaderBase(file string) Config {
+	return Config{
+		ConfigFile: file,
+		Reloadable: Reloadable{
+			CaptchaSecret:      "env-captcha",
+			GatewaySecret:      "env-gateway",
+			CorsAllowedOrigins: map[string]bool{"https://env.test": true},
+			TrustedProxies:     []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")},
+		},
+	}
+}
+
+func TestLiveFallsBackToGlobalBeforePublish(t *testing.T) {
+	resetLive(t)
+
+	original := Global
+	t.Cleanup(func() { Global = original })
+
+	Global.CaptchaSecret = "global"
+	assert.Equal(t, "global", Live().CaptchaSecret)
+
+	Publish(Reloadable{CaptchaSecret: "published"})
+	assert.Equal(t, "published", Live().CaptchaSecret)
+}
+
+func TestReloadWithoutFilePublishesEnvironment(t *testing.T) {
+	resetLive(t)
+
+	base := newReloaderBase("")
+
+	assert.NoError(t, NewReloader(base).Reload())
+	assert.Equal(t, base.Reloadable, *Live())
+}
+
+func TestReloadAppliesFileOverEnvironment(t *testing.T) {
+	resetLive(t)
+
+	path := writeTempFile(t, "gateway.yaml", `
+captchaSecret: file-captcha
+corsAllowedOrigins:
+  - https://My.App.test
+  - http://localhost:3000
+trustedProxies:
+  - 172.20.0.5
+  - 192.168.0.0/16
+`)
+	applied := testutil.ToFloat64(configReloadsTotal.WithLabelValues("applied"))
+
+	assert.NoError(t, NewReloader(newReloaderBase(path)).Reload())
+
+	assert.Equal(t, "file-captcha", Live().CaptchaSecret)
+	assert.Equal(t, "env-gateway", Live().GatewaySecret, "omitted keys keep the environment value")
+	assert.Equal(t, map[string]bool{"https://my.app.test": true, "http://localhost:3000": true}, Live().CorsAllowedOrigins)
+	assert.Equal(t, []netip.Prefix{
+		netip.MustParsePrefix("172.20.0.5/32"),
+		netip.MustParsePrefix("192.168.0.0/16"),
+	}, Live().TrustedProxies)
+	assert.Equal(t, applied+1, testutil.ToFloat64(configReloadsTotal.WithLabelValues("applied")))
+}
+
+func TestReloadRejectsInvalidFileAndKeepsCurrent(t *testing.T) {
+	for name, content := range map[string]string{
+		"Malformed":          `corsAllowedOrigins: [`,
+		"OriginWithPath":     `corsAllowedOrigins: ["https://app.test/"]`,
+		"OriginWithoutHost":  `corsAllowedOrigins: ["app.test"]`,
+		"InvalidProxy":       `trustedProxies: ["10.0.0.0/8", "not-a-cidr"]`,
+		"OneBadAmongGoodOne": `corsAllowedOrigins: ["https://ok.test", " https://space.test"]`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			resetLive(t)
+			Publish(Reloadable{CaptchaSecret: "current"})
+
+			path := writeTempFile(t, "gateway.yaml", content)
+			rejected := testutil.ToFloat64(configReloadsTotal.WithLabelValues("rejected"))
+
+			assert.Error(t, NewReloader(newReloaderBase(path)).Reload())
+
+			assert.Equal(t, "current", Live().CaptchaSecret)
+			assert.Equal(t, rejected+1, testutil.ToFloat64(configReloadsTotal.WithLabelValues("rejected")))
+		})
+	}
+}
+
+func TestReloadMissingFileRejected(t *testing.T) {
+	resetLive(t)
+
+	assert.Error(t, NewReloader(newReloaderBase(filepath.Join(t.TempDir(), "missing.yaml"))).Reload())
+	assert.Nil(t, live.Load())
+}
+
+func TestWatchReloadsOnSignal(t *testing.T) {
+	resetLive(t)
+
+	path := writeTempFile(t, "gateway.yaml", `captchaSecret: first`)
+	r := NewReloader(newReloaderBase(path))
+	assert.NoError(t, r.Reload())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hup := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	go func() {
+		r.Watch(ctx, hup)
+		close(done)
+	}()
+
+	assert.NoError(t, os.WriteFile(path, []byte(`captchaSecret: second`), 0o600))
+	hup <- os.Interrupt
+
+	assert.Eventually(t, func() bool {
+		return Live().CaptchaSecret == "second"
+	}, time.Second, 5*time.Millisecond)
+
+	cancel()
+	<-done
+}
+
+func TestReloaderChanged(t *testing.T) {
+	path := writeTempFile(t, "gateway.yaml", `captchaSecret: first`)
+	r := NewReloader(newReloaderBase(path))
+
+	assert.True(t, r.changed(), "never loaded")
+
+	resetLive(t)
+	assert.NoError(t, r.Reload())
+	assert.False(t, r.changed())
+
+	later := time.Now().Add(time.Minute)
+	assert.NoError(t, os.Chtimes(path, later, later))
+	assert.True(t, r.changed())
+
+	assert.False(t, NewReloader(newReloaderBase("")).changed(), "nothing to watch without a file")
+}
+
+func TestReloaderChangedMissingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gateway.yaml")
+	r := NewReloader(newReloaderBase(path))
+
+	assert.True(t, r.changed(), "the missing file is reported")
+	assert.False(t, r.changed(), "and only once")
+
+	assert.NoError(t, os.WriteFile(path, []byte(`captchaSecret: first`), 0o600))
+	assert.True(t, r.changed(), "until it reappears")
+	assert.False(t, r.changed())
+
+	// an invalid file is rejected once too, until it is written again
+	assert.NoError(t, os.WriteFile(path, []byte(`trustedProxies: [nope]`), 0o600))
+	later := time.Now().Add(time.Minute)
+	assert.NoError(t, os.Chtimes(path, later, later))
+	assert.True(t, r.changed())
+	assert.False(t, r.changed())
+}
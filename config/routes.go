@@ -0,0 +1,253 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	ApiUpstreamName        = "API"
+	apiUpstreamPrefix      = "/api"
+	apiUpstreamRewrite     = "/v1"
+	apiUpstreamHealthcheck = "/healthcheck"
+)
+
+// Upstream maps an inbound path prefix to a backend service. The matched prefix is
+// replaced with Rewrite (an empty Rewrite strips it). Zero timeouts, retry attempts and
+// breaker settings fall back to the forwarder defaults. An empty Healthcheck path
+// excludes the upstream from readiness checks.
+type Upstream struct {
+	Name          string          `yaml:"name"`
+	Prefix        string          `yaml:"prefix"`
+	Rewrite       string          `yaml:"rewrite"`
+	Url           string          `yaml:"url"`
+	URI           *url.URL        `yaml:"-"`
+	Healthcheck   string          `yaml:"healthcheck"`
+	ReadTimeout   time.Duration   `yaml:"readTimeout"`
+	WriteTimeout  time.Duration   `yaml:"writeTimeout"`
+	RetryAttempts uint            `yaml:"retryAttempts"`
+	Breaker       BreakerSettings `yaml:"breaker"`
+	// BreakerGroups trip apart from the rest of the upstream.
+	BreakerGroups []BreakerGroup `yaml:"breakerGroups"`
+}
+
+// RouteTable is the ROUTES_FILE document. YAML or JSON, since JSON is valid YAML.
+type RouteTable struct {
+	// Breaker is the default of the breaker settings of every upstream.
+	Breaker    BreakerSettings `yaml:"breaker"`
+	Upstreams  []Upstream      `yaml:"upstreams"`
+	RateLimits []RateLimit     `yaml:"rateLimits"`
+	Captcha    []CaptchaPolicy `yaml:"captcha"`
+	Policies   []AccessPolicy  `yaml:"policies"`
+	BodyLimits []BodyLimit     `yaml:"bodyLimits"`
+	Streams    []Stream        `yaml:"streams"`
+	Realtime   []Realtime      `yaml:"realtime"`
+	Cache      []CacheRule     `yaml:"cache"`
+}
+
+// ApiUpstream is the default upstream derived from API_URL: /api/* is forwarded to /v1/*.
+func (c *Config) ApiUpstream() Upstream {
+	return Upstream{
+		Name:        ApiUpstreamName,
+		Prefix:      apiUpstreamPrefix,
+		Rewrite:     apiUpstreamRewrite,
+		Url:         c.ApiUrl,
+		URI:         c.ApiURI,
+		Healthcheck: apiUpstreamHealthcheck,
+	}
+}
+
+// LoadRoutes fills Upstreams with the API upstream followed by the ones declared in
+// ROUTES_FILE. An entry named "API" in the file overrides the defaults of the API
+// upstream instead of adding a new one. Declared rateLimits replace the default ones, and
+// captcha policies apply on top of the provider verdict. Access policies are checked in
+// the declared order, body limits, streams, realtime routes and cache rules by the longest
+// prefix.
+// Must be called after Load.
+func (c *Config) LoadRoutes() error {
+	table := RouteTable{}
+
+	if c.RoutesFile != "" {
+		data, err := os.ReadFile(c.RoutesFile)
+		if err != nil {
+			return fmt.Errorf("read routes file: %w", err)
+		}
+
+		if err := yaml.Unmarshal(data, &table); err != nil {
+			return fmt.Errorf("parse routes file %s: %w", c.RoutesFile, err)
+		}
+	}
+
+	upstreams, err := c.buildUpstreams(table.Upstreams, table.Breaker)
+	if err != nil {
+		return fmt.Errorf("routes file %s: %w", c.RoutesFile, err)
+	}
+
+	rateLimits, err := buildRateLimits(table.RateLimits)
+	if err != nil {
+		return fmt.Errorf("routes file %s: %w", c.RoutesFile, err)
+	}
+
+	captchaPolicies, err := c.buildCaptchaPolicies(table.Captcha)
+	if err != nil {
+		return fmt.Errorf("routes file %s: %w", c.RoutesFile, err)
+	}
+
+	accessPolicies, err := c.buildAccessPolicies(table.Policies)
+	if err != nil {
+		return fmt.Errorf("routes file %s: %w", c.RoutesFile, err)
+	}
+
+	bodyLimits, err := buildBodyLimits(table.BodyLimits)
+	if err != nil {
+		return fmt.Errorf("routes file %s: %w", c.RoutesFile, err)
+	}
+
+	streams, err := buildStreams(table.Streams)
+	if err != nil {
+		return fmt.Errorf("routes file %s: %w", c.RoutesFile, err)
+	}
+
+	realtime, err := buildRealtime(table.Realtime, upstreams)
+	if err != nil {
+		return fmt.Errorf("routes file %s: %w", c.RoutesFile, err)
+	}
+
+	cacheRules, err := c.buildCacheRules(table.Cache)
+	if err != nil {
+		return fmt.Errorf("routes file %s: %w", c.RoutesFile, err)
+	}
+
+	c.Upstreams = upstreams
+	c.RateLimits = rateLimits
+	c.CaptchaPolicies = captchaPolicies
+	c.AccessPolicies = accessPolicies
+	c.BodyLimits = bodyLimits
+	c.Streams = streams
+	c.Realtime = realtime
+	c.CacheRules = cacheRules
+
+	return nil
+}
+
+func (c *Config) buildUpstreams(declared []Upstream, breaker BreakerSettings) ([]Upstream, error) {
+	api := c.ApiUpstream()
+	upstreams := []Upstream{api}
+	names := map[string]bool{}
+	prefixes := map[string]bool{}
+
+	for _, u := range declared {
+		if u.Name == ApiUpstreamName {
+			upstreams[0] = mergeUpstream(api, u)
+
+			continue
+		}
+
+		upstreams = append(upstreams, u)
+	}
+
+	for i := range upstreams {
+		u := &upstreams[i]
+
+		if err := u.validate(); err != nil {
+			return nil, err
+		}
+
+		u.Breaker = mergeBreaker(breaker, u.Breaker)
+		if err := u.buildBreakerGroups(); err != nil {
+			return nil, err
+		}
+
+		if names[u.Name] {
+			return nil, fmt.Errorf("duplicate upstream name %q", u.Name)
+		}
+
+		if prefixes[u.Prefix] {
+			return nil, fmt.Errorf("duplicate upstream prefix %q", u.Prefix)
+		}
+
+		names[u.Name] = true
+		prefixes[u.Prefix] = true
+	}
+
+	return upstreams, nil
+}
+
+// mergeUpstream applies the non-zero fields of override on top of base.
+func mergeUpstream(base, override Upstream) Upstream {
+	if override.Prefix != "" {
+		base.Prefix = override.Prefix
+	}
+
+	if override.Rewrite != "" {
+		base.Rewrite = override.Rewrite
+	}
+
+	if override.Url != "" {
+		base.Url = override.Url
+		base.URI = nil
+	}
+
+	if override.Healthcheck != "" {
+		base.Healthcheck = override.Healthcheck
+	}
+
+	if override.ReadTimeout != 0 {
+		base.ReadTimeout = override.ReadTimeout
+	}
+
+	if override.WriteTimeout != 0 {
+		base.WriteTimeout = override.WriteTimeout
+	}
+
+	if override.RetryAttempts != 0 {
+		base.RetryAttempts = override.RetryAttempts
+	}
+
+	if override.Breaker != (BreakerSettings{}) {
+		base.Breaker = override.Breaker
+	}
+
+	if len(override.BreakerGroups) > 0 {
+		base.BreakerGroups = override.BreakerGroups
+	}
+
+	return base
+}
+
+// validate checks the upstream and parses Url into URI when not already set.
+func (u *Upstream) validate() error {
+	if u.Name == "" {
+		return fmt.Errorf("upstream with prefix %q has no name", u.Prefix)
+	}
+
+	if !strings.HasPrefix(u.Prefix, "/") || strings.HasSuffix(u.Prefix, "/") {
+		return fmt.Errorf("upstream %q: prefix %q must start and not end with a slash", u.Name, u.Prefix)
+	}
+
+	if u.Rewrite != "" && (!strings.HasPrefix(u.Rewrite, "/") || strings.HasSuffix(u.Rewrite, "/")) {
+		return fmt.Errorf("upstream %q: rewrite %q must start and not end with a slash", u.Name, u.Rewrite)
+	}
+
+	if u.URI != nil {
+		return nil
+	}
+
+	uri, err := url.Parse(u.Url)
+	if err != nil {
+		return fmt.Errorf("upstream %q: invalid url: %w", u.Name, err)
+	}
+
+	if uri.Scheme == "" || uri.Host == "" {
+		return fmt.Errorf("upstream %q: url %q must be absolute", u.Name, u.Url)
+	}
+
+	u.URI = uri
+
+	return nil
+}
@@ -0,0 +1,126 @@
+package config
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeTempFile(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	return path
+}
+
+func TestLoadRoutesWithoutFileUsesApiUpstream(t *testing.T) {
+	apiUri, _ := url.Parse("http://api:80")
+	config := &Config{ApiUrl: "http://api:80", ApiURI: apiUri}
+
+	assert.NoError(t, config.LoadRoutes())
+
+	assert.Equal(t, []Upstream{{
+		Name:        "API",
+		Prefix:      "/api",
+		Rewrite:     "/v1",
+		Url:         "http://api:80",
+		URI:         apiUri,
+		Healthcheck: "/healthcheck",
+	}}, config.Upstreams)
+}
+
+func TestLoadRoutesYaml(t *testing.T) {
+	apiUri, _ := url.Parse("http://api:80")
+	config := &Config{ApiUrl: "http://api:80", ApiURI: apiUri}
+	config.RoutesFile = writeTempFile(t, "routes.yaml", `
+upstreams:
+  - name: API
+    readTimeout: 10s
+    breaker:
+      failureThreshold: 3
+  - name: exports
+    prefix: /api/exports
+    rewrite: /v2/exports
+    url: http://exports:8080
+    readTimeout: 30s
+    writeTimeout: 2s
+    retryAttempts: 1
+    breaker:
+      maxRequests: 1
+      timeout: 1m
+      failureThreshold: 5
+`)
+
+	assert.NoError(t, config.LoadRoutes())
+	assert.Len(t, config.Upstreams, 2)
+
+	api := config.Upstreams[0]
+	assert.Equal(t, "API", api.Name)
+	assert.Equal(t, "/api", api.Prefix)
+	assert.Equal(t, "/v1", api.Rewrite)
+	assert.Equal(t, apiUri, api.URI)
+	assert.Equal(t, 10*time.Second, api.ReadTimeout)
+	assert.Equal(t, BreakerSettings{FailureThreshold: 3}, api.Breaker)
+
+	exports := config.Upstreams[1]
+	assert.Equal(t, "exports", exports.Name)
+	assert.Equal(t, "/api/exports", exports.Prefix)
+	assert.Equal(t, "/v2/exports", exports.Rewrite)
+	assert.Equal(t, "exports:8080", exports.URI.Host)
+	assert.Empty(t, exports.Healthcheck)
+	assert.Equal(t, 30*time.Second, exports.ReadTimeout)
+	assert.Equal(t, 2*time.Second, exports.WriteTimeout)
+	assert.Equal(t, uint(1), exports.RetryAttempts)
+	assert.Equal(t, BreakerSettings{MaxRequests: 1, Timeout: time.Minute, FailureThreshold: 5}, exports.Breaker)
+}
+
+func TestLoadRoutesJson(t *testing.T) {
+	apiUri, _ := url.Parse("http://api:80")
+	config := &Config{ApiUrl: "http://api:80", ApiURI: apiUri}
+	config.RoutesFile = writeTempFile(t, "routes.json",
+		`{"upstreams":[{"name":"files","prefix":"/files","url":"https://files.internal","healthcheck":"/health"}]}`)
+
+	assert.NoError(t, config.LoadRoutes())
+	assert.Len(t, config.Upstreams, 2)
+	assert.Equal(t, "API", config.Upstreams[0].Name)
+	assert.Equal(t, "files", config.Upstreams[1].Name)
+	assert.Equal(t, "", config.Upstreams[1].Rewrite)
+	assert.Equal(t, "/health", config.Upstreams[1].Healthcheck)
+	assert.Equal(t, "https", config.Upstreams[1].URI.Scheme)
+}
+
+func TestLoadRoutesInvalid(t *testing.T) {
+	for name, content := range map[string]string{
+		"Malformed":        `upstreams: [`,
+		"NoName":           `{"upstreams":[{"prefix":"/files","url":"http://files"}]}`,
+		"PrefixNoSlash":    `{"upstreams":[{"name":"files","prefix":"files","url":"http://files"}]}`,
+		"PrefixTrailing":   `{"upstreams":[{"name":"files","prefix":"/files/","url":"http://files"}]}`,
+		"RewriteTrailing":  `{"upstreams":[{"name":"files","prefix":"/files","rewrite":"/v1/","url":"http://files"}]}`,
+		"RelativeUrl":      `{"upstreams":[{"name":"files","prefix":"/files","url":"files:80"}]}`,
+		"MissingUrl":       `{"upstreams":[{"name":"files","prefix":"/files"}]}`,
+		"DuplicateName":    `{"upstreams":[{"name":"files","prefix":"/a","url":"http://a"},{"name":"files","prefix":"/b","url":"http://b"}]}`,
+		"DuplicatePrefix":  `{"upstreams":[{"name":"files","prefix":"/api","url":"http://files"}]}`,
+		"BadTimeoutFormat": `{"upstreams":[{"name":"files","prefix":"/files","url":"http://files","readTimeout":"soon"}]}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			apiUri, _ := url.Parse("http://api:80")
+			config := &Config{ApiUrl: "http://api:80", ApiURI: apiUri}
+			config.RoutesFile = writeTempFile(t, "routes.yaml", content)
+
+			assert.Error(t, config.LoadRoutes())
+			assert.Nil(t, config.Upstreams)
+		})
+	}
+}
+
+func TestLoadRoutesMissingFile(t *testing.T) {
+	config := &Config{RoutesFile: filepath.Join(t.TempDir(), "missing.yaml")}
+
+	assert.Error(t, config.LoadRoutes())
+}
@@ -0,0 +1,59 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Stream forwards the bodies of the requests whose path starts with Prefix and, when set,
+// whose method is one of Methods as they are read, and the bodies of their responses as
+// they arrive, instead of buffering them whole: for large uploads and downloads. The rule
+// with the longest matching prefix applies.
+type Stream struct {
+	Name    string   `yaml:"name"`
+	Prefix  string   `yaml:"prefix"`
+	Methods []string `yaml:"methods"`
+}
+
+// Matches reports whether the rule applies to the request method and path.
+func (s Stream) Matches(method, path string) bool {
+	return RateLimit{Prefix: s.Prefix, Methods: s.Methods}.Matches(method, path)
+}
+
+// FindStream returns the rule with the longest prefix matching the request.
+func (c *Config) FindStream(method, path string) (Stream, bool) {
+	var (
+		found Stream
+		ok    bool
+	)
+
+	for _, s := range c.Streams {
+		if s.Matches(method, path) && (!ok || len(s.Prefix) > len(found.Prefix)) {
+			found, ok = s, true
+		}
+	}
+
+	return found, ok
+}
+
+func buildStreams(declared []Stream) ([]Stream, error) {
+	names := map[string]bool{}
+
+	for _, s := range declared {
+		if s.Name == "" {
+			return nil, fmt.Errorf("stream with prefix %q has no name", s.Prefix)
+		}
+
+		if !strings.HasPrefix(s.Prefix, "/") {
+			return nil, fmt.Errorf("stream %q: prefix %q must start with a slash", s.Name, s.Prefix)
+		}
+
+		if names[s.Name] {
+			return nil, fmt.Errorf("duplicate stream name %q", s.Name)
+		}
+
+		names[s.Name] = true
+	}
+
+	return declared, nil
+}
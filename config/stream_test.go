@@ -0,0 +1,74 @@
+package config
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindStream(t *testing.T) {
+	config := &Config{
+		Streams: []Stream{
+			{Name: "attachments", Prefix: "/api/attachments"},
+			{Name: "uploads", Prefix: "/api/attachments/upload", Methods: []string{"POST"}},
+		},
+	}
+
+	stream, ok := config.FindStream("POST", "/api/attachments/upload")
+	assert.True(t, ok)
+	assert.Equal(t, "uploads", stream.Name)
+
+	stream, ok = config.FindStream("GET", "/api/attachments/upload")
+	assert.True(t, ok)
+	assert.Equal(t, "attachments", stream.Name)
+
+	_, ok = config.FindStream("POST", "/api/wallets")
+	assert.False(t, ok)
+}
+
+func TestLoadRoutesStreams(t *testing.T) {
+	apiUri, _ := url.Parse("http://api:80")
+
+	config := &Config{ApiUrl: "http://api:80", ApiURI: apiUri}
+	config.RoutesFile = writeTempFile(t, "routes.yaml", `
+streams:
+  - name: attachments
+    prefix: /api/attachments
+    methods: [GET, POST]
+`)
+
+	assert.NoError(t, config.LoadRoutes())
+	assert.Equal(t, []Stream{
+		{Name: "attachments", Prefix: "/api/attachments", Methods: []string{"GET", "POST"}},
+	}, config.Streams)
+}
+
+func TestLoadRoutesInvalidStreams(t *testing.T) {
+	apiUri, _ := url.Parse("http://api:80")
+
+	for name, test := range map[string]struct {
+		content string
+		err     string
+	}{
+		"NoName": {
+			content: "streams: [{prefix: /api}]",
+			err:     `stream with prefix "/api" has no name`,
+		},
+		"Prefix": {
+			content: "streams: [{name: api, prefix: api}]",
+			err:     `stream "api": prefix "api" must start with a slash`,
+		},
+		"DuplicateName": {
+			content: "streams: [{name: api, prefix: /api}, {name: api, prefix: /csrf}]",
+			err:     `duplicate stream name "api"`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			config := &Config{ApiUrl: "http://api:80", ApiURI: apiUri}
+			config.RoutesFile = writeTempFile(t, "routes.yaml", test.content)
+
+			assert.ErrorContains(t, config.LoadRoutes(), test.err)
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ValidationError reports one invalid setting, keyed by its environment variable.
+type ValidationError struct {
+	Key     string
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Key, e.Message)
+}
+
+// ValidationErrors lists every invalid setting found by Load, not just the first one.
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	list := make([]string, 0, len(e))
+	for _, err := range e {
+		list = append(list, err.Error())
+	}
+
+	return strings.Join(list, "; ")
+}
+
+func (e *ValidationErrors) add(key string, format string, args ...any) {
+	*e = append(*e, ValidationError{Key: key, Message: fmt.Sprintf(format, args...)})
+}
+
+// parseAbsoluteUrl accepts an http(s) URL with a host. An empty value is only an
+// error when required.
+func parseAbsoluteUrl(errs *ValidationErrors, key, value string, required bool) *url.URL {
+	if value == "" {
+		if required {
+			errs.add(key, "is required")
+		}
+
+		return &url.URL{}
+	}
+
+	u, err := url.Parse(value)
+	if err != nil {
+		errs.add(key, "invalid URL: %v", err)
+
+		return &url.URL{}
+	}
+
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs.add(key, "%q must be an absolute http(s) URL", value)
+	}
+
+	return u
+}
+
+// validateOrigin accepts a bare scheme://host[:port] origin as sent in the Origin header.
+func validateOrigin(origin string) error {
+	u, err := url.Parse(origin)
+	if err != nil || u.Scheme == "" || u.Host == "" || u.Path != "" || u.RawQuery != "" || u.User != nil {
+		return fmt.Errorf("cors allowed origin %q must be scheme://host[:port]", origin)
+	}
+
+	return nil
+}
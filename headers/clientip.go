@@ -55,7 +55,7 @@ func isTrustedPeer(ctx *fasthttp.RequestCtx) bool {
 }
 
 func isTrustedProxy(addr netip.Addr) bool {
-	for _, prefix := range config.Global.TrustedProxies {
+	for _, prefix := range config.Live().TrustedProxies {
 		if prefix.Contains(addr) {
 			return true
 		}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/golang-jwt/jwt/v4"
 	"github.com/valyala/fasthttp"
 	"go.opentelemetry.io/otel/attribute"
 
@@ -22,7 +23,13 @@ type Auth struct {
 	RefreshTokenExpiredAt string `json:"refreshTokenExpiredAt,omitempty"`
 }
 
+// ReadAuthCookie returns the tokens of the request: the ones of the server-side session
+// when session mode resolved one, the token cookies otherwise.
 func ReadAuthCookie(ctx *fasthttp.RequestCtx) Auth {
+	if auth, ok := GetSessionAuth(ctx); ok {
+		return auth
+	}
+
 	auth := Auth{}
 
 	if val := ctx.Request.Header.Cookie(AccessTokenCookieName); val != nil {
@@ -76,6 +83,31 @@ func (a Auth) GetRefreshTokenExpireDate() (time.Time, error) {
 	return t, nil
 }
 
+// GetAccessTokenExpireDate reads the exp claim of the access token. The signature is not
+// verified: the token only ever comes from the API, through the gateway.
+func (a Auth) GetAccessTokenExpireDate() (time.Time, bool) {
+	if a.AccessToken == "" {
+		return time.Time{}, false
+	}
+
+	token, _, err := jwt.NewParser().ParseUnverified(a.AccessToken, jwt.MapClaims{})
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	exp, ok := claims["exp"].(float64)
+	if !ok || exp <= 0 {
+		return time.Time{}, false
+	}
+
+	return time.Unix(int64(exp), 0), true
+}
+
 func (a Auth) GetOpenTelemetryAttributes() []attribute.KeyValue {
 	return []attribute.KeyValue{
 		attribute.Bool(semconv.CashTrackAuthIsLoggedKey, a.IsLogged()),
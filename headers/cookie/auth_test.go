@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/cash-track/gateway/config"
+	"github.com/golang-jwt/jwt/v4"
 	"github.com/stretchr/testify/assert"
 	"github.com/valyala/fasthttp"
 )
@@ -244,3 +245,39 @@ func TestAuth_GetOpenTelemetryAttributes(t *testing.T) {
 		})
 	}
 }
+
+func TestGetAccessTokenExpireDate(t *testing.T) {
+	exp := time.Now().Add(time.Minute).Truncate(time.Second)
+
+	for name, test := range map[string]struct {
+		claims jwt.MapClaims
+		token  string
+		expect time.Time
+		ok     bool
+	}{
+		"OK": {
+			claims: jwt.MapClaims{"sub": 1, "exp": exp.Unix()},
+			expect: exp,
+			ok:     true,
+		},
+		"NoExp": {
+			claims: jwt.MapClaims{"sub": 1},
+		},
+		"NotJwt": {
+			token: "access_token",
+		},
+		"Empty": {},
+	} {
+		t.Run(name, func(t *testing.T) {
+			token := test.token
+			if test.claims != nil {
+				token, _ = jwt.NewWithClaims(jwt.SigningMethodHS256, test.claims).SignedString([]byte("asd"))
+			}
+
+			expireAt, ok := Auth{AccessToken: token}.GetAccessTokenExpireDate()
+
+			assert.Equal(t, test.ok, ok)
+			assert.True(t, test.expect.Equal(expireAt))
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package cookie
+
+import (
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+const SessionCookieName = "cshtrks"
+
+// sessionAuthUserValue carries the Auth of a server-side session once resolved, so that
+// ReadAuthCookie returns it instead of the token cookies, which session mode never sets.
+const sessionAuthUserValue = "cookie.sessionAuth"
+
+func ReadSessionCookie(ctx *fasthttp.RequestCtx) string {
+	return string(ctx.Request.Header.Cookie(SessionCookieName))
+}
+
+// WriteSessionCookie sets the opaque session ID, or deletes the cookie when id is empty.
+func WriteSessionCookie(ctx *fasthttp.RequestCtx, id string, expire time.Time) {
+	if id == "" {
+		ctx.Response.Header.SetCookie(newCookie(SessionCookieName, "", fasthttp.CookieExpireDelete))
+
+		return
+	}
+
+	ctx.Response.Header.SetCookie(newCookie(SessionCookieName, id, expire))
+}
+
+// SetSessionAuth makes auth the one ReadAuthCookie returns for the rest of the request.
+func SetSessionAuth(ctx *fasthttp.RequestCtx, auth Auth) {
+	ctx.SetUserValue(sessionAuthUserValue, auth)
+}
+
+// GetSessionAuth returns the Auth set by SetSessionAuth, if any.
+func GetSessionAuth(ctx *fasthttp.RequestCtx) (Auth, bool) {
+	auth, ok := ctx.UserValue(sessionAuthUserValue).(Auth)
+
+	return auth, ok
+}
@@ -0,0 +1,67 @@
+package cookie
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+
+	"github.com/cash-track/gateway/config"
+)
+
+func TestReadAuthCookiePrefersSessionAuth(t *testing.T) {
+	ctx := fasthttp.RequestCtx{}
+	ctx.Request.Header.SetCookie(AccessTokenCookieName, "cookie_token")
+
+	SetSessionAuth(&ctx, Auth{AccessToken: "session_token", RefreshToken: "session_refresh"})
+
+	auth := ReadAuthCookie(&ctx)
+
+	assert.Equal(t, "session_token", auth.AccessToken)
+	assert.Equal(t, "session_refresh", auth.RefreshToken)
+}
+
+// A resolved guest session must not fall back to token cookies left from cookie mode.
+func TestReadAuthCookieSessionGuestIgnoresTokenCookies(t *testing.T) {
+	ctx := fasthttp.RequestCtx{}
+	ctx.Request.Header.SetCookie(AccessTokenCookieName, "cookie_token")
+
+	SetSessionAuth(&ctx, Auth{})
+
+	assert.False(t, ReadAuthCookie(&ctx).IsLogged())
+}
+
+func TestWriteSessionCookie(t *testing.T) {
+	config.Global.CookieDomain = "test.domain.com"
+	config.Global.CookieSecure = true
+	tomorrow := time.Now().Add(24 * time.Hour)
+
+	ctx := fasthttp.RequestCtx{}
+	WriteSessionCookie(&ctx, "session_id", tomorrow)
+
+	c := fasthttp.Cookie{}
+	c.SetKey(SessionCookieName)
+	assert.True(t, ctx.Response.Header.Cookie(&c))
+	assert.Equal(t, "session_id", string(c.Value()))
+	assert.Equal(t, tomorrow.Unix(), c.Expire().Unix())
+	assert.True(t, c.HTTPOnly())
+	assert.True(t, c.Secure())
+
+	ctx = fasthttp.RequestCtx{}
+	WriteSessionCookie(&ctx, "", time.Time{})
+
+	c = fasthttp.Cookie{}
+	c.SetKey(SessionCookieName)
+	assert.True(t, ctx.Response.Header.Cookie(&c))
+	assert.Empty(t, string(c.Value()))
+	assert.Equal(t, fasthttp.CookieExpireDelete.Unix(), c.Expire().Unix())
+}
+
+func TestReadSessionCookie(t *testing.T) {
+	ctx := fasthttp.RequestCtx{}
+	assert.Empty(t, ReadSessionCookie(&ctx))
+
+	ctx.Request.Header.SetCookie(SessionCookieName, "session_id")
+	assert.Equal(t, "session_id", ReadSessionCookie(&ctx))
+}
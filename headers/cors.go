@@ -29,6 +29,8 @@ var (
 		XCtGatewaySha,
 		XCtApiVersion,
 		XCtApiSha,
+		ETag,
+		IdempotentReplayed,
 	}
 	// healthPaths lists probe endpoints excluded from CORS and security response headers.
 	healthPaths = map[string]bool{
@@ -78,7 +80,7 @@ func writePreflightResponse(ctx *fasthttp.RequestCtx) {
 	ctx.Response.SetStatusCode(fasthttp.StatusNoContent)
 
 	origin := requestOrigin(ctx)
-	allowed := config.Global.CorsAllowedOrigins[origin]
+	allowed := config.Live().CorsAllowedOrigins[origin]
 
 	debugCorsOrigin(ctx, "preflight", origin, allowed)
 
@@ -103,7 +105,7 @@ func isAllowedOrigin(ctx *fasthttp.RequestCtx) bool {
 	}
 
 	origin := requestOrigin(ctx)
-	allowed := config.Global.CorsAllowedOrigins[origin]
+	allowed := config.Live().CorsAllowedOrigins[origin]
 
 	debugCorsOrigin(ctx, "actual", origin, allowed)
 
@@ -120,6 +122,11 @@ func writeActualCorsHeaders(ctx *fasthttp.RequestCtx) {
 	ctx.Response.Header.Set(AccessControlExposeHeaders, strings.Join(CorsExposedHeaders, ","))
 }
 
+// IsOriginAllowed reports whether the Origin header of ctx is in the allow-list.
+func IsOriginAllowed(ctx *fasthttp.RequestCtx) bool {
+	return config.Live().CorsAllowedOrigins[requestOrigin(ctx)]
+}
+
 func requestOrigin(ctx *fasthttp.RequestCtx) string {
 	return strings.ToLower(string(ctx.Request.Header.Peek(Origin)))
 }
@@ -0,0 +1,77 @@
+package headers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+)
+
+// SetETag gives a 200 answer to a GET request without ETag a weak one hashing its body,
+// unless it is streamed or its Cache-Control forbids storing it.
+func SetETag(ctx *fasthttp.RequestCtx) {
+	if !ctx.IsGet() || ctx.Response.StatusCode() != fasthttp.StatusOK || ctx.Response.IsBodyStream() {
+		return
+	}
+
+	if len(ctx.Response.Header.Peek(ETag)) > 0 {
+		return
+	}
+
+	for _, directive := range SplitList(ctx.Response.Header.Peek(CacheControl)) {
+		if strings.EqualFold(directive, "no-store") {
+			return
+		}
+	}
+
+	ctx.Response.Header.Set(ETag, BodyETag(ctx.Response.Body()))
+}
+
+// BodyETag is the weak ETag of body: the same body is sent gzip, brotli or identity
+// encoded with GATEWAY_COMPRESS, byte for byte different answers a strong one cannot name.
+func BodyETag(body []byte) string {
+	sum := sha256.Sum256(body)
+
+	return `W/"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// NotModified turns a 200 answer into a 304 without body when If-None-Match of the request
+// names its ETag.
+func NotModified(ctx *fasthttp.RequestCtx) {
+	if ctx.Response.StatusCode() != fasthttp.StatusOK || ctx.Response.IsBodyStream() {
+		return
+	}
+
+	etag := ctx.Response.Header.Peek(ETag)
+	if len(etag) == 0 || !MatchesETag(ctx.Request.Header.Peek(IfNoneMatch), etag) {
+		return
+	}
+
+	ctx.SetStatusCode(fasthttp.StatusNotModified)
+	ctx.Response.ResetBody()
+}
+
+// MatchesETag compares weakly, as If-None-Match does.
+func MatchesETag(ifNoneMatch, etag []byte) bool {
+	for _, candidate := range SplitList(ifNoneMatch) {
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(string(etag), "W/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SplitList returns the trimmed, non-empty items of a comma separated header value.
+func SplitList(value []byte) []string {
+	list := make([]string, 0)
+
+	for _, v := range strings.Split(string(value), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+
+	return list
+}
@@ -0,0 +1,43 @@
+package headers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+)
+
+func TestBodyETag(t *testing.T) {
+	assert.Equal(t, BodyETag([]byte("a")), BodyETag([]byte("a")))
+	assert.NotEqual(t, BodyETag([]byte("a")), BodyETag([]byte("b")))
+	assert.Regexp(t, `^W/"[0-9a-f]{32}"$`, BodyETag(nil))
+}
+
+func TestSetETagSkipsErrors(t *testing.T) {
+	ctx := &fasthttp.RequestCtx{}
+	ctx.SetStatusCode(fasthttp.StatusNotFound)
+	ctx.SetBodyString("not found")
+
+	SetETag(ctx)
+
+	assert.Empty(t, ctx.Response.Header.Peek(ETag))
+}
+
+func TestMatchesETag(t *testing.T) {
+	for name, test := range map[string]struct {
+		ifNoneMatch string
+		etag        string
+		expected    bool
+	}{
+		"Same":     {ifNoneMatch: `"v1"`, etag: `"v1"`, expected: true},
+		"Weak":     {ifNoneMatch: `W/"v1"`, etag: `"v1"`, expected: true},
+		"List":     {ifNoneMatch: `"v0", "v1"`, etag: `"v1"`, expected: true},
+		"Any":      {ifNoneMatch: "*", etag: `"v1"`, expected: true},
+		"Other":    {ifNoneMatch: `"v0"`, etag: `"v1"`},
+		"NoHeader": {etag: `"v1"`},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.expected, MatchesETag([]byte(test.ifNoneMatch), []byte(test.etag)))
+		})
+	}
+}
@@ -9,6 +9,7 @@ import (
 
 const (
 	Accept                        = "Accept"
+	AcceptEncoding                = "Accept-Encoding"
 	AcceptLanguage                = "Accept-Language"
 	AccessControlAllowOrigin      = "Access-Control-Allow-Origin"
 	AccessControlAllowMethods     = "Access-Control-Allow-Methods"
@@ -18,15 +19,31 @@ const (
 	AccessControlRequestMethod    = "Access-Control-Request-Method"
 	AccessControlRequestHeaders   = "Access-Control-Request-Headers"
 	AccessControlMaxAge           = "Access-Control-Max-Age"
+	Age                           = "Age"
+	Allow                         = "Allow"
 	Authorization                 = "Authorization"
+	CacheControl                  = "Cache-Control"
 	CfConnectingIP                = "Cf-Connecting-IP"
+	Connection                    = "Connection"
+	ContentDisposition            = "Content-Disposition"
+	ContentLanguage               = "Content-Language"
 	ContentSecurityPolicy         = "Content-Security-Policy"
 	ContentType                   = "Content-Type"
+	ETag                          = "ETag"
+	IdempotencyKey                = "Idempotency-Key"
+	IdempotentReplayed            = "Idempotent-Replayed"
+	IfNoneMatch                   = "If-None-Match"
+	LastEventId                   = "Last-Event-Id"
 	Origin                        = "Origin"
 	Referer                       = "Referer"
 	ReferrerPolicy                = "Referrer-Policy"
 	RetryAfter                    = "Retry-After"
+	SecWebSocketExtensions        = "Sec-Websocket-Extensions"
+	SecWebSocketKey               = "Sec-Websocket-Key"
+	SecWebSocketProtocol          = "Sec-Websocket-Protocol"
+	SecWebSocketVersion           = "Sec-Websocket-Version"
 	StrictTransportSecurity       = "Strict-Transport-Security"
+	Upgrade                       = "Upgrade"
 	UserAgent                     = "User-Agent"
 	Vary                          = "Vary"
 	XContentTypeOptions           = "X-Content-Type-Options"
@@ -45,9 +62,11 @@ const (
 )
 
 var (
-	multipleSep     = []byte(", ")
-	ContentTypeJson = []byte("application/json")
-	ContentTypeForm = []byte("application/x-www-form-urlencoded")
+	multipleSep            = []byte(", ")
+	ContentTypeJson        = []byte("application/json")
+	ContentTypeEventStream = []byte("text/event-stream")
+	ContentTypeProblemJson = []byte("application/problem+json")
+	ContentTypeForm        = []byte("application/x-www-form-urlencoded")
 
 	// A list of headers which will always be overwritten if an attempt to write new value
 	// occurs when other value already exists.
@@ -0,0 +1,39 @@
+package headers
+
+import (
+	"bytes"
+
+	"github.com/valyala/fasthttp"
+)
+
+var upstreamVaryUserValue = []byte("UpstreamVary")
+
+// KeepUpstreamVary keeps the Vary of an upstream answer for the response cache. It is not
+// relayed to the client, CorsHandler alone decides the Vary of the answer.
+func KeepUpstreamVary(ctx *fasthttp.RequestCtx, resp *fasthttp.Response) {
+	if vary := resp.Header.Peek(Vary); len(vary) > 0 {
+		ctx.SetUserValueBytes(upstreamVaryUserValue, bytes.Clone(vary))
+	}
+}
+
+// CompressHandler compresses the answers of h for the clients accepting it, see
+// fasthttp.CompressHandler, and adds Accept-Encoding to the Vary of every answer: one
+// left uncompressed for a client is not the answer for the others.
+func CompressHandler(h fasthttp.RequestHandler) fasthttp.RequestHandler {
+	compress := fasthttp.CompressHandler(h)
+
+	return func(ctx *fasthttp.RequestCtx) {
+		compress(ctx)
+
+		if !ctx.Hijacked() {
+			addVary(&ctx.Response.Header, AcceptEncoding)
+		}
+	}
+}
+
+// UpstreamVary returns the Vary of the upstream answer to the request, nil without one.
+func UpstreamVary(ctx *fasthttp.RequestCtx) []byte {
+	vary, _ := ctx.UserValueBytes(upstreamVaryUserValue).([]byte)
+
+	return vary
+}
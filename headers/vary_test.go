@@ -0,0 +1,51 @@
+package headers
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+)
+
+func TestKeepUpstreamVary(t *testing.T) {
+	ctx := &fasthttp.RequestCtx{}
+	assert.Nil(t, UpstreamVary(ctx))
+
+	resp := &fasthttp.Response{}
+	KeepUpstreamVary(ctx, resp)
+	assert.Nil(t, UpstreamVary(ctx))
+
+	resp.Header.Set(Vary, "Accept-Language")
+	KeepUpstreamVary(ctx, resp)
+	resp.Header.Set(Vary, "Origin")
+
+	assert.Equal(t, "Accept-Language", string(UpstreamVary(ctx)))
+	assert.Empty(t, ctx.Response.Header.Peek(Vary))
+}
+
+func TestCompressHandler(t *testing.T) {
+	h := CompressHandler(func(ctx *fasthttp.RequestCtx) {
+		ctx.SetContentType("application/json")
+		ctx.SetBodyString(`{"data":"` + strings.Repeat("a", 1024) + `"}`)
+		ctx.Response.Header.Set(Vary, "Origin")
+	})
+
+	for name, test := range map[string]struct {
+		acceptEncoding  string
+		contentEncoding string
+	}{
+		"Identity": {},
+		"Gzip":     {acceptEncoding: "gzip", contentEncoding: "gzip"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctx := &fasthttp.RequestCtx{}
+			ctx.Request.Header.Set(AcceptEncoding, test.acceptEncoding)
+
+			h(ctx)
+
+			assert.Equal(t, test.contentEncoding, string(ctx.Response.Header.ContentEncoding()))
+			assert.Equal(t, []string{"Origin", "Accept-Encoding"}, SplitList(ctx.Response.Header.Peek(Vary)))
+		})
+	}
+}
@@ -1,14 +1,32 @@
 package retryhttp
 
 import (
+	"fmt"
 	"log/slog"
-	"strings"
+	"time"
 
-	"github.com/cash-track/gateway/http"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/valyala/fasthttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/cash-track/gateway/http"
+	"github.com/cash-track/gateway/traces"
 )
 
-const defaultRetryAttempts = 1
+const (
+	defaultRetryAttempts = 1
+	metricsNamespace     = "gateway"
+	metricsRetrySubsys   = "http_retry"
+)
+
+var retryAttemptsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: metricsNamespace,
+	Subsystem: metricsRetrySubsys,
+	Name:      "attempts_total",
+	Help:      "Failed outgoing request attempts by host, reason and outcome: retried or given up.",
+}, []string{"host", "reason", "outcome"})
 
 type Client interface {
 	http.Client
@@ -20,12 +38,15 @@ type FastHttpRetryClient struct {
 	http.Client
 
 	attempts uint
+	policy   Policy
+	sleep    func(time.Duration)
 }
 
 func NewFastHttpRetryClient() Client {
 	return &FastHttpRetryClient{
 		Client:   http.NewFastHttpClient(),
 		attempts: defaultRetryAttempts,
+		policy:   DefaultPolicy(),
 	}
 }
 
@@ -33,16 +54,54 @@ func (c *FastHttpRetryClient) Do(req *fasthttp.Request, resp *fasthttp.Response)
 	return c.DoWithRetry(req, resp, c.attempts)
 }
 
+// DoWithRetry makes up to attempts attempts of req as the policy allows: retryable
+// failures of a request that can be sent again are retried after a backoff, within the
+// budget of the policy. Each retry is an event of a span joining the trace of req.
 func (c *FastHttpRetryClient) DoWithRetry(req *fasthttp.Request, resp *fasthttp.Response, attempts uint) error {
-	err := c.Client.Do(req, resp)
+	policy := c.policy.orDefault()
+	start := time.Now()
 
-	if attempts == 1 || err == nil || !strings.Contains(err.Error(), "broken pipe") {
-		return err
-	}
+	var span trace.Span
+
+	for attempt := uint(1); ; attempt++ {
+		err := c.Client.Do(req, resp)
+
+		reason := policy.Reason(resp, err)
+		if reason == "" {
+			endRetrySpan(span, attempt, err)
+
+			return err
+		}
+
+		host := string(req.URI().Host())
+		delay := policy.Backoff(attempt)
+
+		if attempt >= attempts || !policy.Retryable(req) || time.Since(start)+delay > policy.Budget {
+			retryAttemptsTotal.WithLabelValues(host, reason, "given_up").Inc()
+			endRetrySpan(span, attempt, err)
+
+			return err
+		}
 
-	slog.Warn("retrying request due to an error", "attempt", attempts, "error", err)
+		retryAttemptsTotal.WithLabelValues(host, reason, "retried").Inc()
+		slog.Warn("retrying request", "host", host, "attempt", attempt, "reason", reason, "delay", delay, "error", err)
 
-	return c.DoWithRetry(req, resp, attempts-1)
+		if span == nil {
+			_, span = traces.GetTracer().Start(
+				traces.ExtractContextFromRequest(req),
+				fmt.Sprintf("retry %s %s", req.Header.Method(), req.URI().PathOriginal()),
+			)
+		}
+		span.AddEvent("retry", trace.WithAttributes(
+			attribute.Int("retry.attempt", int(attempt)),
+			attribute.String("retry.reason", reason),
+			attribute.Int64("retry.delay_ms", delay.Milliseconds()),
+		))
+
+		// a streamed answer left unread would hold its connection
+		_ = resp.CloseBodyStream()
+		c.wait(delay)
+	}
 }
 
 func (c *FastHttpRetryClient) WithRetryAttempts(attempts uint) Client {
@@ -50,3 +109,25 @@ func (c *FastHttpRetryClient) WithRetryAttempts(attempts uint) Client {
 
 	return c
 }
+
+func (c *FastHttpRetryClient) wait(delay time.Duration) {
+	if c.sleep != nil {
+		c.sleep(delay)
+
+		return
+	}
+
+	time.Sleep(delay)
+}
+
+func endRetrySpan(span trace.Span, attempts uint, err error) {
+	if span == nil {
+		return
+	}
+
+	span.SetAttributes(attribute.Int("retry.attempts", int(attempts)))
+	if err != nil {
+		span.RecordError(err)
+	}
+	span.End()
+}
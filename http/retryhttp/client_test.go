@@ -3,6 +3,7 @@ package retryhttp
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/valyala/fasthttp"
@@ -32,6 +33,111 @@ func TestDoWithRetry(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestDoWithRetryPolicy(t *testing.T) {
+	for name, test := range map[string]struct {
+		method        string
+		errors        []error
+		statuses      []int
+		attempts      uint
+		budget        time.Duration
+		expectCalls   int
+		expectStatus  int
+		expectedError bool
+	}{
+		"Success": {
+			method:       fasthttp.MethodGet,
+			statuses:     []int{fasthttp.StatusOK},
+			attempts:     3,
+			expectCalls:  1,
+			expectStatus: fasthttp.StatusOK,
+		},
+		"RecoversFromStatus": {
+			method:       fasthttp.MethodGet,
+			statuses:     []int{fasthttp.StatusServiceUnavailable, fasthttp.StatusBadGateway, fasthttp.StatusOK},
+			attempts:     3,
+			expectCalls:  3,
+			expectStatus: fasthttp.StatusOK,
+		},
+		"RecoversFromError": {
+			method:       fasthttp.MethodDelete,
+			errors:       []error{fasthttp.ErrConnectionClosed, nil},
+			statuses:     []int{0, fasthttp.StatusNoContent},
+			attempts:     3,
+			expectCalls:  2,
+			expectStatus: fasthttp.StatusNoContent,
+		},
+		"Exhausted": {
+			method:       fasthttp.MethodGet,
+			statuses:     []int{fasthttp.StatusGatewayTimeout, fasthttp.StatusGatewayTimeout},
+			attempts:     2,
+			expectCalls:  2,
+			expectStatus: fasthttp.StatusGatewayTimeout,
+		},
+		"NotIdempotent": {
+			method:        fasthttp.MethodPost,
+			errors:        []error{fasthttp.ErrTimeout},
+			attempts:      3,
+			expectCalls:   1,
+			expectedError: true,
+		},
+		"NotRetryable": {
+			method:       fasthttp.MethodGet,
+			statuses:     []int{fasthttp.StatusInternalServerError},
+			attempts:     3,
+			expectCalls:  1,
+			expectStatus: fasthttp.StatusInternalServerError,
+		},
+		"OverBudget": {
+			method:        fasthttp.MethodGet,
+			errors:        []error{fasthttp.ErrTimeout},
+			attempts:      3,
+			budget:        time.Nanosecond,
+			expectCalls:   1,
+			expectedError: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			c := httpmock.NewClientMock(ctrl)
+
+			calls := 0
+			c.EXPECT().Do(gomock.Any(), gomock.Any()).Times(test.expectCalls).DoAndReturn(func(_ *fasthttp.Request, resp *fasthttp.Response) error {
+				defer func() { calls++ }()
+
+				if calls < len(test.statuses) && test.statuses[calls] > 0 {
+					resp.SetStatusCode(test.statuses[calls])
+				}
+
+				if calls < len(test.errors) {
+					return test.errors[calls]
+				}
+
+				return nil
+			})
+
+			var delays []time.Duration
+			client := FastHttpRetryClient{
+				Client: c,
+				policy: Policy{Budget: test.budget},
+				sleep:  func(d time.Duration) { delays = append(delays, d) },
+			}
+
+			req := &fasthttp.Request{}
+			req.Header.SetMethod(test.method)
+			req.SetRequestURI("http://api.test.com/v1/wallets")
+			resp := &fasthttp.Response{}
+
+			err := client.DoWithRetry(req, resp, test.attempts)
+
+			assert.Equal(t, test.expectedError, err != nil)
+			assert.Len(t, delays, test.expectCalls-1)
+			if !test.expectedError {
+				assert.Equal(t, test.expectStatus, resp.StatusCode())
+			}
+		})
+	}
+}
+
 func TestWithRetryAttempts(t *testing.T) {
 	client := FastHttpRetryClient{
 		Client: &http.FastHttpClient{},
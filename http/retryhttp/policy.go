@@ -0,0 +1,132 @@
+package retryhttp
+
+import (
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/valyala/fasthttp"
+
+	"github.com/cash-track/gateway/headers"
+)
+
+const (
+	defaultBaseDelay = 50 * time.Millisecond
+	defaultMaxDelay  = time.Second
+	defaultBudget    = 10 * time.Second
+)
+
+// idempotentMethods can be sent again without changing the outcome on the upstream.
+var idempotentMethods = map[string]bool{
+	fasthttp.MethodGet:     true,
+	fasthttp.MethodHead:    true,
+	fasthttp.MethodOptions: true,
+	fasthttp.MethodTrace:   true,
+	fasthttp.MethodPut:     true,
+	fasthttp.MethodDelete:  true,
+}
+
+// Policy decides whether and when a failed attempt is made again. The zero fields fall
+// back to the defaults of DefaultPolicy.
+type Policy struct {
+	// BaseDelay is the backoff ceiling of the first retry, doubled on every next one up
+	// to MaxDelay. The actual delay is picked at random below the ceiling.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// Budget caps the time of a request with all its attempts: no retry starts once its
+	// backoff would end past it.
+	Budget time.Duration
+	// Statuses are the answers worth another attempt, the upstream did not process them.
+	Statuses map[int]bool
+}
+
+func DefaultPolicy() Policy {
+	return Policy{
+		BaseDelay: defaultBaseDelay,
+		MaxDelay:  defaultMaxDelay,
+		Budget:    defaultBudget,
+		Statuses: map[int]bool{
+			fasthttp.StatusBadGateway:         true,
+			fasthttp.StatusServiceUnavailable: true,
+			fasthttp.StatusGatewayTimeout:     true,
+		},
+	}
+}
+
+func (p Policy) orDefault() Policy {
+	def := DefaultPolicy()
+
+	if p.BaseDelay <= 0 {
+		p.BaseDelay = def.BaseDelay
+	}
+
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = def.MaxDelay
+	}
+
+	if p.Budget <= 0 {
+		p.Budget = def.Budget
+	}
+
+	if p.Statuses == nil {
+		p.Statuses = def.Statuses
+	}
+
+	return p
+}
+
+// Retryable tells whether req may be sent again: its method is idempotent or it carries
+// an Idempotency-Key, and its body is not streamed, which is read once.
+func (p Policy) Retryable(req *fasthttp.Request) bool {
+	if req.IsBodyStream() {
+		return false
+	}
+
+	return idempotentMethods[string(req.Header.Method())] || len(req.Header.Peek(headers.IdempotencyKey)) > 0
+}
+
+// Reason classifies the outcome of an attempt worth retrying, empty when it is not: the
+// upstream failed to answer, or answered with one of Statuses.
+func (p Policy) Reason(resp *fasthttp.Response, err error) string {
+	if err == nil {
+		if p.Statuses[resp.StatusCode()] {
+			return "status_" + strconv.Itoa(resp.StatusCode())
+		}
+
+		return ""
+	}
+
+	var netErr net.Error
+
+	switch {
+	case errors.Is(err, syscall.ECONNRESET):
+		return "connection_reset"
+	case errors.Is(err, syscall.EPIPE) || strings.Contains(err.Error(), "broken pipe"):
+		return "broken_pipe"
+	case errors.Is(err, fasthttp.ErrConnectionClosed) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF):
+		// an idle keep-alive connection closed by the upstream
+		return "connection_closed"
+	case errors.Is(err, fasthttp.ErrTimeout) || errors.Is(err, fasthttp.ErrDialTimeout) ||
+		errors.Is(err, os.ErrDeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()):
+		return "timeout"
+	}
+
+	return ""
+}
+
+// Backoff is the delay before the retry following attempt, counted from 1: full jitter
+// below an exponential ceiling.
+func (p Policy) Backoff(attempt uint) time.Duration {
+	ceiling := p.MaxDelay
+	if shift := attempt - 1; shift < 32 && p.BaseDelay<<shift < p.MaxDelay {
+		ceiling = p.BaseDelay << shift
+	}
+
+	return rand.N(ceiling + 1)
+}
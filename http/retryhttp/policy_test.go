@@ -0,0 +1,92 @@
+package retryhttp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+
+	"github.com/cash-track/gateway/headers"
+)
+
+func TestPolicyRetryable(t *testing.T) {
+	for name, test := range map[string]struct {
+		method         string
+		idempotencyKey string
+		stream         bool
+		expected       bool
+	}{
+		"Get":            {method: fasthttp.MethodGet, expected: true},
+		"Put":            {method: fasthttp.MethodPut, expected: true},
+		"Delete":         {method: fasthttp.MethodDelete, expected: true},
+		"Post":           {method: fasthttp.MethodPost},
+		"Patch":          {method: fasthttp.MethodPatch},
+		"IdempotencyKey": {method: fasthttp.MethodPost, idempotencyKey: "c0ffee", expected: true},
+		"Streamed":       {method: fasthttp.MethodPut, stream: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			req := &fasthttp.Request{}
+			req.Header.SetMethod(test.method)
+			if test.idempotencyKey != "" {
+				req.Header.Set(headers.IdempotencyKey, test.idempotencyKey)
+			}
+			if test.stream {
+				req.SetBodyStream(io.NopCloser(nil), -1)
+			}
+
+			assert.Equal(t, test.expected, DefaultPolicy().Retryable(req))
+		})
+	}
+}
+
+func TestPolicyReason(t *testing.T) {
+	for name, test := range map[string]struct {
+		status   int
+		err      error
+		expected string
+	}{
+		"Ok":              {status: fasthttp.StatusOK},
+		"ServerError":     {status: fasthttp.StatusInternalServerError},
+		"BadGateway":      {status: fasthttp.StatusBadGateway, expected: "status_502"},
+		"Unavailable":     {status: fasthttp.StatusServiceUnavailable, expected: "status_503"},
+		"GatewayTimeout":  {status: fasthttp.StatusGatewayTimeout, expected: "status_504"},
+		"Reset":           {err: fmt.Errorf("read: %w", syscall.ECONNRESET), expected: "connection_reset"},
+		"BrokenPipe":      {err: errors.New("write: broken pipe"), expected: "broken_pipe"},
+		"IdleClosed":      {err: fasthttp.ErrConnectionClosed, expected: "connection_closed"},
+		"EOF":             {err: io.EOF, expected: "connection_closed"},
+		"Timeout":         {err: fasthttp.ErrTimeout, expected: "timeout"},
+		"DialTimeout":     {err: fasthttp.ErrDialTimeout, expected: "timeout"},
+		"NoFreeConns":     {err: fasthttp.ErrNoFreeConns},
+		"ConnectionError": {err: errors.New("dial tcp: lookup api: no such host")},
+	} {
+		t.Run(name, func(t *testing.T) {
+			resp := &fasthttp.Response{}
+			resp.SetStatusCode(test.status)
+
+			assert.Equal(t, test.expected, DefaultPolicy().Reason(resp, test.err))
+		})
+	}
+}
+
+func TestPolicyBackoff(t *testing.T) {
+	policy := Policy{BaseDelay: 10 * time.Millisecond, MaxDelay: 50 * time.Millisecond}
+
+	for attempt, ceiling := range map[uint]time.Duration{
+		1:  10 * time.Millisecond,
+		2:  20 * time.Millisecond,
+		3:  40 * time.Millisecond,
+		4:  50 * time.Millisecond,
+		40: 50 * time.Millisecond,
+	} {
+		for range 20 {
+			delay := policy.Backoff(attempt)
+			assert.GreaterOrEqual(t, delay, time.Duration(0))
+			assert.LessOrEqual(t, delay, ceiling)
+		}
+	}
+}
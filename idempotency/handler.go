@@ -0,0 +1,224 @@
+package idempotency
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/valyala/fasthttp"
+
+	"github.com/cash-track/gateway/config"
+	"github.com/cash-track/gateway/headers"
+	bodyHandler "github.com/cash-track/gateway/router/body"
+	"github.com/cash-track/gateway/router/response"
+	"github.com/cash-track/gateway/session"
+	"github.com/cash-track/gateway/token"
+	"github.com/cash-track/gateway/traces"
+)
+
+const (
+	metricsNamespace         = "gateway"
+	metricsIdempotencySubsys = "idempotency"
+	maxKeyLength             = 255
+)
+
+var idempotencyRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: metricsNamespace,
+	Subsystem: metricsIdempotencySubsys,
+	Name:      "requests_total",
+	Help:      "Requests with an Idempotency-Key by result: forwarded, replayed, in_flight or reused.",
+}, []string{"result"})
+
+var idempotencyStoreErrorsTotal = promauto.NewCounter(prometheus.CounterOpts{
+	Namespace: metricsNamespace,
+	Subsystem: metricsIdempotencySubsys,
+	Name:      "store_errors_total",
+	Help:      "Idempotency store operations that failed, the requests were forwarded without deduplication.",
+})
+
+var methods = map[string]bool{
+	fasthttp.MethodPost:  true,
+	fasthttp.MethodPut:   true,
+	fasthttp.MethodPatch: true,
+}
+
+// storedHeaders are replayed with the answer. The others are specific to the first
+// request, like Set-Cookie, or set by the gateway on every answer.
+var storedHeaders = []string{
+	headers.CacheControl,
+	headers.ContentDisposition,
+	headers.ContentType,
+	headers.ETag,
+	headers.XCtApiSha,
+	headers.XCtApiVersion,
+}
+
+type Handler struct {
+	store Store
+}
+
+func NewHandler(store Store) *Handler {
+	return &Handler{store: store}
+}
+
+// Handler forwards a POST, PUT or PATCH with an Idempotency-Key once per user and key: its
+// answer is replayed to the duplicates for config.Global.IdempotencyTTL, duplicates
+// arriving while it is forwarded get a 409 and the key reused for another request a 422.
+// Answers of the upstream failing (5xx) are not kept, for the request to be sent again.
+// Requests without a verified user or server-side session, see scope, and streamed bodies
+// are forwarded as usual.
+func (i *Handler) Handler(h fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		key := ctx.Request.Header.Peek(headers.IdempotencyKey)
+		if len(key) == 0 || !methods[string(ctx.Method())] {
+			h(ctx)
+
+			return
+		}
+
+		if !validKey(key) {
+			response.New(response.CodeIdempotencyInvalid).Write(ctx)
+
+			return
+		}
+
+		scope, ok := scope(ctx)
+		if !ok {
+			h(ctx)
+
+			return
+		}
+
+		if _, streamed := bodyHandler.Stream(ctx); streamed {
+			h(ctx)
+
+			return
+		}
+
+		parent := traces.FindParentContext(ctx)
+		storeKey := scope + ":" + hash(key)
+		bodyHash := requestHash(ctx)
+
+		record, claimed, err := i.store.Claim(parent, storeKey, bodyHash)
+		if err != nil {
+			i.storeFailed(ctx, err)
+			h(ctx)
+
+			return
+		}
+
+		if !claimed {
+			i.answerDuplicate(ctx, record, bodyHash)
+
+			return
+		}
+
+		idempotencyRequestsTotal.WithLabelValues("forwarded").Inc()
+		h(ctx)
+
+		if ctx.Response.StatusCode() >= fasthttp.StatusInternalServerError || ctx.Response.IsBodyStream() || ctx.Hijacked() {
+			if err := i.store.Release(parent, storeKey); err != nil {
+				i.storeFailed(ctx, err)
+			}
+
+			return
+		}
+
+		if err := i.store.Finish(parent, storeKey, newRecord(ctx, bodyHash), config.Global.IdempotencyTTL); err != nil {
+			i.storeFailed(ctx, err)
+		}
+	}
+}
+
+// scope returns whom the keys of the request belong to: the subject of its verified access
+// token, else its server-side session. The subject of an unverified token is not used, a
+// forged one would get the answers of another user replayed.
+func scope(ctx *fasthttp.RequestCtx) (string, bool) {
+	if claims, ok := token.ClaimsFromContext(ctx); ok && claims.UserId != "" {
+		return "user:" + claims.UserId, true
+	}
+
+	if id, ok := session.Id(ctx); ok {
+		return "session:" + hash([]byte(id)), true
+	}
+
+	return "", false
+}
+
+func (i *Handler) answerDuplicate(ctx *fasthttp.RequestCtx, record Record, bodyHash string) {
+	switch {
+	case record.BodyHash != bodyHash:
+		idempotencyRequestsTotal.WithLabelValues("reused").Inc()
+		response.New(response.CodeIdempotencyReused).Write(ctx)
+	case !record.Done:
+		idempotencyRequestsTotal.WithLabelValues("in_flight").Inc()
+		response.New(response.CodeIdempotencyInFlight).Write(ctx)
+	default:
+		idempotencyRequestsTotal.WithLabelValues("replayed").Inc()
+		ctx.SetStatusCode(record.Status)
+		for name, value := range record.Header {
+			ctx.Response.Header.Set(name, value)
+		}
+		ctx.Response.Header.Set(headers.IdempotentReplayed, "true")
+		ctx.SetBody(record.Body)
+	}
+}
+
+func (i *Handler) storeFailed(ctx *fasthttp.RequestCtx, err error) {
+	idempotencyStoreErrorsTotal.Inc()
+	slog.Warn("idempotency store unreachable, forwarding", "trace_id", traces.FindTraceId(ctx), "error", err)
+}
+
+func newRecord(ctx *fasthttp.RequestCtx, bodyHash string) Record {
+	record := Record{
+		BodyHash: bodyHash,
+		Done:     true,
+		Status:   ctx.Response.StatusCode(),
+		Header:   make(map[string]string),
+		Body:     bytes.Clone(ctx.Response.Body()),
+	}
+
+	for _, name := range storedHeaders {
+		if value := ctx.Response.Header.Peek(name); len(value) > 0 {
+			record.Header[name] = string(value)
+		}
+	}
+
+	return record
+}
+
+// validKey accepts 1 to 255 visible ASCII characters.
+func validKey(key []byte) bool {
+	if len(key) > maxKeyLength {
+		return false
+	}
+
+	for _, c := range key {
+		if c < '!' || c > '~' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// requestHash tells a duplicate from another request reusing its key.
+func requestHash(ctx *fasthttp.RequestCtx) string {
+	h := sha256.New()
+	for _, part := range [][]byte{ctx.Method(), ctx.Path(), ctx.URI().QueryString(), ctx.Request.Body()} {
+		_, _ = h.Write([]byte(strconv.Itoa(len(part)) + ":"))
+		_, _ = h.Write(part)
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func hash(value []byte) string {
+	sum := sha256.Sum256(value)
+
+	return hex.EncodeToString(sum[:])
+}
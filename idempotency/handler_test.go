@@ -0,0 +1,292 @@
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redismock/v9"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+
+	"github.com/cash-track/gateway/config"
+	"github.com/cash-track/gateway/headers"
+	"github.com/cash-track/gateway/headers/cookie"
+	"github.com/cash-track/gateway/router/response"
+	"github.com/cash-track/gateway/session"
+	"github.com/cash-track/gateway/token"
+)
+
+var testSecret = []byte("test-secret")
+
+// mapStore keeps the records in memory, failing with err when set.
+type mapStore struct {
+	mu      sync.Mutex
+	records map[string]Record
+	ttl     time.Duration
+	err     error
+}
+
+func newMapStore() *mapStore {
+	return &mapStore{records: map[string]Record{}}
+}
+
+func (s *mapStore) Claim(_ context.Context, key, bodyHash string) (Record, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return Record{}, false, s.err
+	}
+
+	if record, ok := s.records[key]; ok {
+		return record, false, nil
+	}
+
+	s.records[key] = Record{BodyHash: bodyHash}
+
+	return Record{}, true, nil
+}
+
+func (s *mapStore) Finish(_ context.Context, key string, record Record, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.records[key], s.ttl = record, ttl
+
+	return s.err
+}
+
+func (s *mapStore) Release(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.records, key)
+
+	return s.err
+}
+
+func accessToken(userId int, secret []byte) string {
+	s, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": userId,
+		"iat": time.Now().Unix(),
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}).SignedString(secret)
+
+	return s
+}
+
+// verified puts the token verification in front of h, as main does.
+func verified(h fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return token.NewHandler(token.NewHmacVerifier(testSecret), session.CookieStore{}).Handler(h)
+}
+
+func withIdempotencyTTL(t *testing.T) {
+	original := config.Global.IdempotencyTTL
+	config.Global.IdempotencyTTL = time.Hour
+	t.Cleanup(func() { config.Global.IdempotencyTTL = original })
+}
+
+// upstream creates a transaction per request, answering with the status.
+func upstream(calls *int, status int) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		*calls++
+
+		ctx.SetStatusCode(status)
+		ctx.SetContentType("application/json")
+		ctx.Response.Header.Set(headers.XCtApiVersion, "v1.0.0")
+		ctx.Response.Header.Set(headers.XRateLimitRemaining, "9")
+		ctx.SetBodyString(`{"id":1}`)
+	}
+}
+
+func postCtx(body string) *fasthttp.RequestCtx {
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod(fasthttp.MethodPost)
+	ctx.Request.SetRequestURI("/api/transactions")
+	ctx.Request.SetBodyString(body)
+
+	return ctx
+}
+
+func request(h fasthttp.RequestHandler, userId int, key, body string) *fasthttp.RequestCtx {
+	ctx := postCtx(body)
+	if key != "" {
+		ctx.Request.Header.Set(headers.IdempotencyKey, key)
+	}
+	if userId > 0 {
+		ctx.Request.Header.SetCookie(cookie.AccessTokenCookieName, accessToken(userId, testSecret))
+	}
+
+	verified(h)(ctx)
+
+	return ctx
+}
+
+func TestHandlerReplays(t *testing.T) {
+	withIdempotencyTTL(t)
+
+	calls := 0
+	store := newMapStore()
+	h := NewHandler(store).Handler(upstream(&calls, fasthttp.StatusCreated))
+	replayed := testutil.ToFloat64(idempotencyRequestsTotal.WithLabelValues("replayed"))
+
+	ctx := request(h, 1, "key-1", `{"amount":10}`)
+	assert.Equal(t, fasthttp.StatusCreated, ctx.Response.StatusCode())
+	assert.Empty(t, ctx.Response.Header.Peek(headers.IdempotentReplayed))
+
+	ctx = request(h, 1, "key-1", `{"amount":10}`)
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, fasthttp.StatusCreated, ctx.Response.StatusCode())
+	assert.Equal(t, `{"id":1}`, string(ctx.Response.Body()))
+	assert.Equal(t, "application/json", string(ctx.Response.Header.ContentType()))
+	assert.Equal(t, "v1.0.0", string(ctx.Response.Header.Peek(headers.XCtApiVersion)))
+	assert.Empty(t, ctx.Response.Header.Peek(headers.XRateLimitRemaining))
+	assert.Equal(t, "true", string(ctx.Response.Header.Peek(headers.IdempotentReplayed)))
+	assert.Equal(t, time.Hour, store.ttl)
+	assert.Equal(t, replayed+1, testutil.ToFloat64(idempotencyRequestsTotal.WithLabelValues("replayed")))
+
+	// keys are per user, and requests without one are forwarded
+	request(h, 2, "key-1", `{"amount":10}`)
+	request(h, 1, "", `{"amount":10}`)
+	request(h, 1, "", `{"amount":10}`)
+	assert.Equal(t, 4, calls)
+}
+
+func TestHandlerRejects(t *testing.T) {
+	withIdempotencyTTL(t)
+
+	for name, test := range map[string]struct {
+		record       *Record
+		key          string
+		expectStatus int
+		expectCode   response.Code
+	}{
+		"InvalidKey": {
+			key:          "key with spaces",
+			expectStatus: fasthttp.StatusBadRequest,
+			expectCode:   response.CodeIdempotencyInvalid,
+		},
+		"InFlight": {
+			record:       &Record{BodyHash: requestHash(postCtx(`{"amount":10}`))},
+			key:          "key-1",
+			expectStatus: fasthttp.StatusConflict,
+			expectCode:   response.CodeIdempotencyInFlight,
+		},
+		"Reused": {
+			record:       &Record{BodyHash: requestHash(postCtx(`{"amount":20}`)), Done: true, Status: fasthttp.StatusCreated},
+			key:          "key-1",
+			expectStatus: fasthttp.StatusUnprocessableEntity,
+			expectCode:   response.CodeIdempotencyReused,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			store := newMapStore()
+			if test.record != nil {
+				store.records["user:1:"+hash([]byte(test.key))] = *test.record
+			}
+
+			calls := 0
+			ctx := request(NewHandler(store).Handler(upstream(&calls, fasthttp.StatusCreated)), 1, test.key, `{"amount":10}`)
+
+			assert.Equal(t, 0, calls)
+			assert.Equal(t, test.expectStatus, ctx.Response.StatusCode())
+			assert.Contains(t, string(ctx.Response.Body()), string(test.expectCode))
+		})
+	}
+}
+
+func TestHandlerReleasesFailures(t *testing.T) {
+	withIdempotencyTTL(t)
+
+	calls := 0
+	store := newMapStore()
+	h := NewHandler(store).Handler(upstream(&calls, fasthttp.StatusServiceUnavailable))
+
+	request(h, 1, "key-1", `{"amount":10}`)
+	request(h, 1, "key-1", `{"amount":10}`)
+
+	assert.Equal(t, 2, calls)
+	assert.Empty(t, store.records)
+}
+
+func TestHandlerForwardsAnonymous(t *testing.T) {
+	withIdempotencyTTL(t)
+
+	calls := 0
+	store := newMapStore()
+	h := NewHandler(store).Handler(upstream(&calls, fasthttp.StatusCreated))
+
+	request(h, 0, "key-1", `{"amount":10}`)
+	request(h, 0, "key-1", `{"amount":10}`)
+
+	assert.Equal(t, 2, calls)
+	assert.Empty(t, store.records)
+}
+
+func TestHandlerStoreError(t *testing.T) {
+	withIdempotencyTTL(t)
+
+	store := newMapStore()
+	store.err = errors.New("connection refused")
+	errorsBefore := testutil.ToFloat64(idempotencyStoreErrorsTotal)
+
+	calls := 0
+	ctx := request(NewHandler(store).Handler(upstream(&calls, fasthttp.StatusCreated)), 1, "key-1", `{"amount":10}`)
+
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, fasthttp.StatusCreated, ctx.Response.StatusCode())
+	assert.Equal(t, errorsBefore+1, testutil.ToFloat64(idempotencyStoreErrorsTotal))
+}
+
+// Without verification the subject of the token cookie could be anyone's, so the request
+// is not deduplicated rather than scoped by it.
+func TestHandlerForwardsUnverified(t *testing.T) {
+	withIdempotencyTTL(t)
+
+	calls := 0
+	store := newMapStore()
+	h := NewHandler(store).Handler(upstream(&calls, fasthttp.StatusCreated))
+
+	for range 2 {
+		ctx := postCtx(`{"amount":10}`)
+		ctx.Request.Header.Set(headers.IdempotencyKey, "key-1")
+		ctx.Request.Header.SetCookie(cookie.AccessTokenCookieName, accessToken(1, []byte("forged")))
+		h(ctx)
+	}
+
+	assert.Equal(t, 2, calls)
+	assert.Empty(t, store.records)
+}
+
+func TestHandlerScopesBySession(t *testing.T) {
+	withIdempotencyTTL(t)
+
+	client, mock := redismock.NewClientMock()
+	sessions := session.NewRedisStore(client)
+	sessionId := strings.Repeat("a", 43)
+	stored, _ := json.Marshal(session.Session{Auth: cookie.Auth{AccessToken: "access_token", RefreshToken: "refresh_token"}})
+
+	calls := 0
+	store := newMapStore()
+	h := sessions.Handler(NewHandler(store).Handler(upstream(&calls, fasthttp.StatusCreated)))
+
+	for range 2 {
+		mock.ExpectGet("CT:session:" + sessionId).SetVal(string(stored))
+
+		ctx := postCtx(`{"amount":10}`)
+		ctx.Request.Header.Set(headers.IdempotencyKey, "key-1")
+		ctx.Request.Header.SetCookie(cookie.SessionCookieName, sessionId)
+		h(ctx)
+	}
+
+	assert.Equal(t, 1, calls)
+	assert.Contains(t, store.records, "session:"+hash([]byte(sessionId))+":"+hash([]byte("key-1")))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
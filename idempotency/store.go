@@ -0,0 +1,106 @@
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	keyPrefix = "CT:idempotency"
+	// Outlives one forwarded request with its retries; a key left pending by a crashed
+	// replica frees itself after that.
+	pendingTtl = time.Minute
+	// A key expiring between SetNX and Get is claimed again, a few times at most.
+	claimAttempts = 3
+)
+
+var errClaimLost = errors.New("idempotency key changed while claiming it")
+
+// Record is the state of a key: pending while the first request carrying it is forwarded,
+// then its answer.
+type Record struct {
+	BodyHash string            `json:"bodyHash"`
+	Done     bool              `json:"done,omitempty"`
+	Status   int               `json:"status,omitempty"`
+	Header   map[string]string `json:"header,omitempty"`
+	Body     []byte            `json:"body,omitempty"`
+}
+
+// Store keeps the records of the keys, shared by the replicas.
+type Store interface {
+	// Claim records the key as pending for bodyHash and reports true, or returns the
+	// record the key already has.
+	Claim(ctx context.Context, key, bodyHash string) (Record, bool, error)
+	// Finish keeps the answer of the claimed key for ttl.
+	Finish(ctx context.Context, key string, record Record, ttl time.Duration) error
+	// Release forgets the claimed key, for the request to be sent again.
+	Release(ctx context.Context, key string) error
+}
+
+type RedisStore struct {
+	client *redis.Client
+}
+
+func NewRedisStore(client *redis.Client) *RedisStore {
+	return &RedisStore{client: client}
+}
+
+func (s *RedisStore) Claim(ctx context.Context, key, bodyHash string) (Record, bool, error) {
+	pending, err := json.Marshal(Record{BodyHash: bodyHash})
+	if err != nil {
+		return Record{}, false, fmt.Errorf("idempotency encode: %w", err)
+	}
+
+	for range claimAttempts {
+		claimed, err := s.client.SetNX(ctx, keyPrefix+":"+key, pending, pendingTtl).Result()
+		if err != nil {
+			return Record{}, false, fmt.Errorf("idempotency claim: %w", err)
+		}
+		if claimed {
+			return Record{}, true, nil
+		}
+
+		data, err := s.client.Get(ctx, keyPrefix+":"+key).Bytes()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			return Record{}, false, fmt.Errorf("idempotency get: %w", err)
+		}
+
+		record := Record{}
+		if err := json.Unmarshal(data, &record); err != nil {
+			return Record{}, false, fmt.Errorf("idempotency decode: %w", err)
+		}
+
+		return record, false, nil
+	}
+
+	return Record{}, false, errClaimLost
+}
+
+func (s *RedisStore) Finish(ctx context.Context, key string, record Record, ttl time.Duration) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("idempotency encode: %w", err)
+	}
+
+	if err := s.client.Set(ctx, keyPrefix+":"+key, data, ttl).Err(); err != nil {
+		return fmt.Errorf("idempotency set: %w", err)
+	}
+
+	return nil
+}
+
+func (s *RedisStore) Release(ctx context.Context, key string) error {
+	if err := s.client.Del(ctx, keyPrefix+":"+key).Err(); err != nil {
+		return fmt.Errorf("idempotency release: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,86 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redismock/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testKey     = "123:key"
+	testPending = `{"bodyHash":"hash"}`
+	testDone    = `{"bodyHash":"hash","done":true,"status":201,"header":{"Content-Type":"application/json"},"body":"eyJpZCI6MX0="}`
+)
+
+var testRecord = Record{
+	BodyHash: "hash",
+	Done:     true,
+	Status:   201,
+	Header:   map[string]string{"Content-Type": "application/json"},
+	Body:     []byte(`{"id":1}`),
+}
+
+func TestRedisStoreClaim(t *testing.T) {
+	client, mock := redismock.NewClientMock()
+	mock.ExpectSetNX(keyPrefix+":"+testKey, []byte(testPending), pendingTtl).SetVal(true)
+
+	_, claimed, err := NewRedisStore(client).Claim(context.Background(), testKey, "hash")
+
+	assert.NoError(t, err)
+	assert.True(t, claimed)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRedisStoreClaimKnownKey(t *testing.T) {
+	client, mock := redismock.NewClientMock()
+	mock.ExpectSetNX(keyPrefix+":"+testKey, []byte(testPending), pendingTtl).SetVal(false)
+	mock.ExpectGet(keyPrefix + ":" + testKey).SetVal(testDone)
+
+	record, claimed, err := NewRedisStore(client).Claim(context.Background(), testKey, "hash")
+
+	assert.NoError(t, err)
+	assert.False(t, claimed)
+	assert.Equal(t, testRecord, record)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRedisStoreClaimExpiredMeanwhile(t *testing.T) {
+	client, mock := redismock.NewClientMock()
+	mock.ExpectSetNX(keyPrefix+":"+testKey, []byte(testPending), pendingTtl).SetVal(false)
+	mock.ExpectGet(keyPrefix + ":" + testKey).RedisNil()
+	mock.ExpectSetNX(keyPrefix+":"+testKey, []byte(testPending), pendingTtl).SetVal(true)
+
+	_, claimed, err := NewRedisStore(client).Claim(context.Background(), testKey, "hash")
+
+	assert.NoError(t, err)
+	assert.True(t, claimed)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRedisStoreClaimError(t *testing.T) {
+	client, mock := redismock.NewClientMock()
+	mock.ExpectSetNX(keyPrefix+":"+testKey, []byte(testPending), pendingTtl).SetErr(errors.New("connection refused"))
+
+	_, _, err := NewRedisStore(client).Claim(context.Background(), testKey, "hash")
+
+	assert.ErrorContains(t, err, "idempotency claim: connection refused")
+}
+
+func TestRedisStoreFinish(t *testing.T) {
+	client, mock := redismock.NewClientMock()
+	mock.ExpectSet(keyPrefix+":"+testKey, []byte(testDone), time.Hour).SetVal("OK")
+
+	assert.NoError(t, NewRedisStore(client).Finish(context.Background(), testKey, testRecord, time.Hour))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRedisStoreRelease(t *testing.T) {
+	client, mock := redismock.NewClientMock()
+	mock.ExpectDel(keyPrefix + ":" + testKey).SetErr(errors.New("connection refused"))
+
+	assert.ErrorContains(t, NewRedisStore(client).Release(context.Background(), testKey), "idempotency release: connection refused")
+}
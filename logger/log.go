@@ -18,16 +18,35 @@ var (
 	}
 )
 
+// DebugRequest dumps req, without a streamed body: reading it would leave nothing to
+// forward.
 func DebugRequest(req *fasthttp.Request, service string) {
-	if config.Global.DebugHttp {
-		slog.Debug("debug request", "service", service, "dump", req.String())
+	if !config.Global.DebugHttp {
+		return
+	}
+
+	if req.IsBodyStream() {
+		slog.Debug("debug request", "service", service, "dump", req.Header.String())
+
+		return
 	}
+
+	slog.Debug("debug request", "service", service, "dump", req.String())
 }
 
+// DebugResponse dumps resp, without a streamed body, see DebugRequest.
 func DebugResponse(resp *fasthttp.Response, service string) {
-	if config.Global.DebugHttp {
-		slog.Debug("debug response", "service", service, "dump", resp.String())
+	if !config.Global.DebugHttp {
+		return
+	}
+
+	if resp.IsBodyStream() {
+		slog.Debug("debug response", "service", service, "dump", resp.Header.String())
+
+		return
 	}
+
+	slog.Debug("debug response", "service", service, "dump", resp.String())
 }
 
 func DebugHandler(h fasthttp.RequestHandler) fasthttp.RequestHandler {
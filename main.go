@@ -2,8 +2,12 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
+	"net"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	prom "github.com/flf2ko/fasthttp-prometheus"
@@ -11,15 +15,25 @@ import (
 	"github.com/redis/go-redis/v9"
 	"github.com/valyala/fasthttp"
 
+	"github.com/cash-track/gateway/cache"
 	"github.com/cash-track/gateway/captcha"
 	"github.com/cash-track/gateway/config"
 	"github.com/cash-track/gateway/headers"
+	"github.com/cash-track/gateway/http"
 	"github.com/cash-track/gateway/http/retryhttp"
+	"github.com/cash-track/gateway/idempotency"
 	"github.com/cash-track/gateway/logger"
+	"github.com/cash-track/gateway/ratelimit"
 	"github.com/cash-track/gateway/router"
+	"github.com/cash-track/gateway/router/admin"
 	apiHandler "github.com/cash-track/gateway/router/api"
+	"github.com/cash-track/gateway/router/body"
 	csrfHandler "github.com/cash-track/gateway/router/csrf"
+	"github.com/cash-track/gateway/router/policy"
+	sessionsHandler "github.com/cash-track/gateway/router/sessions"
 	apiService "github.com/cash-track/gateway/service/api"
+	"github.com/cash-track/gateway/session"
+	"github.com/cash-track/gateway/token"
 	"github.com/cash-track/gateway/traces"
 )
 
@@ -34,85 +48,293 @@ func main() {
 	handler := slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug})
 	slog.SetDefault(slog.New(handler).With("component", "gateway"))
 
+	switch args := os.Args[1:]; {
+	case len(args) == 0:
+		os.Exit(run())
+	case len(args) == 2 && args[0] == "config" && args[1] == "check":
+		os.Exit(configCheck(os.Stdout, os.Stderr))
+	default:
+		_, _ = fmt.Fprintln(os.Stderr, "usage: gateway [config check]")
+		os.Exit(2)
+	}
+}
+
+// run starts the gateway and blocks until it is shut down. Returning the exit code
+// instead of calling os.Exit lets the shutdown sequence run on every path.
+func run() int {
 	ctx := context.Background()
 
-	config.Global.Load()
+	if errs := config.Global.Load(); len(errs) > 0 {
+		for _, err := range errs {
+			slog.Error("invalid configuration", "key", err.Key, "error", err.Message)
+		}
+
+		return 1
+	}
 
-	if _, tracerClose, err := traces.NewTracer(ctx); err != nil {
+	if err := config.Global.LoadRoutes(); err != nil {
+		slog.Error("error loading route table", "error", err)
+
+		return 1
+	}
+
+	// CONFIG_FILE is applied before serving: a broken file fails the start, but only
+	// gets a reload rejected later on.
+	reloader := config.NewReloader(config.Global)
+	if err := reloader.Reload(); err != nil {
+		return 1
+	}
+
+	watchCtx, stopWatch := context.WithCancel(ctx)
+	defer stopWatch()
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go reloader.Watch(watchCtx, hup)
+
+	tracerProvider, _, err := traces.NewTracer(ctx)
+	if err != nil {
 		slog.Error("error creating OpenTelemetry tracer", "error", err)
-		os.Exit(1)
-	} else {
-		defer tracerClose()
+
+		return 1
+	}
+
+	captchaProvider, err := captcha.NewProvider(config.Global.CaptchaProvider, retryhttp.NewFastHttpRetryClient())
+	if err != nil {
+		slog.Error("error creating captcha provider", "error", err)
+
+		return 1
 	}
 
 	redisClient := getRedisClient()
 	csrf := csrfHandler.NewRedisHandler(redisClient)
-	breaker := apiService.NewBreaker()
-	apiService.RegisterBreakerMetrics(breaker)
-
-	r := router.New(
-		apiHandler.NewHttp(
-			config.Global,
-			apiService.NewHttp(retryhttp.NewFastHttpRetryClient(), config.Global, csrf, breaker),
-			captcha.NewGoogleReCaptchaProvider(retryhttp.NewFastHttpRetryClient(), config.Global),
-			csrf,
-		),
-		csrf,
+
+	// the local provider accepts one fixed challenge, which end-to-end tests reuse
+	if config.Global.CaptchaProvider != config.CaptchaProviderLocal {
+		captchaProvider = captcha.NewReplayGuard(captchaProvider, redisClient)
+	}
+
+	var (
+		sessions       session.Store = session.CookieStore{}
+		sessionDevices sessionsHandler.Handler
 	)
-	h := buildHandler(prom.NewPrometheus("http").WrapHandler(r.Router), csrf)
+	if config.Global.SessionEnabled {
+		store := session.NewRedisStore(redisClient)
+		sessions, sessionDevices = store, sessionsHandler.NewHttp(store)
+	}
+
+	var tokens *token.Handler
+	verifier, err := token.NewVerifier(config.Global, http.NewFastHttpClient())
+	if err != nil {
+		slog.Error("error creating access token verifier", "error", err)
+
+		return 1
+	}
+	if verifier != nil {
+		tokens = token.NewHandler(verifier, sessions)
+	}
+
+	api, upstreams, breakers := buildUpstreams(csrf, captchaProvider, sessions, apiService.NewRefreshLock(redisClient))
+
+	r := router.New(api, csrf, sessionDevices, upstreams)
+	rateLimit := ratelimit.NewHandler(ratelimit.NewRedisLimiter(redisClient), ratelimit.NewMemoryLimiter())
+	var cacheStore cache.Store = cache.NewMemoryStore()
+	if config.Global.CacheStore == config.CacheStoreRedis {
+		cacheStore = cache.NewRedisStore(redisClient)
+	}
+	idempotent := idempotency.NewHandler(idempotency.NewRedisStore(redisClient))
+	h := buildHandler(prom.NewPrometheus("http").WrapHandler(r.Router), csrf, rateLimit, sessions, tokens, cache.NewHandler(cacheStore), idempotent)
 
 	s := &fasthttp.Server{
 		Handler:         h,
 		ReadBufferSize:  readBufferSize,
 		WriteBufferSize: writeBufferSize,
+		// bodies are left unread for body.Handler to check against the limits of their
+		// route, and forwarded as they came
+		StreamRequestBody:            true,
+		DisablePreParseMultipartForm: true,
+		// keep-alive clients are told to reconnect elsewhere once shutdown starts
+		CloseOnShutdown: true,
+	}
+
+	ln, err := net.Listen("tcp4", config.Global.Address)
+	if err != nil {
+		slog.Error("error listening", "address", config.Global.Address, "error", err)
+
+		return 1
+	}
+
+	serveErr := make(chan error, 2)
+	go func() {
+		if config.Global.HttpsEnabled {
+			serveErr <- startTls(s, ln)
+		} else {
+			serveErr <- start(s, ln)
+		}
+	}()
+
+	steps := make([]shutdownStep, 0, 3)
+
+	// the admin listener keeps answering while the gateway drains, and stops right after it
+	if config.Global.AdminAddress != "" {
+		adminLn, err := net.Listen("tcp4", config.Global.AdminAddress)
+		if err != nil {
+			slog.Error("error listening", "address", config.Global.AdminAddress, "error", err)
+
+			return 1
+		}
+
+		adminServer := &fasthttp.Server{Handler: admin.New(config.Global.AdminToken, breakers).Handler}
+		go func() {
+			slog.Info("listening on admin HTTP", "address", adminLn.Addr().String())
+			serveErr <- adminServer.Serve(adminLn)
+		}()
+
+		steps = append(steps, shutdownStep{name: "admin", close: adminServer.ShutdownWithContext})
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+
+	code := 0
+
+	select {
+	case sig := <-signals:
+		slog.Info("shutdown signal received", "signal", sig.String())
+	case err := <-serveErr:
+		slog.Error("error in HTTP server", "error", err)
+		code = 1
 	}
 
-	if config.Global.HttpsEnabled {
-		startTls(s)
-	} else {
-		start(s)
+	steps = append(steps,
+		shutdownStep{name: "redis", close: func(context.Context) error { return redisClient.Close() }},
+		shutdownStep{name: "tracer", close: tracerProvider.Shutdown},
+	)
+
+	err = gracefulShutdown(s, r, config.Global.ShutdownDrainPeriod, config.Global.ShutdownTimeout, steps...)
+	if err != nil {
+		slog.Error("error during graceful shutdown", "error", err)
+
+		return 1
+	}
+
+	slog.Info("shutdown complete")
+
+	return code
+}
+
+// buildUpstreams creates a forwarder with its own http client and circuit breakers for
+// every upstream of the route table, one per breaker group and one for the rest. The API
+// upstream always comes first: its handler is returned separately for the auth endpoints,
+// and it refreshes tokens for the others, once per token across the replicas sharing
+// refreshLock. Every breaker is returned too, for the admin endpoints.
+func buildUpstreams(
+	csrf csrfHandler.Handler,
+	captcha captcha.Provider,
+	sessions session.Store,
+	refreshLock *apiService.RefreshLock,
+) (apiHandler.Handler, []router.Upstream, []*apiService.Breaker) {
+	var (
+		api          apiHandler.Handler
+		apiForwarder *apiService.HttpService
+		upstreams    = make([]router.Upstream, 0, len(config.Global.Upstreams))
+		breakers     = make([]*apiService.Breaker, 0, len(config.Global.Upstreams))
+	)
+
+	for _, upstream := range config.Global.Upstreams {
+		breaker := apiService.NewUpstreamBreaker(upstream)
+		apiService.RegisterBreakerMetrics(breaker)
+		breakers = append(breakers, breaker)
+
+		service := apiService.NewHttpUpstream(retryhttp.NewFastHttpRetryClient(), config.Global, upstream, csrf, breaker).
+			WithSessions(sessions)
+		for _, group := range upstream.BreakerGroups {
+			groupBreaker := apiService.NewGroupBreaker(upstream, group)
+			apiService.RegisterBreakerMetrics(groupBreaker)
+			breakers = append(breakers, groupBreaker)
+			service.WithBreakerGroup(group, groupBreaker)
+		}
+		handler := apiHandler.NewHttp(config.Global, service, captcha, csrf).WithSessions(sessions)
+
+		if upstream.Name == config.ApiUpstreamName {
+			api, apiForwarder = handler, service.WithRefreshLock(refreshLock)
+		} else {
+			service.WithRefresher(apiForwarder)
+		}
+
+		upstreams = append(upstreams, router.Upstream{Name: upstream.Name, Prefix: upstream.Prefix, Handler: handler})
 	}
+
+	return api, upstreams, breakers
 }
 
 // buildHandler chains the middleware applied to every request, outermost first:
-// traces -> logger -> cors -> headers -> csrf (if enabled) -> inner.
+// traces -> logger -> cors -> headers -> rate limit (if enabled) -> body limits -> session
+// (if enabled) -> token verification (if a key source is set) -> access policies (if
+// declared) -> response cache (if declared) -> csrf (if enabled) -> idempotency keys -> inner.
 //
 // headers must wrap csrf, not the reverse: csrf short-circuits a validation failure with a
 // 417 without calling its inner handler, which would leave that response with no trace ID
-// and no provenance headers.
-func buildHandler(inner fasthttp.RequestHandler, csrf csrfHandler.Handler) fasthttp.RequestHandler {
+// and no provenance headers. The same goes for the 429 of the rate limit, which also needs
+// the client IP resolved by headers. The session must be resolved before csrf reads the
+// access token of the request, and that token verified before csrf keys its tokens by the
+// user it names and before access policies read its roles. A cached answer is only served
+// once the policies let the request through. A replayed answer passes csrf like a forwarded
+// one, rotating the token of the client.
+func buildHandler(
+	inner fasthttp.RequestHandler,
+	csrf csrfHandler.Handler,
+	rateLimit *ratelimit.Handler,
+	sessions session.Store,
+	tokens *token.Handler,
+	responseCache *cache.Handler,
+	idempotent *idempotency.Handler,
+) fasthttp.RequestHandler {
 	h := inner
+	if idempotent != nil {
+		h = idempotent.Handler(h)
+	}
 	if config.Global.CsrfEnabled {
 		h = csrf.Handler(h)
 	}
+	if len(config.Global.CacheRules) > 0 {
+		h = responseCache.Handler(h)
+	}
+	if len(config.Global.AccessPolicies) > 0 {
+		h = policy.Handler(h)
+	}
+	if tokens != nil {
+		h = tokens.Handler(h)
+	}
+	if config.Global.SessionEnabled {
+		h = sessions.Handler(h)
+	}
+	h = body.Handler(h)
+	if config.Global.RateLimitEnabled {
+		h = rateLimit.Handler(h)
+	}
 	h = headers.Handler(h)
 	h = headers.CorsHandler(h)
 	h = logger.DebugHandler(h)
 	h = traces.TraceHandler(h)
 
 	if config.Global.Compress {
-		h = fasthttp.CompressHandler(h)
+		h = headers.CompressHandler(h)
 	}
 
 	return h
 }
 
-func start(s *fasthttp.Server) {
-	slog.Info("listening on HTTP", "address", config.Global.Address)
+func start(s *fasthttp.Server, ln net.Listener) error {
+	slog.Info("listening on HTTP", "address", ln.Addr().String())
 
-	if err := s.ListenAndServe(config.Global.Address); err != nil {
-		slog.Error("error in HTTP server", "error", err)
-		os.Exit(1)
-	}
+	return s.Serve(ln)
 }
 
-func startTls(s *fasthttp.Server) {
-	slog.Info("listening on HTTPS", "address", config.Global.Address)
+func startTls(s *fasthttp.Server, ln net.Listener) error {
+	slog.Info("listening on HTTPS", "address", ln.Addr().String())
 
-	if err := s.ListenAndServeTLS(config.Global.Address, config.Global.HttpsCrt, config.Global.HttpsKey); err != nil {
-		slog.Error("error in HTTPS server", "error", err)
-		os.Exit(1)
-	}
+	return s.ServeTLS(ln, config.Global.HttpsCrt, config.Global.HttpsKey)
 }
 
 func getRedisClient() *redis.Client {
@@ -2,6 +2,7 @@ package main
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/valyala/fasthttp"
@@ -10,6 +11,7 @@ import (
 	"github.com/cash-track/gateway/config"
 	"github.com/cash-track/gateway/headers"
 	"github.com/cash-track/gateway/mocks"
+	"github.com/cash-track/gateway/ratelimit"
 )
 
 // Pins the chain order: headers must wrap csrf, otherwise a CSRF-rejected request never
@@ -36,7 +38,7 @@ func TestBuildHandlerCsrfRejectionStillGetsGatewayHeaders(t *testing.T) {
 	innerCalled := false
 	h := buildHandler(func(ctx *fasthttp.RequestCtx) {
 		innerCalled = true
-	}, csrf)
+	}, csrf, nil, nil, nil, nil, nil)
 
 	ctx := &fasthttp.RequestCtx{}
 	ctx.Request.Header.SetMethod(fasthttp.MethodPost)
@@ -71,7 +73,7 @@ func TestBuildHandlerCsrfDisabledStillGetsGatewayHeaders(t *testing.T) {
 	h := buildHandler(func(ctx *fasthttp.RequestCtx) {
 		innerCalled = true
 		ctx.SetStatusCode(fasthttp.StatusOK)
-	}, csrf)
+	}, csrf, nil, nil, nil, nil, nil)
 
 	ctx := &fasthttp.RequestCtx{}
 	ctx.Request.Header.SetMethod(fasthttp.MethodGet)
@@ -87,3 +89,37 @@ func TestBuildHandlerCsrfDisabledStillGetsGatewayHeaders(t *testing.T) {
 	assert.Equal(t, "strict-origin-when-cross-origin", string(ctx.Response.Header.Peek(headers.ReferrerPolicy)))
 	assert.Equal(t, "default-src 'none'; frame-ancestors 'none'", string(ctx.Response.Header.Peek(headers.ContentSecurityPolicy)))
 }
+
+// The 429 of the rate limit must get the gateway headers too, and rely on headers.Handler
+// having resolved the client IP.
+func TestBuildHandlerRateLimitRejectionStillGetsGatewayHeaders(t *testing.T) {
+	original := config.Global
+	t.Cleanup(func() { config.Global = original })
+
+	config.Global.CsrfEnabled = false
+	config.Global.RateLimitEnabled = true
+	config.Global.RateLimits = []config.RateLimit{{Name: "auth", Prefix: "/api/auth", Limit: 1, Window: time.Minute}}
+	config.Global.GitTag = "v1.2.3"
+	config.Global.Compress = false
+	config.Global.CorsAllowedOrigins = map[string]bool{}
+
+	calls := 0
+	h := buildHandler(func(ctx *fasthttp.RequestCtx) {
+		calls++
+	}, nil, ratelimit.NewHandler(ratelimit.NewMemoryLimiter(), ratelimit.NewMemoryLimiter()), nil, nil, nil, nil)
+
+	for i := 0; i < 2; i++ {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.Header.SetMethod(fasthttp.MethodPost)
+		ctx.Request.SetRequestURI("/api/auth/login")
+		h(ctx)
+
+		if i == 1 {
+			assert.Equal(t, fasthttp.StatusTooManyRequests, ctx.Response.StatusCode())
+			assert.Equal(t, "v1.2.3", string(ctx.Response.Header.Peek(headers.XCtGatewayVersion)))
+			assert.Equal(t, "nosniff", string(ctx.Response.Header.Peek(headers.XContentTypeOptions)))
+		}
+	}
+
+	assert.Equal(t, 1, calls)
+}
@@ -12,6 +12,7 @@ package mocks
 import (
 	reflect "reflect"
 
+	config "github.com/cash-track/gateway/config"
 	fasthttp "github.com/valyala/fasthttp"
 	gomock "go.uber.org/mock/gomock"
 )
@@ -20,7 +21,6 @@ import (
 type ApiServiceMock struct {
 	ctrl     *gomock.Controller
 	recorder *ApiServiceMockMockRecorder
-	isgomock struct{}
 }
 
 // ApiServiceMockMockRecorder is the mock recorder for ApiServiceMock.
@@ -40,6 +40,20 @@ func (m *ApiServiceMock) EXPECT() *ApiServiceMockMockRecorder {
 	return m.recorder
 }
 
+// ForwardRealtime mocks base method.
+func (m *ApiServiceMock) ForwardRealtime(ctx *fasthttp.RequestCtx, route config.Realtime) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ForwardRealtime", ctx, route)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ForwardRealtime indicates an expected call of ForwardRealtime.
+func (mr *ApiServiceMockMockRecorder) ForwardRealtime(ctx, route any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ForwardRealtime", reflect.TypeOf((*ApiServiceMock)(nil).ForwardRealtime), ctx, route)
+}
+
 // ForwardRequest mocks base method.
 func (m *ApiServiceMock) ForwardRequest(ctx *fasthttp.RequestCtx, body []byte) error {
 	m.ctrl.T.Helper()
@@ -0,0 +1,144 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: router/sessions/handler.go
+//
+// Generated by this command:
+//
+//	mockgen -source=router/sessions/handler.go -package=mocks -destination=mocks/sessions_handler_mock.go -mock_names=Handler=SessionsHandlerMock,Store=SessionsStoreMock
+//
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	reflect "reflect"
+
+	session "github.com/cash-track/gateway/session"
+	fasthttp "github.com/valyala/fasthttp"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// SessionsHandlerMock is a mock of Handler interface.
+type SessionsHandlerMock struct {
+	ctrl     *gomock.Controller
+	recorder *SessionsHandlerMockMockRecorder
+}
+
+// SessionsHandlerMockMockRecorder is the mock recorder for SessionsHandlerMock.
+type SessionsHandlerMockMockRecorder struct {
+	mock *SessionsHandlerMock
+}
+
+// NewSessionsHandlerMock creates a new mock instance.
+func NewSessionsHandlerMock(ctrl *gomock.Controller) *SessionsHandlerMock {
+	mock := &SessionsHandlerMock{ctrl: ctrl}
+	mock.recorder = &SessionsHandlerMockMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *SessionsHandlerMock) EXPECT() *SessionsHandlerMockMockRecorder {
+	return m.recorder
+}
+
+// ListHandler mocks base method.
+func (m *SessionsHandlerMock) ListHandler(ctx *fasthttp.RequestCtx) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "ListHandler", ctx)
+}
+
+// ListHandler indicates an expected call of ListHandler.
+func (mr *SessionsHandlerMockMockRecorder) ListHandler(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListHandler", reflect.TypeOf((*SessionsHandlerMock)(nil).ListHandler), ctx)
+}
+
+// RevokeHandler mocks base method.
+func (m *SessionsHandlerMock) RevokeHandler(ctx *fasthttp.RequestCtx) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "RevokeHandler", ctx)
+}
+
+// RevokeHandler indicates an expected call of RevokeHandler.
+func (mr *SessionsHandlerMockMockRecorder) RevokeHandler(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeHandler", reflect.TypeOf((*SessionsHandlerMock)(nil).RevokeHandler), ctx)
+}
+
+// RevokeOthersHandler mocks base method.
+func (m *SessionsHandlerMock) RevokeOthersHandler(ctx *fasthttp.RequestCtx) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "RevokeOthersHandler", ctx)
+}
+
+// RevokeOthersHandler indicates an expected call of RevokeOthersHandler.
+func (mr *SessionsHandlerMockMockRecorder) RevokeOthersHandler(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeOthersHandler", reflect.TypeOf((*SessionsHandlerMock)(nil).RevokeOthersHandler), ctx)
+}
+
+// SessionsStoreMock is a mock of Store interface.
+type SessionsStoreMock struct {
+	ctrl     *gomock.Controller
+	recorder *SessionsStoreMockMockRecorder
+}
+
+// SessionsStoreMockMockRecorder is the mock recorder for SessionsStoreMock.
+type SessionsStoreMockMockRecorder struct {
+	mock *SessionsStoreMock
+}
+
+// NewSessionsStoreMock creates a new mock instance.
+func NewSessionsStoreMock(ctrl *gomock.Controller) *SessionsStoreMock {
+	mock := &SessionsStoreMock{ctrl: ctrl}
+	mock.recorder = &SessionsStoreMockMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *SessionsStoreMock) EXPECT() *SessionsStoreMockMockRecorder {
+	return m.recorder
+}
+
+// List mocks base method.
+func (m *SessionsStoreMock) List(ctx *fasthttp.RequestCtx) ([]session.Device, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List", ctx)
+	ret0, _ := ret[0].([]session.Device)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List.
+func (mr *SessionsStoreMockMockRecorder) List(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*SessionsStoreMock)(nil).List), ctx)
+}
+
+// Revoke mocks base method.
+func (m *SessionsStoreMock) Revoke(ctx *fasthttp.RequestCtx, id string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Revoke", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Revoke indicates an expected call of Revoke.
+func (mr *SessionsStoreMockMockRecorder) Revoke(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Revoke", reflect.TypeOf((*SessionsStoreMock)(nil).Revoke), ctx, id)
+}
+
+// RevokeOthers mocks base method.
+func (m *SessionsStoreMock) RevokeOthers(ctx *fasthttp.RequestCtx) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeOthers", ctx)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RevokeOthers indicates an expected call of RevokeOthers.
+func (mr *SessionsStoreMockMockRecorder) RevokeOthers(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeOthers", reflect.TypeOf((*SessionsStoreMock)(nil).RevokeOthers), ctx)
+}
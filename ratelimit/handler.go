@@ -0,0 +1,129 @@
+package ratelimit
+
+import (
+	"log/slog"
+	"math"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/valyala/fasthttp"
+
+	"github.com/cash-track/gateway/config"
+	"github.com/cash-track/gateway/headers"
+	"github.com/cash-track/gateway/router/response"
+	"github.com/cash-track/gateway/traces"
+)
+
+const (
+	metricsNamespace       = "gateway"
+	metricsRateLimitSubsys = "ratelimit"
+)
+
+var rateLimitRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: metricsNamespace,
+	Subsystem: metricsRateLimitSubsys,
+	Name:      "requests_total",
+	Help:      "Requests matching a rate limit rule by rule and result: allowed or limited.",
+}, []string{"rule", "result"})
+
+var rateLimitFallbackTotal = promauto.NewCounter(prometheus.CounterOpts{
+	Namespace: metricsNamespace,
+	Subsystem: metricsRateLimitSubsys,
+	Name:      "fallback_total",
+	Help:      "Requests counted by the in-memory limiter because Redis was unreachable.",
+})
+
+// rateLimitRedisUp is updated as requests flow through, not by a dedicated health check.
+var rateLimitRedisUp = newRedisUpGauge()
+
+func newRedisUpGauge() prometheus.Gauge {
+	g := promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsRateLimitSubsys,
+		Name:      "redis_up",
+		Help:      "Whether the last rate limit Redis operation succeeded (1) or failed (0). Optimistic 1 before first use.",
+	})
+	g.Set(1)
+
+	return g
+}
+
+type Handler struct {
+	limiter  Limiter
+	fallback Limiter
+}
+
+// NewHandler counts requests with limiter, and with fallback whenever limiter fails.
+func NewHandler(limiter, fallback Limiter) *Handler {
+	return &Handler{
+		limiter:  limiter,
+		fallback: fallback,
+	}
+}
+
+// Handler rejects with 429 the requests of a client IP over the limit of the matching
+// rule in config.Global.RateLimits. Must run after headers.Handler resolved the client IP.
+func (l *Handler) Handler(h fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		method := string(ctx.Request.Header.Method())
+
+		if method == fasthttp.MethodOptions {
+			h(ctx)
+
+			return
+		}
+
+		rule, ok := config.Global.FindRateLimit(method, string(ctx.Path()))
+		if !ok {
+			h(ctx)
+
+			return
+		}
+
+		result := l.allow(ctx, headers.GetClientIPFromContext(ctx), rule)
+		if result.Allowed {
+			rateLimitRequestsTotal.WithLabelValues(rule.Name, "allowed").Inc()
+			h(ctx)
+
+			return
+		}
+
+		rateLimitRequestsTotal.WithLabelValues(rule.Name, "limited").Inc()
+		slog.Warn("rate limit exceeded",
+			"trace_id", traces.FindTraceId(ctx), "rule", rule.Name, "client_ip", headers.GetClientIPFromContext(ctx))
+
+		writeLimited(ctx, result)
+	}
+}
+
+func (l *Handler) allow(ctx *fasthttp.RequestCtx, clientIp string, rule config.RateLimit) Result {
+	result, err := l.limiter.Allow(traces.FindParentContext(ctx), clientIp, rule)
+	if err == nil {
+		rateLimitRedisUp.Set(1)
+
+		return result
+	}
+
+	rateLimitRedisUp.Set(0)
+	rateLimitFallbackTotal.Inc()
+	slog.Error("rate limit redis unreachable, using in-memory limiter",
+		"trace_id", traces.FindTraceId(ctx), "error", err)
+
+	// the in-memory limiter never fails
+	result, _ = l.fallback.Allow(traces.FindParentContext(ctx), clientIp, rule)
+
+	return result
+}
+
+func writeLimited(ctx *fasthttp.RequestCtx, result Result) {
+	retryAfter := int(math.Ceil(result.RetryAfter.Seconds()))
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+
+	response.New(response.CodeRateLimited).Write(ctx)
+	ctx.Response.Header.Set(headers.RetryAfter, strconv.Itoa(retryAfter))
+	ctx.Response.Header.Set(headers.XRateLimit, strconv.FormatUint(uint64(result.Limit), 10))
+	ctx.Response.Header.Set(headers.XRateLimitRemaining, strconv.FormatUint(uint64(result.Remaining), 10))
+}
@@ -0,0 +1,133 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+
+	"github.com/cash-track/gateway/config"
+	"github.com/cash-track/gateway/headers"
+)
+
+type limiterFunc func(ctx context.Context, key string, rule config.RateLimit) (Result, error)
+
+func (f limiterFunc) Allow(ctx context.Context, key string, rule config.RateLimit) (Result, error) {
+	return f(ctx, key, rule)
+}
+
+func unexpectedLimiter(t *testing.T) Limiter {
+	return limiterFunc(func(context.Context, string, config.RateLimit) (Result, error) {
+		t.Error("limiter must not be called")
+
+		return Result{}, nil
+	})
+}
+
+func TestHandler(t *testing.T) {
+	original := config.Global.RateLimits
+	config.Global.RateLimits = []config.RateLimit{testRule}
+	t.Cleanup(func() { config.Global.RateLimits = original })
+
+	for name, test := range map[string]struct {
+		method        string
+		path          string
+		limiter       func(t *testing.T) Limiter
+		fallback      func(t *testing.T) Limiter
+		expectPass    bool
+		expectStatus  int
+		expectHeaders map[string]string
+	}{
+		"NoMatchingRule": {
+			method:     fasthttp.MethodPost,
+			path:       "/api/wallets",
+			limiter:    unexpectedLimiter,
+			fallback:   unexpectedLimiter,
+			expectPass: true,
+		},
+		"OptionsSkipped": {
+			method:     fasthttp.MethodOptions,
+			path:       "/api/auth/login",
+			limiter:    unexpectedLimiter,
+			fallback:   unexpectedLimiter,
+			expectPass: true,
+		},
+		"Allowed": {
+			method: fasthttp.MethodPost,
+			path:   "/api/auth/login",
+			limiter: func(t *testing.T) Limiter {
+				return limiterFunc(func(_ context.Context, key string, rule config.RateLimit) (Result, error) {
+					assert.Equal(t, "10.0.0.1", key)
+					assert.Equal(t, testRule, rule)
+
+					return Result{Allowed: true, Limit: 10, Remaining: 5}, nil
+				})
+			},
+			fallback:   unexpectedLimiter,
+			expectPass: true,
+		},
+		"Limited": {
+			method: fasthttp.MethodPost,
+			path:   "/api/auth/login",
+			limiter: func(t *testing.T) Limiter {
+				return limiterFunc(func(context.Context, string, config.RateLimit) (Result, error) {
+					return Result{Limit: 10, RetryAfter: 1500 * time.Millisecond}, nil
+				})
+			},
+			fallback:     unexpectedLimiter,
+			expectStatus: fasthttp.StatusTooManyRequests,
+			expectHeaders: map[string]string{
+				headers.RetryAfter:          "2",
+				headers.XRateLimit:          "10",
+				headers.XRateLimitRemaining: "0",
+				headers.ContentType:         "application/json",
+			},
+		},
+		"RedisDownFallsBackToMemory": {
+			method: fasthttp.MethodPost,
+			path:   "/api/auth/login",
+			limiter: func(t *testing.T) Limiter {
+				return limiterFunc(func(context.Context, string, config.RateLimit) (Result, error) {
+					return Result{}, errors.New("connection refused")
+				})
+			},
+			fallback: func(t *testing.T) Limiter {
+				return limiterFunc(func(context.Context, string, config.RateLimit) (Result, error) {
+					return Result{Limit: 10, RetryAfter: 30 * time.Second}, nil
+				})
+			},
+			expectStatus: fasthttp.StatusTooManyRequests,
+			expectHeaders: map[string]string{
+				headers.RetryAfter: "30",
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctx := &fasthttp.RequestCtx{}
+			ctx.SetRemoteAddr(&net.TCPAddr{IP: net.ParseIP("10.0.0.1")})
+			ctx.Request.Header.SetMethod(test.method)
+			ctx.Request.SetRequestURI(test.path)
+
+			passed := false
+			h := NewHandler(test.limiter(t), test.fallback(t)).Handler(func(ctx *fasthttp.RequestCtx) {
+				passed = true
+			})
+			h(ctx)
+
+			assert.Equal(t, test.expectPass, passed)
+
+			if test.expectStatus != 0 {
+				assert.Equal(t, test.expectStatus, ctx.Response.StatusCode())
+				assert.JSONEq(t, `{"code":"rate_limited","message":"Too many requests. Please try again later."}`, string(ctx.Response.Body()))
+			}
+
+			for key, value := range test.expectHeaders {
+				assert.Equal(t, value, string(ctx.Response.Header.Peek(key)), key)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/cash-track/gateway/config"
+)
+
+// Result of counting one request against a rule.
+type Result struct {
+	Allowed    bool
+	Limit      uint
+	Remaining  uint
+	RetryAfter time.Duration
+}
+
+// Limiter counts a request for key against rule. Both implementations use a sliding window
+// counter: the count of the previous fixed window, weighted by how much of it still
+// overlaps the sliding window, plus the count of the current one.
+type Limiter interface {
+	Allow(ctx context.Context, key string, rule config.RateLimit) (Result, error)
+}
+
+// windowStart returns the start of the fixed window containing now and how far into it
+// now is.
+func windowStart(now time.Time, window time.Duration) (time.Time, time.Duration) {
+	start := now.Truncate(window)
+
+	return start, now.Sub(start)
+}
+
+// evaluate decides on a request given the counts of the previous and current fixed
+// windows, before counting the request itself.
+func evaluate(rule config.RateLimit, previous, current uint, elapsed time.Duration) Result {
+	overlap := float64(rule.Window-elapsed) / float64(rule.Window)
+	used := uint(math.Floor(float64(previous)*overlap)) + current
+
+	if used < rule.Limit {
+		return Result{Allowed: true, Limit: rule.Limit, Remaining: rule.Limit - used - 1}
+	}
+
+	return Result{Limit: rule.Limit, RetryAfter: retryAfter(rule, previous, current, elapsed)}
+}
+
+// retryAfter is how long until the weighted count drops below the limit, assuming no
+// other request is counted meanwhile.
+func retryAfter(rule config.RateLimit, previous, current uint, elapsed time.Duration) time.Duration {
+	if current >= rule.Limit || previous == 0 {
+		// only the current window rolling into the previous one helps
+		return rule.Window - elapsed
+	}
+
+	// previous*(window-t)/window + current < limit  =>  t > window*(1-(limit-current)/previous)
+	at := time.Duration(float64(rule.Window) * (1 - float64(rule.Limit-current)/float64(previous)))
+	if at <= elapsed {
+		return time.Second
+	}
+
+	return at - elapsed
+}
@@ -0,0 +1,68 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cash-track/gateway/config"
+)
+
+var testRule = config.RateLimit{Name: "auth", Prefix: "/api/auth", Limit: 10, Window: time.Minute}
+
+func TestWindowStart(t *testing.T) {
+	now := time.Date(2024, 5, 1, 10, 30, 45, 0, time.UTC)
+
+	start, elapsed := windowStart(now, time.Minute)
+
+	assert.Equal(t, time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC), start)
+	assert.Equal(t, 45*time.Second, elapsed)
+}
+
+func TestEvaluate(t *testing.T) {
+	for name, test := range map[string]struct {
+		previous uint
+		current  uint
+		elapsed  time.Duration
+		expected Result
+	}{
+		"EmptyWindows": {
+			expected: Result{Allowed: true, Limit: 10, Remaining: 9},
+		},
+		"PreviousWeightedByOverlap": {
+			// 8 * 0.75 = 6 still counted from the previous window
+			previous: 8,
+			current:  2,
+			elapsed:  15 * time.Second,
+			expected: Result{Allowed: true, Limit: 10, Remaining: 1},
+		},
+		"LastAllowed": {
+			current:  9,
+			elapsed:  30 * time.Second,
+			expected: Result{Allowed: true, Limit: 10, Remaining: 0},
+		},
+		"CurrentWindowFull": {
+			current:  10,
+			elapsed:  20 * time.Second,
+			expected: Result{Limit: 10, RetryAfter: 40 * time.Second},
+		},
+		"PreviousWindowKeepsLimited": {
+			// 10 * 0.5 + 5 = 10: allowed again as soon as the previous window weighs less than 5
+			previous: 10,
+			current:  5,
+			elapsed:  30 * time.Second,
+			expected: Result{Limit: 10, RetryAfter: time.Second},
+		},
+		"PreviousWindowLongBeforeExpiry": {
+			// 20 * 0.75 = 15: the weighted previous count drops under 10 at 30s
+			previous: 20,
+			elapsed:  15 * time.Second,
+			expected: Result{Limit: 10, RetryAfter: 15 * time.Second},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.expected, evaluate(testRule, test.previous, test.current, test.elapsed))
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cash-track/gateway/config"
+)
+
+// sweepThreshold is the number of counters above which expired ones are dropped.
+const sweepThreshold = 10000
+
+type memoryCounter struct {
+	count   uint
+	expires time.Time
+}
+
+// MemoryLimiter keeps the counters of this instance only. It is the fallback while Redis
+// is unreachable: limits are then enforced per instance instead of across the fleet.
+type MemoryLimiter struct {
+	mu       sync.Mutex
+	counters map[string]memoryCounter
+	now      func() time.Time
+}
+
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{
+		counters: make(map[string]memoryCounter),
+		now:      time.Now,
+	}
+}
+
+func (l *MemoryLimiter) Allow(_ context.Context, key string, rule config.RateLimit) (Result, error) {
+	now := l.now()
+	start, elapsed := windowStart(now, rule.Window)
+
+	previousKey := windowKey(rule, key, start.Add(-rule.Window))
+	currentKey := windowKey(rule, key, start)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.counters) > sweepThreshold {
+		l.sweep(now)
+	}
+
+	result := evaluate(rule, l.count(previousKey, now), l.count(currentKey, now), elapsed)
+	if result.Allowed {
+		l.counters[currentKey] = memoryCounter{
+			count:   l.count(currentKey, now) + 1,
+			expires: start.Add(2 * rule.Window),
+		}
+	}
+
+	return result, nil
+}
+
+func (l *MemoryLimiter) count(key string, now time.Time) uint {
+	c, ok := l.counters[key]
+	if !ok || !now.Before(c.expires) {
+		return 0
+	}
+
+	return c.count
+}
+
+func (l *MemoryLimiter) sweep(now time.Time) {
+	for key, c := range l.counters {
+		if !now.Before(c.expires) {
+			delete(l.counters, key)
+		}
+	}
+}
+
+func windowKey(rule config.RateLimit, key string, start time.Time) string {
+	return rule.Name + ":" + key + ":" + start.Format(time.RFC3339Nano)
+}
@@ -0,0 +1,54 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cash-track/gateway/config"
+)
+
+func TestMemoryLimiterAllow(t *testing.T) {
+	now := time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC)
+	rule := config.RateLimit{Name: "auth", Prefix: "/api/auth", Limit: 3, Window: time.Minute}
+
+	l := NewMemoryLimiter()
+	l.now = func() time.Time { return now }
+
+	for i := uint(0); i < 3; i++ {
+		result, err := l.Allow(context.Background(), "10.0.0.1", rule)
+		assert.NoError(t, err)
+		assert.Equal(t, Result{Allowed: true, Limit: 3, Remaining: 2 - i}, result)
+	}
+
+	result, _ := l.Allow(context.Background(), "10.0.0.1", rule)
+	assert.Equal(t, Result{Limit: 3, RetryAfter: time.Minute}, result)
+
+	// other clients have their own counters
+	result, _ = l.Allow(context.Background(), "10.0.0.2", rule)
+	assert.True(t, result.Allowed)
+
+	// 3 * 0.5 = 1 still counted from the previous window
+	now = now.Add(90 * time.Second)
+	result, _ = l.Allow(context.Background(), "10.0.0.1", rule)
+	assert.Equal(t, Result{Allowed: true, Limit: 3, Remaining: 1}, result)
+
+	// both windows expired
+	now = now.Add(2 * time.Minute)
+	result, _ = l.Allow(context.Background(), "10.0.0.1", rule)
+	assert.Equal(t, Result{Allowed: true, Limit: 3, Remaining: 2}, result)
+}
+
+func TestMemoryLimiterSweep(t *testing.T) {
+	now := time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC)
+
+	l := NewMemoryLimiter()
+	l.counters["expired"] = memoryCounter{count: 1, expires: now}
+	l.counters["active"] = memoryCounter{count: 1, expires: now.Add(time.Second)}
+
+	l.sweep(now)
+
+	assert.Equal(t, map[string]memoryCounter{"active": {count: 1, expires: now.Add(time.Second)}}, l.counters)
+}
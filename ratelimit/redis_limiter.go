@@ -0,0 +1,68 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/cash-track/gateway/config"
+)
+
+const keyPrefix = "CT:ratelimit"
+
+// allowScript reads both window counters and counts the request in the current window
+// only when allowed, so rejected attempts do not extend a lockout. Returns
+// {allowed, previous, current} with current before counting.
+var allowScript = redis.NewScript(`
+local previous = tonumber(redis.call('GET', KEYS[1]) or '0')
+local current = tonumber(redis.call('GET', KEYS[2]) or '0')
+local limit = tonumber(ARGV[1])
+local overlap = tonumber(ARGV[2])
+
+if math.floor(previous * overlap) + current >= limit then
+	return {0, previous, current}
+end
+
+redis.call('INCR', KEYS[2])
+redis.call('PEXPIRE', KEYS[2], ARGV[3])
+
+return {1, previous, current}
+`)
+
+type RedisLimiter struct {
+	client *redis.Client
+	now    func() time.Time
+}
+
+func NewRedisLimiter(client *redis.Client) *RedisLimiter {
+	return &RedisLimiter{
+		client: client,
+		now:    time.Now,
+	}
+}
+
+func (l *RedisLimiter) Allow(ctx context.Context, key string, rule config.RateLimit) (Result, error) {
+	start, elapsed := windowStart(l.now(), rule.Window)
+	overlap := float64(rule.Window-elapsed) / float64(rule.Window)
+
+	keys := []string{
+		fmt.Sprintf("%s:%s:%s:%d", keyPrefix, rule.Name, key, start.Add(-rule.Window).UnixMilli()),
+		fmt.Sprintf("%s:%s:%s:%d", keyPrefix, rule.Name, key, start.UnixMilli()),
+	}
+
+	// the current counter is still read as the previous one during the next window
+	ttl := (2 * rule.Window).Milliseconds()
+
+	values, err := allowScript.Run(ctx, l.client, keys, rule.Limit, overlap, ttl).Int64Slice()
+	if err != nil {
+		return Result{}, fmt.Errorf("rate limit script: %w", err)
+	}
+
+	if len(values) != 3 {
+		return Result{}, fmt.Errorf("rate limit script: unexpected reply %v", values)
+	}
+
+	return evaluate(rule, uint(values[1]), uint(values[2]), elapsed), nil
+}
@@ -0,0 +1,68 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redismock/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedisLimiterAllow(t *testing.T) {
+	now := time.Date(2024, 5, 1, 10, 30, 15, 0, time.UTC)
+	keys := []string{
+		"CT:ratelimit:auth:10.0.0.1:1714559340000",
+		"CT:ratelimit:auth:10.0.0.1:1714559400000",
+	}
+
+	for name, test := range map[string]struct {
+		reply    []interface{}
+		err      error
+		expected Result
+		wantErr  string
+	}{
+		"Allowed": {
+			reply:    []interface{}{int64(1), int64(4), int64(2)},
+			expected: Result{Allowed: true, Limit: 10, Remaining: 4},
+		},
+		"Limited": {
+			reply:    []interface{}{int64(0), int64(0), int64(10)},
+			expected: Result{Limit: 10, RetryAfter: 45 * time.Second},
+		},
+		"RedisError": {
+			err:     errors.New("connection refused"),
+			wantErr: "rate limit script: connection refused",
+		},
+		"UnexpectedReply": {
+			reply:   []interface{}{int64(1)},
+			wantErr: "rate limit script: unexpected reply [1]",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			client, mock := redismock.NewClientMock()
+
+			l := NewRedisLimiter(client)
+			l.now = func() time.Time { return now }
+
+			expect := mock.ExpectEvalSha(allowScript.Hash(), keys, uint(10), 0.75, int64(120000))
+			if test.err != nil {
+				expect.SetErr(test.err)
+			} else {
+				expect.SetVal(test.reply)
+			}
+
+			result, err := l.Allow(context.Background(), "10.0.0.1", testRule)
+
+			if test.wantErr != "" {
+				assert.EqualError(t, err, test.wantErr)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, test.expected, result)
+			}
+
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log/slog"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/fasthttp/router"
+	"github.com/valyala/fasthttp"
+
+	"github.com/cash-track/gateway/headers"
+	"github.com/cash-track/gateway/router/response"
+	"github.com/cash-track/gateway/service/api"
+)
+
+const bearerPrefix = "Bearer "
+
+// Router serves the admin endpoints on their own listener, every one of them behind the
+// ADMIN_TOKEN bearer token:
+//
+//	GET  /breakers                 state, mode and counts of every circuit breaker
+//	POST /breakers/open?name=API   reject every call to the upstream
+//	POST /breakers/close?name=API  let every call through, failing or not
+//	POST /breakers/auto?name=API   hand the breaker back to its counts
+//
+// The breakers are those of this replica only, named by the replica of every answer: a
+// mode has to be set on each replica behind the load balancer.
+type Router struct {
+	*router.Router
+
+	token    []byte
+	breakers []*api.Breaker
+	replica  string
+}
+
+func New(token string, breakers []*api.Breaker) *Router {
+	replica, _ := os.Hostname()
+
+	r := &Router{
+		Router:   router.New(),
+		token:    []byte(token),
+		breakers: breakers,
+		replica:  replica,
+	}
+	r.register()
+
+	return r
+}
+
+func (r *Router) register() {
+	r.GET("/breakers", r.auth(r.ListHandler))
+	r.POST("/breakers/open", r.auth(r.modeHandler(api.BreakerForcedOpen)))
+	r.POST("/breakers/close", r.auth(r.modeHandler(api.BreakerForcedClosed)))
+	r.POST("/breakers/auto", r.auth(r.modeHandler(api.BreakerAuto)))
+}
+
+type breakerResponse struct {
+	Name  string `json:"name"`
+	State string `json:"state"`
+	Mode  string `json:"mode"`
+	// RetryAfter is the whole seconds the breaker stays open for on its counts, zero when
+	// forced open.
+	RetryAfter int            `json:"retryAfter"`
+	Counts     countsResponse `json:"counts"`
+	// Replica is the host name of the gateway instance the breaker belongs to.
+	Replica string `json:"replica"`
+}
+
+type countsResponse struct {
+	Requests             uint32 `json:"requests"`
+	TotalSuccesses       uint32 `json:"totalSuccesses"`
+	TotalFailures        uint32 `json:"totalFailures"`
+	ConsecutiveSuccesses uint32 `json:"consecutiveSuccesses"`
+	ConsecutiveFailures  uint32 `json:"consecutiveFailures"`
+}
+
+type listResponse struct {
+	Data []breakerResponse `json:"data"`
+}
+
+func (r *Router) ListHandler(ctx *fasthttp.RequestCtx) {
+	list := listResponse{Data: make([]breakerResponse, 0, len(r.breakers))}
+	for _, b := range r.breakers {
+		list.Data = append(list.Data, r.newBreakerResponse(b))
+	}
+
+	writeJson(ctx, list)
+}
+
+// modeHandler forces the breaker named by the name query argument into mode.
+func (r *Router) modeHandler(mode api.BreakerMode) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		name := string(ctx.QueryArgs().Peek("name"))
+
+		b := r.find(name)
+		if b == nil {
+			response.New(response.CodeNotFound).Write(ctx)
+
+			return
+		}
+
+		b.SetMode(mode)
+		slog.Warn("circuit breaker mode set by admin", "service", name, "mode", mode.String(),
+			"replica", r.replica, "client_ip", ctx.RemoteIP().String())
+
+		writeJson(ctx, r.newBreakerResponse(b))
+	}
+}
+
+func (r *Router) find(name string) *api.Breaker {
+	for _, b := range r.breakers {
+		if b.Name() == name {
+			return b
+		}
+	}
+
+	return nil
+}
+
+// auth rejects requests without the admin token with 401, comparing in constant time.
+func (r *Router) auth(h fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		token, ok := strings.CutPrefix(string(ctx.Request.Header.Peek(headers.Authorization)), bearerPrefix)
+		if !ok || subtle.ConstantTimeCompare([]byte(token), r.token) != 1 {
+			response.New(response.CodeTokenInvalid).Write(ctx)
+
+			return
+		}
+
+		h(ctx)
+	}
+}
+
+func (r *Router) newBreakerResponse(b *api.Breaker) breakerResponse {
+	counts := b.Counts()
+
+	return breakerResponse{
+		Name:       b.Name(),
+		State:      b.State().String(),
+		Mode:       b.Mode().String(),
+		RetryAfter: int(math.Ceil(b.RemainingOpen().Seconds())),
+		Counts: countsResponse{
+			Requests:             counts.Requests,
+			TotalSuccesses:       counts.TotalSuccesses,
+			TotalFailures:        counts.TotalFailures,
+			ConsecutiveSuccesses: counts.ConsecutiveSuccesses,
+			ConsecutiveFailures:  counts.ConsecutiveFailures,
+		},
+		Replica: r.replica,
+	}
+}
+
+func writeJson(ctx *fasthttp.RequestCtx, v any) {
+	b, _ := json.Marshal(v)
+
+	ctx.SetStatusCode(fasthttp.StatusOK)
+	ctx.Response.Header.SetContentTypeBytes(headers.ContentTypeJson)
+	ctx.Response.SetBody(b)
+}
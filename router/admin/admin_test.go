@@ -0,0 +1,96 @@
+package admin
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+
+	"github.com/cash-track/gateway/service/api"
+)
+
+func serve(r *Router, method, uri, token string) *fasthttp.RequestCtx {
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod(method)
+	ctx.Request.SetRequestURI(uri)
+	if token != "" {
+		ctx.Request.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	r.Handler(ctx)
+
+	return ctx
+}
+
+func TestAuth(t *testing.T) {
+	r := New("secret", []*api.Breaker{api.NewBreaker()})
+
+	tests := map[string]struct {
+		token  string
+		status int
+	}{
+		"Missing": {status: fasthttp.StatusUnauthorized},
+		"Wrong":   {token: "guess", status: fasthttp.StatusUnauthorized},
+		"Valid":   {token: "secret", status: fasthttp.StatusOK},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx := serve(r, fasthttp.MethodGet, "/breakers", tt.token)
+
+			assert.Equal(t, tt.status, ctx.Response.StatusCode())
+		})
+	}
+}
+
+func TestListHandler(t *testing.T) {
+	r := New("secret", []*api.Breaker{api.NewBreaker()})
+	r.replica = "gateway-7d9c"
+
+	ctx := serve(r, fasthttp.MethodGet, "/breakers", "secret")
+
+	assert.Equal(t, fasthttp.StatusOK, ctx.Response.StatusCode())
+	assert.Equal(t, "application/json", string(ctx.Response.Header.ContentType()))
+	assert.JSONEq(t, `{"data":[{
+		"name":"API","state":"closed","mode":"auto","retryAfter":0,
+		"counts":{"requests":0,"totalSuccesses":0,"totalFailures":0,"consecutiveSuccesses":0,"consecutiveFailures":0},
+		"replica":"gateway-7d9c"
+	}]}`, string(ctx.Response.Body()))
+}
+
+func TestModeHandlers(t *testing.T) {
+	breaker := api.NewBreaker()
+	r := New("secret", []*api.Breaker{breaker})
+
+	ctx := serve(r, fasthttp.MethodPost, "/breakers/open?name=API", "secret")
+	assert.Equal(t, fasthttp.StatusOK, ctx.Response.StatusCode())
+	assert.Equal(t, api.BreakerForcedOpen, breaker.Mode())
+
+	var open breakerResponse
+	assert.NoError(t, json.Unmarshal(ctx.Response.Body(), &open))
+	assert.Equal(t, "open", open.State)
+	assert.Equal(t, "forced-open", open.Mode)
+	assert.Zero(t, open.RetryAfter)
+	hostname, _ := os.Hostname()
+	assert.Equal(t, hostname, open.Replica)
+
+	ctx = serve(r, fasthttp.MethodPost, "/breakers/close?name=API", "secret")
+	assert.Equal(t, fasthttp.StatusOK, ctx.Response.StatusCode())
+	assert.Equal(t, api.BreakerForcedClosed, breaker.Mode())
+
+	ctx = serve(r, fasthttp.MethodPost, "/breakers/auto?name=API", "secret")
+	assert.Equal(t, fasthttp.StatusOK, ctx.Response.StatusCode())
+	assert.Equal(t, api.BreakerAuto, breaker.Mode())
+}
+
+func TestModeHandlerUnknownBreaker(t *testing.T) {
+	breaker := api.NewBreaker()
+	r := New("secret", []*api.Breaker{breaker})
+
+	ctx := serve(r, fasthttp.MethodPost, "/breakers/open?name=exports", "secret")
+
+	assert.Equal(t, fasthttp.StatusNotFound, ctx.Response.StatusCode())
+	assert.Equal(t, api.BreakerAuto, breaker.Mode())
+}
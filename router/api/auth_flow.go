@@ -0,0 +1,161 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/valyala/fasthttp"
+
+	"github.com/cash-track/gateway/captcha"
+	"github.com/cash-track/gateway/headers/cookie"
+	"github.com/cash-track/gateway/router/response"
+	"github.com/cash-track/gateway/session"
+	"github.com/cash-track/gateway/traces"
+)
+
+// authFlow is the state shared by the stages of one auth request.
+type authFlow struct {
+	ctx  *fasthttp.RequestCtx
+	auth cookie.Auth
+	// stage that stopped the flow, empty when every stage ran
+	stoppedAt string
+	// failure that stopped the flow, nil when a stage stopped it on purpose (e.g. the
+	// backend rejected the credentials)
+	err error
+}
+
+// authStage is one step of an auth endpoint. A stage returns false when it has written
+// the final response, which stops the flow: nothing after it touches ctx.
+type authStage struct {
+	name string
+	run  func(f *authFlow) bool
+}
+
+// authSetStages sign the user in: verify captcha -> forward -> start the session
+// -> seed CSRF -> rewrite body.
+func (h *HttpHandler) authSetStages() []authStage {
+	return append(h.captchaForwardStages(), h.sessionStages()...)
+}
+
+// captchaForwardStages relay the backend response once the captcha is verified.
+func (h *HttpHandler) captchaForwardStages() []authStage {
+	return []authStage{
+		{name: "captcha", run: h.verifyCaptchaStage},
+		{name: "forward", run: h.forwardStage},
+	}
+}
+
+// sessionStages turn a successful backend auth response into a session.
+func (h *HttpHandler) sessionStages() []authStage {
+	return []authStage{
+		{name: "session", run: h.persistSessionStage},
+		{name: "csrf", run: h.seedCsrfStage},
+		{name: "body", run: h.rewriteBodyStage},
+	}
+}
+
+func (h *HttpHandler) runAuthFlow(ctx *fasthttp.RequestCtx, stages []authStage) *authFlow {
+	f := &authFlow{ctx: ctx}
+
+	for _, stage := range stages {
+		if !stage.run(f) {
+			f.stoppedAt = stage.name
+			slog.Debug("auth flow stopped",
+				"trace_id", traces.FindTraceId(ctx),
+				"path", string(ctx.Path()),
+				"stage", stage.name,
+				"status", ctx.Response.StatusCode(),
+			)
+
+			break
+		}
+	}
+
+	return f
+}
+
+func (h *HttpHandler) verifyCaptchaStage(f *authFlow) bool {
+	ok, err := h.captcha.Verify(f.ctx)
+	if err == nil && ok {
+		return true
+	}
+
+	var rejected *captcha.RejectedError
+	switch {
+	case errors.As(err, &rejected):
+		response.NewCaptchaRejectedResponse(rejected.Reason, rejected.Fallback).Write(f.ctx)
+	case err != nil:
+		f.err = err
+		response.NewCaptchaErrorResponse().Write(f.ctx)
+	default:
+		response.NewCaptchaBadResponse().Write(f.ctx)
+	}
+
+	return false
+}
+
+// forwardStage passes the request to the backend. Any response but 200 is final and
+// relayed as the backend wrote it.
+func (h *HttpHandler) forwardStage(f *authFlow) bool {
+	if _, ok := allowedMethods[string(f.ctx.Request.Header.Method())]; !ok {
+		f.err = fmt.Errorf("request method %s is not allowed", f.ctx.Request.Header.Method())
+		writeMethodNotAllowed(f.ctx)
+
+		return false
+	}
+
+	// signing in again is how a device signed out remotely recovers
+	session.ClearRevoked(f.ctx)
+
+	if err := h.service.ForwardRequest(f.ctx, nil); err != nil {
+		f.err = err
+		writeForwardError(f.ctx, err)
+
+		return false
+	}
+
+	return f.ctx.Response.StatusCode() == fasthttp.StatusOK
+}
+
+func (h *HttpHandler) persistSessionStage(f *authFlow) bool {
+	if f.ctx.Response.StatusCode() != fasthttp.StatusOK {
+		return false
+	}
+
+	if err := json.Unmarshal(f.ctx.Response.Body(), &f.auth); err != nil {
+		f.err = fmt.Errorf("login response body invalid: %w", err)
+		response.New(response.CodeUpstreamUnavailable).Write(f.ctx)
+
+		return false
+	}
+
+	if err := h.sessions.Start(f.ctx, f.auth); err != nil {
+		f.err = fmt.Errorf("login start session: %w", err)
+		response.New(response.CodeUpstreamUnavailable).Write(f.ctx)
+
+		return false
+	}
+
+	return true
+}
+
+// seedCsrfStage never stops the flow: if Redis is unavailable the user will recover
+// automatically on their first mutation via GET /csrf.
+func (h *HttpHandler) seedCsrfStage(f *authFlow) bool {
+	if err := h.csrf.Seed(f.ctx, f.auth); err != nil {
+		slog.Warn("csrf seed failed after login", "trace_id", traces.FindTraceId(f.ctx), "error", err)
+	}
+
+	return true
+}
+
+// rewriteBodyStage replaces the tokens in the body, now kept in the session only, with
+// the web app redirect.
+func (h *HttpHandler) rewriteBodyStage(f *authFlow) bool {
+	b, _ := h.newWebAppRedirect().ToJson()
+	f.ctx.Response.SetBody(b)
+
+	return true
+}
@@ -0,0 +1,321 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+	"go.uber.org/mock/gomock"
+
+	"github.com/cash-track/gateway/captcha"
+	"github.com/cash-track/gateway/config"
+	"github.com/cash-track/gateway/headers"
+	"github.com/cash-track/gateway/headers/cookie"
+	"github.com/cash-track/gateway/mocks"
+	"github.com/cash-track/gateway/service/api"
+)
+
+type mockCSRFSeeder struct {
+	err    error
+	called bool
+	auth   cookie.Auth
+}
+
+func (m *mockCSRFSeeder) Seed(ctx *fasthttp.RequestCtx, auth cookie.Auth) error {
+	m.called = true
+	m.auth = auth
+	return m.err
+}
+
+func TestAuthFlow(t *testing.T) {
+	tomorrow := time.Now().Add(time.Hour * 24).Format(time.RFC3339)
+	authBody := fmt.Sprintf(`{"accessToken":"new_access_token","refreshToken":"new_refresh_token","refreshTokenExpiredAt":"%s"}`, tomorrow)
+
+	type expectation struct {
+		stoppedAt    string
+		status       int
+		body         string
+		cookies      bool
+		seeded       bool
+		forwardCalls int
+	}
+
+	routes := map[string]struct {
+		path string
+		// passkey init only verifies the captcha: no session is created
+		sessionless bool
+	}{
+		"Login":          {path: "/api/auth/login"},
+		"LoginPasskey":   {path: "/api/auth/login/passkey"},
+		"Register":       {path: "/api/auth/register"},
+		"ProviderGoogle": {path: "/api/auth/provider/google"},
+		"PasskeyInit":    {path: "/api/auth/login/passkey/init", sessionless: true},
+	}
+
+	cases := map[string]struct {
+		method            string
+		verifyOk          bool
+		verifyErr         error
+		forwardErr        error
+		backendCode       int
+		backendBody       string
+		seedErr           error
+		expect            expectation
+		expectSessionless *expectation
+	}{
+		"Success": {
+			verifyOk:    true,
+			backendCode: fasthttp.StatusOK,
+			backendBody: authBody,
+			expect: expectation{
+				status:       fasthttp.StatusOK,
+				body:         `{"redirectUrl":"https://my.cash-track.app"}`,
+				cookies:      true,
+				seeded:       true,
+				forwardCalls: 1,
+			},
+			expectSessionless: &expectation{status: fasthttp.StatusOK, body: authBody, forwardCalls: 1},
+		},
+		"CaptchaUnsuccessful": {
+			expect: expectation{
+				stoppedAt: "captcha",
+				status:    fasthttp.StatusBadRequest,
+				body:      `{"code":"captcha_failed","message":"Captcha validation unsuccessful. Please try again."}`,
+			},
+		},
+		"CaptchaProviderError": {
+			verifyErr: errors.New("captcha api down"),
+			expect: expectation{
+				stoppedAt: "captcha",
+				status:    fasthttp.StatusInternalServerError,
+				body:      `{"code":"captcha_unavailable","message":"Unexpected response from captcha validation service. Please try again later."}`,
+			},
+		},
+		"CaptchaRejectedByPolicy": {
+			verifyErr: &captcha.RejectedError{Reason: captcha.RejectedReplay, Detail: "challenge already used"},
+			expect: expectation{
+				stoppedAt: "captcha",
+				status:    fasthttp.StatusBadRequest,
+				body:      `{"code":"captcha_failed","fallback":false,"message":"Captcha validation unsuccessful. Please try again.","reason":"replay"}`,
+			},
+		},
+		"MethodNotAllowed": {
+			method:   fasthttp.MethodTrace,
+			verifyOk: true,
+			expect: expectation{
+				stoppedAt: "forward",
+				status:    fasthttp.StatusMethodNotAllowed,
+				body:      `{"code":"method_not_allowed","message":"This request method is not allowed."}`,
+			},
+		},
+		"ForwardError": {
+			verifyOk:   true,
+			forwardErr: errors.New("connection refused"),
+			expect: expectation{
+				stoppedAt:    "forward",
+				status:       fasthttp.StatusBadGateway,
+				body:         `{"code":"upstream_unavailable","message":"The service is temporarily unavailable. Please try again later."}`,
+				forwardCalls: 1,
+			},
+		},
+		"CircuitOpen": {
+			verifyOk:   true,
+			forwardErr: api.ErrCircuitOpen,
+			expect: expectation{
+				stoppedAt:    "forward",
+				status:       fasthttp.StatusServiceUnavailable,
+				body:         `{"code":"circuit_open","message":"The service is temporarily unavailable. Please try again in a few seconds."}`,
+				forwardCalls: 1,
+			},
+		},
+		"BackendRejectsCredentials": {
+			verifyOk:    true,
+			backendCode: fasthttp.StatusUnauthorized,
+			backendBody: `{"message":"Wrong email or password."}`,
+			expect: expectation{
+				stoppedAt:    "forward",
+				status:       fasthttp.StatusUnauthorized,
+				body:         `{"message":"Wrong email or password."}`,
+				forwardCalls: 1,
+			},
+		},
+		"BackendBodyInvalid": {
+			verifyOk:    true,
+			backendCode: fasthttp.StatusOK,
+			backendBody: `{"accessToken":"new_access_token"`,
+			expect: expectation{
+				stoppedAt:    "session",
+				status:       fasthttp.StatusBadGateway,
+				body:         `{"code":"upstream_unavailable","message":"The service is temporarily unavailable. Please try again later."}`,
+				forwardCalls: 1,
+			},
+			expectSessionless: &expectation{status: fasthttp.StatusOK, body: `{"accessToken":"new_access_token"`, forwardCalls: 1},
+		},
+		"CookieWriteFails": {
+			verifyOk:    true,
+			backendCode: fasthttp.StatusOK,
+			backendBody: `{"accessToken":"new_access_token","refreshToken":"new_refresh_token","refreshTokenExpiredAt":"not-a-timestamp"}`,
+			expect: expectation{
+				stoppedAt:    "session",
+				status:       fasthttp.StatusBadGateway,
+				forwardCalls: 1,
+			},
+			expectSessionless: &expectation{
+				status:       fasthttp.StatusOK,
+				body:         `{"accessToken":"new_access_token","refreshToken":"new_refresh_token","refreshTokenExpiredAt":"not-a-timestamp"}`,
+				forwardCalls: 1,
+			},
+		},
+		"CsrfSeedFailsNonFatal": {
+			verifyOk:    true,
+			backendCode: fasthttp.StatusOK,
+			backendBody: authBody,
+			seedErr:     errors.New("redis down"),
+			expect: expectation{
+				status:       fasthttp.StatusOK,
+				body:         `{"redirectUrl":"https://my.cash-track.app"}`,
+				cookies:      true,
+				seeded:       true,
+				forwardCalls: 1,
+			},
+			expectSessionless: &expectation{status: fasthttp.StatusOK, body: authBody, forwardCalls: 1},
+		},
+	}
+
+	for routeName, route := range routes {
+		for caseName, test := range cases {
+			t.Run(routeName+"/"+caseName, func(t *testing.T) {
+				expect := test.expect
+				if route.sessionless && test.expectSessionless != nil {
+					expect = *test.expectSessionless
+				}
+
+				ctrl := gomock.NewController(t)
+				s := mocks.NewApiServiceMock(ctrl)
+				c := mocks.NewCaptchaProviderMock(ctrl)
+				csrf := &mockCSRFSeeder{err: test.seedErr}
+				h := NewHttp(config.Config{WebAppUrl: "https://my.cash-track.app"}, s, c, csrf)
+
+				c.EXPECT().Verify(gomock.Any()).Return(test.verifyOk, test.verifyErr)
+				s.EXPECT().ForwardRequest(gomock.Any(), nil).Times(expect.forwardCalls).DoAndReturn(func(ctx *fasthttp.RequestCtx, _ []byte) error {
+					if test.forwardErr != nil {
+						return test.forwardErr
+					}
+
+					ctx.Response.SetStatusCode(test.backendCode)
+					ctx.Response.SetBodyString(test.backendBody)
+
+					return nil
+				})
+
+				ctx := &fasthttp.RequestCtx{}
+				ctx.Request.SetRequestURI(route.path)
+				ctx.Request.Header.SetMethod(fasthttp.MethodPost)
+				if test.method != "" {
+					ctx.Request.Header.SetMethod(test.method)
+				}
+
+				stages := h.authSetStages()
+				if route.sessionless {
+					stages = h.captchaForwardStages()
+				}
+
+				f := h.runAuthFlow(ctx, stages)
+
+				assert.Equal(t, expect.stoppedAt, f.stoppedAt)
+				assert.Equal(t, expect.status, ctx.Response.StatusCode())
+				if expect.status == fasthttp.StatusMethodNotAllowed {
+					assert.NotEmpty(t, ctx.Response.Header.Peek(headers.Allow))
+				}
+				if expect.body != "" {
+					assert.Equal(t, expect.body, string(ctx.Response.Body()))
+				}
+				assert.Equal(t, expect.seeded, csrf.called)
+
+				if expect.cookies {
+					assert.Contains(t, string(ctx.Response.Header.PeekCookie(cookie.AccessTokenCookieName)), "new_access_token")
+					assert.Contains(t, string(ctx.Response.Header.PeekCookie(cookie.RefreshTokenCookieName)), "new_refresh_token")
+				} else {
+					assert.Empty(t, ctx.Response.Header.PeekCookie(cookie.AccessTokenCookieName))
+					assert.Empty(t, ctx.Response.Header.PeekCookie(cookie.RefreshTokenCookieName))
+				}
+			})
+		}
+	}
+}
+
+// The handlers the router registers run the same stages as above.
+func TestAuthSetHandlerStopsAfterCaptchaFailure(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	s := mocks.NewApiServiceMock(ctrl)
+	c := mocks.NewCaptchaProviderMock(ctrl)
+	csrf := &mockCSRFSeeder{}
+	h := NewHttp(config.Config{WebAppUrl: "https://my.cash-track.app"}, s, c, csrf)
+
+	// no ForwardRequest expected
+	c.EXPECT().Verify(gomock.Any()).Return(false, nil)
+
+	ctx := fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod(fasthttp.MethodPost)
+
+	h.AuthSetHandler(&ctx)
+
+	assert.Equal(t, fasthttp.StatusBadRequest, ctx.Response.StatusCode())
+	assert.Equal(t, `{"code":"captcha_failed","message":"Captcha validation unsuccessful. Please try again."}`, string(ctx.Response.Body()))
+	assert.False(t, csrf.called)
+}
+
+func TestCaptchaVerifyHandlerForwardsAsIs(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	s := mocks.NewApiServiceMock(ctrl)
+	c := mocks.NewCaptchaProviderMock(ctrl)
+	csrf := &mockCSRFSeeder{}
+	h := NewHttp(config.Config{WebAppUrl: "https://my.cash-track.app"}, s, c, csrf)
+
+	c.EXPECT().Verify(gomock.Any()).Return(true, nil)
+	s.EXPECT().ForwardRequest(gomock.Any(), nil).DoAndReturn(func(ctx *fasthttp.RequestCtx, _ []byte) error {
+		ctx.Response.SetStatusCode(fasthttp.StatusOK)
+		ctx.Response.SetBodyString(`{"challenge":"abc"}`)
+
+		return nil
+	})
+
+	ctx := fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod(fasthttp.MethodPost)
+
+	h.CaptchaVerifyHandler(&ctx)
+
+	assert.Equal(t, fasthttp.StatusOK, ctx.Response.StatusCode())
+	assert.Equal(t, `{"challenge":"abc"}`, string(ctx.Response.Body()))
+	assert.False(t, csrf.called)
+}
+
+// TestAuthSetHandlerMalformedRefreshExpiryReturns502 exercises the full handler chain
+// (AuthSetHandler -> session stages) to confirm a malformed refreshTokenExpiredAt from the
+// backend surfaces as a 502, per writeForwardError/response.ByErrorAndStatus mapping.
+func TestAuthSetHandlerMalformedRefreshExpiryReturns502(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	s := mocks.NewApiServiceMock(ctrl)
+	c := mocks.NewCaptchaProviderMock(ctrl)
+	csrf := &mockCSRFSeeder{}
+	h := NewHttp(config.Config{WebAppUrl: "https://home.com"}, s, c, csrf)
+
+	c.EXPECT().Verify(gomock.Any()).Return(true, nil)
+	s.EXPECT().ForwardRequest(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx *fasthttp.RequestCtx, _ []byte) error {
+		ctx.Response.SetStatusCode(fasthttp.StatusOK)
+		ctx.Response.SetBodyString(`{"accessToken":"new_access_token","refreshToken":"new_refresh_token","refreshTokenExpiredAt":"not-a-timestamp"}`)
+
+		return nil
+	})
+
+	ctx := fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod(fasthttp.MethodPost)
+
+	h.AuthSetHandler(&ctx)
+
+	assert.Equal(t, fasthttp.StatusBadGateway, ctx.Response.StatusCode())
+	assert.Empty(t, ctx.Response.Header.PeekCookie(cookie.AccessTokenCookieName))
+}
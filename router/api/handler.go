@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log/slog"
 	"strconv"
+	"strings"
 
 	"github.com/valyala/fasthttp"
 
@@ -13,9 +14,11 @@ import (
 	"github.com/cash-track/gateway/config"
 	"github.com/cash-track/gateway/headers"
 	"github.com/cash-track/gateway/headers/cookie"
+	bodyHandler "github.com/cash-track/gateway/router/body"
 	"github.com/cash-track/gateway/router/csrf"
 	"github.com/cash-track/gateway/router/response"
 	"github.com/cash-track/gateway/service/api"
+	"github.com/cash-track/gateway/session"
 	"github.com/cash-track/gateway/traces"
 )
 
@@ -28,6 +31,16 @@ var allowedMethods = map[string]bool{
 	fasthttp.MethodOptions: true,
 }
 
+// allowHeader lists allowedMethods for the Allow header a 405 must carry.
+var allowHeader = strings.Join([]string{
+	fasthttp.MethodGet,
+	fasthttp.MethodPost,
+	fasthttp.MethodPut,
+	fasthttp.MethodPatch,
+	fasthttp.MethodDelete,
+	fasthttp.MethodOptions,
+}, ", ")
+
 type Handler interface {
 	AuthSetHandler(ctx *fasthttp.RequestCtx)
 	AuthResetHandler(ctx *fasthttp.RequestCtx)
@@ -37,49 +50,53 @@ type Handler interface {
 }
 
 type HttpHandler struct {
-	config  config.Config
-	captcha captcha.Provider
-	service api.Service
-	csrf    csrf.CSRFSeeder
+	config   config.Config
+	captcha  captcha.Provider
+	service  api.Service
+	csrf     csrf.CSRFSeeder
+	sessions session.Store
 }
 
 func NewHttp(config config.Config, service api.Service, captcha captcha.Provider, csrf csrf.CSRFSeeder) *HttpHandler {
 	return &HttpHandler{
-		config:  config,
-		captcha: captcha,
-		service: service,
-		csrf:    csrf,
+		config:   config,
+		captcha:  captcha,
+		service:  service,
+		csrf:     csrf,
+		sessions: session.CookieStore{},
 	}
 }
 
-func (h *HttpHandler) AuthSetHandler(ctx *fasthttp.RequestCtx) {
-	h.CaptchaVerifyHandler(ctx)
+// WithSessions keeps the tokens of a login in sessions instead of the default cookies.
+func (h *HttpHandler) WithSessions(sessions session.Store) *HttpHandler {
+	h.sessions = sessions
 
-	if err := h.Login(ctx); err != nil {
-		response.ByErrorAndStatus(err, fasthttp.StatusBadGateway).Write(ctx)
-	}
+	return h
 }
 
-func (h *HttpHandler) CaptchaVerifyHandler(ctx *fasthttp.RequestCtx) {
-	if ok, err := h.captcha.Verify(ctx); err != nil || !ok {
-		if err != nil {
-			response.NewCaptchaErrorResponse(err).Write(ctx)
-
-			return
-		} else {
-			response.NewCaptchaBadResponse().Write(ctx)
-
-			return
-		}
-	}
+// AuthSetHandler signs the user in on the login, register, passkey and Google provider
+// endpoints, see authSetStages.
+func (h *HttpHandler) AuthSetHandler(ctx *fasthttp.RequestCtx) {
+	h.runAuthFlow(ctx, h.authSetStages())
+}
 
-	h.FullForwardedHandler(ctx)
+// CaptchaVerifyHandler forwards the request only once the captcha is verified.
+func (h *HttpHandler) CaptchaVerifyHandler(ctx *fasthttp.RequestCtx) {
+	h.runAuthFlow(ctx, h.captchaForwardStages())
 }
 
 func (h *HttpHandler) AuthResetHandler(ctx *fasthttp.RequestCtx) {
-	auth := cookie.ReadAuthCookie(ctx)
+	auth, err := h.sessions.Resolve(ctx)
+	if err != nil && !errors.Is(err, session.ErrRevoked) {
+		slog.Warn("logout: session unreadable, signing out without the backend",
+			"trace_id", traces.FindTraceId(ctx),
+			"error", err,
+		)
+	}
+
+	api.SkipRefreshAhead(ctx)
 
-	err := h.FullForwardedHandlerWithBody(ctx, cookie.Auth{
+	err = h.FullForwardedHandlerWithBody(ctx, cookie.Auth{
 		RefreshToken: auth.RefreshToken,
 	})
 	if err != nil {
@@ -104,17 +121,21 @@ func (h *HttpHandler) AuthResetHandler(ctx *fasthttp.RequestCtx) {
 	h.Logout(ctx)
 }
 
+// FullForwardedHandler forwards ctx as it came. The WebSocket upgrades and Server-Sent
+// Events subscriptions on a config.Realtime route are proxied for as long as they stay open.
 func (h *HttpHandler) FullForwardedHandler(ctx *fasthttp.RequestCtx) {
 	if _, ok := allowedMethods[string(ctx.Request.Header.Method())]; !ok {
-		response.ByErrorAndStatus(
-			fmt.Errorf("request method %s is not allowed", ctx.Request.Header.Method()),
-			fasthttp.StatusBadRequest,
-		).Write(ctx)
+		writeMethodNotAllowed(ctx)
 
 		return
 	}
 
-	err := h.service.ForwardRequest(ctx, nil)
+	var err error
+	if route, ok := h.config.FindRealtime(string(ctx.Path())); ok {
+		err = h.service.ForwardRealtime(ctx, route)
+	} else {
+		err = h.service.ForwardRequest(ctx, nil)
+	}
 	if err != nil {
 		writeForwardError(ctx, err)
 
@@ -127,14 +148,14 @@ func (h *HttpHandler) FullForwardedHandler(ctx *fasthttp.RequestCtx) {
 func (h *HttpHandler) FullForwardedHandlerWithBody(ctx *fasthttp.RequestCtx, body any) error {
 	if _, ok := allowedMethods[string(ctx.Request.Header.Method())]; !ok {
 		err := fmt.Errorf("request method %s is not allowed", ctx.Request.Header.Method())
-		response.ByErrorAndStatus(err, fasthttp.StatusBadRequest).Write(ctx)
+		writeMethodNotAllowed(ctx)
 
 		return err
 	}
 
 	b, err := json.Marshal(body)
 	if err != nil {
-		response.ByError(err).Write(ctx)
+		response.New(response.CodeInternalError).Write(ctx)
 
 		return err
 	}
@@ -149,7 +170,8 @@ func (h *HttpHandler) FullForwardedHandlerWithBody(ctx *fasthttp.RequestCtx, bod
 }
 
 // writeForwardError maps a ForwardRequest failure to a response: 503 with a Retry-After
-// hint when the circuit breaker is open, 502 for any other transport error.
+// hint when the circuit breaker is open, 413 when a streamed body outgrew its limit, 502 for
+// any other transport error.
 func writeForwardError(ctx *fasthttp.RequestCtx, err error) {
 	attrs := []any{
 		"trace_id", traces.FindTraceId(ctx),
@@ -161,17 +183,34 @@ func writeForwardError(ctx *fasthttp.RequestCtx, err error) {
 	if errors.Is(err, api.ErrCircuitOpen) {
 		slog.Warn("forward request rejected: circuit breaker open", attrs...)
 
-		ctx.Response.Header.Set(headers.RetryAfter, strconv.Itoa(api.RetryAfterSeconds))
-		response.ByErrorAndStatus(err, fasthttp.StatusServiceUnavailable).Write(ctx)
+		// a breaker forced open has no end time to tell
+		if retryAfter := api.RetryAfter(err); retryAfter > 0 {
+			ctx.Response.Header.Set(headers.RetryAfter, strconv.Itoa(retryAfter))
+		}
+		response.New(response.CodeCircuitOpen).Write(ctx)
+
+		return
+	}
+
+	if errors.Is(err, bodyHandler.ErrTooLarge) {
+		slog.Info("forward request aborted: streamed body too large", attrs...)
+
+		response.New(response.CodeBodyTooLarge).Write(ctx)
+		ctx.SetConnectionClose()
 
 		return
 	}
 
 	slog.Error("forward request failed", attrs...)
 
-	response.ByErrorAndStatus(err, fasthttp.StatusBadGateway).Write(ctx)
+	response.New(response.CodeUpstreamUnavailable).Write(ctx)
 }
 
 func (h *HttpHandler) Healthcheck() error {
 	return h.service.Healthcheck()
 }
+
+func writeMethodNotAllowed(ctx *fasthttp.RequestCtx) {
+	ctx.Response.Header.Set(headers.Allow, allowHeader)
+	response.New(response.CodeMethodNotAllowed).Write(ctx)
+}
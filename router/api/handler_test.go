@@ -13,10 +13,12 @@ import (
 	"github.com/valyala/fasthttp"
 	"go.uber.org/mock/gomock"
 
+	"github.com/cash-track/gateway/captcha"
 	"github.com/cash-track/gateway/config"
 	"github.com/cash-track/gateway/headers"
 	"github.com/cash-track/gateway/headers/cookie"
 	"github.com/cash-track/gateway/mocks"
+	bodyHandler "github.com/cash-track/gateway/router/body"
 	"github.com/cash-track/gateway/service/api"
 )
 
@@ -93,6 +95,32 @@ func TestAuthSetHandlerCaptchaError(t *testing.T) {
 	assert.Equal(t, fasthttp.StatusInternalServerError, ctx.Response.StatusCode())
 }
 
+func TestCaptchaVerifyHandlerRejected(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	s := mocks.NewApiServiceMock(ctrl)
+	c := mocks.NewCaptchaProviderMock(ctrl)
+	h := NewHttp(config.Config{}, s, c, &mockCSRFSeeder{})
+
+	ctx := fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod(fasthttp.MethodPost)
+
+	c.EXPECT().Verify(gomock.Any()).Return(false, &captcha.RejectedError{
+		Reason:   captcha.RejectedScore,
+		Fallback: true,
+		Detail:   "score 0.10 below 0.50",
+	})
+
+	h.CaptchaVerifyHandler(&ctx)
+
+	assert.Equal(t, fasthttp.StatusBadRequest, ctx.Response.StatusCode())
+	assert.JSONEq(t, `{
+		"code": "captcha_required",
+		"message": "Additional captcha verification required. Please complete the challenge.",
+		"reason": "score",
+		"fallback": true
+	}`, string(ctx.Response.Body()))
+}
+
 func TestAuthSetHandlerLoginError(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	s := mocks.NewApiServiceMock(ctrl)
@@ -260,6 +288,28 @@ func TestFullForwardedHandler(t *testing.T) {
 	h.FullForwardedHandler(&ctx)
 }
 
+func TestFullForwardedHandlerRealtime(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	s := mocks.NewApiServiceMock(ctrl)
+	c := mocks.NewCaptchaProviderMock(ctrl)
+	route := config.Realtime{Name: "balance", Prefix: "/api/realtime", IdleTimeout: time.Minute}
+	h := NewHttp(config.Config{Realtime: []config.Realtime{route}}, s, c, &mockCSRFSeeder{})
+
+	ctx := fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod(fasthttp.MethodGet)
+	ctx.Request.SetRequestURI("/api/realtime/balance")
+
+	s.EXPECT().ForwardRealtime(gomock.Any(), route).Return(nil)
+
+	h.FullForwardedHandler(&ctx)
+
+	ctx.Request.SetRequestURI("/api/wallets")
+
+	s.EXPECT().ForwardRequest(gomock.Any(), nil).Return(nil)
+
+	h.FullForwardedHandler(&ctx)
+}
+
 func TestFullForwardedHandlerError(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	s := mocks.NewApiServiceMock(ctrl)
@@ -345,6 +395,58 @@ func TestFullForwardedHandlerCircuitOpen(t *testing.T) {
 	assert.Equal(t, strconv.Itoa(api.RetryAfterSeconds), string(ctx.Response.Header.Peek(headers.RetryAfter)))
 }
 
+func TestFullForwardedHandlerCircuitOpenRemaining(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	s := mocks.NewApiServiceMock(ctrl)
+	c := mocks.NewCaptchaProviderMock(ctrl)
+	h := NewHttp(config.Config{}, s, c, &mockCSRFSeeder{})
+
+	ctx := fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod(fasthttp.MethodGet)
+
+	s.EXPECT().ForwardRequest(gomock.Any(), nil).Return(&api.CircuitOpenError{RetryAfter: 11500 * time.Millisecond})
+
+	h.FullForwardedHandler(&ctx)
+
+	assert.Equal(t, fasthttp.StatusServiceUnavailable, ctx.Response.StatusCode())
+	assert.Equal(t, "12", string(ctx.Response.Header.Peek(headers.RetryAfter)))
+}
+
+func TestFullForwardedHandlerCircuitForcedOpen(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	s := mocks.NewApiServiceMock(ctrl)
+	c := mocks.NewCaptchaProviderMock(ctrl)
+	h := NewHttp(config.Config{}, s, c, &mockCSRFSeeder{})
+
+	ctx := fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod(fasthttp.MethodGet)
+
+	s.EXPECT().ForwardRequest(gomock.Any(), nil).Return(&api.CircuitOpenError{Forced: true})
+
+	h.FullForwardedHandler(&ctx)
+
+	assert.Equal(t, fasthttp.StatusServiceUnavailable, ctx.Response.StatusCode())
+	assert.Contains(t, string(ctx.Response.Body()), `"code":"circuit_open"`)
+	assert.Empty(t, ctx.Response.Header.Peek(headers.RetryAfter))
+}
+
+func TestFullForwardedHandlerStreamedBodyTooLarge(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	s := mocks.NewApiServiceMock(ctrl)
+	c := mocks.NewCaptchaProviderMock(ctrl)
+	h := NewHttp(config.Config{}, s, c, &mockCSRFSeeder{})
+
+	ctx := fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod(fasthttp.MethodPost)
+
+	s.EXPECT().ForwardRequest(gomock.Any(), nil).Return(fmt.Errorf("API request error: %w", bodyHandler.ErrTooLarge))
+
+	h.FullForwardedHandler(&ctx)
+
+	assert.Equal(t, fasthttp.StatusRequestEntityTooLarge, ctx.Response.StatusCode())
+	assert.True(t, ctx.Response.ConnectionClose())
+}
+
 func TestFullForwardedHandlerWithBodyCircuitOpen(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	s := mocks.NewApiServiceMock(ctrl)
@@ -374,7 +476,8 @@ func TestFullForwardedHandlerRestrictedMethod(t *testing.T) {
 
 	h.FullForwardedHandler(&ctx)
 
-	assert.Equal(t, fasthttp.StatusBadRequest, ctx.Response.StatusCode())
+	assert.Equal(t, fasthttp.StatusMethodNotAllowed, ctx.Response.StatusCode())
+	assert.Equal(t, "GET, POST, PUT, PATCH, DELETE, OPTIONS", string(ctx.Response.Header.Peek(headers.Allow)))
 }
 
 func TestFullForwardedHandlerWithBody(t *testing.T) {
@@ -446,7 +549,8 @@ func TestFullForwardedHandlerWithBodyRestrictedMethod(t *testing.T) {
 
 	h.FullForwardedHandlerWithBody(&ctx, nil)
 
-	assert.Equal(t, fasthttp.StatusBadRequest, ctx.Response.StatusCode())
+	assert.Equal(t, fasthttp.StatusMethodNotAllowed, ctx.Response.StatusCode())
+	assert.Equal(t, "GET, POST, PUT, PATCH, DELETE, OPTIONS", string(ctx.Response.Header.Peek(headers.Allow)))
 }
 
 func TestHealthcheck(t *testing.T) {
@@ -1,15 +1,19 @@
 package api
 
 import (
+	"log/slog"
+
 	"github.com/valyala/fasthttp"
 
-	"github.com/cash-track/gateway/headers/cookie"
+	"github.com/cash-track/gateway/traces"
 )
 
 func (h *HttpHandler) Logout(ctx *fasthttp.RequestCtx) {
-	// Auth{} is never "logged" (AccessToken == ""), so this always takes the
-	// delete branch and cannot fail — error structurally impossible here.
-	_ = cookie.Auth{}.WriteCookie(ctx)
+	// The cookies are cleared even when this fails: the session then only survives in
+	// Redis until it expires, unreachable without the cookie.
+	if err := h.sessions.End(ctx); err != nil {
+		slog.Warn("logout: session end failed", "trace_id", traces.FindTraceId(ctx), "error", err)
+	}
 
 	b, _ := h.newWebsiteRedirect().ToJson()
 
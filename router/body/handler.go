@@ -0,0 +1,201 @@
+package body
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"math"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/valyala/fasthttp"
+
+	"github.com/cash-track/gateway/config"
+	"github.com/cash-track/gateway/headers"
+	"github.com/cash-track/gateway/router/response"
+	"github.com/cash-track/gateway/traces"
+)
+
+const (
+	metricsNamespace  = "gateway"
+	metricsBodySubsys = "body"
+)
+
+const streamCtxKey = "body.stream"
+
+// ErrTooLarge fails the read of a streamed body without Content-Length past its limit.
+var ErrTooLarge = errors.New("request body too large")
+
+var bodyRejectedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: metricsNamespace,
+	Subsystem: metricsBodySubsys,
+	Name:      "rejected_total",
+	Help:      "Requests rejected for their body by body limit and error code.",
+}, []string{"limit", "code"})
+
+// stream is the body of a request on a config.Stream route, forwarded as it is read.
+type stream struct {
+	body io.Reader
+}
+
+// Handler rejects the request bodies the matching config.BodyLimit does not allow before
+// they are read past the limit: 413 over the size limit, 415 with a Content-Type out of the
+// allow-list. A body without Content-Type is forwarded as JSON, so it needs JSON allowed.
+//
+// The server streams every request body. The allowed ones are read into memory here,
+// except on config.Stream routes where Stream hands them to the forwarder unread.
+func Handler(h fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		method, path := string(ctx.Method()), string(ctx.Path())
+		_, streamed := config.Global.FindStream(method, path)
+
+		if !hasBody(ctx) {
+			if streamed {
+				ctx.SetUserValue(streamCtxKey, stream{})
+			}
+
+			h(ctx)
+
+			return
+		}
+
+		limit := config.Global.FindBodyLimit(method, path)
+
+		if code, rejected := check(ctx, limit); rejected {
+			reject(ctx, limit.Name, code)
+
+			return
+		}
+
+		if streamed {
+			ctx.SetUserValue(streamCtxKey, stream{body: limitStream(ctx, limit)})
+			h(ctx)
+
+			return
+		}
+
+		if err := read(ctx, limit); err != nil {
+			reject(ctx, limit.Name, response.CodeBodyTooLarge)
+
+			return
+		}
+
+		h(ctx)
+	}
+}
+
+// Stream returns the body of a request on a config.Stream route, nil when it has none,
+// and false on the other routes, whose bodies are read into memory.
+func Stream(ctx *fasthttp.RequestCtx) (io.Reader, bool) {
+	s, ok := ctx.UserValue(streamCtxKey).(stream)
+
+	return s.body, ok
+}
+
+// hasBody tells from the Content-Length, or from the body itself when it is not streamed,
+// e.g. in tests.
+func hasBody(ctx *fasthttp.RequestCtx) bool {
+	if ctx.RequestBodyStream() == nil {
+		return len(ctx.Request.Body()) > 0
+	}
+
+	return ctx.Request.Header.ContentLength() != 0
+}
+
+func check(ctx *fasthttp.RequestCtx, limit config.BodyLimit) (response.Code, bool) {
+	size := ctx.Request.Header.ContentLength()
+	if ctx.RequestBodyStream() == nil {
+		size = len(ctx.Request.Body())
+	}
+
+	if limit.MaxSize > 0 && size > int(limit.MaxSize) {
+		return response.CodeBodyTooLarge, true
+	}
+
+	contentType := string(ctx.Request.Header.ContentType())
+	if contentType == "" {
+		contentType = string(headers.ContentTypeJson)
+	}
+
+	if !limit.AllowsContentType(contentType) {
+		return response.CodeUnsupportedMedia, true
+	}
+
+	return "", false
+}
+
+// read buffers the body, which is chunked or already checked by its Content-Length, up to
+// one byte past the limit.
+func read(ctx *fasthttp.RequestCtx, limit config.BodyLimit) error {
+	s := ctx.RequestBodyStream()
+	if s == nil || limit.MaxSize == 0 {
+		ctx.Request.Body()
+
+		return nil
+	}
+
+	b, err := io.ReadAll(io.LimitReader(s, int64(limit.MaxSize)+1))
+	if err != nil {
+		return err
+	}
+
+	if len(b) > int(limit.MaxSize) {
+		return ErrTooLarge
+	}
+
+	ctx.Request.SetBody(b)
+
+	return nil
+}
+
+// limitStream fails the read past the limit of a chunked body, whose size is only known
+// once read. The stream is always wrapped: a request given the stream of ctx as is would
+// release it to the fasthttp pool a second time.
+func limitStream(ctx *fasthttp.RequestCtx, limit config.BodyLimit) io.Reader {
+	s := ctx.RequestBodyStream()
+	if s == nil {
+		return nil
+	}
+
+	left := int64(math.MaxInt64 - 1)
+	if limit.MaxSize > 0 && ctx.Request.Header.ContentLength() < 0 {
+		left = int64(limit.MaxSize)
+	}
+
+	return &limitedReader{r: s, left: left}
+}
+
+type limitedReader struct {
+	r    io.Reader
+	left int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.left < 0 {
+		return 0, ErrTooLarge
+	}
+
+	if int64(len(p)) > l.left+1 {
+		p = p[:l.left+1]
+	}
+
+	n, err := l.r.Read(p)
+	l.left -= int64(n)
+
+	if l.left < 0 {
+		return n, ErrTooLarge
+	}
+
+	return n, err
+}
+
+// reject answers with the error of the code and counts it against the body limit. The
+// connection is closed, the rest of the body is not read.
+func reject(ctx *fasthttp.RequestCtx, limit string, code response.Code) {
+	bodyRejectedTotal.WithLabelValues(limit, string(code)).Inc()
+	slog.Info("request body rejected", "trace_id", traces.FindTraceId(ctx),
+		"limit", limit, "code", code, "content_type", string(ctx.Request.Header.ContentType()))
+
+	response.New(code).Write(ctx)
+	ctx.SetConnectionClose()
+}
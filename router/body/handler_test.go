@@ -0,0 +1,169 @@
+package body
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+
+	"github.com/cash-track/gateway/config"
+	"github.com/cash-track/gateway/router/response"
+)
+
+func withBodyLimits(t *testing.T) {
+	original := config.Global
+	t.Cleanup(func() { config.Global = original })
+
+	config.Global.MaxRequestBodySize = 16
+	config.Global.AllowedContentTypes = []string{"application/json"}
+	config.Global.BodyLimits = []config.BodyLimit{
+		{Name: "attachments", Prefix: "/api/attachments", Methods: []string{"POST"}, MaxSize: 64, ContentTypes: []string{"multipart/form-data", "image/*"}},
+	}
+}
+
+func TestHandler(t *testing.T) {
+	withBodyLimits(t)
+
+	for name, test := range map[string]struct {
+		path        string
+		contentType string
+		body        string
+		expectPass  bool
+		expectCode  response.Code
+	}{
+		"NoBody": {
+			path:       "/api/wallets",
+			expectPass: true,
+		},
+		"Json": {
+			path:        "/api/wallets",
+			contentType: "application/json; charset=utf-8",
+			body:        `{"name":"a"}`,
+			expectPass:  true,
+		},
+		"NoContentTypeIsJson": {
+			path:       "/api/wallets",
+			body:       `{"name":"a"}`,
+			expectPass: true,
+		},
+		"TooLarge": {
+			path:        "/api/wallets",
+			contentType: "application/json",
+			body:        `{"name":"` + strings.Repeat("a", 16) + `"}`,
+			expectCode:  response.CodeBodyTooLarge,
+		},
+		"UnsupportedMedia": {
+			path:        "/api/wallets",
+			contentType: "multipart/form-data; boundary=xyz",
+			body:        "--xyz--",
+			expectCode:  response.CodeUnsupportedMedia,
+		},
+		"RouteContentType": {
+			path:        "/api/attachments",
+			contentType: "multipart/form-data; boundary=xyz",
+			body:        "--xyz--",
+			expectPass:  true,
+		},
+		"RouteWildcardContentType": {
+			path:        "/api/attachments",
+			contentType: "image/png",
+			body:        strings.Repeat("a", 32),
+			expectPass:  true,
+		},
+		"RouteTooLarge": {
+			path:        "/api/attachments",
+			contentType: "image/png",
+			body:        strings.Repeat("a", 65),
+			expectCode:  response.CodeBodyTooLarge,
+		},
+		"RouteJsonNotAllowed": {
+			path:        "/api/attachments",
+			contentType: "application/json",
+			body:        `{}`,
+			expectCode:  response.CodeUnsupportedMedia,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctx := &fasthttp.RequestCtx{}
+			ctx.Request.Header.SetMethod(fasthttp.MethodPost)
+			ctx.Request.SetRequestURI(test.path)
+			if test.contentType != "" {
+				ctx.Request.Header.SetContentType(test.contentType)
+			}
+			ctx.Request.SetBodyString(test.body)
+
+			passed := false
+			Handler(func(ctx *fasthttp.RequestCtx) {
+				passed = true
+			})(ctx)
+
+			assert.Equal(t, test.expectPass, passed)
+
+			if !test.expectPass {
+				assert.Equal(t, test.expectCode.Status(), ctx.Response.StatusCode())
+
+				body := map[string]string{}
+				assert.NoError(t, json.Unmarshal(ctx.Response.Body(), &body))
+				assert.Equal(t, string(test.expectCode), body["code"])
+			}
+		})
+	}
+}
+
+func TestStream(t *testing.T) {
+	withBodyLimits(t)
+	config.Global.Streams = []config.Stream{{Name: "uploads", Prefix: "/api/attachments"}}
+
+	for name, test := range map[string]struct {
+		path         string
+		body         string
+		expectStream bool
+		expectBody   bool
+	}{
+		"StreamedWithBody": {
+			path:         "/api/attachments",
+			body:         "--xyz--",
+			expectStream: true,
+		},
+		"StreamedWithoutBody": {
+			path:         "/api/attachments",
+			expectStream: true,
+		},
+		"Buffered": {
+			path: "/api/wallets",
+			body: `{}`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctx := &fasthttp.RequestCtx{}
+			ctx.Request.Header.SetMethod(fasthttp.MethodPost)
+			ctx.Request.Header.SetContentType("multipart/form-data; boundary=xyz")
+			if test.path == "/api/wallets" {
+				ctx.Request.Header.SetContentType("application/json")
+			}
+			ctx.Request.SetRequestURI(test.path)
+			ctx.Request.SetBodyString(test.body)
+
+			Handler(func(ctx *fasthttp.RequestCtx) {
+				_, streamed := Stream(ctx)
+				assert.Equal(t, test.expectStream, streamed)
+			})(ctx)
+
+			assert.Equal(t, fasthttp.StatusOK, ctx.Response.StatusCode())
+		})
+	}
+}
+
+func TestLimitedReader(t *testing.T) {
+	r := &limitedReader{r: strings.NewReader(strings.Repeat("a", 10)), left: 10}
+	b, err := io.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Len(t, b, 10)
+
+	r = &limitedReader{r: strings.NewReader(strings.Repeat("a", 11)), left: 10}
+	_, err = io.ReadAll(r)
+	assert.ErrorIs(t, err, ErrTooLarge)
+}
@@ -143,7 +143,7 @@ func (r *RedisHandler) Handler(h fasthttp.RequestHandler) fasthttp.RequestHandle
 			span.SetStatus(codes.Error, "invalid")
 			span.End()
 			slog.Warn("CSRF token validation error", "trace_id", traces.FindTraceId(ctx), "error", err)
-			response.ByErrorAndStatus(err, fasthttp.StatusExpectationFailed).Write(ctx)
+			response.New(response.CodeCsrfInvalid).Write(ctx)
 
 			return
 		}
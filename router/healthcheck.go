@@ -1,6 +1,7 @@
 package router
 
 import (
+	"fmt"
 	"log/slog"
 
 	"github.com/valyala/fasthttp"
@@ -9,6 +10,7 @@ import (
 var (
 	bodyOk     = []byte("ok")
 	bodyApiNok = []byte("[api] nok")
+	bodyDrain  = []byte("draining")
 )
 
 // LiveHandler consider liveness check successful if request reached the handler.
@@ -17,8 +19,16 @@ func (r *Router) LiveHandler(ctx *fasthttp.RequestCtx) {
 	ctx.SetBody(bodyOk)
 }
 
-// ReadyHandler check all dependency for service readiness.
+// ReadyHandler check all dependency for service readiness: the API and every other upstream
+// of the route table with a healthcheck path.
 func (r *Router) ReadyHandler(ctx *fasthttp.RequestCtx) {
+	if r.draining.Load() {
+		ctx.SetStatusCode(fasthttp.StatusServiceUnavailable)
+		ctx.SetBody(bodyDrain)
+
+		return
+	}
+
 	if err := r.api.Healthcheck(); err != nil {
 		slog.Warn("API not ready", "error", err)
 		ctx.SetStatusCode(fasthttp.StatusInternalServerError)
@@ -27,6 +37,21 @@ func (r *Router) ReadyHandler(ctx *fasthttp.RequestCtx) {
 		return
 	}
 
+	for _, u := range r.upstreams {
+		// the API upstream was probed above
+		if u.Handler == r.api {
+			continue
+		}
+
+		if err := u.Handler.Healthcheck(); err != nil {
+			slog.Warn("upstream not ready", "upstream", u.Name, "error", err)
+			ctx.SetStatusCode(fasthttp.StatusInternalServerError)
+			ctx.SetBodyString(fmt.Sprintf("[%s] nok", u.Name))
+
+			return
+		}
+	}
+
 	ctx.SetStatusCode(fasthttp.StatusOK)
 	ctx.SetBody(bodyOk)
 }
@@ -15,7 +15,7 @@ func TestLiveHandler(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	a := mocks.NewApiHandlerMock(ctrl)
 	c := mocks.NewCsrfHandlerMock(ctrl)
-	r := New(a, c)
+	r := New(a, c, nil, nil)
 
 	ctx := fasthttp.RequestCtx{}
 
@@ -30,7 +30,7 @@ func TestReadyHandler(t *testing.T) {
 	a := mocks.NewApiHandlerMock(ctrl)
 	a.EXPECT().Healthcheck().Return(nil)
 	c := mocks.NewCsrfHandlerMock(ctrl)
-	r := New(a, c)
+	r := New(a, c, nil, nil)
 
 	ctx := fasthttp.RequestCtx{}
 
@@ -45,7 +45,7 @@ func TestReadyHandlerFail(t *testing.T) {
 	a := mocks.NewApiHandlerMock(ctrl)
 	a.EXPECT().Healthcheck().Return(fmt.Errorf("context cancelled"))
 	c := mocks.NewCsrfHandlerMock(ctrl)
-	r := New(a, c)
+	r := New(a, c, nil, nil)
 
 	ctx := fasthttp.RequestCtx{}
 
@@ -54,3 +54,40 @@ func TestReadyHandlerFail(t *testing.T) {
 	assert.Equal(t, fasthttp.StatusInternalServerError, ctx.Response.StatusCode())
 	assert.Equal(t, "[api] nok", string(ctx.Response.Body()))
 }
+
+func TestReadyHandlerDraining(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	a := mocks.NewApiHandlerMock(ctrl)
+	// no EXPECT: a draining instance must not probe the API
+	c := mocks.NewCsrfHandlerMock(ctrl)
+	r := New(a, c, nil, nil)
+
+	r.Drain()
+
+	ctx := fasthttp.RequestCtx{}
+
+	r.ReadyHandler(&ctx)
+
+	assert.Equal(t, fasthttp.StatusServiceUnavailable, ctx.Response.StatusCode())
+	assert.Equal(t, "draining", string(ctx.Response.Body()))
+}
+
+func TestReadyHandlerUpstreams(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	a := mocks.NewApiHandlerMock(ctrl)
+	a.EXPECT().Healthcheck().Return(nil)
+	exports := mocks.NewApiHandlerMock(ctrl)
+	exports.EXPECT().Healthcheck().Return(fmt.Errorf("connection refused"))
+	c := mocks.NewCsrfHandlerMock(ctrl)
+	r := New(a, c, nil, []Upstream{
+		{Name: "API", Prefix: "/api", Handler: a},
+		{Name: "exports", Prefix: "/api/exports", Handler: exports},
+	})
+
+	ctx := fasthttp.RequestCtx{}
+
+	r.ReadyHandler(&ctx)
+
+	assert.Equal(t, fasthttp.StatusInternalServerError, ctx.Response.StatusCode())
+	assert.Equal(t, "[exports] nok", string(ctx.Response.Body()))
+}
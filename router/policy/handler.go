@@ -0,0 +1,106 @@
+package policy
+
+import (
+	"log/slog"
+	"slices"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/valyala/fasthttp"
+
+	"github.com/cash-track/gateway/config"
+	"github.com/cash-track/gateway/headers/cookie"
+	"github.com/cash-track/gateway/router/response"
+	"github.com/cash-track/gateway/session"
+	"github.com/cash-track/gateway/token"
+	"github.com/cash-track/gateway/traces"
+)
+
+const (
+	metricsNamespace    = "gateway"
+	metricsPolicySubsys = "policy"
+)
+
+var policyRejectedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: metricsNamespace,
+	Subsystem: metricsPolicySubsys,
+	Name:      "rejected_total",
+	Help:      "Requests rejected by an access policy by policy and error code.",
+}, []string{"policy", "code"})
+
+// Handler rejects the requests the matching policy of config.Global.AccessPolicies does
+// not allow, before they reach the router and the upstreams: 401 without a signed-in user,
+// 403 without one of the required roles or with a user on an anonymous only route. Must
+// run after the session is resolved and the access token verified.
+func Handler(h fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		method := string(ctx.Request.Header.Method())
+
+		if method == fasthttp.MethodOptions {
+			h(ctx)
+
+			return
+		}
+
+		p, ok := config.Global.FindAccessPolicy(method, string(ctx.Path()))
+		if !ok {
+			h(ctx)
+
+			return
+		}
+
+		if resp, denied := check(ctx, p); denied {
+			policyRejectedTotal.WithLabelValues(p.Name, string(resp.Code)).Inc()
+			slog.Info("request rejected by access policy", "trace_id", traces.FindTraceId(ctx),
+				"policy", p.Name, "code", resp.Code)
+			resp.Write(ctx)
+
+			return
+		}
+
+		h(ctx)
+	}
+}
+
+// check lets a user through an authenticated policy only with an access token Handler of
+// the token package verified, config refuses such policies without verification. Any token
+// keeps a user off an anonymous route, verified or not.
+func check(ctx *fasthttp.RequestCtx, p config.AccessPolicy) (response.ErrorResponse, bool) {
+	switch p.Access {
+	case config.AccessAnonymous:
+		if cookie.ReadAuthCookie(ctx).IsLogged() {
+			return response.New(response.CodeAlreadySignedIn), true
+		}
+	case config.AccessAuthenticated:
+		if session.IsRevoked(ctx) {
+			return response.New(response.CodeSessionRevoked), true
+		}
+
+		if _, verified := token.ClaimsFromContext(ctx); !verified {
+			return response.New(response.CodeNotSignedIn), true
+		}
+
+		if len(p.Roles) > 0 && !hasAnyRole(ctx, p.Roles) {
+			return response.New(response.CodeAccessDenied), true
+		}
+	}
+
+	return response.ErrorResponse{}, false
+}
+
+// hasAnyRole reads the roles of the verified claims only: a policy requiring roles is
+// refused by config unless access tokens are verified.
+func hasAnyRole(ctx *fasthttp.RequestCtx, roles []string) bool {
+	claims, ok := token.ClaimsFromContext(ctx)
+	if !ok {
+		return false
+	}
+
+	for _, role := range roles {
+		if slices.Contains(claims.Roles, role) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,162 @@
+package policy
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+
+	"github.com/cash-track/gateway/config"
+	"github.com/cash-track/gateway/headers/cookie"
+	"github.com/cash-track/gateway/router/response"
+	"github.com/cash-track/gateway/session"
+	"github.com/cash-track/gateway/token"
+)
+
+var testSecret = []byte("test-secret")
+
+var testPolicies = []config.AccessPolicy{
+	{Name: "register", Path: "/api/auth/register", Methods: []string{"POST"}, Access: config.AccessAnonymous},
+	{Name: "admin", Path: "/api/admin/{path:*}", Access: config.AccessAuthenticated, Roles: []string{"admin", "support"}},
+	{Name: "staff", Path: "/api/staff", Access: config.AccessAuthenticated, Roles: []string{"admin"}},
+	{Name: "status", Path: "/api/status", Access: config.AccessPublic},
+	{Name: "private", Path: "/api/{path:*}", Access: config.AccessAuthenticated},
+}
+
+func accessToken(roles ...string) string {
+	s, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub":   1,
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"roles": roles,
+	}).SignedString(testSecret)
+
+	return s
+}
+
+func TestHandler(t *testing.T) {
+	original := config.Global.AccessPolicies
+	config.Global.AccessPolicies = testPolicies
+	t.Cleanup(func() { config.Global.AccessPolicies = original })
+
+	for name, test := range map[string]struct {
+		method       string
+		path         string
+		accessToken  string
+		expectPass   bool
+		expectStatus int
+		expectCode   response.Code
+	}{
+		"NoMatchingPolicy": {
+			path:       "/csrf",
+			expectPass: true,
+		},
+		"OptionsSkipped": {
+			method:     fasthttp.MethodOptions,
+			path:       "/api/wallets",
+			expectPass: true,
+		},
+		"Public": {
+			path:       "/api/status",
+			expectPass: true,
+		},
+		"AuthenticatedGuest": {
+			path:         "/api/wallets",
+			expectStatus: fasthttp.StatusUnauthorized,
+			expectCode:   response.CodeNotSignedIn,
+		},
+		"AuthenticatedSignedIn": {
+			path:        "/api/wallets",
+			accessToken: accessToken(),
+			expectPass:  true,
+		},
+		"RoleGuest": {
+			path:         "/api/admin/users",
+			expectStatus: fasthttp.StatusUnauthorized,
+			expectCode:   response.CodeNotSignedIn,
+		},
+		"RoleMissing": {
+			path:         "/api/admin/users",
+			accessToken:  accessToken("user"),
+			expectStatus: fasthttp.StatusForbidden,
+			expectCode:   response.CodeAccessDenied,
+		},
+		"RoleHeld": {
+			path:        "/api/admin/users",
+			accessToken: accessToken("user", "support"),
+			expectPass:  true,
+		},
+		"RoleMissingTrailingSlash": {
+			// forwarded to /api/staff all the same
+			path:         "/api/staff/",
+			accessToken:  accessToken("user"),
+			expectStatus: fasthttp.StatusForbidden,
+			expectCode:   response.CodeAccessDenied,
+		},
+		"PublicTrailingSlash": {
+			path:       "/api/status/",
+			expectPass: true,
+		},
+		"AnonymousGuest": {
+			method:     fasthttp.MethodPost,
+			path:       "/api/auth/register",
+			expectPass: true,
+		},
+		"AnonymousSignedIn": {
+			method:       fasthttp.MethodPost,
+			path:         "/api/auth/register",
+			accessToken:  accessToken(),
+			expectStatus: fasthttp.StatusForbidden,
+			expectCode:   response.CodeAlreadySignedIn,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctx := &fasthttp.RequestCtx{}
+			ctx.Request.Header.SetMethod(fasthttp.MethodGet)
+			if test.method != "" {
+				ctx.Request.Header.SetMethod(test.method)
+			}
+			ctx.Request.SetRequestURI(test.path)
+			if test.accessToken != "" {
+				ctx.Request.Header.SetCookie(cookie.AccessTokenCookieName, test.accessToken)
+			}
+
+			passed := false
+			h := token.NewHandler(token.NewHmacVerifier(testSecret), session.CookieStore{}).Handler(Handler(func(ctx *fasthttp.RequestCtx) {
+				passed = true
+			}))
+			h(ctx)
+
+			assert.Equal(t, test.expectPass, passed)
+
+			if !test.expectPass {
+				assert.Equal(t, test.expectStatus, ctx.Response.StatusCode())
+
+				body := map[string]string{}
+				assert.NoError(t, json.Unmarshal(ctx.Response.Body(), &body))
+				assert.Equal(t, string(test.expectCode), body["code"])
+			}
+		})
+	}
+}
+
+func TestHandlerUnverifiedToken(t *testing.T) {
+	original := config.Global.AccessPolicies
+	config.Global.AccessPolicies = testPolicies
+	t.Cleanup(func() { config.Global.AccessPolicies = original })
+
+	// a token cookie the token handler never verified, e.g. forged by the client
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod(fasthttp.MethodGet)
+	ctx.Request.SetRequestURI("/api/wallets")
+	ctx.Request.Header.SetCookie(cookie.AccessTokenCookieName, "forged")
+
+	passed := false
+	Handler(func(ctx *fasthttp.RequestCtx) { passed = true })(ctx)
+
+	assert.False(t, passed)
+	assert.Equal(t, fasthttp.StatusUnauthorized, ctx.Response.StatusCode())
+}
@@ -1,15 +1,20 @@
 package response
 
-import "github.com/valyala/fasthttp"
-
 func NewCaptchaBadResponse() ErrorResponse {
-	return NewErrorResponse("Captcha validation unsuccessful. Please try again.", nil, fasthttp.StatusBadRequest)
+	return New(CodeCaptchaFailed)
+}
+
+func NewCaptchaErrorResponse() ErrorResponse {
+	return New(CodeCaptchaUnavailable)
 }
 
-func NewCaptchaErrorResponse(err error) ErrorResponse {
-	return NewErrorResponse(
-		"Unexpected response from captcha validation service. Please try again later.",
-		err,
-		fasthttp.StatusInternalServerError,
-	)
+// NewCaptchaRejectedResponse is written when the provider accepted the challenge but the
+// endpoint policy did not. Fallback tells the client to show an interactive challenge.
+func NewCaptchaRejectedResponse(reason string, fallback bool) ErrorResponse {
+	code := CodeCaptchaFailed
+	if fallback {
+		code = CodeCaptchaRequired
+	}
+
+	return New(code).With("reason", reason).With("fallback", fallback)
 }
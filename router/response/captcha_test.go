@@ -1,7 +1,6 @@
 package response
 
 import (
-	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -11,15 +10,40 @@ import (
 func TestNewCaptchaBadResponse(t *testing.T) {
 	resp := NewCaptchaBadResponse()
 
-	assert.Equal(t, "Captcha validation unsuccessful. Please try again.", resp.Message)
-	assert.Empty(t, resp.Error)
+	assert.Equal(t, CodeCaptchaFailed, resp.Code)
 	assert.Equal(t, fasthttp.StatusBadRequest, resp.StatusCode)
 }
 
 func TestNewCaptchaErrorResponse(t *testing.T) {
-	resp := NewCaptchaErrorResponse(fmt.Errorf("broken pipe"))
+	resp := NewCaptchaErrorResponse()
 
-	assert.Equal(t, "Unexpected response from captcha validation service. Please try again later.", resp.Message)
-	assert.Equal(t, "broken pipe", resp.Error)
+	assert.Equal(t, CodeCaptchaUnavailable, resp.Code)
 	assert.Equal(t, fasthttp.StatusInternalServerError, resp.StatusCode)
 }
+
+func TestNewCaptchaRejectedResponse(t *testing.T) {
+	resp := NewCaptchaRejectedResponse("action", false)
+
+	assert.Equal(t, CodeCaptchaFailed, resp.Code)
+	assert.Equal(t, fasthttp.StatusBadRequest, resp.StatusCode)
+
+	ctx := fasthttp.RequestCtx{}
+	resp.Write(&ctx)
+
+	assert.Equal(t, fasthttp.StatusBadRequest, ctx.Response.StatusCode())
+	assert.Equal(t, "application/json", string(ctx.Response.Header.ContentType()))
+	assert.JSONEq(t, `{
+		"code": "captcha_failed",
+		"message": "Captcha validation unsuccessful. Please try again.",
+		"reason": "action",
+		"fallback": false
+	}`, string(ctx.Response.Body()))
+}
+
+func TestNewCaptchaRejectedResponseFallback(t *testing.T) {
+	resp := NewCaptchaRejectedResponse("score", true)
+
+	assert.Equal(t, CodeCaptchaRequired, resp.Code)
+	assert.Equal(t, true, resp.Extensions["fallback"])
+	assert.Equal(t, "score", resp.Extensions["reason"])
+}
@@ -0,0 +1,66 @@
+package response
+
+import "github.com/valyala/fasthttp"
+
+// Code identifies an error for clients. Codes are stable across releases and languages,
+// clients branch on them rather than on messages.
+type Code string
+
+const (
+	CodeInternalError       Code = "internal_error"
+	CodeMethodNotAllowed    Code = "method_not_allowed"
+	CodeNotFound            Code = "not_found"
+	CodeCaptchaFailed       Code = "captcha_failed"
+	CodeCaptchaRequired     Code = "captcha_required"
+	CodeCaptchaUnavailable  Code = "captcha_unavailable"
+	CodeCsrfInvalid         Code = "csrf_invalid"
+	CodeRateLimited         Code = "rate_limited"
+	CodeUpstreamUnavailable Code = "upstream_unavailable"
+	CodeCircuitOpen         Code = "circuit_open"
+	CodeSessionUnavailable  Code = "session_unavailable"
+	CodeSessionRevoked      Code = "session_revoked"
+	CodeSessionNotFound     Code = "session_not_found"
+	CodeNotSignedIn         Code = "not_signed_in"
+	CodeTokenInvalid        Code = "token_invalid"
+	CodeAccessDenied        Code = "access_denied"
+	CodeAlreadySignedIn     Code = "already_signed_in"
+	CodeBodyTooLarge        Code = "body_too_large"
+	CodeUnsupportedMedia    Code = "unsupported_media_type"
+	CodeIdempotencyInvalid  Code = "idempotency_key_invalid"
+	CodeIdempotencyInFlight Code = "idempotency_key_in_flight"
+	CodeIdempotencyReused   Code = "idempotency_key_reused"
+)
+
+// statuses are the HTTP status each code is answered with.
+var statuses = map[Code]int{
+	CodeInternalError:       fasthttp.StatusInternalServerError,
+	CodeMethodNotAllowed:    fasthttp.StatusMethodNotAllowed,
+	CodeNotFound:            fasthttp.StatusNotFound,
+	CodeCaptchaFailed:       fasthttp.StatusBadRequest,
+	CodeCaptchaRequired:     fasthttp.StatusBadRequest,
+	CodeCaptchaUnavailable:  fasthttp.StatusInternalServerError,
+	CodeCsrfInvalid:         fasthttp.StatusExpectationFailed,
+	CodeRateLimited:         fasthttp.StatusTooManyRequests,
+	CodeUpstreamUnavailable: fasthttp.StatusBadGateway,
+	CodeCircuitOpen:         fasthttp.StatusServiceUnavailable,
+	CodeSessionUnavailable:  fasthttp.StatusServiceUnavailable,
+	CodeSessionRevoked:      fasthttp.StatusUnauthorized,
+	CodeSessionNotFound:     fasthttp.StatusNotFound,
+	CodeNotSignedIn:         fasthttp.StatusUnauthorized,
+	CodeTokenInvalid:        fasthttp.StatusUnauthorized,
+	CodeAccessDenied:        fasthttp.StatusForbidden,
+	CodeAlreadySignedIn:     fasthttp.StatusForbidden,
+	CodeBodyTooLarge:        fasthttp.StatusRequestEntityTooLarge,
+	CodeUnsupportedMedia:    fasthttp.StatusUnsupportedMediaType,
+	CodeIdempotencyInvalid:  fasthttp.StatusBadRequest,
+	CodeIdempotencyInFlight: fasthttp.StatusConflict,
+	CodeIdempotencyReused:   fasthttp.StatusUnprocessableEntity,
+}
+
+func (c Code) Status() int {
+	if status, ok := statuses[c]; ok {
+		return status
+	}
+
+	return fasthttp.StatusInternalServerError
+}
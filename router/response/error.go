@@ -1,43 +1,86 @@
 package response
 
 import (
+	"bytes"
 	"encoding/json"
-	"errors"
 
 	"github.com/valyala/fasthttp"
+
+	"github.com/cash-track/gateway/headers"
+	"github.com/cash-track/gateway/traces"
 )
 
-const DefaultErrorMessage = "Unexpected error happened. Please try again later."
+// problemTypePrefix makes the RFC 7807 type of a code, e.g. urn:cash-track:error:rate_limited.
+const problemTypePrefix = "urn:cash-track:error:"
 
+// ErrorResponse is the body of every error the gateway writes itself. The message is
+// picked at write time in the language of the request. The Go error behind it is only
+// ever logged: it may name internal hosts and addresses.
 type ErrorResponse struct {
-	Message    string `json:"message,omitempty"`
-	Error      string `json:"error,omitempty"`
-	StatusCode int    `json:"-"`
+	Code       Code
+	StatusCode int
+	// Extensions are additional members of the body, e.g. the captcha rejection reason.
+	Extensions map[string]any
 }
 
-func NewErrorResponse(message string, err error, status int) ErrorResponse {
-	if err == nil {
-		err = errors.New("")
+// New creates the response of the code, answered with the status of the catalogue.
+func New(code Code) ErrorResponse {
+	return ErrorResponse{
+		Code:       code,
+		StatusCode: code.Status(),
 	}
+}
 
-	return ErrorResponse{
-		Message:    message,
-		Error:      err.Error(),
-		StatusCode: status,
+// With adds a member to the body.
+func (e ErrorResponse) With(key string, value any) ErrorResponse {
+	extensions := make(map[string]any, len(e.Extensions)+1)
+	for k, v := range e.Extensions {
+		extensions[k] = v
 	}
+
+	extensions[key] = value
+	e.Extensions = extensions
+
+	return e
 }
 
+// Write answers with {"code","message","traceId"}, or with an RFC 7807 problem when the
+// client accepts application/problem+json.
 func (e ErrorResponse) Write(ctx *fasthttp.RequestCtx) {
-	body, _ := json.Marshal(e)
-	ctx.Response.SetBody(body)
-	ctx.Response.SetStatusCode(e.StatusCode)
-	ctx.Response.Header.Set("Content-Type", "application/json")
-}
+	lang := Language(ctx)
+	message := Message(e.Code, lang)
 
-func ByError(err error) ErrorResponse {
-	return NewErrorResponse(DefaultErrorMessage, err, fasthttp.StatusInternalServerError)
+	body := make(map[string]any, len(e.Extensions)+6)
+	for k, v := range e.Extensions {
+		body[k] = v
+	}
+
+	body["code"] = e.Code
+
+	if traceId := traces.FindTraceId(ctx); traceId != "" {
+		body["traceId"] = traceId
+	}
+
+	contentType := headers.ContentTypeJson
+
+	if acceptsProblem(ctx) {
+		contentType = headers.ContentTypeProblemJson
+		body["type"] = problemTypePrefix + string(e.Code)
+		body["title"] = fasthttp.StatusMessage(e.StatusCode)
+		body["status"] = e.StatusCode
+		body["detail"] = message
+		body["instance"] = string(ctx.Path())
+	} else {
+		body["message"] = message
+	}
+
+	b, _ := json.Marshal(body)
+	ctx.Response.SetBody(b)
+	ctx.Response.SetStatusCode(e.StatusCode)
+	ctx.Response.Header.SetContentTypeBytes(contentType)
+	ctx.Response.Header.Set(headers.ContentLanguage, lang)
 }
 
-func ByErrorAndStatus(err error, status int) ErrorResponse {
-	return NewErrorResponse(DefaultErrorMessage, err, status)
+func acceptsProblem(ctx *fasthttp.RequestCtx) bool {
+	return bytes.Contains(ctx.Request.Header.Peek(headers.Accept), headers.ContentTypeProblemJson)
 }
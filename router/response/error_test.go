@@ -1,46 +1,94 @@
 package response
 
 import (
-	"fmt"
+	"encoding/json"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/valyala/fasthttp"
 
 	"github.com/cash-track/gateway/headers"
+	"github.com/cash-track/gateway/traces"
 )
 
-func TestNewErrorResponse(t *testing.T) {
-	resp := NewErrorResponse("", nil, 0)
+func TestNew(t *testing.T) {
+	resp := New(CodeRateLimited)
 
-	assert.Empty(t, resp.Error)
+	assert.Equal(t, CodeRateLimited, resp.Code)
+	assert.Equal(t, fasthttp.StatusTooManyRequests, resp.StatusCode)
+	assert.Empty(t, resp.Extensions)
 }
 
-func TestWriteByError(t *testing.T) {
-	errorMsg := "broken pipe"
+func TestNewUnknownCode(t *testing.T) {
+	resp := New("unknown")
 
-	resp := ByError(fmt.Errorf("%s", errorMsg))
+	assert.Equal(t, fasthttp.StatusInternalServerError, resp.StatusCode)
+}
+
+func TestWith(t *testing.T) {
+	resp := New(CodeCaptchaFailed)
+	extended := resp.With("reason", "replay")
+
+	assert.Empty(t, resp.Extensions)
+	assert.Equal(t, map[string]any{"reason": "replay"}, extended.Extensions)
+}
 
+func TestWrite(t *testing.T) {
 	ctx := fasthttp.RequestCtx{}
 
-	resp.Write(&ctx)
+	New(CodeUpstreamUnavailable).Write(&ctx)
 
-	assert.Equal(t, fasthttp.StatusInternalServerError, ctx.Response.StatusCode())
+	assert.Equal(t, fasthttp.StatusBadGateway, ctx.Response.StatusCode())
 	assert.Equal(t, string(headers.ContentTypeJson), string(ctx.Response.Header.Peek(headers.ContentType)))
-	assert.Equal(t, fmt.Sprintf(`{"message":"%s","error":"%s"}`, DefaultErrorMessage, errorMsg), string(ctx.Response.Body()))
+	assert.Equal(t, "en", string(ctx.Response.Header.Peek(headers.ContentLanguage)))
+	assert.JSONEq(t, `{
+		"code": "upstream_unavailable",
+		"message": "The service is temporarily unavailable. Please try again later."
+	}`, string(ctx.Response.Body()))
 }
 
-func TestWriteByErrorAndStatus(t *testing.T) {
-	errorMsg := "broken pipe"
-	statusCode := fasthttp.StatusUnprocessableEntity
+func TestWriteTraceId(t *testing.T) {
+	ctx := fasthttp.RequestCtx{}
+
+	traces.TraceHandler(func(ctx *fasthttp.RequestCtx) {
+		New(CodeInternalError).Write(ctx)
+	})(&ctx)
 
-	resp := ByErrorAndStatus(fmt.Errorf("%s", errorMsg), statusCode)
+	body := map[string]string{}
+	assert.NoError(t, json.Unmarshal(ctx.Response.Body(), &body))
+	assert.NotEmpty(t, body["traceId"])
+	assert.Equal(t, traces.FindTraceId(&ctx), body["traceId"])
+}
 
+func TestWriteLocalized(t *testing.T) {
 	ctx := fasthttp.RequestCtx{}
+	ctx.Request.Header.Set(headers.AcceptLanguage, "uk-UA,uk;q=0.9,en;q=0.8")
 
-	resp.Write(&ctx)
+	New(CodeRateLimited).Write(&ctx)
 
-	assert.Equal(t, statusCode, ctx.Response.StatusCode())
-	assert.Equal(t, string(headers.ContentTypeJson), string(ctx.Response.Header.Peek(headers.ContentType)))
-	assert.Equal(t, fmt.Sprintf(`{"message":"%s","error":"%s"}`, DefaultErrorMessage, errorMsg), string(ctx.Response.Body()))
+	assert.Equal(t, "uk", string(ctx.Response.Header.Peek(headers.ContentLanguage)))
+	assert.JSONEq(t, `{
+		"code": "rate_limited",
+		"message": "Забагато запитів. Спробуйте пізніше."
+	}`, string(ctx.Response.Body()))
+}
+
+func TestWriteProblem(t *testing.T) {
+	ctx := fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI("/api/wallets")
+	ctx.Request.Header.Set(headers.Accept, "application/problem+json, application/json")
+
+	New(CodeCircuitOpen).With("retryAfter", 30).Write(&ctx)
+
+	assert.Equal(t, fasthttp.StatusServiceUnavailable, ctx.Response.StatusCode())
+	assert.Equal(t, string(headers.ContentTypeProblemJson), string(ctx.Response.Header.Peek(headers.ContentType)))
+	assert.JSONEq(t, `{
+		"type": "urn:cash-track:error:circuit_open",
+		"title": "Service Unavailable",
+		"status": 503,
+		"detail": "The service is temporarily unavailable. Please try again in a few seconds.",
+		"instance": "/api/wallets",
+		"code": "circuit_open",
+		"retryAfter": 30
+	}`, string(ctx.Response.Body()))
 }
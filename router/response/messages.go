@@ -0,0 +1,116 @@
+package response
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+
+	"github.com/cash-track/gateway/headers"
+)
+
+// DefaultLanguage is answered in when the client accepts none of the translated ones.
+const DefaultLanguage = "en"
+
+// messages are the user facing texts of every code by language. A code missing from a
+// translation falls back to the DefaultLanguage text.
+var messages = map[string]map[Code]string{
+	"en": {
+		CodeInternalError:       "Unexpected error happened. Please try again later.",
+		CodeMethodNotAllowed:    "This request method is not allowed.",
+		CodeNotFound:            "The requested resource was not found.",
+		CodeCaptchaFailed:       "Captcha validation unsuccessful. Please try again.",
+		CodeCaptchaRequired:     "Additional captcha verification required. Please complete the challenge.",
+		CodeCaptchaUnavailable:  "Unexpected response from captcha validation service. Please try again later.",
+		CodeCsrfInvalid:         "Your page has expired. Please reload it and try again.",
+		CodeRateLimited:         "Too many requests. Please try again later.",
+		CodeUpstreamUnavailable: "The service is temporarily unavailable. Please try again later.",
+		CodeCircuitOpen:         "The service is temporarily unavailable. Please try again in a few seconds.",
+		CodeSessionUnavailable:  "Your session could not be loaded. Please try again later.",
+		CodeSessionRevoked:      "This session was signed out from another device. Please sign in again.",
+		CodeSessionNotFound:     "Session not found.",
+		CodeNotSignedIn:         "Please sign in to continue.",
+		CodeTokenInvalid:        "Your session is no longer valid. Please sign in again.",
+		CodeAccessDenied:        "You do not have access to this resource.",
+		CodeAlreadySignedIn:     "You are already signed in.",
+		CodeBodyTooLarge:        "The request is too large.",
+		CodeUnsupportedMedia:    "This content type is not supported.",
+		CodeIdempotencyInvalid:  "The Idempotency-Key header must be 1 to 255 visible characters.",
+		CodeIdempotencyInFlight: "This request is already being processed. Please wait for its result.",
+		CodeIdempotencyReused:   "This Idempotency-Key was already used for a different request.",
+	},
+	"uk": {
+		CodeInternalError:       "Сталася неочікувана помилка. Спробуйте пізніше.",
+		CodeMethodNotAllowed:    "Цей метод запиту не підтримується.",
+		CodeNotFound:            "Запитаний ресурс не знайдено.",
+		CodeCaptchaFailed:       "Перевірку captcha не пройдено. Спробуйте ще раз.",
+		CodeCaptchaRequired:     "Потрібна додаткова перевірка captcha. Будь ласка, пройдіть її.",
+		CodeCaptchaUnavailable:  "Сервіс перевірки captcha відповів неочікувано. Спробуйте пізніше.",
+		CodeCsrfInvalid:         "Сторінка застаріла. Оновіть її та спробуйте ще раз.",
+		CodeRateLimited:         "Забагато запитів. Спробуйте пізніше.",
+		CodeUpstreamUnavailable: "Сервіс тимчасово недоступний. Спробуйте пізніше.",
+		CodeCircuitOpen:         "Сервіс тимчасово недоступний. Спробуйте за кілька секунд.",
+		CodeSessionUnavailable:  "Не вдалося завантажити сесію. Спробуйте пізніше.",
+		CodeSessionRevoked:      "Цю сесію завершено з іншого пристрою. Увійдіть знову.",
+		CodeSessionNotFound:     "Сесію не знайдено.",
+		CodeNotSignedIn:         "Увійдіть, щоб продовжити.",
+		CodeTokenInvalid:        "Ваша сесія більше не дійсна. Увійдіть знову.",
+		CodeAccessDenied:        "У вас немає доступу до цього ресурсу.",
+		CodeAlreadySignedIn:     "Ви вже увійшли.",
+		CodeBodyTooLarge:        "Запит завеликий.",
+		CodeUnsupportedMedia:    "Цей тип вмісту не підтримується.",
+		CodeIdempotencyInvalid:  "Заголовок Idempotency-Key має містити від 1 до 255 видимих символів.",
+		CodeIdempotencyInFlight: "Цей запит уже обробляється. Дочекайтеся його результату.",
+		CodeIdempotencyReused:   "Цей Idempotency-Key уже використано для іншого запиту.",
+	},
+}
+
+// Message returns the text of the code in the language, or in DefaultLanguage.
+func Message(code Code, lang string) string {
+	if m, ok := messages[lang][code]; ok {
+		return m
+	}
+
+	if m, ok := messages[DefaultLanguage][code]; ok {
+		return m
+	}
+
+	return messages[DefaultLanguage][CodeInternalError]
+}
+
+// Language picks the translation the request prefers by its Accept-Language header:
+// the highest weighted tag whose primary subtag is translated, e.g. "uk-UA" for "uk".
+func Language(ctx *fasthttp.RequestCtx) string {
+	lang, best := DefaultLanguage, 0.0
+
+	for _, part := range strings.Split(string(ctx.Request.Header.Peek(headers.AcceptLanguage)), ",") {
+		tag, q := parseLanguageRange(part)
+
+		primary, _, _ := strings.Cut(strings.ToLower(tag), "-")
+		if _, ok := messages[primary]; !ok || q <= best {
+			continue
+		}
+
+		lang, best = primary, q
+	}
+
+	return lang
+}
+
+func parseLanguageRange(part string) (string, float64) {
+	tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+	q := 1.0
+
+	for _, param := range strings.Split(params, ";") {
+		name, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+		if !ok || name != "q" {
+			continue
+		}
+
+		if v, err := strconv.ParseFloat(value, 64); err == nil {
+			q = v
+		}
+	}
+
+	return strings.TrimSpace(tag), q
+}
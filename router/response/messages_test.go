@@ -0,0 +1,51 @@
+package response
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+
+	"github.com/cash-track/gateway/headers"
+)
+
+func TestMessagesComplete(t *testing.T) {
+	for code := range statuses {
+		for lang, translation := range messages {
+			assert.NotEmpty(t, translation[code], "%s has no %s message", code, lang)
+		}
+	}
+}
+
+func TestMessage(t *testing.T) {
+	assert.Equal(t, "Too many requests. Please try again later.", Message(CodeRateLimited, "en"))
+	assert.Equal(t, "Забагато запитів. Спробуйте пізніше.", Message(CodeRateLimited, "uk"))
+	assert.Equal(t, "Too many requests. Please try again later.", Message(CodeRateLimited, "de"))
+	assert.Equal(t, "Unexpected error happened. Please try again later.", Message("unknown", "en"))
+}
+
+func TestLanguage(t *testing.T) {
+	for name, test := range map[string]struct {
+		header string
+		lang   string
+	}{
+		"Missing":         {header: "", lang: "en"},
+		"Exact":           {header: "uk", lang: "uk"},
+		"Region":          {header: "uk-UA", lang: "uk"},
+		"CaseInsensitive": {header: "UK-ua", lang: "uk"},
+		"Unsupported":     {header: "de-DE, fr;q=0.8", lang: "en"},
+		"Weighted":        {header: "en;q=0.5, uk;q=0.9", lang: "uk"},
+		"FirstOfEqual":    {header: "en, uk", lang: "en"},
+		"SkipsUnknown":    {header: "de, uk;q=0.3", lang: "uk"},
+		"Excluded":        {header: "uk;q=0, en;q=0.1", lang: "en"},
+		"Wildcard":        {header: "*", lang: "en"},
+		"InvalidQuality":  {header: "uk;q=abc", lang: "uk"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctx := fasthttp.RequestCtx{}
+			ctx.Request.Header.Set(headers.AcceptLanguage, test.header)
+
+			assert.Equal(t, test.lang, Language(&ctx))
+		})
+	}
+}
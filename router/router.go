@@ -1,30 +1,55 @@
 package router
 
 import (
+	"sync/atomic"
+
 	"github.com/fasthttp/router"
 
 	"github.com/cash-track/gateway/router/api"
 	"github.com/cash-track/gateway/router/csrf"
+	"github.com/cash-track/gateway/router/sessions"
 )
 
+// Upstream forwards every request under Prefix through its own handler.
+type Upstream struct {
+	Name    string
+	Prefix  string
+	Handler api.Handler
+}
+
 type Router struct {
 	*router.Router
 
-	api  api.Handler
-	csrf csrf.Handler
+	api       api.Handler
+	csrf      csrf.Handler
+	sessions  sessions.Handler
+	upstreams []Upstream
+
+	draining atomic.Bool
 }
 
-func New(api api.Handler, csrf csrf.Handler) *Router {
+// New registers the gateway endpoints on top of the route table. api handles the auth
+// endpoints and readiness; it is usually also the handler of the "/api" upstream.
+// sessions is nil unless SESSION_ENABLED, the devices of cookie mode are not tracked.
+func New(api api.Handler, csrf csrf.Handler, sessions sessions.Handler, upstreams []Upstream) *Router {
 	r := &Router{
-		Router: router.New(),
-		api:    api,
-		csrf:   csrf,
+		Router:    router.New(),
+		api:       api,
+		csrf:      csrf,
+		sessions:  sessions,
+		upstreams: upstreams,
 	}
 	r.register()
 
 	return r
 }
 
+// Drain makes the readiness check fail from now on, so the load balancer stops routing
+// new requests to this instance while in-flight ones are still served.
+func (r *Router) Drain() {
+	r.draining.Store(true)
+}
+
 func (r *Router) register() {
 	r.ANY("/live", r.LiveHandler)
 	r.ANY("/ready", r.ReadyHandler)
@@ -36,5 +61,14 @@ func (r *Router) register() {
 	r.POST("/api/auth/register", r.api.AuthSetHandler)
 	r.POST("/api/auth/provider/google", r.api.AuthSetHandler)
 	r.POST("/api/auth/logout", r.api.AuthResetHandler)
-	r.ANY("/api/{path:*}", r.api.FullForwardedHandler)
+
+	if r.sessions != nil {
+		r.GET("/gateway/sessions", r.sessions.ListHandler)
+		r.DELETE("/gateway/sessions", r.sessions.RevokeOthersHandler)
+		r.DELETE("/gateway/sessions/{id}", r.sessions.RevokeHandler)
+	}
+
+	for _, u := range r.upstreams {
+		r.ANY(u.Prefix+"/{path:*}", u.Handler.FullForwardedHandler)
+	}
 }
@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
 	"go.uber.org/mock/gomock"
 
 	"github.com/cash-track/gateway/mocks"
@@ -13,7 +14,7 @@ func TestNew(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	a := mocks.NewApiHandlerMock(ctrl)
 	c := mocks.NewCsrfHandlerMock(ctrl)
-	r := New(a, c)
+	r := New(a, c, nil, []Upstream{{Prefix: "/api", Handler: a}})
 
 	l := r.List()
 
@@ -38,3 +39,52 @@ func TestNew(t *testing.T) {
 	assert.Contains(t, l["POST"], "/api/auth/register")
 	assert.Contains(t, l["POST"], "/api/auth/provider/google")
 }
+
+func TestNewRegistersSessionEndpoints(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	a := mocks.NewApiHandlerMock(ctrl)
+	c := mocks.NewCsrfHandlerMock(ctrl)
+	s := mocks.NewSessionsHandlerMock(ctrl)
+	r := New(a, c, s, []Upstream{{Prefix: "/api", Handler: a}})
+
+	l := r.List()
+
+	assert.Contains(t, l["GET"], "/gateway/sessions")
+	assert.Len(t, l["DELETE"], 2)
+	assert.Contains(t, l["DELETE"], "/gateway/sessions")
+	assert.Contains(t, l["DELETE"], "/gateway/sessions/{id}")
+}
+
+func TestNewRegistersEveryUpstream(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	a := mocks.NewApiHandlerMock(ctrl)
+	exports := mocks.NewApiHandlerMock(ctrl)
+	files := mocks.NewApiHandlerMock(ctrl)
+	c := mocks.NewCsrfHandlerMock(ctrl)
+
+	r := New(a, c, nil, []Upstream{
+		{Prefix: "/api", Handler: a},
+		{Prefix: "/api/exports", Handler: exports},
+		{Prefix: "/files", Handler: files},
+	})
+
+	l := r.List()
+
+	assert.Len(t, l["*"], 5)
+	assert.Contains(t, l["*"], "/api/{path:*}")
+	assert.Contains(t, l["*"], "/api/exports/{path:*}")
+	assert.Contains(t, l["*"], "/files/{path:*}")
+
+	// the longest prefix wins, regardless of registration order
+	for path, handler := range map[string]*mocks.ApiHandlerMock{
+		"/api/profile":        a,
+		"/api/exports/report": exports,
+		"/files/receipt.png":  files,
+	} {
+		handler.EXPECT().FullForwardedHandler(gomock.Any())
+
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.SetRequestURI(path)
+		r.Handler(ctx)
+	}
+}
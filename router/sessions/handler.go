@@ -0,0 +1,104 @@
+package sessions
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+
+	"github.com/valyala/fasthttp"
+
+	"github.com/cash-track/gateway/headers"
+	"github.com/cash-track/gateway/router/response"
+	"github.com/cash-track/gateway/session"
+	"github.com/cash-track/gateway/traces"
+)
+
+// Handler serves /gateway/sessions, where a user sees the devices signed in to their
+// account and signs them out.
+type Handler interface {
+	ListHandler(ctx *fasthttp.RequestCtx)
+	RevokeHandler(ctx *fasthttp.RequestCtx)
+	RevokeOthersHandler(ctx *fasthttp.RequestCtx)
+}
+
+// Store is the part of session.RedisStore the endpoints need.
+type Store interface {
+	List(ctx *fasthttp.RequestCtx) ([]session.Device, error)
+	Revoke(ctx *fasthttp.RequestCtx, id string) error
+	RevokeOthers(ctx *fasthttp.RequestCtx) (int, error)
+}
+
+type HttpHandler struct {
+	store Store
+}
+
+func NewHttp(store Store) *HttpHandler {
+	return &HttpHandler{
+		store: store,
+	}
+}
+
+type listResponse struct {
+	Data []session.Device `json:"data"`
+}
+
+type revokeOthersResponse struct {
+	Revoked int `json:"revoked"`
+}
+
+func (h *HttpHandler) ListHandler(ctx *fasthttp.RequestCtx) {
+	devices, err := h.store.List(ctx)
+	if err != nil {
+		writeError(ctx, err)
+
+		return
+	}
+
+	writeJson(ctx, listResponse{Data: devices})
+}
+
+func (h *HttpHandler) RevokeHandler(ctx *fasthttp.RequestCtx) {
+	id, _ := ctx.UserValue("id").(string)
+
+	if err := h.store.Revoke(ctx, id); err != nil {
+		writeError(ctx, err)
+
+		return
+	}
+
+	slog.Info("session revoked", "trace_id", traces.FindTraceId(ctx), "client_ip", headers.GetClientIPFromContext(ctx))
+	ctx.SetStatusCode(fasthttp.StatusNoContent)
+}
+
+func (h *HttpHandler) RevokeOthersHandler(ctx *fasthttp.RequestCtx) {
+	revoked, err := h.store.RevokeOthers(ctx)
+	if err != nil {
+		writeError(ctx, err)
+
+		return
+	}
+
+	slog.Info("other sessions revoked",
+		"trace_id", traces.FindTraceId(ctx), "client_ip", headers.GetClientIPFromContext(ctx), "revoked", revoked)
+	writeJson(ctx, revokeOthersResponse{Revoked: revoked})
+}
+
+func writeJson(ctx *fasthttp.RequestCtx, v any) {
+	b, _ := json.Marshal(v)
+
+	ctx.SetStatusCode(fasthttp.StatusOK)
+	ctx.Response.Header.SetContentTypeBytes(headers.ContentTypeJson)
+	ctx.Response.SetBody(b)
+}
+
+func writeError(ctx *fasthttp.RequestCtx, err error) {
+	switch {
+	case errors.Is(err, session.ErrNotSignedIn):
+		response.New(response.CodeNotSignedIn).Write(ctx)
+	case errors.Is(err, session.ErrNotFound):
+		response.New(response.CodeSessionNotFound).Write(ctx)
+	default:
+		slog.Error("session management failed", "trace_id", traces.FindTraceId(ctx), "error", err)
+		response.New(response.CodeSessionUnavailable).Write(ctx)
+	}
+}
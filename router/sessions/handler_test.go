@@ -0,0 +1,84 @@
+package sessions
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+	"go.uber.org/mock/gomock"
+
+	"github.com/cash-track/gateway/mocks"
+	"github.com/cash-track/gateway/session"
+)
+
+func TestListHandler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mocks.NewSessionsStoreMock(ctrl)
+
+	seen := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	store.EXPECT().List(gomock.Any()).Return([]session.Device{
+		{Id: "abc", Current: true, Device: "Chrome on macOS", ClientIp: "1.1.1.1", UserAgent: "ua", CreatedAt: seen, LastSeenAt: seen},
+	}, nil)
+
+	ctx := &fasthttp.RequestCtx{}
+	NewHttp(store).ListHandler(ctx)
+
+	assert.Equal(t, fasthttp.StatusOK, ctx.Response.StatusCode())
+	assert.Equal(t, "application/json", string(ctx.Response.Header.ContentType()))
+	assert.JSONEq(t, `{"data":[{
+		"id":"abc","current":true,"device":"Chrome on macOS","clientIp":"1.1.1.1","userAgent":"ua",
+		"createdAt":"2026-01-01T00:00:00Z","lastSeenAt":"2026-01-01T00:00:00Z"
+	}]}`, string(ctx.Response.Body()))
+}
+
+func TestRevokeHandler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mocks.NewSessionsStoreMock(ctrl)
+	store.EXPECT().Revoke(gomock.Any(), "abc").Return(nil)
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.SetUserValue("id", "abc")
+	NewHttp(store).RevokeHandler(ctx)
+
+	assert.Equal(t, fasthttp.StatusNoContent, ctx.Response.StatusCode())
+}
+
+func TestRevokeOthersHandler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mocks.NewSessionsStoreMock(ctrl)
+	store.EXPECT().RevokeOthers(gomock.Any()).Return(3, nil)
+
+	ctx := &fasthttp.RequestCtx{}
+	NewHttp(store).RevokeOthersHandler(ctx)
+
+	assert.Equal(t, fasthttp.StatusOK, ctx.Response.StatusCode())
+	assert.JSONEq(t, `{"revoked":3}`, string(ctx.Response.Body()))
+}
+
+func TestHandlerErrors(t *testing.T) {
+	for name, test := range map[string]struct {
+		err    error
+		status int
+		code   string
+	}{
+		"NotSignedIn": {err: session.ErrNotSignedIn, status: fasthttp.StatusUnauthorized, code: "not_signed_in"},
+		"NotFound":    {err: session.ErrNotFound, status: fasthttp.StatusNotFound, code: "session_not_found"},
+		"Redis":       {err: errors.New("session list: connection refused"), status: fasthttp.StatusServiceUnavailable, code: "session_unavailable"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			store := mocks.NewSessionsStoreMock(ctrl)
+			store.EXPECT().Revoke(gomock.Any(), "abc").Return(test.err)
+
+			ctx := &fasthttp.RequestCtx{}
+			ctx.SetUserValue("id", "abc")
+			NewHttp(store).RevokeHandler(ctx)
+
+			assert.Equal(t, test.status, ctx.Response.StatusCode())
+			assert.Contains(t, string(ctx.Response.Body()), `"code":"`+test.code+`"`)
+			assert.NotContains(t, string(ctx.Response.Body()), test.err.Error())
+		})
+	}
+}
@@ -4,12 +4,18 @@ import (
 	"context"
 	"errors"
 	"log/slog"
+	"math"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/sony/gobreaker/v2"
 	"github.com/valyala/fasthttp"
+
+	"github.com/cash-track/gateway/config"
+	bodyHandler "github.com/cash-track/gateway/router/body"
 )
 
 const (
@@ -19,69 +25,288 @@ const (
 	breakerInterval         = time.Duration(0)
 	breakerTimeout          = 30 * time.Second
 	breakerFailureThreshold = 10
+	// A failure ratio is counted over this window unless the settings name one.
+	breakerRatioInterval    = time.Minute
+	breakerMinRequests      = 20
 	metricsNamespace        = "gateway"
 	metricsApiBreakerSubsys = "api_breaker"
 )
 
-// RetryAfterSeconds is the Retry-After hint sent with 503s while the breaker is open.
+// RetryAfterSeconds is the Retry-After hint sent with 503s while the breaker is open, when
+// the time it stays open is unknown.
 const RetryAfterSeconds = int(breakerTimeout / time.Second)
 
 // ErrCircuitOpen lets callers tell a tripped breaker from a plain transport error.
 var ErrCircuitOpen = errors.New("circuit breaker is open")
 
-var breakerRejectedTotal = promauto.NewCounter(prometheus.CounterOpts{
+// CircuitOpenError is ErrCircuitOpen with the time left until the breaker lets calls
+// through again, unknown when Forced open by an operator.
+type CircuitOpenError struct {
+	RetryAfter time.Duration
+	Forced     bool
+}
+
+func (e *CircuitOpenError) Error() string {
+	return ErrCircuitOpen.Error()
+}
+
+func (e *CircuitOpenError) Is(target error) bool {
+	return target == ErrCircuitOpen
+}
+
+// RetryAfter returns the whole seconds until the breaker that rejected a call with err
+// lets calls through again, at least one, or RetryAfterSeconds when err does not tell.
+// Zero for a breaker forced open, which stays open until an operator says otherwise.
+func RetryAfter(err error) int {
+	var open *CircuitOpenError
+	if !errors.As(err, &open) {
+		return RetryAfterSeconds
+	}
+	if open.Forced {
+		return 0
+	}
+
+	return max(1, int(math.Ceil(open.RetryAfter.Seconds())))
+}
+
+// BreakerMode tells whether a breaker follows its counts or is forced by an operator.
+type BreakerMode int32
+
+const (
+	BreakerAuto BreakerMode = iota
+	// BreakerForcedOpen rejects every call, e.g. during a maintenance of the upstream.
+	BreakerForcedOpen
+	// BreakerForcedClosed lets every call through without counting it.
+	BreakerForcedClosed
+)
+
+func (m BreakerMode) String() string {
+	switch m {
+	case BreakerForcedOpen:
+		return "forced-open"
+	case BreakerForcedClosed:
+		return "forced-closed"
+	default:
+		return "auto"
+	}
+}
+
+// Breaker is the circuit breaker of an upstream or of one of its breaker groups, which an
+// operator can force open or closed from the admin listener. The mode is kept in memory,
+// each replica of the gateway has its own.
+type Breaker struct {
+	*gobreaker.CircuitBreaker[struct{}]
+
+	timeout time.Duration
+	mode    atomic.Int32
+	// openedAt is the Unix time in nanoseconds the breaker last tripped at.
+	openedAt atomic.Int64
+	now      func() time.Time
+}
+
+// State is the state calls meet: the forced one, or the one of the counts.
+func (b *Breaker) State() gobreaker.State {
+	switch b.Mode() {
+	case BreakerForcedOpen:
+		return gobreaker.StateOpen
+	case BreakerForcedClosed:
+		return gobreaker.StateClosed
+	default:
+		return b.CircuitBreaker.State()
+	}
+}
+
+func (b *Breaker) Mode() BreakerMode {
+	return BreakerMode(b.mode.Load())
+}
+
+// SetMode forces the breaker, or hands it back to its counts with BreakerAuto. The counts
+// keep the state they had before.
+func (b *Breaker) SetMode(mode BreakerMode) {
+	b.mode.Store(int32(mode))
+}
+
+// RemainingOpen returns how long the breaker stays open, zero unless it is open on its
+// counts: forced open, it has no end time.
+func (b *Breaker) RemainingOpen() time.Duration {
+	if b.Mode() != BreakerAuto || b.State() != gobreaker.StateOpen {
+		return 0
+	}
+
+	return max(0, time.Unix(0, b.openedAt.Load()).Add(b.timeout).Sub(b.now()))
+}
+
+// execute runs call through the breaker, or as forced.
+func (b *Breaker) execute(call func() (struct{}, error)) error {
+	switch b.Mode() {
+	case BreakerForcedOpen:
+		return gobreaker.ErrOpenState
+	case BreakerForcedClosed:
+		_, err := call()
+
+		return err
+	}
+
+	_, err := b.Execute(call)
+
+	return err
+}
+
+// errServerError counts a 5xx answer as a failure of the breaker, it is relayed as usual.
+var errServerError = errors.New("upstream answered with a server error")
+
+var breakerRejectedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
 	Namespace: metricsNamespace,
 	Subsystem: metricsApiBreakerSubsys,
 	Name:      "rejected_total",
-	Help:      "Requests rejected without hitting the API because the circuit breaker was open or half-open and full.",
-})
+	Help:      "Requests rejected without hitting the upstream because the circuit breaker was open or half-open and full.",
+}, []string{"upstream"})
 
 // NewBreaker builds the API circuit breaker. Call once per process and share the
 // instance across requests.
-func NewBreaker() *gobreaker.CircuitBreaker[struct{}] {
-	return gobreaker.NewCircuitBreaker[struct{}](gobreaker.Settings{
-		Name:        ServiceId,
-		MaxRequests: breakerMaxRequests,
-		Interval:    breakerInterval,
-		Timeout:     breakerTimeout,
+func NewBreaker() *Breaker {
+	return NewUpstreamBreaker(config.Upstream{Name: ServiceId})
+}
+
+// NewUpstreamBreaker builds the circuit breaker of one upstream, named after it. Zero
+// settings fall back to the API defaults.
+func NewUpstreamBreaker(upstream config.Upstream) *Breaker {
+	return newBreaker(upstream.Name, upstream.Breaker)
+}
+
+// NewGroupBreaker builds the circuit breaker of a breaker group of the upstream, named
+// "<upstream>/<group>".
+func NewGroupBreaker(upstream config.Upstream, group config.BreakerGroup) *Breaker {
+	return newBreaker(upstream.Name+"/"+group.Name, group.Breaker)
+}
+
+func newBreaker(name string, settings config.BreakerSettings) *Breaker {
+	b := &Breaker{
+		timeout: orDefault(settings.Timeout, breakerTimeout),
+		now:     time.Now,
+	}
+	threshold := orDefault(settings.FailureThreshold, breakerFailureThreshold)
+	minRequests := orDefault(settings.MinRequests, breakerMinRequests)
+	interval := orDefault(settings.Interval, breakerInterval)
+	if settings.FailureRatio > 0 && interval == 0 {
+		interval = breakerRatioInterval
+	}
+
+	b.CircuitBreaker = gobreaker.NewCircuitBreaker[struct{}](gobreaker.Settings{
+		Name:        name,
+		MaxRequests: orDefault(settings.MaxRequests, breakerMaxRequests),
+		Interval:    interval,
+		Timeout:     b.timeout,
 		ReadyToTrip: func(counts gobreaker.Counts) bool {
-			return counts.ConsecutiveFailures > breakerFailureThreshold
+			if counts.ConsecutiveFailures > threshold {
+				return true
+			}
+
+			return settings.FailureRatio > 0 && counts.Requests >= minRequests &&
+				float64(counts.TotalFailures)/float64(counts.Requests) >= settings.FailureRatio
+		},
+		// a client streaming a body past its limit says nothing about the upstream
+		IsSuccessful: func(err error) bool {
+			return err == nil || errors.Is(err, bodyHandler.ErrTooLarge)
 		},
 		OnStateChange: func(name string, from, to gobreaker.State) {
 			level := slog.LevelInfo
 			if to == gobreaker.StateOpen {
 				level = slog.LevelWarn
+				b.openedAt.Store(b.now().UnixNano())
 			}
 			slog.Log(context.Background(), level, "circuit breaker state change",
 				"service", name, "from", from.String(), "to", to.String())
 		},
 	})
+
+	return b
 }
 
-// RegisterBreakerMetrics exposes the breaker state on the default Prometheus registry.
-// Call once — a second call panics on duplicate registration.
-func RegisterBreakerMetrics(breaker *gobreaker.CircuitBreaker[struct{}]) {
+// RegisterBreakerMetrics exposes the breaker state on the default Prometheus registry,
+// labelled with the breaker name. Call once per breaker — a second call panics on
+// duplicate registration.
+func RegisterBreakerMetrics(breaker *Breaker) {
 	promauto.NewGaugeFunc(prometheus.GaugeOpts{
-		Namespace: metricsNamespace,
-		Subsystem: metricsApiBreakerSubsys,
-		Name:      "state",
-		Help:      "API circuit breaker state: 0=closed, 1=half-open, 2=open.",
+		Namespace:   metricsNamespace,
+		Subsystem:   metricsApiBreakerSubsys,
+		Name:        "state",
+		Help:        "Upstream circuit breaker state: 0=closed, 1=half-open, 2=open.",
+		ConstLabels: prometheus.Labels{"upstream": breaker.Name()},
 	}, func() float64 {
 		return float64(breaker.State())
 	})
 }
 
-// doWithBreaker runs the API call through the breaker. Transport errors pass through
-// unwrapped; a rejected call yields ErrCircuitOpen.
+// breakerGroup is the breaker of the requests whose path on the upstream starts with
+// prefix.
+type breakerGroup struct {
+	prefix   string
+	breaker  *Breaker
+	settings config.BreakerSettings
+}
+
+// WithBreakerGroup gives the requests of the group their own breaker.
+func (s *HttpService) WithBreakerGroup(group config.BreakerGroup, breaker *Breaker) *HttpService {
+	s.breakerGroups = append(s.breakerGroups, breakerGroup{
+		prefix:   s.upstream.Rewrite + strings.TrimPrefix(group.Prefix, s.upstream.Prefix),
+		breaker:  breaker,
+		settings: group.Breaker,
+	})
+
+	return s
+}
+
+// breakerFor returns the breaker of the group with the longest prefix matching the path
+// of req, or the one of the upstream.
+func (s *HttpService) breakerFor(req *fasthttp.Request) (*Breaker, config.BreakerSettings) {
+	path := string(req.URI().Path())
+	breaker, settings, longest := s.breaker, s.upstream.Breaker, 0
+
+	for _, g := range s.breakerGroups {
+		if (config.BreakerGroup{Prefix: g.prefix}).Matches(path) && len(g.prefix) > longest {
+			breaker, settings, longest = g.breaker, g.settings, len(g.prefix)
+		}
+	}
+
+	return breaker, settings
+}
+
+// doWithBreaker runs the upstream call through the breaker of its group. Transport errors
+// pass through unwrapped; a rejected call yields ErrCircuitOpen. A 5xx answer counts as a
+// failure when the settings say so, and is returned as any answer. A streamed body is
+// read once, so its request is never retried.
 func (s *HttpService) doWithBreaker(req *fasthttp.Request, resp *fasthttp.Response) error {
-	_, err := s.breaker.Execute(func() (struct{}, error) {
-		return struct{}{}, s.http.Do(req, resp)
+	breaker, settings := s.breakerFor(req)
+
+	err := breaker.execute(func() (struct{}, error) {
+		var err error
+		if req.IsBodyStream() {
+			err = s.http.DoWithRetry(req, resp, 1)
+		} else {
+			err = s.http.Do(req, resp)
+		}
+
+		if err == nil && settings.CountServerErrors && resp.StatusCode() >= fasthttp.StatusInternalServerError {
+			return struct{}{}, errServerError
+		}
+
+		return struct{}{}, err
 	})
 
+	if errors.Is(err, errServerError) {
+		return nil
+	}
+
+	return s.breakerError(breaker, err)
+}
+
+// breakerError turns the rejection of a call by the breaker into a CircuitOpenError.
+func (s *HttpService) breakerError(breaker *Breaker, err error) error {
 	if errors.Is(err, gobreaker.ErrOpenState) || errors.Is(err, gobreaker.ErrTooManyRequests) {
-		breakerRejectedTotal.Inc()
+		breakerRejectedTotal.WithLabelValues(breaker.Name()).Inc()
 
-		return ErrCircuitOpen
+		return &CircuitOpenError{RetryAfter: breaker.RemainingOpen(), Forced: breaker.Mode() == BreakerForcedOpen}
 	}
 
 	return err
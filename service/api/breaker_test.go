@@ -2,6 +2,7 @@ package api
 
 import (
 	"errors"
+	"fmt"
 	"net/url"
 	"strings"
 	"testing"
@@ -20,11 +21,11 @@ import (
 
 // testBreaker returns a breaker with production settings. NewBreaker registers no
 // metrics, so each test can have its own.
-func testBreaker() *gobreaker.CircuitBreaker[struct{}] {
+func testBreaker() *Breaker {
 	return NewBreaker()
 }
 
-func newTestService(t *testing.T, breaker *gobreaker.CircuitBreaker[struct{}]) (*HttpService, *mocks.HttpRetryClientMock) {
+func newTestService(t *testing.T, breaker *Breaker) (*HttpService, *mocks.HttpRetryClientMock) {
 	t.Helper()
 
 	ctrl := gomock.NewController(t)
@@ -117,11 +118,11 @@ func TestForwardRequestErrorIsErrCircuitOpenWhenBreakerOpen(t *testing.T) {
 
 func TestHealthcheckBypassesBreaker(t *testing.T) {
 	// A breaker that opens on the very first failure, so an open state is trivial to reach.
-	breaker := gobreaker.NewCircuitBreaker[struct{}](gobreaker.Settings{
+	breaker := &Breaker{now: time.Now, CircuitBreaker: gobreaker.NewCircuitBreaker[struct{}](gobreaker.Settings{
 		ReadyToTrip: func(counts gobreaker.Counts) bool {
 			return counts.ConsecutiveFailures >= 1
 		},
-	})
+	})}
 
 	s, h := newTestService(t, breaker)
 
@@ -153,21 +154,21 @@ func TestRegisterBreakerMetricsExposesState(t *testing.T) {
 	RegisterBreakerMetrics(breaker)
 
 	expected := `
-# HELP gateway_api_breaker_state API circuit breaker state: 0=closed, 1=half-open, 2=open.
+# HELP gateway_api_breaker_state Upstream circuit breaker state: 0=closed, 1=half-open, 2=open.
 # TYPE gateway_api_breaker_state gauge
-gateway_api_breaker_state 0
+gateway_api_breaker_state{upstream="API"} 0
 `
 	assert.NoError(t, testutil.GatherAndCompare(prometheus.DefaultGatherer, strings.NewReader(expected), "gateway_api_breaker_state"))
 }
 
 func TestBreakerHalfOpenRecovery(t *testing.T) {
-	breaker := gobreaker.NewCircuitBreaker[struct{}](gobreaker.Settings{
+	breaker := &Breaker{now: time.Now, CircuitBreaker: gobreaker.NewCircuitBreaker[struct{}](gobreaker.Settings{
 		MaxRequests: 1,
 		Timeout:     20 * time.Millisecond,
 		ReadyToTrip: func(counts gobreaker.Counts) bool {
 			return counts.ConsecutiveFailures >= 1
 		},
-	})
+	})}
 
 	s, h := newTestService(t, breaker)
 
@@ -201,3 +202,173 @@ func TestBreakerHalfOpenRecovery(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, gobreaker.StateClosed, breaker.State())
 }
+
+func TestNewUpstreamBreakerSettings(t *testing.T) {
+	breaker := NewUpstreamBreaker(config.Upstream{
+		Name:    "exports",
+		Breaker: config.BreakerSettings{FailureThreshold: 1},
+	})
+	s, h := newTestService(t, breaker)
+
+	h.EXPECT().Do(gomock.Any(), gomock.Any()).Return(errors.New("connection refused")).Times(2)
+
+	req := fasthttp.AcquireRequest()
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseRequest(req)
+	defer fasthttp.ReleaseResponse(resp)
+
+	_ = s.doWithBreaker(req, resp)
+	assert.Equal(t, gobreaker.StateClosed, breaker.State())
+
+	_ = s.doWithBreaker(req, resp)
+	assert.Equal(t, gobreaker.StateOpen, breaker.State())
+	assert.Equal(t, "exports", breaker.Name())
+}
+
+func TestDoWithBreakerCountsServerErrors(t *testing.T) {
+	breaker := NewUpstreamBreaker(config.Upstream{
+		Name:    "API",
+		Breaker: config.BreakerSettings{FailureThreshold: 1, CountServerErrors: true},
+	})
+	s, h := newTestService(t, breaker)
+	s.upstream.Breaker = config.BreakerSettings{FailureThreshold: 1, CountServerErrors: true}
+
+	h.EXPECT().Do(gomock.Any(), gomock.Any()).DoAndReturn(func(_ *fasthttp.Request, resp *fasthttp.Response) error {
+		resp.SetStatusCode(fasthttp.StatusGatewayTimeout)
+
+		return nil
+	}).Times(2)
+
+	req := fasthttp.AcquireRequest()
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseRequest(req)
+	defer fasthttp.ReleaseResponse(resp)
+
+	// the answer is relayed as usual, only counted as a failure
+	assert.NoError(t, s.doWithBreaker(req, resp))
+	assert.Equal(t, fasthttp.StatusGatewayTimeout, resp.StatusCode())
+	assert.NoError(t, s.doWithBreaker(req, resp))
+	assert.Equal(t, gobreaker.StateOpen, breaker.State())
+
+	assert.ErrorIs(t, s.doWithBreaker(req, resp), ErrCircuitOpen)
+}
+
+func TestBreakerFailureRatio(t *testing.T) {
+	breaker := newBreaker("ratio", config.BreakerSettings{FailureRatio: 0.5, MinRequests: 4})
+	failure := errors.New("connection refused")
+
+	for i, err := range []error{nil, failure, nil, failure} {
+		assert.Equal(t, gobreaker.StateClosed, breaker.State(), "call %d", i)
+		_, _ = breaker.Execute(func() (struct{}, error) { return struct{}{}, err })
+	}
+
+	assert.Equal(t, gobreaker.StateOpen, breaker.State())
+}
+
+func TestDoWithBreakerGroups(t *testing.T) {
+	s, h := newTestService(t, testBreaker())
+	exports := config.BreakerGroup{Name: "exports", Prefix: "/api/exports", Breaker: config.BreakerSettings{FailureThreshold: 1}}
+	exportsBreaker := NewGroupBreaker(s.upstream, exports)
+	s.WithBreakerGroup(exports, exportsBreaker)
+
+	h.EXPECT().Do(gomock.Any(), gomock.Any()).DoAndReturn(func(req *fasthttp.Request, resp *fasthttp.Response) error {
+		if strings.HasPrefix(string(req.URI().Path()), "/v1/exports") {
+			return errors.New("connection refused")
+		}
+		resp.SetStatusCode(fasthttp.StatusOK)
+
+		return nil
+	}).Times(3)
+
+	req := fasthttp.AcquireRequest()
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseRequest(req)
+	defer fasthttp.ReleaseResponse(resp)
+
+	req.SetRequestURI(endpoint + "/v1/exports/42")
+	_ = s.doWithBreaker(req, resp)
+	_ = s.doWithBreaker(req, resp)
+	assert.Equal(t, gobreaker.StateOpen, exportsBreaker.State())
+	assert.Equal(t, "API/exports", exportsBreaker.Name())
+	assert.ErrorIs(t, s.doWithBreaker(req, resp), ErrCircuitOpen)
+
+	req.SetRequestURI(endpoint + "/v1/auth/login")
+	assert.NoError(t, s.doWithBreaker(req, resp))
+	assert.Equal(t, gobreaker.StateClosed, s.breaker.State())
+}
+
+func TestBreakerRetryAfterRemainingOpen(t *testing.T) {
+	breaker := NewUpstreamBreaker(config.Upstream{
+		Name:    "API",
+		Breaker: config.BreakerSettings{FailureThreshold: 1, Timeout: time.Minute},
+	})
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	breaker.now = func() time.Time { return now }
+	s, h := newTestService(t, breaker)
+
+	h.EXPECT().Do(gomock.Any(), gomock.Any()).Return(errors.New("connection refused")).Times(2)
+
+	req := fasthttp.AcquireRequest()
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseRequest(req)
+	defer fasthttp.ReleaseResponse(resp)
+
+	_ = s.doWithBreaker(req, resp)
+	_ = s.doWithBreaker(req, resp)
+	assert.Equal(t, gobreaker.StateOpen, breaker.State())
+
+	now = now.Add(20*time.Second + time.Millisecond)
+	err := s.doWithBreaker(req, resp)
+
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.Equal(t, 40, RetryAfter(err))
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := map[string]struct {
+		err      error
+		expected int
+	}{
+		"Remaining":       {err: &CircuitOpenError{RetryAfter: 12500 * time.Millisecond}, expected: 13},
+		"AtLeastOne":      {err: &CircuitOpenError{}, expected: 1},
+		"Wrapped":         {err: fmt.Errorf("forward: %w", &CircuitOpenError{RetryAfter: 5 * time.Second}), expected: 5},
+		"UnknownFallback": {err: ErrCircuitOpen, expected: RetryAfterSeconds},
+		"Forced":          {err: &CircuitOpenError{Forced: true}, expected: 0},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, RetryAfter(tt.err))
+		})
+	}
+}
+
+func TestBreakerForcedModes(t *testing.T) {
+	breaker := testBreaker()
+	s, h := newTestService(t, breaker)
+
+	req := fasthttp.AcquireRequest()
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseRequest(req)
+	defer fasthttp.ReleaseResponse(resp)
+
+	// forced open: rejected without reaching the transport, for as long as it is forced
+	breaker.SetMode(BreakerForcedOpen)
+	err := s.doWithBreaker(req, resp)
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.Equal(t, 0, RetryAfter(err))
+	assert.Zero(t, breaker.RemainingOpen())
+	assert.Equal(t, gobreaker.StateOpen, breaker.State())
+
+	// forced closed: failures reach the client but are not counted
+	breaker.SetMode(BreakerForcedClosed)
+	h.EXPECT().Do(gomock.Any(), gomock.Any()).Return(errors.New("connection refused")).Times(breakerFailureThreshold + 1)
+	for range breakerFailureThreshold + 1 {
+		assert.Error(t, s.doWithBreaker(req, resp))
+	}
+	assert.Equal(t, gobreaker.StateClosed, breaker.State())
+
+	breaker.SetMode(BreakerAuto)
+	assert.Equal(t, uint32(0), breaker.Counts().Requests)
+	assert.Equal(t, time.Duration(0), breaker.RemainingOpen())
+}
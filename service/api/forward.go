@@ -2,6 +2,7 @@ package api
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"log/slog"
 	"time"
@@ -14,6 +15,9 @@ import (
 	"github.com/cash-track/gateway/headers"
 	"github.com/cash-track/gateway/headers/cookie"
 	"github.com/cash-track/gateway/logger"
+	bodyHandler "github.com/cash-track/gateway/router/body"
+	"github.com/cash-track/gateway/router/response"
+	"github.com/cash-track/gateway/session"
 	"github.com/cash-track/gateway/traces"
 )
 
@@ -29,19 +33,25 @@ func (s *HttpService) ForwardRequest(ctx *fasthttp.RequestCtx, body []byte) erro
 	req.Header.SetMethodBytes(bytes.Clone(ctx.Request.Header.Method()))
 	s.copyRequestURI(ctx.Request.URI(), req.URI())
 
-	req.Header.SetContentTypeBytes(headers.ContentTypeJson)
+	// passed through as it came, e.g. multipart uploads: body.Handler already refused the
+	// content types the route does not allow
+	if contentType := ctx.Request.Header.ContentType(); len(contentType) > 0 {
+		req.Header.SetContentTypeBytes(bytes.Clone(contentType))
+	} else {
+		req.Header.SetContentTypeBytes(headers.ContentTypeJson)
+	}
 	req.Header.SetBytesV(headers.Accept, headers.ContentTypeJson)
 	req.Header.Set(headers.XForwardedFor, remoteIp)
 
 	// set once: the refreshed-token retry below reuses this same req
 	headers.WriteGatewayVersion(&req.Header, s.config.GitTag, s.config.GitSha)
-	headers.WriteGatewaySecret(&req.Header, s.config.GatewaySecret)
+	headers.WriteGatewaySecret(&req.Header, config.Live().GatewaySecret)
 
 	headers.CopyFromRequest(ctx, req, []string{
 		headers.AcceptLanguage,
 		headers.AccessControlRequestHeaders,
 		headers.AccessControlRequestMethod,
-		headers.ContentType,
+		headers.IdempotencyKey,
 		headers.UserAgent,
 		headers.Referer,
 		headers.Origin,
@@ -50,25 +60,45 @@ func (s *HttpService) ForwardRequest(ctx *fasthttp.RequestCtx, body []byte) erro
 	headers.CopyCloudFlareHeaders(ctx, req)
 
 	// propagate authentication
-	auth := cookie.ReadAuthCookie(ctx)
+	auth, err := s.sessions.Resolve(ctx)
+	if errors.Is(err, session.ErrRevoked) {
+		// signed out from another device: no need to ask the API about a dropped token
+		response.New(response.CodeSessionRevoked).Write(ctx)
+
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%s resolve session: %w", s.upstream.Name, err)
+	}
+
+	// refreshedAhead: auth is already a fresh pair, the 401 retry below is not needed
+	auth, refreshedAhead := s.refreshAhead(ctx, auth)
 	if auth.IsLogged() {
 		headers.WriteBearerToken(req, auth.AccessToken)
 	}
 
+	stream, streamed := bodyHandler.Stream(ctx)
+	bodyStreamed := false
+
 	// copy Body if method allows
 	if _, ok := methodsWithBody[string(ctx.Method())]; ok {
-		if body == nil {
-			req.SetBody(bytes.Clone(ctx.Request.Body()))
-		} else {
+		switch {
+		case body != nil:
 			req.SetBody(bytes.Clone(body))
+		case stream != nil:
+			// sent as it is read, with the Content-Length of the request or chunked
+			req.SetBodyStream(stream, ctx.Request.Header.ContentLength())
+			bodyStreamed = true
+		default:
+			req.SetBody(bytes.Clone(ctx.Request.Body()))
 		}
 	}
 
-	logger.DebugRequest(req, ServiceId)
+	logger.DebugRequest(req, s.upstream.Name)
 
 	spanCtx, span := traces.GetTracer().Start(
 		traces.FindParentContext(ctx),
-		fmt.Sprintf("forward %s %s %s", ServiceId, ctx.Request.Header.Method(), ctx.URI().PathOriginal()),
+		fmt.Sprintf("forward %s %s %s", s.upstream.Name, ctx.Request.Header.Method(), ctx.URI().PathOriginal()),
 		trace.WithAttributes(
 			traces.MergeAttributes(
 				traces.Attributes(attribute.String("http.request.real_ip", remoteIp)),
@@ -84,32 +114,53 @@ func (s *HttpService) ForwardRequest(ctx *fasthttp.RequestCtx, body []byte) erro
 
 	// execute request
 	resp := fasthttp.AcquireResponse()
+	resp.StreamBody = streamed
+	// a streamed body is handed to ctx, which releases resp once written to the client
+	handedOff := false
 	defer func() {
-		fasthttp.ReleaseResponse(resp)
+		if !handedOff {
+			fasthttp.ReleaseResponse(resp)
+		}
 	}()
 
+	respond := func() error {
+		handedOff = resp.IsBodyStream()
+
+		return forwardResponse(ctx, resp)
+	}
+
 	start := time.Now()
-	err := s.doWithBreaker(req, resp)
+	err = s.doWithBreaker(req, resp)
 	duration := time.Since(start)
 
 	if err != nil {
 		span.RecordError(err)
 
-		return fmt.Errorf("API request error: %w", err)
+		return fmt.Errorf("%s request error: %w", s.upstream.Name, err)
 	}
 
-	logger.DebugResponse(resp, ServiceId)
-	logger.FullForwarded(ctx, resp, ServiceId, duration)
+	logger.DebugResponse(resp, s.upstream.Name)
+	logger.FullForwarded(ctx, resp, s.upstream.Name, duration)
 
 	span.SetAttributes(traces.ResponseAttributes(resp)...)
 	span.SetAttributes(responseBodyAttributes(resp)...)
 
+	if refreshedAhead {
+		if err := s.keepRefreshed(ctx, resp, auth, remoteIp); err != nil {
+			span.RecordError(err)
+
+			return err
+		}
+
+		return respond()
+	}
+
 	if !auth.IsLogged() || !auth.CanRefresh() || resp.StatusCode() != fasthttp.StatusUnauthorized {
-		return forwardResponse(ctx, resp)
+		return respond()
 	}
 
 	// perform refresh token
-	newAuth, err := s.refreshToken(auth, spanCtx, ctx)
+	newAuth, err := s.authService().refreshTokenShared(auth, spanCtx, ctx)
 	if err != nil {
 		// Transient failure: could not reach the API or it returned a non-401
 		// (e.g. 5xx). The refresh token may still be valid, so DO NOT delete
@@ -125,18 +176,20 @@ func (s *HttpService) ForwardRequest(ctx *fasthttp.RequestCtx, body []byte) erro
 		resp.Reset()
 		resp.SetStatusCode(fasthttp.StatusServiceUnavailable)
 
-		// WriteCookie deliberately NOT called → cookies untouched.
-		return forwardResponse(ctx, resp)
+		// sessions.Update deliberately NOT called → session untouched.
+		return respond()
 	}
 
-	if newAuth.IsLogged() {
+	// A streamed body cannot be sent twice: the refreshed tokens are kept and the 401
+	// relayed, for the client to retry with them.
+	if newAuth.IsLogged() && !bodyStreamed {
 		headers.WriteBearerToken(req, newAuth.AccessToken)
 
 		span.End()
 
 		_, retrySpan := traces.GetTracer().Start(
 			spanCtx,
-			fmt.Sprintf("forward (refreshed) %s %s %s", ServiceId, ctx.Request.Header.Method(), ctx.URI().PathOriginal()),
+			fmt.Sprintf("forward (refreshed) %s %s %s", s.upstream.Name, ctx.Request.Header.Method(), ctx.URI().PathOriginal()),
 			trace.WithAttributes(
 				traces.MergeAttributes(
 					traces.Attributes(attribute.String("http.request.real_ip", remoteIp)),
@@ -156,65 +209,106 @@ func (s *HttpService) ForwardRequest(ctx *fasthttp.RequestCtx, body []byte) erro
 		if retryErr != nil {
 			retrySpan.RecordError(retryErr)
 
-			return fmt.Errorf("API request with fresh token error: %w", retryErr)
+			return fmt.Errorf("%s request with fresh token error: %w", s.upstream.Name, retryErr)
 		}
 
-		logger.DebugResponse(resp, ServiceId)
+		logger.DebugResponse(resp, s.upstream.Name)
 		// Logged separately from the initial attempt above: this is a second, genuinely
 		// distinct round trip to the API (with a refreshed token), matching the separate
 		// "forward (refreshed)" trace span created for it.
-		logger.FullForwarded(ctx, resp, ServiceId, retryDuration)
+		logger.FullForwarded(ctx, resp, s.upstream.Name, retryDuration)
 		retrySpan.SetAttributes(traces.ResponseAttributes(resp)...)
 		retrySpan.SetAttributes(responseBodyAttributes(resp)...)
-
-		// Seed a fresh CSRF token keyed to the new access token's iat so the
-		// next mutating request is not rejected with 417. Non-fatal: the user
-		// can recover via GET /csrf if Redis is temporarily unavailable.
-		// Only seed on 2xx to avoid advancing CSRF state when the retried request fails.
-		if s.csrf != nil && resp.StatusCode() >= fasthttp.StatusOK && resp.StatusCode() < fasthttp.StatusMultipleChoices {
-			if err := s.csrf.Seed(ctx, newAuth); err != nil {
-				slog.Warn("csrf seed after token refresh failed",
-					"trace_id", traces.FindTraceId(ctx),
-					"client_ip", remoteIp,
-					"error", err,
-				)
-			}
-		}
 	}
 
 	// This is also reached when newAuth is not logged (refresh token genuinely
-	// expired/invalid) ⇒ WriteCookie takes the delete branch, logging the user out.
-	if err := newAuth.WriteCookie(ctx); err != nil {
+	// expired/invalid) ⇒ Update ends the session, logging the user out.
+	if err := s.keepRefreshed(ctx, resp, newAuth, remoteIp); err != nil {
 		span.RecordError(err)
 
+		return err
+	}
+
+	return respond()
+}
+
+// keepRefreshed persists the token pair refreshed for this request once its response is
+// known.
+func (s *HttpService) keepRefreshed(ctx *fasthttp.RequestCtx, resp *fasthttp.Response, newAuth cookie.Auth, remoteIp string) error {
+	// Seed a fresh CSRF token keyed to the new access token's iat so the
+	// next mutating request is not rejected with 417. Non-fatal: the user
+	// can recover via GET /csrf if Redis is temporarily unavailable.
+	// Only seed on 2xx to avoid advancing CSRF state when the request failed.
+	if newAuth.IsLogged() && s.csrf != nil &&
+		resp.StatusCode() >= fasthttp.StatusOK && resp.StatusCode() < fasthttp.StatusMultipleChoices {
+		if err := s.csrf.Seed(ctx, newAuth); err != nil {
+			slog.Warn("csrf seed after token refresh failed",
+				"trace_id", traces.FindTraceId(ctx),
+				"client_ip", remoteIp,
+				"error", err,
+			)
+		}
+	}
+
+	if err := s.sessions.Update(ctx, newAuth); err != nil {
 		return fmt.Errorf("write auth cookie after refresh: %w", err)
 	}
 
-	return forwardResponse(ctx, resp)
+	return nil
 }
 
-// forwardResponse relays the backend's status, body and headers to the client.
+// forwardResponse relays the backend's status, body and headers to the client. A streamed
+// body is relayed as it arrives, with the Content-Length of the backend or chunked, and
+// resp is released once it is written. A 200 answer to a GET request gets an ETag when the
+// backend sends none, and a 304 without body when If-None-Match of the request names it.
 // CORS response headers are set by headers.CorsHandler, which wraps this call.
 func forwardResponse(ctx *fasthttp.RequestCtx, resp *fasthttp.Response) error {
 	ctx.SetStatusCode(resp.StatusCode())
-	ctx.SetBody(bytes.Clone(resp.Body()))
+
+	if resp.IsBodyStream() {
+		ctx.SetBodyStream(&responseStream{resp: resp}, resp.Header.ContentLength())
+	} else {
+		ctx.SetBody(bytes.Clone(resp.Body()))
+	}
 
 	headers.CopyFromResponse(resp, ctx, []string{
+		headers.CacheControl,
+		headers.ContentDisposition,
 		headers.ContentType,
+		headers.ETag,
 		headers.RetryAfter,
 		headers.XCtApiSha,
 		headers.XCtApiVersion,
 		headers.XRateLimit,
 		headers.XRateLimitRemaining,
 	})
+	headers.KeepUpstreamVary(ctx, resp)
+	headers.SetETag(ctx)
+	headers.NotModified(ctx)
 
 	return nil
 }
 
+// responseStream is the streamed body of resp, released with it once closed.
+type responseStream struct {
+	resp *fasthttp.Response
+}
+
+func (s *responseStream) Read(p []byte) (int, error) {
+	return s.resp.BodyStream().Read(p)
+}
+
+func (s *responseStream) Close() error {
+	err := s.resp.CloseBodyStream()
+	fasthttp.ReleaseResponse(s.resp)
+
+	return err
+}
+
 // requestBodyAttributes returns a redacted body span attribute for req, or nil when
-// body capture is disabled via TRACE_CAPTURE_BODY.
+// body capture is disabled via TRACE_CAPTURE_BODY or the body is streamed.
 func requestBodyAttributes(req *fasthttp.Request) []attribute.KeyValue {
-	if !config.Global.TraceCaptureBody {
+	if !config.Global.TraceCaptureBody || req.IsBodyStream() {
 		return nil
 	}
 
@@ -222,9 +316,9 @@ func requestBodyAttributes(req *fasthttp.Request) []attribute.KeyValue {
 }
 
 // responseBodyAttributes returns a redacted body span attribute for resp, or nil when
-// body capture is disabled via TRACE_CAPTURE_BODY.
+// body capture is disabled via TRACE_CAPTURE_BODY or the body is streamed.
 func responseBodyAttributes(resp *fasthttp.Response) []attribute.KeyValue {
-	if !config.Global.TraceCaptureBody {
+	if !config.Global.TraceCaptureBody || resp.IsBodyStream() {
 		return nil
 	}
 
@@ -2,19 +2,29 @@ package api
 
 import (
 	"fmt"
+	"io"
 	"net"
 	"net/url"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/go-redis/redismock/v9"
+	"github.com/golang-jwt/jwt/v4"
 	"github.com/stretchr/testify/assert"
 	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
 	"go.uber.org/mock/gomock"
 
 	"github.com/cash-track/gateway/config"
 	"github.com/cash-track/gateway/headers"
 	"github.com/cash-track/gateway/headers/cookie"
+	gatewayhttp "github.com/cash-track/gateway/http"
+	"github.com/cash-track/gateway/http/retryhttp"
 	"github.com/cash-track/gateway/mocks"
+	bodyHandler "github.com/cash-track/gateway/router/body"
+	"github.com/cash-track/gateway/session"
 )
 
 // tomorrowRFC3339 returns a valid future RefreshTokenExpiredAt value for tests that
@@ -23,6 +33,16 @@ func tomorrowRFC3339() string {
 	return time.Now().Add(time.Hour * 24).Format(time.RFC3339)
 }
 
+// withGatewaySecret sets the live GATEWAY_SECRET for the duration of the test.
+func withGatewaySecret(t *testing.T, secret string) {
+	t.Helper()
+
+	original := config.Global
+	t.Cleanup(func() { config.Global = original })
+
+	config.Global.GatewaySecret = secret
+}
+
 func TestFullForwardRequestWithAuth(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	h := mocks.NewHttpRetryClientMock(ctrl)
@@ -64,6 +84,97 @@ func TestFullForwardRequestWithAuth(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestForwardRequestWithSessionAuth(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	h := mocks.NewHttpRetryClientMock(ctrl)
+	h.EXPECT().WithReadTimeout(gomock.Eq(httpReadTimeout))
+	h.EXPECT().WithWriteTimeout(gomock.Eq(httpWriteTimeout))
+	h.EXPECT().WithRetryAttempts(gomock.Eq(httpRetryAttempts))
+	h.EXPECT().Do(gomock.Any(), gomock.Any()).DoAndReturn(func(req *fasthttp.Request, resp *fasthttp.Response) error {
+		resp.SetStatusCode(fasthttp.StatusOK)
+
+		assert.Equal(t, "Bearer session_access_token", string(req.Header.Peek(headers.Authorization)))
+
+		return nil
+	})
+
+	sessionId := strings.Repeat("a", 43)
+	client, mock := redismock.NewClientMock()
+	mock.ExpectGet("CT:session:" + sessionId).SetVal(`{"auth":{"accessToken":"session_access_token"}}`)
+
+	apiUrl, _ := url.Parse(endpoint)
+	s := NewHttp(h, config.Config{
+		ApiURI: apiUrl,
+	}, nil, testBreaker()).WithSessions(session.NewRedisStore(client))
+
+	ctx := fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod(fasthttp.MethodGet)
+	ctx.Request.Header.SetCookie(cookie.SessionCookieName, sessionId)
+	// left over from cookie mode, never forwarded
+	ctx.Request.Header.SetCookie(cookie.AccessTokenCookieName, "access_token")
+
+	err := s.ForwardRequest(&ctx, nil)
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestForwardRequestRevokedSessionRejected(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	h := mocks.NewHttpRetryClientMock(ctrl)
+	h.EXPECT().WithReadTimeout(gomock.Eq(httpReadTimeout))
+	h.EXPECT().WithWriteTimeout(gomock.Eq(httpWriteTimeout))
+	h.EXPECT().WithRetryAttempts(gomock.Eq(httpRetryAttempts))
+	// no Do expected: a revoked session never reaches the API
+
+	sessionId := strings.Repeat("a", 43)
+	client, mock := redismock.NewClientMock()
+	mock.ExpectGet("CT:session:" + sessionId).SetVal(`{"userId":"42","revokedAt":"2026-01-01T00:00:00Z"}`)
+
+	apiUrl, _ := url.Parse(endpoint)
+	s := NewHttp(h, config.Config{
+		ApiURI: apiUrl,
+	}, nil, testBreaker()).WithSessions(session.NewRedisStore(client))
+
+	ctx := fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod(fasthttp.MethodGet)
+	ctx.Request.Header.SetCookie(cookie.SessionCookieName, sessionId)
+
+	err := s.ForwardRequest(&ctx, nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, fasthttp.StatusUnauthorized, ctx.Response.StatusCode())
+	assert.Contains(t, string(ctx.Response.Body()), `"code":"session_revoked"`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestForwardRequestSessionResolveError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	h := mocks.NewHttpRetryClientMock(ctrl)
+	h.EXPECT().WithReadTimeout(gomock.Eq(httpReadTimeout))
+	h.EXPECT().WithWriteTimeout(gomock.Eq(httpWriteTimeout))
+	h.EXPECT().WithRetryAttempts(gomock.Eq(httpRetryAttempts))
+	// no Do expected: the request is not sent without its credentials
+
+	sessionId := strings.Repeat("a", 43)
+	client, mock := redismock.NewClientMock()
+	mock.ExpectGet("CT:session:" + sessionId).SetErr(fmt.Errorf("connection refused"))
+
+	apiUrl, _ := url.Parse(endpoint)
+	s := NewHttp(h, config.Config{
+		ApiURI: apiUrl,
+	}, nil, testBreaker()).WithSessions(session.NewRedisStore(client))
+
+	ctx := fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod(fasthttp.MethodGet)
+	ctx.Request.Header.SetCookie(cookie.SessionCookieName, sessionId)
+
+	err := s.ForwardRequest(&ctx, nil)
+
+	assert.ErrorContains(t, err, "resolve session: session load: connection refused")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestForwardRequestWithBodyOverride(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	h := mocks.NewHttpRetryClientMock(ctrl)
@@ -94,6 +205,36 @@ func TestForwardRequestWithBodyOverride(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestForwardRequestKeepsContentType(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	h := mocks.NewHttpRetryClientMock(ctrl)
+	h.EXPECT().WithReadTimeout(gomock.Eq(httpReadTimeout))
+	h.EXPECT().WithWriteTimeout(gomock.Eq(httpWriteTimeout))
+	h.EXPECT().WithRetryAttempts(gomock.Eq(httpRetryAttempts))
+	h.EXPECT().Do(gomock.Any(), gomock.Any()).DoAndReturn(func(req *fasthttp.Request, resp *fasthttp.Response) error {
+		resp.SetStatusCode(fasthttp.StatusOK)
+
+		assert.Equal(t, "multipart/form-data; boundary=xyz", string(req.Header.ContentType()))
+		assert.Equal(t, "--xyz--", string(req.Body()))
+
+		return nil
+	})
+
+	apiUrl, _ := url.Parse(endpoint)
+	s := NewHttp(h, config.Config{
+		ApiURI: apiUrl,
+	}, nil, testBreaker())
+
+	ctx := fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod(fasthttp.MethodPost)
+	ctx.Request.Header.SetContentType("multipart/form-data; boundary=xyz")
+	ctx.Request.SetBodyString("--xyz--")
+
+	err := s.ForwardRequest(&ctx, nil)
+
+	assert.NoError(t, err)
+}
+
 func TestForwardRequestSetsGatewayVersionHeaders(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	h := mocks.NewHttpRetryClientMock(ctrl)
@@ -167,9 +308,9 @@ func TestForwardRequestSetsGatewaySecretHeader(t *testing.T) {
 	})
 
 	apiUrl, _ := url.Parse(endpoint)
+	withGatewaySecret(t, "shared-secret")
 	s := NewHttp(h, config.Config{
-		ApiURI:        apiUrl,
-		GatewaySecret: "shared-secret",
+		ApiURI: apiUrl,
 	}, nil, testBreaker())
 
 	ctx := fasthttp.RequestCtx{}
@@ -242,9 +383,9 @@ func TestForwardRequestGatewaySecretHeaderPersistsAcrossRefreshRetry(t *testing.
 	})
 
 	apiUrl, _ := url.Parse(endpoint)
+	withGatewaySecret(t, "shared-secret")
 	s := NewHttp(h, config.Config{
-		ApiURI:        apiUrl,
-		GatewaySecret: "shared-secret",
+		ApiURI: apiUrl,
 	}, nil, testBreaker())
 
 	ctx := fasthttp.RequestCtx{}
@@ -784,6 +925,7 @@ func TestForwardResponseDoesNotRelayCorsHeadersFromBackend(t *testing.T) {
 	assert.Empty(t, ctx.Response.Header.Peek(headers.AccessControlAllowHeaders))
 	assert.Empty(t, ctx.Response.Header.Peek(headers.AccessControlMaxAge))
 	assert.Empty(t, ctx.Response.Header.Peek(headers.Vary))
+	assert.Equal(t, "Content-Type,X-Rate-Limit", string(headers.UpstreamVary(&ctx)))
 	assert.Empty(t, ctx.Response.Header.Peek(headers.AccessControlAllowCredentials))
 	assert.Empty(t, ctx.Response.Header.Peek(headers.AccessControlExposeHeaders))
 }
@@ -804,6 +946,81 @@ func TestForwardResponseDoesNotSynthesizeApiVersionHeaders(t *testing.T) {
 	assert.Empty(t, ctx.Response.Header.Peek(headers.XCtApiSha))
 }
 
+func TestForwardResponseConditionalGet(t *testing.T) {
+	for name, test := range map[string]struct {
+		method       string
+		ifNoneMatch  string
+		etag         string
+		cacheControl string
+		expectStatus int
+		expectETag   string
+	}{
+		"Generated": {
+			method:       fasthttp.MethodGet,
+			expectStatus: fasthttp.StatusOK,
+			expectETag:   headers.BodyETag([]byte(`{"data":[]}`)),
+		},
+		"GeneratedNotModified": {
+			method:       fasthttp.MethodGet,
+			ifNoneMatch:  headers.BodyETag([]byte(`{"data":[]}`)),
+			expectStatus: fasthttp.StatusNotModified,
+			expectETag:   headers.BodyETag([]byte(`{"data":[]}`)),
+		},
+		"Upstream": {
+			method:       fasthttp.MethodGet,
+			ifNoneMatch:  `"v0"`,
+			etag:         `W/"v1"`,
+			expectStatus: fasthttp.StatusOK,
+			expectETag:   `W/"v1"`,
+		},
+		"UpstreamNotModified": {
+			method:       fasthttp.MethodGet,
+			ifNoneMatch:  `"v1"`,
+			etag:         `W/"v1"`,
+			expectStatus: fasthttp.StatusNotModified,
+			expectETag:   `W/"v1"`,
+		},
+		"NoStore": {
+			method:       fasthttp.MethodGet,
+			ifNoneMatch:  "*",
+			cacheControl: "no-store",
+			expectStatus: fasthttp.StatusOK,
+		},
+		"Post": {
+			method:       fasthttp.MethodPost,
+			ifNoneMatch:  "*",
+			expectStatus: fasthttp.StatusOK,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctx := fasthttp.RequestCtx{}
+			ctx.Request.Header.SetMethod(test.method)
+			if test.ifNoneMatch != "" {
+				ctx.Request.Header.Set(headers.IfNoneMatch, test.ifNoneMatch)
+			}
+
+			resp := fasthttp.Response{}
+			resp.SetStatusCode(fasthttp.StatusOK)
+			resp.SetBodyString(`{"data":[]}`)
+			if test.etag != "" {
+				resp.Header.Set(headers.ETag, test.etag)
+			}
+			if test.cacheControl != "" {
+				resp.Header.Set(headers.CacheControl, test.cacheControl)
+			}
+
+			assert.NoError(t, forwardResponse(&ctx, &resp))
+			assert.Equal(t, test.expectStatus, ctx.Response.StatusCode())
+			assert.Equal(t, test.expectETag, string(ctx.Response.Header.Peek(headers.ETag)))
+			if test.expectStatus == fasthttp.StatusNotModified {
+				assert.Empty(t, ctx.Response.Body())
+			} else {
+				assert.Equal(t, `{"data":[]}`, string(ctx.Response.Body()))
+			}
+		})
+	}
+}
+
 func TestRequestBodyAttributesDisabled(t *testing.T) {
 	orig := config.Global.TraceCaptureBody
 	config.Global.TraceCaptureBody = false
@@ -863,3 +1080,463 @@ func TestResponseBodyAttributesEnabled(t *testing.T) {
 	assert.Equal(t, "http.response.body", string(attrs[0].Key))
 	assert.Equal(t, `{"accessToken":"***"}`, attrs[0].Value.AsString())
 }
+
+func TestForwardRequestUpstreamRefreshesThroughApi(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	apiHttp := mocks.NewHttpRetryClientMock(ctrl)
+	apiHttp.EXPECT().WithReadTimeout(gomock.Any())
+	apiHttp.EXPECT().WithWriteTimeout(gomock.Any())
+	apiHttp.EXPECT().WithRetryAttempts(gomock.Any())
+	apiHttp.EXPECT().Do(gomock.Any(), gomock.Any()).DoAndReturn(func(req *fasthttp.Request, resp *fasthttp.Response) error {
+		assert.Equal(t, endpoint+"/v1/auth/refresh", req.URI().String())
+
+		resp.SetStatusCode(fasthttp.StatusOK)
+		resp.SetBodyString(fmt.Sprintf(`{"accessToken":"%s","refreshToken":"%s","refreshTokenExpiredAt":"%s"}`, "new_access_token", "new_refresh_token", tomorrowRFC3339()))
+
+		return nil
+	})
+
+	exportsHttp := mocks.NewHttpRetryClientMock(ctrl)
+	exportsHttp.EXPECT().WithReadTimeout(gomock.Any())
+	exportsHttp.EXPECT().WithWriteTimeout(gomock.Any())
+	exportsHttp.EXPECT().WithRetryAttempts(gomock.Any())
+	gomock.InOrder(
+		exportsHttp.EXPECT().Do(gomock.Any(), gomock.Any()).DoAndReturn(func(req *fasthttp.Request, resp *fasthttp.Response) error {
+			assert.Equal(t, "http://exports.test.com/v2/exports/report", req.URI().String())
+			assert.Equal(t, "Bearer access_token", string(req.Header.Peek(headers.Authorization)))
+
+			resp.SetStatusCode(fasthttp.StatusUnauthorized)
+
+			return nil
+		}),
+		exportsHttp.EXPECT().Do(gomock.Any(), gomock.Any()).DoAndReturn(func(req *fasthttp.Request, resp *fasthttp.Response) error {
+			assert.Equal(t, "Bearer new_access_token", string(req.Header.Peek(headers.Authorization)))
+
+			resp.SetStatusCode(fasthttp.StatusOK)
+
+			return nil
+		}),
+	)
+
+	apiUrl, _ := url.Parse(endpoint)
+	exportsUrl, _ := url.Parse("http://exports.test.com")
+
+	api := NewHttp(apiHttp, config.Config{ApiURI: apiUrl}, nil, testBreaker())
+	s := NewHttpUpstream(exportsHttp, config.Config{}, config.Upstream{
+		Name:    "exports",
+		Prefix:  "/api/exports",
+		Rewrite: "/v2/exports",
+		URI:     exportsUrl,
+	}, nil, NewUpstreamBreaker(config.Upstream{Name: "exports"})).WithRefresher(api)
+
+	ctx := fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod(fasthttp.MethodGet)
+	ctx.Request.SetRequestURI("/api/exports/report")
+	ctx.Request.Header.SetCookie(cookie.AccessTokenCookieName, "access_token")
+	ctx.Request.Header.SetCookie(cookie.RefreshTokenCookieName, "refresh_token")
+
+	err := s.ForwardRequest(&ctx, nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, fasthttp.StatusOK, ctx.Response.StatusCode())
+	assert.Contains(t, string(ctx.Response.Header.PeekCookie(cookie.AccessTokenCookieName)), "new_access_token")
+}
+
+func expiringAccessToken(in time.Duration) string {
+	token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": 42,
+		"iat": time.Now().Unix(),
+		"exp": time.Now().Add(in).Unix(),
+	}).SignedString([]byte("asd"))
+
+	return token
+}
+
+func TestForwardRequestRefreshesAheadOfExpiry(t *testing.T) {
+	accessToken := expiringAccessToken(10 * time.Second)
+
+	ctrl := gomock.NewController(t)
+	h := mocks.NewHttpRetryClientMock(ctrl)
+	h.EXPECT().WithReadTimeout(gomock.Eq(httpReadTimeout))
+	h.EXPECT().WithWriteTimeout(gomock.Eq(httpWriteTimeout))
+	h.EXPECT().WithRetryAttempts(gomock.Eq(httpRetryAttempts))
+	gomock.InOrder(
+		h.EXPECT().Do(gomock.Any(), gomock.Any()).DoAndReturn(func(req *fasthttp.Request, resp *fasthttp.Response) error {
+			assert.Equal(t, string(refreshURI), string(req.URI().Path()))
+			assert.Equal(t, `{"refreshToken":"refresh_token"}`, string(req.Body()))
+
+			resp.SetStatusCode(fasthttp.StatusOK)
+			resp.SetBodyString(fmt.Sprintf(`{"accessToken":"new_access_token","refreshToken":"new_refresh_token","refreshTokenExpiredAt":"%s"}`, tomorrowRFC3339()))
+
+			return nil
+		}),
+		h.EXPECT().Do(gomock.Any(), gomock.Any()).DoAndReturn(func(req *fasthttp.Request, resp *fasthttp.Response) error {
+			assert.Equal(t, "Bearer new_access_token", string(req.Header.Peek(headers.Authorization)))
+			assert.Equal(t, `{"status":"ok"}`, string(req.Body()))
+
+			resp.SetStatusCode(fasthttp.StatusOK)
+
+			return nil
+		}),
+	)
+
+	c := mocks.NewCsrfHandlerMock(ctrl)
+	c.EXPECT().Seed(gomock.Any(), gomock.Any()).DoAndReturn(func(_ *fasthttp.RequestCtx, auth cookie.Auth) error {
+		assert.Equal(t, "new_access_token", auth.AccessToken)
+
+		return nil
+	})
+
+	apiUrl, _ := url.Parse(endpoint)
+	s := NewHttp(h, config.Config{
+		ApiURI:             apiUrl,
+		RefreshAheadWindow: time.Minute,
+	}, c, testBreaker())
+
+	ctx := fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod(fasthttp.MethodPost)
+	ctx.Request.Header.SetCookie(cookie.AccessTokenCookieName, accessToken)
+	ctx.Request.Header.SetCookie(cookie.RefreshTokenCookieName, "refresh_token")
+	ctx.Request.SetBodyString(`{"status":"ok"}`)
+
+	err := s.ForwardRequest(&ctx, nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, fasthttp.StatusOK, ctx.Response.StatusCode())
+	assert.Contains(t, string(ctx.Response.Header.PeekCookie(cookie.AccessTokenCookieName)), "new_access_token")
+	assert.Contains(t, string(ctx.Response.Header.PeekCookie(cookie.RefreshTokenCookieName)), "new_refresh_token")
+}
+
+// A token refreshed ahead is as fresh as it gets: a 401 is the API's final answer.
+func TestForwardRequestRefreshedAheadSkipsRetry(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	h := mocks.NewHttpRetryClientMock(ctrl)
+	h.EXPECT().WithReadTimeout(gomock.Eq(httpReadTimeout))
+	h.EXPECT().WithWriteTimeout(gomock.Eq(httpWriteTimeout))
+	h.EXPECT().WithRetryAttempts(gomock.Eq(httpRetryAttempts))
+	gomock.InOrder(
+		h.EXPECT().Do(gomock.Any(), gomock.Any()).DoAndReturn(func(req *fasthttp.Request, resp *fasthttp.Response) error {
+			resp.SetStatusCode(fasthttp.StatusOK)
+			resp.SetBodyString(fmt.Sprintf(`{"accessToken":"new_access_token","refreshToken":"new_refresh_token","refreshTokenExpiredAt":"%s"}`, tomorrowRFC3339()))
+
+			return nil
+		}),
+		h.EXPECT().Do(gomock.Any(), gomock.Any()).DoAndReturn(func(req *fasthttp.Request, resp *fasthttp.Response) error {
+			resp.SetStatusCode(fasthttp.StatusUnauthorized)
+
+			return nil
+		}),
+	)
+
+	apiUrl, _ := url.Parse(endpoint)
+	s := NewHttp(h, config.Config{
+		ApiURI:             apiUrl,
+		RefreshAheadWindow: time.Minute,
+	}, nil, testBreaker())
+
+	ctx := fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod(fasthttp.MethodGet)
+	ctx.Request.Header.SetCookie(cookie.AccessTokenCookieName, expiringAccessToken(10*time.Second))
+	ctx.Request.Header.SetCookie(cookie.RefreshTokenCookieName, "refresh_token")
+
+	err := s.ForwardRequest(&ctx, nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, fasthttp.StatusUnauthorized, ctx.Response.StatusCode())
+	assert.Contains(t, string(ctx.Response.Header.PeekCookie(cookie.AccessTokenCookieName)), "new_access_token")
+}
+
+func TestForwardRequestRefreshAheadFailureKeepsCurrentToken(t *testing.T) {
+	accessToken := expiringAccessToken(10 * time.Second)
+
+	ctrl := gomock.NewController(t)
+	h := mocks.NewHttpRetryClientMock(ctrl)
+	h.EXPECT().WithReadTimeout(gomock.Eq(httpReadTimeout))
+	h.EXPECT().WithWriteTimeout(gomock.Eq(httpWriteTimeout))
+	h.EXPECT().WithRetryAttempts(gomock.Eq(httpRetryAttempts))
+	gomock.InOrder(
+		h.EXPECT().Do(gomock.Any(), gomock.Any()).DoAndReturn(func(req *fasthttp.Request, resp *fasthttp.Response) error {
+			resp.SetStatusCode(fasthttp.StatusInternalServerError)
+
+			return nil
+		}),
+		h.EXPECT().Do(gomock.Any(), gomock.Any()).DoAndReturn(func(req *fasthttp.Request, resp *fasthttp.Response) error {
+			assert.Equal(t, "Bearer "+accessToken, string(req.Header.Peek(headers.Authorization)))
+			resp.SetStatusCode(fasthttp.StatusOK)
+
+			return nil
+		}),
+	)
+
+	apiUrl, _ := url.Parse(endpoint)
+	s := NewHttp(h, config.Config{
+		ApiURI:             apiUrl,
+		RefreshAheadWindow: time.Minute,
+	}, nil, testBreaker())
+
+	ctx := fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod(fasthttp.MethodGet)
+	ctx.Request.Header.SetCookie(cookie.AccessTokenCookieName, accessToken)
+	ctx.Request.Header.SetCookie(cookie.RefreshTokenCookieName, "refresh_token")
+
+	err := s.ForwardRequest(&ctx, nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, fasthttp.StatusOK, ctx.Response.StatusCode())
+	assert.Nil(t, ctx.Response.Header.PeekCookie(cookie.AccessTokenCookieName))
+}
+
+func TestForwardRequestNoRefreshAhead(t *testing.T) {
+	for name, test := range map[string]struct {
+		accessToken string
+		window      time.Duration
+		skip        bool
+	}{
+		"OutsideWindow": {accessToken: expiringAccessToken(time.Hour), window: time.Minute},
+		"Disabled":      {accessToken: expiringAccessToken(10 * time.Second)},
+		"NoExp":         {accessToken: "access_token", window: time.Minute},
+		"Skipped":       {accessToken: expiringAccessToken(10 * time.Second), window: time.Minute, skip: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			h := mocks.NewHttpRetryClientMock(ctrl)
+			h.EXPECT().WithReadTimeout(gomock.Eq(httpReadTimeout))
+			h.EXPECT().WithWriteTimeout(gomock.Eq(httpWriteTimeout))
+			h.EXPECT().WithRetryAttempts(gomock.Eq(httpRetryAttempts))
+			h.EXPECT().Do(gomock.Any(), gomock.Any()).DoAndReturn(func(req *fasthttp.Request, resp *fasthttp.Response) error {
+				assert.Equal(t, "Bearer "+test.accessToken, string(req.Header.Peek(headers.Authorization)))
+				resp.SetStatusCode(fasthttp.StatusOK)
+
+				return nil
+			})
+
+			apiUrl, _ := url.Parse(endpoint)
+			s := NewHttp(h, config.Config{
+				ApiURI:             apiUrl,
+				RefreshAheadWindow: test.window,
+			}, nil, testBreaker())
+
+			ctx := fasthttp.RequestCtx{}
+			ctx.Request.Header.SetMethod(fasthttp.MethodGet)
+			ctx.Request.Header.SetCookie(cookie.AccessTokenCookieName, test.accessToken)
+			ctx.Request.Header.SetCookie(cookie.RefreshTokenCookieName, "refresh_token")
+			if test.skip {
+				SkipRefreshAhead(&ctx)
+			}
+
+			assert.NoError(t, s.ForwardRequest(&ctx, nil))
+		})
+	}
+}
+
+// zeroReader yields n zero bytes.
+type zeroReader struct {
+	n int
+}
+
+func (r *zeroReader) Read(p []byte) (int, error) {
+	if r.n == 0 {
+		return 0, io.EOF
+	}
+
+	n := min(len(p), r.n)
+	clear(p[:n])
+	r.n -= n
+
+	return n, nil
+}
+
+// startStreamingGateway serves ForwardRequest behind body.Handler with streamed request
+// bodies the way main does, in front of an upstream answering uploads with the size and
+// Content-Length it got and /download/{size} with that many bytes, chunked with ?chunked.
+func startStreamingGateway(tb testing.TB) *fasthttp.HostClient {
+	tb.Helper()
+
+	upstreamLn := fasthttputil.NewInmemoryListener()
+	upstream := &fasthttp.Server{
+		StreamRequestBody: true,
+		Handler: func(ctx *fasthttp.RequestCtx) {
+			if size, ok := strings.CutPrefix(string(ctx.Path()), "/v1/download/"); ok {
+				n, _ := strconv.Atoi(size)
+				length := n
+				if ctx.QueryArgs().Has("chunked") {
+					length = -1
+				}
+				ctx.Response.Header.Set(headers.ContentDisposition, `attachment; filename="export.csv"`)
+				ctx.SetBodyStream(&zeroReader{n: n}, length)
+
+				return
+			}
+
+			n, err := io.Copy(io.Discard, ctx.RequestBodyStream())
+			if err != nil {
+				ctx.SetStatusCode(fasthttp.StatusBadRequest)
+			}
+			ctx.SetBodyString(fmt.Sprintf("%d %d %s", n, ctx.Request.Header.ContentLength(), ctx.Request.Header.ContentType()))
+		},
+	}
+	go func() { _ = upstream.Serve(upstreamLn) }()
+
+	apiUrl, _ := url.Parse("http://upstream")
+	client := &retryhttp.FastHttpRetryClient{Client: &gatewayhttp.FastHttpClient{Client: &fasthttp.Client{
+		Dial: func(string) (net.Conn, error) { return upstreamLn.Dial() },
+	}}}
+	s := NewHttp(client, config.Config{ApiUrl: apiUrl.String(), ApiURI: apiUrl}, nil, testBreaker())
+
+	gatewayLn := fasthttputil.NewInmemoryListener()
+	gateway := &fasthttp.Server{
+		StreamRequestBody:            true,
+		DisablePreParseMultipartForm: true,
+		Handler: bodyHandler.Handler(func(ctx *fasthttp.RequestCtx) {
+			if err := s.ForwardRequest(ctx, nil); err != nil {
+				ctx.Error(err.Error(), fasthttp.StatusBadGateway)
+			}
+		}),
+	}
+	go func() { _ = gateway.Serve(gatewayLn) }()
+
+	tb.Cleanup(func() {
+		_ = gateway.Shutdown()
+		_ = upstream.Shutdown()
+	})
+
+	return &fasthttp.HostClient{
+		Addr:                "gateway",
+		StreamResponseBody:  true,
+		MaxResponseBodySize: 1 << 30,
+		Dial:                func(string) (net.Conn, error) { return gatewayLn.Dial() },
+	}
+}
+
+func withStreams(tb testing.TB, streams ...config.Stream) {
+	original := config.Global
+	tb.Cleanup(func() { config.Global = original })
+
+	config.Global.Streams = streams
+	config.Global.TraceCaptureBody = true
+}
+
+func TestForwardRequestStreamsBodies(t *testing.T) {
+	withStreams(t, config.Stream{Name: "uploads", Prefix: "/api/uploads"}, config.Stream{Name: "downloads", Prefix: "/api/download"})
+	client := startStreamingGateway(t)
+
+	for name, test := range map[string]struct {
+		method        string
+		path          string
+		bodySize      int
+		contentLength int
+		expectBody    string
+		expectLength  int
+	}{
+		"Upload": {
+			method:        fasthttp.MethodPost,
+			path:          "/api/uploads",
+			bodySize:      1 << 20,
+			contentLength: 1 << 20,
+			expectBody:    "1048576 1048576 image/png",
+			expectLength:  25,
+		},
+		"ChunkedUpload": {
+			method:        fasthttp.MethodPost,
+			path:          "/api/uploads",
+			bodySize:      1 << 20,
+			contentLength: -1,
+			expectBody:    "1048576 -1 image/png",
+			expectLength:  20,
+		},
+		"BufferedUpload": {
+			method:        fasthttp.MethodPost,
+			path:          "/api/wallets",
+			bodySize:      1 << 20,
+			contentLength: -1,
+			expectBody:    "1048576 1048576 image/png",
+			expectLength:  25,
+		},
+		"Download": {
+			method:       fasthttp.MethodGet,
+			path:         "/api/download/1048576",
+			expectLength: 1 << 20,
+		},
+		"ChunkedDownload": {
+			method:       fasthttp.MethodGet,
+			path:         "/api/download/1048576?chunked",
+			expectLength: -1,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			req, resp := fasthttp.AcquireRequest(), fasthttp.AcquireResponse()
+			defer fasthttp.ReleaseRequest(req)
+			defer fasthttp.ReleaseResponse(resp)
+
+			req.SetRequestURI("http://gateway" + test.path)
+			req.Header.SetMethod(test.method)
+			if test.bodySize > 0 {
+				req.Header.SetContentType("image/png")
+				req.SetBodyStream(&zeroReader{n: test.bodySize}, test.contentLength)
+			}
+
+			assert.NoError(t, client.Do(req, resp))
+			assert.Equal(t, fasthttp.StatusOK, resp.StatusCode())
+			assert.Equal(t, test.expectLength, resp.Header.ContentLength())
+
+			body, err := io.ReadAll(resp.BodyStream())
+			assert.NoError(t, err)
+
+			if test.expectBody != "" {
+				assert.Equal(t, test.expectBody, string(body))
+			} else {
+				assert.Len(t, body, 1<<20)
+				assert.Equal(t, `attachment; filename="export.csv"`, string(resp.Header.Peek(headers.ContentDisposition)))
+			}
+		})
+	}
+}
+
+// benchmarkForwardRequest sends a 100 MB upload and download through the gateway. B/op
+// stays flat with streams and grows with the payload without.
+func benchmarkForwardRequest(b *testing.B, streams ...config.Stream) {
+	withStreams(b, streams...)
+	config.Global.TraceCaptureBody = false
+	client := startStreamingGateway(b)
+
+	const size = 100 << 20
+
+	b.ReportAllocs()
+	b.SetBytes(2 * size)
+
+	for b.Loop() {
+		req, resp := fasthttp.AcquireRequest(), fasthttp.AcquireResponse()
+
+		req.SetRequestURI("http://gateway/api/uploads")
+		req.Header.SetMethod(fasthttp.MethodPost)
+		req.Header.SetContentType("text/csv")
+		req.SetBodyStream(&zeroReader{n: size}, size)
+
+		if err := client.Do(req, resp); err != nil {
+			b.Fatal(err)
+		}
+
+		req.Reset()
+		req.SetRequestURI(fmt.Sprintf("http://gateway/api/download/%d", size))
+
+		if err := client.Do(req, resp); err != nil {
+			b.Fatal(err)
+		}
+
+		if _, err := io.Copy(io.Discard, resp.BodyStream()); err != nil {
+			b.Fatal(err)
+		}
+
+		fasthttp.ReleaseRequest(req)
+		fasthttp.ReleaseResponse(resp)
+	}
+}
+
+func BenchmarkForwardRequestBuffered(b *testing.B) {
+	benchmarkForwardRequest(b)
+}
+
+func BenchmarkForwardRequestStreamed(b *testing.B) {
+	benchmarkForwardRequest(b, config.Stream{Name: "all", Prefix: "/api"})
+}
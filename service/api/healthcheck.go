@@ -5,26 +5,31 @@ import (
 
 	"github.com/valyala/fasthttp"
 
+	"github.com/cash-track/gateway/config"
 	"github.com/cash-track/gateway/headers"
 	"github.com/cash-track/gateway/logger"
 )
 
-var healthcheckURI = []byte("/healthcheck")
-
+// Healthcheck probes the upstream healthcheck path, bypassing the breaker. Upstreams
+// without one are always considered healthy.
 func (s *HttpService) Healthcheck() error {
+	if s.upstream.Healthcheck == "" {
+		return nil
+	}
+
 	req := fasthttp.AcquireRequest()
 	defer func() {
 		fasthttp.ReleaseRequest(req)
 	}()
 
 	req.Header.SetMethod(fasthttp.MethodGet)
-	s.setRequestURI(req.URI(), healthcheckURI)
+	s.setRequestURI(req.URI(), []byte(s.upstream.Healthcheck))
 	req.Header.SetContentTypeBytes(headers.ContentTypeJson)
 	req.Header.SetBytesV(headers.Accept, headers.ContentTypeJson)
 	headers.WriteGatewayVersion(&req.Header, s.config.GitTag, s.config.GitSha)
-	headers.WriteGatewaySecret(&req.Header, s.config.GatewaySecret)
+	headers.WriteGatewaySecret(&req.Header, config.Live().GatewaySecret)
 
-	logger.DebugRequest(req, ServiceId)
+	logger.DebugRequest(req, s.upstream.Name)
 
 	// execute request
 	resp := fasthttp.AcquireResponse()
@@ -34,13 +39,13 @@ func (s *HttpService) Healthcheck() error {
 
 	err := s.http.Do(req, resp)
 	if err != nil {
-		return fmt.Errorf("healthckeck API request error: %w", err)
+		return fmt.Errorf("healthckeck %s request error: %w", s.upstream.Name, err)
 	}
 
-	logger.DebugResponse(resp, ServiceId)
+	logger.DebugResponse(resp, s.upstream.Name)
 
 	if resp.StatusCode() != fasthttp.StatusOK {
-		return fmt.Errorf("healthckeck failed [%d], body: %s", resp.StatusCode(), resp.Body())
+		return fmt.Errorf("healthckeck %s failed [%d], body: %s", s.upstream.Name, resp.StatusCode(), resp.Body())
 	}
 
 	return nil
@@ -27,7 +27,7 @@ func TestHealthcheckOk(t *testing.T) {
 
 		assert.NotNil(t, req)
 		assert.Equal(t, fasthttp.MethodGet, string(req.Header.Method()))
-		assert.Equal(t, fmt.Sprintf("%s%s", endpoint, healthcheckURI), req.URI().String())
+		assert.Equal(t, endpoint+"/healthcheck", req.URI().String())
 		assert.Equal(t, string(headers.ContentTypeJson), string(req.Header.ContentType()))
 		assert.Equal(t, string(headers.ContentTypeJson), string(req.Header.Peek(headers.Accept)))
 		assert.Empty(t, req.Header.Peek(headers.XCtGatewayVersion))
@@ -86,9 +86,9 @@ func TestHealthcheckSetsGatewaySecretHeader(t *testing.T) {
 	})
 
 	apiUrl, _ := url.Parse(endpoint)
+	withGatewaySecret(t, "shared-secret")
 	s := NewHttp(h, config.Config{
-		ApiURI:        apiUrl,
-		GatewaySecret: "shared-secret",
+		ApiURI: apiUrl,
 	}, nil, testBreaker())
 	err := s.Healthcheck()
 
@@ -155,3 +155,17 @@ func TestHealthcheckError(t *testing.T) {
 
 	assert.Error(t, err)
 }
+
+func TestHealthcheckSkippedWithoutPath(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	h := mocks.NewHttpRetryClientMock(ctrl)
+	h.EXPECT().WithReadTimeout(gomock.Any())
+	h.EXPECT().WithWriteTimeout(gomock.Any())
+	h.EXPECT().WithRetryAttempts(gomock.Any())
+	// no Do: an upstream without a healthcheck path is never probed
+
+	uri, _ := url.Parse("http://exports.test.com")
+	s := NewHttpUpstream(h, config.Config{}, config.Upstream{Name: "exports", URI: uri}, nil, testBreaker())
+
+	assert.NoError(t, s.Healthcheck())
+}
@@ -0,0 +1,377 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/valyala/fasthttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/cash-track/gateway/config"
+	"github.com/cash-track/gateway/headers"
+	"github.com/cash-track/gateway/logger"
+	"github.com/cash-track/gateway/router/response"
+	"github.com/cash-track/gateway/session"
+	"github.com/cash-track/gateway/traces"
+)
+
+const (
+	metricsRealtimeSubsys = "realtime"
+	// realtimeMaxRefusalSize caps the body of an upstream answer refusing the connection,
+	// relayed to the client as a regular response.
+	realtimeMaxRefusalSize = 64 * 1024
+)
+
+// Kinds of realtime connections, see RealtimeKind.
+const (
+	RealtimeWebSocket   = "websocket"
+	RealtimeEventStream = "sse"
+)
+
+var (
+	realtimeConnections = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsRealtimeSubsys,
+		Name:      "connections",
+		Help:      "Open realtime connections by route and kind.",
+	}, []string{"route", "kind"})
+
+	realtimeConnectionsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsRealtimeSubsys,
+		Name:      "connections_total",
+		Help:      "Realtime connections opened by route and kind.",
+	}, []string{"route", "kind"})
+)
+
+// realtimeRequestHeaders are passed through to the upstream on top of the ones every
+// forwarded request gets.
+var realtimeRequestHeaders = []string{
+	headers.AcceptLanguage,
+	headers.UserAgent,
+	headers.Referer,
+	headers.Origin,
+	headers.CacheControl,
+	headers.LastEventId,
+	headers.Upgrade,
+	headers.SecWebSocketKey,
+	headers.SecWebSocketVersion,
+	headers.SecWebSocketProtocol,
+	headers.SecWebSocketExtensions,
+}
+
+// aLongTimeAgo unblocks the pending reads of a connection given as its deadline.
+var aLongTimeAgo = time.Unix(1, 0)
+
+// RealtimeKind tells a WebSocket upgrade or a Server-Sent Events subscription from the
+// requests forwarded by ForwardRequest.
+func RealtimeKind(ctx *fasthttp.RequestCtx) (string, bool) {
+	if !ctx.IsGet() {
+		return "", false
+	}
+
+	if ctx.Request.Header.ConnectionUpgrade() && strings.EqualFold(string(ctx.Request.Header.Peek(headers.Upgrade)), "websocket") {
+		return RealtimeWebSocket, true
+	}
+
+	if bytes.Contains(ctx.Request.Header.Peek(headers.Accept), headers.ContentTypeEventStream) {
+		return RealtimeEventStream, true
+	}
+
+	return "", false
+}
+
+// ForwardRealtime opens a connection to the upstream for the WebSocket upgrade or the
+// Server-Sent Events subscription of ctx, authenticated like ForwardRequest. Once the
+// upstream accepts it, the client connection is hijacked and both are relayed to each
+// other as they are until either end closes or nothing was sent for the IdleTimeout of
+// route. Any other answer of the upstream is relayed as a regular response. A WebSocket
+// upgrade from an Origin not in CORS_ALLOWED_ORIGINS is refused without dialing.
+func (s *HttpService) ForwardRealtime(ctx *fasthttp.RequestCtx, route config.Realtime) error {
+	kind, ok := RealtimeKind(ctx)
+	if !ok {
+		return s.ForwardRequest(ctx, nil)
+	}
+
+	// a page of any site can open a WebSocket carrying the cookies of the user, unlike a
+	// fetch it is not held back by CORS. Browsers always send Origin, other clients cannot
+	// be made to send the cookies of someone else.
+	if kind == RealtimeWebSocket && len(ctx.Request.Header.Peek(headers.Origin)) > 0 && !headers.IsOriginAllowed(ctx) {
+		response.New(response.CodeAccessDenied).Write(ctx)
+
+		return nil
+	}
+
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+
+	remoteIp := headers.GetClientIPFromContext(ctx)
+
+	req.Header.SetMethod(fasthttp.MethodGet)
+	s.copyRequestURI(ctx.Request.URI(), req.URI())
+	req.Header.Set(headers.XForwardedFor, remoteIp)
+
+	headers.WriteGatewayVersion(&req.Header, s.config.GitTag, s.config.GitSha)
+	headers.WriteGatewaySecret(&req.Header, config.Live().GatewaySecret)
+	headers.CopyFromRequest(ctx, req, realtimeRequestHeaders)
+	headers.CopyCloudFlareHeaders(ctx, req)
+
+	if kind == RealtimeWebSocket {
+		req.Header.Set(headers.Connection, "Upgrade")
+	} else {
+		req.Header.SetBytesV(headers.Accept, headers.ContentTypeEventStream)
+	}
+
+	// propagate authentication
+	auth, err := s.sessions.Resolve(ctx)
+	if errors.Is(err, session.ErrRevoked) {
+		response.New(response.CodeSessionRevoked).Write(ctx)
+
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%s resolve session: %w", s.upstream.Name, err)
+	}
+
+	if auth.IsLogged() {
+		headers.WriteBearerToken(req, auth.AccessToken)
+	}
+
+	logger.DebugRequest(req, s.upstream.Name)
+
+	_, span := traces.GetTracer().Start(
+		traces.FindParentContext(ctx),
+		fmt.Sprintf("realtime %s %s %s", s.upstream.Name, kind, ctx.URI().PathOriginal()),
+		trace.WithAttributes(
+			traces.MergeAttributes(
+				traces.Attributes(
+					attribute.String("http.request.real_ip", remoteIp),
+					attribute.String("realtime.route", route.Name),
+				),
+				traces.AttributesGetter(auth),
+				traces.RequestAttributes(req),
+			)...,
+		),
+	)
+	defer span.End()
+
+	traces.PropagateContextToRequest(ctx, req)
+
+	resp := fasthttp.AcquireResponse()
+
+	start := time.Now()
+	conn, br, err := s.dialRealtime(req, resp)
+	duration := time.Since(start)
+
+	if err != nil {
+		fasthttp.ReleaseResponse(resp)
+		span.RecordError(err)
+
+		return fmt.Errorf("%s realtime request error: %w", s.upstream.Name, err)
+	}
+
+	logger.DebugResponse(resp, s.upstream.Name)
+	logger.FullForwarded(ctx, resp, s.upstream.Name, duration)
+
+	span.SetAttributes(traces.ResponseAttributes(resp)...)
+
+	if !acceptsRealtime(kind, resp) {
+		defer fasthttp.ReleaseResponse(resp)
+		defer conn.Close()
+
+		if err := resp.ReadBody(br, realtimeMaxRefusalSize); err != nil {
+			span.RecordError(err)
+
+			return fmt.Errorf("%s realtime refusal read error: %w", s.upstream.Name, err)
+		}
+
+		return forwardResponse(ctx, resp)
+	}
+
+	// the head of the upstream is written by the hijack handler, with the headers the
+	// middleware set on ctx once this returns
+	ctx.HijackSetNoResponse(true)
+	ctx.Hijack(func(c net.Conn) {
+		defer fasthttp.ReleaseResponse(resp)
+		defer conn.Close()
+
+		realtimeConnections.WithLabelValues(route.Name, kind).Inc()
+		realtimeConnectionsTotal.WithLabelValues(route.Name, kind).Inc()
+		defer realtimeConnections.WithLabelValues(route.Name, kind).Dec()
+
+		opened := time.Now()
+
+		if err := writeRealtimeHead(c, ctx, resp); err != nil {
+			slog.Info("realtime connection lost before its head was written",
+				"trace_id", traces.FindTraceId(ctx), "route", route.Name, "kind", kind, "error", err)
+
+			return
+		}
+
+		relay(c, conn, br, route.IdleTimeout)
+
+		slog.Info("realtime connection closed",
+			"trace_id", traces.FindTraceId(ctx),
+			"route", route.Name,
+			"kind", kind,
+			"duration_ms", time.Since(opened).Milliseconds(),
+			"service", s.upstream.Name,
+		)
+	})
+
+	return nil
+}
+
+// dialRealtime connects to the upstream through the breaker, sends req and reads the head
+// of its answer into resp. The rest of the answer is left in the returned reader.
+func (s *HttpService) dialRealtime(req *fasthttp.Request, resp *fasthttp.Response) (net.Conn, *bufio.Reader, error) {
+	var (
+		conn net.Conn
+		br   *bufio.Reader
+	)
+
+	breaker, _ := s.breakerFor(req)
+
+	err := breaker.execute(func() (struct{}, error) {
+		c, err := dialUpstream(s.upstream, orDefault(s.upstream.WriteTimeout, httpWriteTimeout))
+		if err != nil {
+			return struct{}{}, err
+		}
+
+		_ = c.SetDeadline(time.Now().Add(orDefault(s.upstream.ReadTimeout, httpReadTimeout)))
+
+		bw := bufio.NewWriter(c)
+		if err := req.Write(bw); err != nil {
+			_ = c.Close()
+
+			return struct{}{}, err
+		}
+		if err := bw.Flush(); err != nil {
+			_ = c.Close()
+
+			return struct{}{}, err
+		}
+
+		r := bufio.NewReader(c)
+		if err := resp.Header.Read(r); err != nil {
+			_ = c.Close()
+
+			return struct{}{}, err
+		}
+
+		_ = c.SetDeadline(time.Time{})
+		conn, br = c, r
+
+		return struct{}{}, nil
+	})
+
+	if err != nil {
+		return nil, nil, s.breakerError(breaker, err)
+	}
+
+	return conn, br, nil
+}
+
+func dialUpstream(upstream config.Upstream, timeout time.Duration) (net.Conn, error) {
+	isTLS := upstream.URI.Scheme == "https"
+
+	c, err := fasthttp.DialTimeout(fasthttp.AddMissingPort(upstream.URI.Host, isTLS), timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	if isTLS {
+		return tls.Client(c, &tls.Config{ServerName: upstream.URI.Hostname()}), nil
+	}
+
+	return c, nil
+}
+
+func acceptsRealtime(kind string, resp *fasthttp.Response) bool {
+	if kind == RealtimeWebSocket {
+		return resp.StatusCode() == fasthttp.StatusSwitchingProtocols
+	}
+
+	return resp.StatusCode() == fasthttp.StatusOK &&
+		bytes.HasPrefix(resp.Header.ContentType(), headers.ContentTypeEventStream)
+}
+
+// writeRealtimeHead writes the head of the upstream answer to the client, with the
+// headers set on ctx by the middleware (trace ID, CORS, ...) it does not have itself.
+func writeRealtimeHead(c net.Conn, ctx *fasthttp.RequestCtx, resp *fasthttp.Response) error {
+	ctx.Response.Header.VisitAll(func(key, value []byte) {
+		switch string(key) {
+		case fasthttp.HeaderContentType, fasthttp.HeaderContentLength, fasthttp.HeaderContentEncoding,
+			fasthttp.HeaderServer, fasthttp.HeaderDate, fasthttp.HeaderConnection, fasthttp.HeaderTransferEncoding:
+			return
+		}
+
+		if len(resp.Header.PeekBytes(key)) == 0 {
+			resp.Header.SetBytesKV(key, value)
+		}
+	})
+
+	// a hijacked connection keeps the deadlines of the server
+	_ = c.SetDeadline(time.Time{})
+
+	_, err := c.Write(resp.Header.Header())
+
+	return err
+}
+
+// relay copies the client and the upstream to each other until either end closes or
+// nothing was read from both for idle. The client is only unblocked by its deadline: the
+// server closes it once the hijack handler returns.
+func relay(client, upstream net.Conn, upstreamReader io.Reader, idle time.Duration) {
+	var once sync.Once
+	shut := func() {
+		once.Do(func() {
+			_ = client.SetDeadline(aLongTimeAgo)
+			_ = upstream.Close()
+		})
+	}
+
+	timer := time.AfterFunc(idle, shut)
+	defer timer.Stop()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		_, _ = io.Copy(upstream, &activeReader{r: client, timer: timer, idle: idle})
+		shut()
+	}()
+
+	_, _ = io.Copy(client, &activeReader{r: upstreamReader, timer: timer, idle: idle})
+	shut()
+
+	<-done
+}
+
+// activeReader pushes the idle timer back on every read.
+type activeReader struct {
+	r     io.Reader
+	timer *time.Timer
+	idle  time.Duration
+}
+
+func (a *activeReader) Read(p []byte) (int, error) {
+	n, err := a.r.Read(p)
+	if n > 0 {
+		a.timer.Reset(a.idle)
+	}
+
+	return n, err
+}
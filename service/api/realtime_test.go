@@ -0,0 +1,238 @@
+package api
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+
+	"github.com/cash-track/gateway/config"
+	"github.com/cash-track/gateway/headers"
+	"github.com/cash-track/gateway/headers/cookie"
+	gatewayhttp "github.com/cash-track/gateway/http"
+	"github.com/cash-track/gateway/http/retryhttp"
+)
+
+// startRealtimeGateway serves route through a gateway in front of an upstream echoing
+// the WebSocket connections of signed in users and sending two events to subscribers,
+// and returns the address of the gateway.
+func startRealtimeGateway(tb testing.TB, route config.Realtime) string {
+	tb.Helper()
+
+	upstream := &fasthttp.Server{
+		Handler: func(ctx *fasthttp.RequestCtx) {
+			if string(ctx.Request.Header.Peek(headers.Authorization)) != "Bearer access_token" {
+				ctx.SetStatusCode(fasthttp.StatusUnauthorized)
+				ctx.SetBodyString(`{"message":"Unauthenticated"}`)
+
+				return
+			}
+
+			switch string(ctx.Path()) {
+			case "/v1/realtime/echo":
+				if !ctx.Request.Header.ConnectionUpgrade() {
+					ctx.SetStatusCode(fasthttp.StatusBadRequest)
+
+					return
+				}
+
+				ctx.SetStatusCode(fasthttp.StatusSwitchingProtocols)
+				ctx.Response.Header.Set(headers.Connection, "Upgrade")
+				ctx.Response.Header.Set(headers.Upgrade, "websocket")
+				ctx.Hijack(func(c net.Conn) {
+					_, _ = io.Copy(c, c)
+				})
+			case "/v1/realtime/events":
+				ctx.SetContentType("text/event-stream")
+				ctx.SetBodyStreamWriter(func(w *bufio.Writer) {
+					for i := 1; i <= 2; i++ {
+						_, _ = fmt.Fprintf(w, "id: %d\ndata: %s\n\n", i, ctx.Request.Header.Peek(headers.LastEventId))
+						_ = w.Flush()
+					}
+				})
+			default:
+				ctx.SetStatusCode(fasthttp.StatusNotFound)
+			}
+		},
+	}
+	upstreamLn, err := net.Listen("tcp4", "127.0.0.1:0")
+	assert.NoError(tb, err)
+	go func() { _ = upstream.Serve(upstreamLn) }()
+
+	apiUrl, _ := url.Parse("http://" + upstreamLn.Addr().String())
+	client := &retryhttp.FastHttpRetryClient{Client: &gatewayhttp.FastHttpClient{Client: &fasthttp.Client{}}}
+	s := NewHttp(client, config.Config{ApiUrl: apiUrl.String(), ApiURI: apiUrl}, nil, testBreaker())
+
+	gateway := &fasthttp.Server{
+		Handler: func(ctx *fasthttp.RequestCtx) {
+			if err := s.ForwardRealtime(ctx, route); err != nil {
+				ctx.Error(err.Error(), fasthttp.StatusBadGateway)
+			}
+
+			ctx.Response.Header.Set(headers.XCtTraceId, "trace")
+		},
+	}
+	gatewayLn, err := net.Listen("tcp4", "127.0.0.1:0")
+	assert.NoError(tb, err)
+	go func() { _ = gateway.Serve(gatewayLn) }()
+
+	tb.Cleanup(func() {
+		_ = gateway.Shutdown()
+		_ = upstream.Shutdown()
+	})
+
+	return gatewayLn.Addr().String()
+}
+
+// dialRealtime sends the request head to the gateway and reads the head of its answer.
+func dialRealtime(t *testing.T, addr, path string, header map[string]string) (net.Conn, *bufio.Reader, *fasthttp.ResponseHeader) {
+	t.Helper()
+
+	c, err := net.Dial("tcp4", addr)
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = c.Close() })
+	_ = c.SetDeadline(time.Now().Add(5 * time.Second))
+
+	head := fmt.Sprintf("GET %s HTTP/1.1\r\nHost: gateway\r\n", path)
+	for k, v := range header {
+		head += k + ": " + v + "\r\n"
+	}
+	_, err = c.Write([]byte(head + "\r\n"))
+	assert.NoError(t, err)
+
+	br := bufio.NewReader(c)
+	resp := &fasthttp.ResponseHeader{}
+	assert.NoError(t, resp.Read(br))
+
+	return c, br, resp
+}
+
+var webSocketUpgrade = map[string]string{
+	headers.Connection:          "Upgrade",
+	headers.Upgrade:             "websocket",
+	headers.SecWebSocketKey:     "dGhlIHNhbXBsZSBub25jZQ==",
+	headers.SecWebSocketVersion: "13",
+	"Cookie":                    cookie.AccessTokenCookieName + "=access_token",
+}
+
+func TestForwardRealtimeWebSocket(t *testing.T) {
+	route := config.Realtime{Name: "echo", Prefix: "/api/realtime/echo", IdleTimeout: time.Minute}
+	addr := startRealtimeGateway(t, route)
+	opened := testutil.ToFloat64(realtimeConnectionsTotal.WithLabelValues("echo", RealtimeWebSocket))
+
+	c, br, resp := dialRealtime(t, addr, "/api/realtime/echo", webSocketUpgrade)
+
+	assert.Equal(t, fasthttp.StatusSwitchingProtocols, resp.StatusCode())
+	assert.Equal(t, "websocket", string(resp.Peek(headers.Upgrade)))
+	assert.Equal(t, "trace", string(resp.Peek(headers.XCtTraceId)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(realtimeConnections.WithLabelValues("echo", RealtimeWebSocket)))
+
+	for _, message := range []string{"hello", "world"} {
+		_, err := c.Write([]byte(message))
+		assert.NoError(t, err)
+
+		echo := make([]byte, len(message))
+		_, err = io.ReadFull(br, echo)
+		assert.NoError(t, err)
+		assert.Equal(t, message, string(echo))
+	}
+
+	_ = c.Close()
+
+	assert.Eventually(t, func() bool {
+		return testutil.ToFloat64(realtimeConnections.WithLabelValues("echo", RealtimeWebSocket)) == 0
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, opened+1, testutil.ToFloat64(realtimeConnectionsTotal.WithLabelValues("echo", RealtimeWebSocket)))
+}
+
+func TestForwardRealtimeWebSocketIdle(t *testing.T) {
+	route := config.Realtime{Name: "idle", Prefix: "/api/realtime/echo", IdleTimeout: 100 * time.Millisecond}
+	addr := startRealtimeGateway(t, route)
+
+	_, br, resp := dialRealtime(t, addr, "/api/realtime/echo", webSocketUpgrade)
+	assert.Equal(t, fasthttp.StatusSwitchingProtocols, resp.StatusCode())
+
+	start := time.Now()
+	_, err := br.ReadByte()
+
+	assert.ErrorIs(t, err, io.EOF)
+	assert.Less(t, time.Since(start), 2*time.Second)
+}
+
+func TestForwardRealtimeWebSocketRefused(t *testing.T) {
+	route := config.Realtime{Name: "refused", Prefix: "/api/realtime/echo", IdleTimeout: time.Minute}
+	addr := startRealtimeGateway(t, route)
+
+	_, br, resp := dialRealtime(t, addr, "/api/realtime/echo", map[string]string{
+		headers.Connection: "Upgrade",
+		headers.Upgrade:    "websocket",
+	})
+
+	assert.Equal(t, fasthttp.StatusUnauthorized, resp.StatusCode())
+
+	body := make([]byte, resp.ContentLength())
+	_, err := io.ReadFull(br, body)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"message":"Unauthenticated"}`, string(body))
+	assert.Equal(t, float64(0), testutil.ToFloat64(realtimeConnectionsTotal.WithLabelValues("refused", RealtimeWebSocket)))
+}
+
+func TestForwardRealtimeWebSocketOrigin(t *testing.T) {
+	origins := config.Global.CorsAllowedOrigins
+	config.Global.CorsAllowedOrigins = map[string]bool{"https://cash-track.app": true}
+	t.Cleanup(func() { config.Global.CorsAllowedOrigins = origins })
+
+	route := config.Realtime{Name: "origin", Prefix: "/api/realtime/echo", IdleTimeout: time.Minute}
+	addr := startRealtimeGateway(t, route)
+
+	for name, test := range map[string]struct {
+		origin string
+		status int
+	}{
+		"Allowed":    {origin: "https://Cash-Track.app", status: fasthttp.StatusSwitchingProtocols},
+		"CrossSite":  {origin: "https://evil.example", status: fasthttp.StatusForbidden},
+		"NullOrigin": {origin: "null", status: fasthttp.StatusForbidden},
+	} {
+		t.Run(name, func(t *testing.T) {
+			header := map[string]string{headers.Origin: test.origin}
+			for k, v := range webSocketUpgrade {
+				header[k] = v
+			}
+
+			_, _, resp := dialRealtime(t, addr, "/api/realtime/echo", header)
+
+			assert.Equal(t, test.status, resp.StatusCode())
+		})
+	}
+
+	// the refused upgrades never reached the upstream
+	assert.Equal(t, float64(1), testutil.ToFloat64(realtimeConnectionsTotal.WithLabelValues("origin", RealtimeWebSocket)))
+}
+
+func TestForwardRealtimeEventStream(t *testing.T) {
+	route := config.Realtime{Name: "events", Prefix: "/api/realtime/events", IdleTimeout: time.Minute}
+	addr := startRealtimeGateway(t, route)
+
+	_, br, resp := dialRealtime(t, addr, "/api/realtime/events", map[string]string{
+		headers.Accept:      "text/event-stream",
+		headers.LastEventId: "7",
+		"Cookie":            cookie.AccessTokenCookieName + "=access_token",
+	})
+
+	assert.Equal(t, fasthttp.StatusOK, resp.StatusCode())
+	assert.Equal(t, "text/event-stream", string(resp.ContentType()))
+	assert.Equal(t, "trace", string(resp.Peek(headers.XCtTraceId)))
+
+	r := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(r)
+	resp.CopyTo(&r.Header)
+	assert.NoError(t, r.ReadBody(br, 0))
+	assert.Equal(t, "id: 1\ndata: 7\n\nid: 2\ndata: 7\n\n", string(r.Body()))
+}
@@ -0,0 +1,174 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/redis/go-redis/v9"
+	"github.com/valyala/fasthttp"
+
+	"github.com/cash-track/gateway/headers/cookie"
+	"github.com/cash-track/gateway/traces"
+)
+
+const (
+	refreshLockKeyPrefix   = "CT:refresh:lock"
+	refreshResultKeyPrefix = "CT:refresh:result"
+	// Outlives one refresh request with its retries; a lock left by a crashed replica
+	// frees itself after that.
+	refreshLockTtl = 10 * time.Second
+	// Long enough for the requests the SPA fired with the old cookie to come back.
+	refreshResultTtl     = 30 * time.Second
+	refreshPollInterval  = 50 * time.Millisecond
+	metricsRefreshSubsys = "refresh"
+)
+
+var errRefreshLockTimeout = errors.New("refresh token lock wait timed out")
+
+var refreshSharedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: metricsNamespace,
+	Subsystem: metricsRefreshSubsys,
+	Name:      "shared_total",
+	Help:      "Token refreshes answered with the result of another request by source: inflight or redis.",
+}, []string{"source"})
+
+var refreshLockFailedOpenTotal = promauto.NewCounter(prometheus.CounterOpts{
+	Namespace: metricsNamespace,
+	Subsystem: metricsRefreshSubsys,
+	Name:      "lock_failed_open_total",
+	Help:      "Token refreshes done without the cross-replica lock because Redis was unreachable.",
+})
+
+// refreshUnlockScript deletes the lock only while it is still held by ARGV[1]: one that
+// expired during a slow refresh may be held by another replica by now.
+var refreshUnlockScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+
+return 0
+`)
+
+// RefreshLock lets one replica at a time refresh a given refresh token. The result is
+// kept briefly, so the requests waiting on the lock on other replicas, and the ones still
+// arriving with the old token, get the same new token pair instead of refreshing a token
+// the API already rotated.
+type RefreshLock struct {
+	client       *redis.Client
+	pollInterval time.Duration
+	// newOwner returns the random value a lock is taken with, to release only its own.
+	newOwner func() string
+}
+
+func NewRefreshLock(client *redis.Client) *RefreshLock {
+	return &RefreshLock{
+		client:       client,
+		pollInterval: refreshPollInterval,
+		newOwner:     rand.Text,
+	}
+}
+
+// Do returns the cached result for the token, or refreshes it once the lock is taken.
+// Redis failing does not block refreshing: it only loses the cross-replica deduplication.
+func (l *RefreshLock) Do(ctx *fasthttp.RequestCtx, hash string, refresh func() (cookie.Auth, error)) (cookie.Auth, error) {
+	parent := traces.FindParentContext(ctx)
+	deadline := time.Now().Add(refreshLockTtl)
+	owner := l.newOwner()
+
+	for {
+		auth, ok, err := l.result(parent, hash)
+		if err != nil {
+			return l.failOpen(ctx, err, refresh)
+		}
+		if ok {
+			refreshSharedTotal.WithLabelValues("redis").Inc()
+
+			return auth, nil
+		}
+
+		locked, err := l.client.SetNX(parent, refreshLockKey(hash), owner, refreshLockTtl).Result()
+		if err != nil {
+			return l.failOpen(ctx, err, refresh)
+		}
+		if locked {
+			return l.refresh(ctx, hash, owner, refresh)
+		}
+
+		if time.Now().After(deadline) {
+			return cookie.Auth{}, errRefreshLockTimeout
+		}
+
+		time.Sleep(l.pollInterval)
+	}
+}
+
+func (l *RefreshLock) refresh(ctx *fasthttp.RequestCtx, hash, owner string, refresh func() (cookie.Auth, error)) (cookie.Auth, error) {
+	parent := traces.FindParentContext(ctx)
+
+	auth, err := refresh()
+	// a transient failure is not kept: the next request tries again
+	if err == nil {
+		b, _ := json.Marshal(auth)
+		if setErr := l.client.Set(parent, refreshResultKey(hash), b, refreshResultTtl).Err(); setErr != nil {
+			slog.Warn("refresh token result not shared", "trace_id", traces.FindTraceId(ctx), "error", setErr)
+		}
+	}
+
+	released, delErr := refreshUnlockScript.Run(parent, l.client, []string{refreshLockKey(hash)}, owner).Int()
+	if delErr != nil {
+		slog.Warn("refresh token lock release failed", "trace_id", traces.FindTraceId(ctx), "error", delErr)
+	} else if released == 0 {
+		slog.Warn("refresh token lock expired before its refresh ended", "trace_id", traces.FindTraceId(ctx))
+	}
+
+	return auth, err
+}
+
+func (l *RefreshLock) result(ctx context.Context, hash string) (cookie.Auth, bool, error) {
+	auth := cookie.Auth{}
+
+	b, err := l.client.Get(ctx, refreshResultKey(hash)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return auth, false, nil
+	}
+	if err != nil {
+		return auth, false, err
+	}
+
+	if err := json.Unmarshal(b, &auth); err != nil {
+		return auth, false, fmt.Errorf("refresh token result decode: %w", err)
+	}
+
+	return auth, true, nil
+}
+
+func (l *RefreshLock) failOpen(ctx *fasthttp.RequestCtx, err error, refresh func() (cookie.Auth, error)) (cookie.Auth, error) {
+	refreshLockFailedOpenTotal.Inc()
+	slog.Error("refresh token lock failed open, redis unreachable", "trace_id", traces.FindTraceId(ctx), "error", err)
+
+	return refresh()
+}
+
+// refreshTokenHash keys the deduplication without keeping the refresh token itself.
+func refreshTokenHash(refreshToken string) string {
+	sum := sha256.Sum256([]byte(refreshToken))
+
+	return hex.EncodeToString(sum[:])
+}
+
+func refreshLockKey(hash string) string {
+	return fmt.Sprintf("%s:%s", refreshLockKeyPrefix, hash)
+}
+
+func refreshResultKey(hash string) string {
+	return fmt.Sprintf("%s:%s", refreshResultKeyPrefix, hash)
+}
@@ -0,0 +1,228 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redismock/v9"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+	"go.uber.org/mock/gomock"
+
+	"github.com/cash-track/gateway/config"
+	"github.com/cash-track/gateway/headers/cookie"
+	"github.com/cash-track/gateway/mocks"
+)
+
+var (
+	refreshTestHash = refreshTokenHash("refresh_token")
+	refreshTestAuth = cookie.Auth{AccessToken: "new_access_token", RefreshToken: "new_refresh_token"}
+)
+
+const (
+	refreshTestResult = `{"accessToken":"new_access_token","refreshToken":"new_refresh_token"}`
+	refreshTestOwner  = "owner"
+)
+
+func newTestRefreshLock(client *redis.Client) *RefreshLock {
+	l := NewRefreshLock(client)
+	l.pollInterval = time.Millisecond
+	l.newOwner = func() string { return refreshTestOwner }
+
+	return l
+}
+
+func TestRefreshLockRefreshesOnceLocked(t *testing.T) {
+	client, mock := redismock.NewClientMock()
+
+	mock.ExpectGet(refreshResultKey(refreshTestHash)).RedisNil()
+	mock.ExpectSetNX(refreshLockKey(refreshTestHash), refreshTestOwner, refreshLockTtl).SetVal(true)
+	mock.ExpectSet(refreshResultKey(refreshTestHash), []byte(refreshTestResult), refreshResultTtl).SetVal("OK")
+	mock.ExpectEvalSha(refreshUnlockScript.Hash(), []string{refreshLockKey(refreshTestHash)}, refreshTestOwner).SetVal(int64(1))
+
+	calls := 0
+	auth, err := newTestRefreshLock(client).Do(&fasthttp.RequestCtx{}, refreshTestHash, func() (cookie.Auth, error) {
+		calls++
+
+		return refreshTestAuth, nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, refreshTestAuth, auth)
+	assert.Equal(t, 1, calls)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRefreshLockSharesCachedResult(t *testing.T) {
+	client, mock := redismock.NewClientMock()
+
+	mock.ExpectGet(refreshResultKey(refreshTestHash)).SetVal(refreshTestResult)
+
+	auth, err := newTestRefreshLock(client).Do(&fasthttp.RequestCtx{}, refreshTestHash, func() (cookie.Auth, error) {
+		t.Error("the token was refreshed already")
+
+		return cookie.Auth{}, nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, refreshTestAuth, auth)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// The API answered 401: every request holding the token is signed out alike.
+func TestRefreshLockSharesSignOut(t *testing.T) {
+	client, mock := redismock.NewClientMock()
+
+	mock.ExpectGet(refreshResultKey(refreshTestHash)).SetVal(`{}`)
+
+	auth, err := newTestRefreshLock(client).Do(&fasthttp.RequestCtx{}, refreshTestHash, func() (cookie.Auth, error) {
+		t.Error("the token was refreshed already")
+
+		return cookie.Auth{}, nil
+	})
+
+	assert.NoError(t, err)
+	assert.False(t, auth.IsLogged())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRefreshLockWaitsForOtherReplica(t *testing.T) {
+	client, mock := redismock.NewClientMock()
+
+	mock.ExpectGet(refreshResultKey(refreshTestHash)).RedisNil()
+	mock.ExpectSetNX(refreshLockKey(refreshTestHash), refreshTestOwner, refreshLockTtl).SetVal(false)
+	mock.ExpectGet(refreshResultKey(refreshTestHash)).SetVal(refreshTestResult)
+
+	l := newTestRefreshLock(client)
+
+	auth, err := l.Do(&fasthttp.RequestCtx{}, refreshTestHash, func() (cookie.Auth, error) {
+		t.Error("the other replica refreshes the token")
+
+		return cookie.Auth{}, nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, refreshTestAuth, auth)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// The other replica failed and released the lock without a result: this one tries.
+func TestRefreshLockTakesOverReleasedLock(t *testing.T) {
+	client, mock := redismock.NewClientMock()
+
+	mock.ExpectGet(refreshResultKey(refreshTestHash)).RedisNil()
+	mock.ExpectSetNX(refreshLockKey(refreshTestHash), refreshTestOwner, refreshLockTtl).SetVal(false)
+	mock.ExpectGet(refreshResultKey(refreshTestHash)).RedisNil()
+	mock.ExpectSetNX(refreshLockKey(refreshTestHash), refreshTestOwner, refreshLockTtl).SetVal(true)
+	mock.ExpectSet(refreshResultKey(refreshTestHash), []byte(refreshTestResult), refreshResultTtl).SetVal("OK")
+	mock.ExpectEvalSha(refreshUnlockScript.Hash(), []string{refreshLockKey(refreshTestHash)}, refreshTestOwner).SetVal(int64(1))
+
+	l := newTestRefreshLock(client)
+
+	auth, err := l.Do(&fasthttp.RequestCtx{}, refreshTestHash, func() (cookie.Auth, error) {
+		return refreshTestAuth, nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, refreshTestAuth, auth)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// The refresh outlived the lock, now held by another replica: it is left to that one.
+func TestRefreshLockKeepsLockOfOtherReplica(t *testing.T) {
+	client, mock := redismock.NewClientMock()
+
+	mock.ExpectGet(refreshResultKey(refreshTestHash)).RedisNil()
+	mock.ExpectSetNX(refreshLockKey(refreshTestHash), refreshTestOwner, refreshLockTtl).SetVal(true)
+	mock.ExpectSet(refreshResultKey(refreshTestHash), []byte(refreshTestResult), refreshResultTtl).SetVal("OK")
+	mock.ExpectEvalSha(refreshUnlockScript.Hash(), []string{refreshLockKey(refreshTestHash)}, refreshTestOwner).SetVal(int64(0))
+
+	auth, err := newTestRefreshLock(client).Do(&fasthttp.RequestCtx{}, refreshTestHash, func() (cookie.Auth, error) {
+		return refreshTestAuth, nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, refreshTestAuth, auth)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRefreshLockTransientErrorNotShared(t *testing.T) {
+	client, mock := redismock.NewClientMock()
+
+	mock.ExpectGet(refreshResultKey(refreshTestHash)).RedisNil()
+	mock.ExpectSetNX(refreshLockKey(refreshTestHash), refreshTestOwner, refreshLockTtl).SetVal(true)
+	mock.ExpectEvalSha(refreshUnlockScript.Hash(), []string{refreshLockKey(refreshTestHash)}, refreshTestOwner).SetVal(int64(1))
+
+	_, err := newTestRefreshLock(client).Do(&fasthttp.RequestCtx{}, refreshTestHash, func() (cookie.Auth, error) {
+		return cookie.Auth{}, errors.New("refresh token API request error")
+	})
+
+	assert.EqualError(t, err, "refresh token API request error")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRefreshLockRedisErrorFailsOpen(t *testing.T) {
+	client, mock := redismock.NewClientMock()
+
+	mock.ExpectGet(refreshResultKey(refreshTestHash)).SetErr(errors.New("connection refused"))
+
+	auth, err := newTestRefreshLock(client).Do(&fasthttp.RequestCtx{}, refreshTestHash, func() (cookie.Auth, error) {
+		return refreshTestAuth, nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, refreshTestAuth, auth)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRefreshTokenSharedConcurrentRequests(t *testing.T) {
+	release := make(chan struct{})
+
+	ctrl := gomock.NewController(t)
+	h := mocks.NewHttpRetryClientMock(ctrl)
+	h.EXPECT().WithReadTimeout(gomock.Eq(httpReadTimeout))
+	h.EXPECT().WithWriteTimeout(gomock.Eq(httpWriteTimeout))
+	h.EXPECT().WithRetryAttempts(gomock.Eq(httpRetryAttempts))
+	// a single refresh for all the requests
+	h.EXPECT().Do(gomock.Any(), gomock.Any()).Times(1).DoAndReturn(func(req *fasthttp.Request, resp *fasthttp.Response) error {
+		<-release
+		resp.SetStatusCode(fasthttp.StatusOK)
+		resp.SetBodyString(refreshTestResult)
+
+		return nil
+	})
+
+	apiUrl, _ := url.Parse(endpoint)
+	s := NewHttp(h, config.Config{
+		ApiURI: apiUrl,
+	}, nil, testBreaker())
+
+	const requests = 5
+	results := make([]cookie.Auth, requests)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			auth, err := s.refreshTokenShared(cookie.Auth{RefreshToken: "refresh_token"}, context.TODO(), &fasthttp.RequestCtx{})
+			assert.NoError(t, err)
+			results[i] = auth
+		}(i)
+	}
+
+	// let every request join the refresh in flight
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	for _, auth := range results {
+		assert.Equal(t, refreshTestAuth, auth)
+	}
+}
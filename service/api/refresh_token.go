@@ -4,11 +4,16 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"log/slog"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/valyala/fasthttp"
 	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/trace"
 
+	"github.com/cash-track/gateway/config"
 	"github.com/cash-track/gateway/headers"
 	"github.com/cash-track/gateway/headers/cookie"
 	"github.com/cash-track/gateway/logger"
@@ -17,6 +22,90 @@ import (
 
 var refreshURI = []byte("/v1/auth/refresh")
 
+var refreshAheadTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: metricsNamespace,
+	Subsystem: metricsRefreshSubsys,
+	Name:      "ahead_total",
+	Help:      "Access tokens refreshed before expiry by result: refreshed, rejected or failed.",
+}, []string{"result"})
+
+// skipRefreshAheadUserValue marks a request that must reach the API with its current
+// token pair, see SkipRefreshAhead.
+const skipRefreshAheadUserValue = "api.skipRefreshAhead"
+
+// SkipRefreshAhead forwards the request with the current tokens even when they are about
+// to expire. Logout needs it: it sends the refresh token to revoke in its body, rotating
+// the token first would leave the new one alive.
+func SkipRefreshAhead(ctx *fasthttp.RequestCtx) {
+	ctx.SetUserValue(skipRefreshAheadUserValue, true)
+}
+
+// refreshAhead swaps an access token expiring within REFRESH_AHEAD_WINDOW for a fresh
+// pair before forwarding, which saves the 401 round trip and the body replay. When that
+// fails the request goes on with the current token, still valid, and the usual 401 path
+// applies.
+func (s *HttpService) refreshAhead(ctx *fasthttp.RequestCtx, auth cookie.Auth) (cookie.Auth, bool) {
+	if s.config.RefreshAheadWindow <= 0 || !auth.IsLogged() || !auth.CanRefresh() {
+		return auth, false
+	}
+
+	if skip, _ := ctx.UserValue(skipRefreshAheadUserValue).(bool); skip {
+		return auth, false
+	}
+
+	expireAt, ok := auth.GetAccessTokenExpireDate()
+	if !ok || time.Until(expireAt) > s.config.RefreshAheadWindow {
+		return auth, false
+	}
+
+	newAuth, err := s.authService().refreshTokenShared(auth, traces.FindParentContext(ctx), ctx)
+	if err != nil {
+		refreshAheadTotal.WithLabelValues("failed").Inc()
+		slog.Warn("refresh token ahead of expiry failed, forwarding with current token",
+			"trace_id", traces.FindTraceId(ctx),
+			"error", err,
+		)
+
+		return auth, false
+	}
+
+	if !newAuth.IsLogged() {
+		refreshAheadTotal.WithLabelValues("rejected").Inc()
+
+		return auth, false
+	}
+
+	refreshAheadTotal.WithLabelValues("refreshed").Inc()
+
+	return newAuth, true
+}
+
+// refreshTokenShared refreshes auth once for all the requests holding the same refresh
+// token. A SPA fires its API calls in parallel, so they all get the 401 at once; with
+// rotation, every refresh of an already rotated token would fail and sign the user out.
+func (s *HttpService) refreshTokenShared(
+	auth cookie.Auth,
+	spanCtx context.Context,
+	ctx *fasthttp.RequestCtx,
+) (cookie.Auth, error) {
+	hash := refreshTokenHash(auth.RefreshToken)
+
+	v, err, shared := s.refreshes.Do(hash, func() (any, error) {
+		if s.refreshLock == nil {
+			return s.refreshToken(auth, spanCtx, ctx)
+		}
+
+		return s.refreshLock.Do(ctx, hash, func() (cookie.Auth, error) {
+			return s.refreshToken(auth, spanCtx, ctx)
+		})
+	})
+	if shared {
+		refreshSharedTotal.WithLabelValues("inflight").Inc()
+	}
+
+	return v.(cookie.Auth), err
+}
+
 func (s *HttpService) refreshToken(
 	auth cookie.Auth,
 	spanCtx context.Context,
@@ -32,12 +121,12 @@ func (s *HttpService) refreshToken(
 	req.Header.SetContentTypeBytes(headers.ContentTypeJson)
 	req.Header.SetBytesV(headers.Accept, headers.ContentTypeJson)
 	headers.WriteGatewayVersion(&req.Header, s.config.GitTag, s.config.GitSha)
-	headers.WriteGatewaySecret(&req.Header, s.config.GatewaySecret)
+	headers.WriteGatewaySecret(&req.Header, config.Live().GatewaySecret)
 
 	data, _ := json.Marshal(cookie.Auth{RefreshToken: auth.RefreshToken})
 	req.SetBody(data)
 
-	logger.DebugRequest(req, ServiceId)
+	logger.DebugRequest(req, s.upstream.Name)
 	traces.PropagateContextToRequest(ctx, req)
 
 	// execute request
@@ -48,7 +137,7 @@ func (s *HttpService) refreshToken(
 
 	_, span := traces.GetTracer().Start(
 		spanCtx,
-		fmt.Sprintf("refresh token %s %s %s", ServiceId, req.Header.Method(), req.URI().PathOriginal()),
+		fmt.Sprintf("refresh token %s %s %s", s.upstream.Name, req.Header.Method(), req.URI().PathOriginal()),
 		trace.WithAttributes(
 			traces.MergeAttributes(
 				traces.AttributesGetter(auth),
@@ -66,7 +155,7 @@ func (s *HttpService) refreshToken(
 		return newAuth, fmt.Errorf("refresh token API request error: %w", err)
 	}
 
-	logger.DebugResponse(resp, ServiceId)
+	logger.DebugResponse(resp, s.upstream.Name)
 	span.SetAttributes(traces.ResponseAttributes(resp)...)
 
 	if resp.StatusCode() == fasthttp.StatusUnauthorized {
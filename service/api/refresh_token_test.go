@@ -111,9 +111,9 @@ func TestRefreshTokenSetsGatewaySecretHeader(t *testing.T) {
 	})
 
 	apiUrl, _ := url.Parse(endpoint)
+	withGatewaySecret(t, "shared-secret")
 	s := NewHttp(h, config.Config{
-		ApiURI:        apiUrl,
-		GatewaySecret: "shared-secret",
+		ApiURI: apiUrl,
 	}, nil, testBreaker())
 
 	auth := cookie.Auth{RefreshToken: "refresh_token", AccessToken: "access_token"}
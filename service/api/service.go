@@ -4,16 +4,17 @@ import (
 	"strings"
 	"time"
 
-	"github.com/sony/gobreaker/v2"
 	"github.com/valyala/fasthttp"
+	"golang.org/x/sync/singleflight"
 
 	"github.com/cash-track/gateway/config"
 	"github.com/cash-track/gateway/http/retryhttp"
 	"github.com/cash-track/gateway/router/csrf"
+	"github.com/cash-track/gateway/session"
 )
 
 const (
-	ServiceId         = "API"
+	ServiceId         = config.ApiUpstreamName
 	httpReadTimeout   = 5 * time.Second
 	httpWriteTimeout  = 5 * time.Second
 	httpRetryAttempts = uint(2)
@@ -27,43 +28,108 @@ var methodsWithBody = map[string]bool{
 
 type Service interface {
 	ForwardRequest(ctx *fasthttp.RequestCtx, body []byte) error
+	ForwardRealtime(ctx *fasthttp.RequestCtx, route config.Realtime) error
 	Healthcheck() error
 }
 
 type HttpService struct {
-	http    retryhttp.Client
-	config  config.Config
-	csrf    csrf.CSRFSeeder
-	breaker *gobreaker.CircuitBreaker[struct{}]
+	http     retryhttp.Client
+	config   config.Config
+	upstream config.Upstream
+	csrf     csrf.CSRFSeeder
+	breaker  *Breaker
+	sessions session.Store
+	// breakerGroups trip apart from breaker, for the requests under their prefix.
+	breakerGroups []breakerGroup
+	// refresher owns the token refresh endpoint; nil means this service does.
+	refresher *HttpService
+	// refreshes and refreshLock deduplicate concurrent refreshes of the same token, in
+	// this process and across replicas; refreshLock is nil for the former only.
+	refreshes   singleflight.Group
+	refreshLock *RefreshLock
 }
 
+// NewHttp builds the forwarder for the default API upstream.
 func NewHttp(
 	http retryhttp.Client,
 	config config.Config,
 	csrf csrf.CSRFSeeder,
-	breaker *gobreaker.CircuitBreaker[struct{}],
+	breaker *Breaker,
 ) *HttpService {
-	http.WithReadTimeout(httpReadTimeout)
-	http.WithWriteTimeout(httpWriteTimeout)
-	http.WithRetryAttempts(httpRetryAttempts)
+	return NewHttpUpstream(http, config, config.ApiUpstream(), csrf, breaker)
+}
+
+// NewHttpUpstream builds the forwarder for one upstream of the route table. Each
+// upstream needs its own http client and breaker so they are tuned and trip independently.
+func NewHttpUpstream(
+	http retryhttp.Client,
+	config config.Config,
+	upstream config.Upstream,
+	csrf csrf.CSRFSeeder,
+	breaker *Breaker,
+) *HttpService {
+	http.WithReadTimeout(orDefault(upstream.ReadTimeout, httpReadTimeout))
+	http.WithWriteTimeout(orDefault(upstream.WriteTimeout, httpWriteTimeout))
+	http.WithRetryAttempts(orDefault(upstream.RetryAttempts, httpRetryAttempts))
 
 	return &HttpService{
-		http:    http,
-		config:  config,
-		csrf:    csrf,
-		breaker: breaker,
+		http:     http,
+		config:   config,
+		upstream: upstream,
+		csrf:     csrf,
+		breaker:  breaker,
+		sessions: session.CookieStore{},
 	}
 }
 
+// WithRefresher makes expired tokens refresh through api instead of this upstream, which
+// does not issue tokens itself.
+func (s *HttpService) WithRefresher(api *HttpService) *HttpService {
+	s.refresher = api
+
+	return s
+}
+
+// WithSessions keeps the tokens in sessions instead of the default cookies.
+func (s *HttpService) WithSessions(sessions session.Store) *HttpService {
+	s.sessions = sessions
+
+	return s
+}
+
+// WithRefreshLock shares the token refreshes of this service with the other replicas.
+func (s *HttpService) WithRefreshLock(lock *RefreshLock) *HttpService {
+	s.refreshLock = lock
+
+	return s
+}
+
+func (s *HttpService) authService() *HttpService {
+	if s.refresher != nil {
+		return s.refresher
+	}
+
+	return s
+}
+
 func (s *HttpService) setRequestURI(dest *fasthttp.URI, path []byte) {
-	_ = dest.Parse([]byte(s.config.ApiUrl), nil)
-	dest.SetScheme(s.config.ApiURI.Scheme)
-	dest.SetHost(s.config.ApiURI.Host)
+	_ = dest.Parse([]byte(s.upstream.Url), nil)
+	dest.SetScheme(s.upstream.URI.Scheme)
+	dest.SetHost(s.upstream.URI.Host)
 	dest.SetPathBytes(path)
 }
 
 func (s *HttpService) copyRequestURI(src, dest *fasthttp.URI) {
-	path := "/v1" + strings.TrimPrefix(string(src.PathOriginal()), "/api")
+	path := s.upstream.Rewrite + strings.TrimPrefix(string(src.PathOriginal()), s.upstream.Prefix)
 	s.setRequestURI(dest, []byte(path))
 	dest.SetQueryStringBytes(src.QueryString())
 }
+
+func orDefault[T comparable](v, def T) T {
+	var zero T
+	if v == zero {
+		return def
+	}
+
+	return v
+}
@@ -3,6 +3,7 @@ package api
 import (
 	"net/url"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/valyala/fasthttp"
@@ -64,3 +65,58 @@ func TestCopyRequestURI(t *testing.T) {
 
 	assert.Equal(t, "http://api.test.com/v1/users/create%20one?one=two%203", dest.String())
 }
+
+func TestNewHttpUpstream(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	h := mocks.NewHttpRetryClientMock(ctrl)
+	h.EXPECT().WithReadTimeout(gomock.Eq(30 * time.Second))
+	h.EXPECT().WithWriteTimeout(gomock.Eq(httpWriteTimeout))
+	h.EXPECT().WithRetryAttempts(gomock.Eq(uint(5)))
+
+	s := NewHttpUpstream(h, config.Config{}, config.Upstream{
+		Name:          "exports",
+		ReadTimeout:   30 * time.Second,
+		RetryAttempts: 5,
+	}, nil, testBreaker())
+
+	assert.Equal(t, "exports", s.upstream.Name)
+	assert.Same(t, s, s.authService())
+}
+
+func TestCopyRequestURIUpstreamRewrite(t *testing.T) {
+	for name, test := range map[string]struct {
+		prefix   string
+		rewrite  string
+		path     string
+		expected string
+	}{
+		"Rewrite":     {prefix: "/api/exports", rewrite: "/v2/exports", path: "/api/exports/report.csv", expected: "http://exports.test.com/v2/exports/report.csv?one=two"},
+		"Strip":       {prefix: "/files", rewrite: "", path: "/files/receipts/1.png", expected: "http://exports.test.com/receipts/1.png?one=two"},
+		"PassThrough": {prefix: "/files", rewrite: "/files", path: "/files/receipts/1.png", expected: "http://exports.test.com/files/receipts/1.png?one=two"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			uri, _ := url.Parse("http://exports.test.com")
+
+			ctrl := gomock.NewController(t)
+			h := mocks.NewHttpRetryClientMock(ctrl)
+			h.EXPECT().WithReadTimeout(gomock.Any())
+			h.EXPECT().WithWriteTimeout(gomock.Any())
+			h.EXPECT().WithRetryAttempts(gomock.Any())
+			s := NewHttpUpstream(h, config.Config{}, config.Upstream{
+				Name:    "exports",
+				Prefix:  test.prefix,
+				Rewrite: test.rewrite,
+				URI:     uri,
+			}, nil, testBreaker())
+
+			src := fasthttp.URI{}
+			src.SetPath(test.path)
+			src.SetQueryString("one=two")
+			dest := fasthttp.URI{}
+
+			s.copyRequestURI(&src, &dest)
+
+			assert.Equal(t, test.expected, dest.String())
+		})
+	}
+}
@@ -0,0 +1,314 @@
+package session
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/redis/go-redis/v9"
+	"github.com/valyala/fasthttp"
+
+	"github.com/cash-track/gateway/traces"
+)
+
+const userKeyPrefix = "CT:session:user"
+
+var (
+	// ErrNotSignedIn is returned when the request has no session to act for.
+	ErrNotSignedIn = errors.New("not signed in")
+	// ErrNotFound is returned when the session to revoke is not one of the user's.
+	ErrNotFound = errors.New("session not found")
+)
+
+// Device describes one active session of the user. Id is a handle derived from the
+// session ID, which is a credential and never listed.
+type Device struct {
+	Id         string    `json:"id"`
+	Current    bool      `json:"current"`
+	Device     string    `json:"device"`
+	ClientIp   string    `json:"clientIp,omitempty"`
+	UserAgent  string    `json:"userAgent,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastSeenAt time.Time `json:"lastSeenAt"`
+}
+
+// List returns the active sessions of the signed-in user, most recently seen first.
+// Sessions expired or revoked since they were indexed are dropped from the index.
+func (s *RedisStore) List(ctx *fasthttp.RequestCtx) ([]Device, error) {
+	userId, err := s.currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	members, err := s.client.ZRevRangeWithScores(traces.FindParentContext(ctx), userKey(userId), 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("session list: %w", err)
+	}
+
+	if len(members) == 0 {
+		return []Device{}, nil
+	}
+
+	keys := make([]string, 0, len(members))
+	for _, m := range members {
+		keys = append(keys, key(m.Member.(string)))
+	}
+
+	values, err := s.client.MGet(traces.FindParentContext(ctx), keys...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("session list: %w", err)
+	}
+
+	current := readId(ctx)
+	devices := make([]Device, 0, len(members))
+	stale := make([]any, 0)
+
+	for i, m := range members {
+		id := m.Member.(string)
+
+		sess := Session{}
+		if v, ok := values[i].(string); !ok || json.Unmarshal([]byte(v), &sess) != nil || sess.RevokedAt != nil {
+			stale = append(stale, id)
+
+			continue
+		}
+
+		devices = append(devices, Device{
+			Id:         handle(id),
+			Current:    id == current,
+			Device:     describeDevice(sess.UserAgent),
+			ClientIp:   sess.ClientIp,
+			UserAgent:  sess.UserAgent,
+			CreatedAt:  sess.CreatedAt,
+			LastSeenAt: time.Unix(int64(m.Score), 0).UTC(),
+		})
+	}
+
+	s.unindex(ctx, userId, stale...)
+
+	return devices, nil
+}
+
+// Revoke signs out the session of the user with the given handle. Revoking the current
+// session signs the request out.
+func (s *RedisStore) Revoke(ctx *fasthttp.RequestCtx, id string) error {
+	userId, err := s.currentUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	members, err := s.client.ZRange(traces.FindParentContext(ctx), userKey(userId), 0, -1).Result()
+	if err != nil {
+		return fmt.Errorf("session list: %w", err)
+	}
+
+	for _, m := range members {
+		if handle(m) != id {
+			continue
+		}
+
+		if m == readId(ctx) {
+			s.unindex(ctx, userId, m)
+
+			return s.End(ctx)
+		}
+
+		return s.revoke(ctx, userId, m)
+	}
+
+	return ErrNotFound
+}
+
+// RevokeOthers signs out every session of the user but the current one and returns how
+// many were.
+func (s *RedisStore) RevokeOthers(ctx *fasthttp.RequestCtx) (int, error) {
+	userId, err := s.currentUser(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	members, err := s.client.ZRange(traces.FindParentContext(ctx), userKey(userId), 0, -1).Result()
+	if err != nil {
+		return 0, fmt.Errorf("session list: %w", err)
+	}
+
+	current := readId(ctx)
+	ids := make([]string, 0, len(members))
+	for _, m := range members {
+		if m != current {
+			ids = append(ids, m)
+		}
+	}
+
+	return len(ids), s.revoke(ctx, userId, ids...)
+}
+
+// revoke replaces the sessions with tombstones, keeping their expiry, so the devices
+// holding them are rejected instead of silently treated as guests.
+func (s *RedisStore) revoke(ctx *fasthttp.RequestCtx, userId string, ids ...string) error {
+	revokedAt := s.now().UTC()
+	unindex := make([]any, 0, len(ids))
+
+	for _, id := range ids {
+		sess, err := s.load(ctx, id)
+		if errors.Is(err, redis.Nil) {
+			unindex = append(unindex, id)
+
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		tombstone := Session{
+			UserId:    sess.UserId,
+			CreatedAt: sess.CreatedAt,
+			ClientIp:  sess.ClientIp,
+			UserAgent: sess.UserAgent,
+			RevokedAt: &revokedAt,
+		}
+
+		b, err := json.Marshal(tombstone)
+		if err != nil {
+			return fmt.Errorf("session encode: %w", err)
+		}
+
+		if err := s.client.Set(traces.FindParentContext(ctx), key(id), b, redis.KeepTTL).Err(); err != nil {
+			return fmt.Errorf("session revoke: %w", err)
+		}
+
+		unindex = append(unindex, id)
+	}
+
+	s.unindex(ctx, userId, unindex...)
+
+	return nil
+}
+
+func (s *RedisStore) currentUser(ctx *fasthttp.RequestCtx) (string, error) {
+	auth, err := s.Resolve(ctx)
+	if errors.Is(err, ErrRevoked) {
+		return "", ErrNotSignedIn
+	}
+	if err != nil {
+		return "", err
+	}
+
+	userId := userIdFromAccessToken(auth.AccessToken)
+	if userId == "" {
+		return "", ErrNotSignedIn
+	}
+
+	return userId, nil
+}
+
+// index adds the session to the sessions of the user. The index expires with the latest
+// session started or refreshed, which is also the one to expire last.
+func (s *RedisStore) index(ctx *fasthttp.RequestCtx, id, userId string, expireAt time.Time) {
+	if userId == "" {
+		return
+	}
+
+	parent := traces.FindParentContext(ctx)
+	_, err := s.client.TxPipelined(parent, func(pipe redis.Pipeliner) error {
+		pipe.ZAdd(parent, userKey(userId), redis.Z{Score: float64(s.now().Unix()), Member: id})
+		pipe.Expire(parent, userKey(userId), expireAt.Sub(s.now()))
+
+		return nil
+	})
+	if err != nil {
+		// the session works, it is just missing from the list of devices
+		slog.Warn("session index failed", "trace_id", traces.FindTraceId(ctx), "error", err)
+	}
+}
+
+// reindex moves the expiry of the index to a refreshed session without adding the session
+// back, had it been revoked and unindexed right after its update.
+func (s *RedisStore) reindex(ctx *fasthttp.RequestCtx, id, userId string, expireAt time.Time) {
+	if userId == "" {
+		return
+	}
+
+	parent := traces.FindParentContext(ctx)
+	_, err := s.client.TxPipelined(parent, func(pipe redis.Pipeliner) error {
+		pipe.ZAddXX(parent, userKey(userId), redis.Z{Score: float64(s.now().Unix()), Member: id})
+		pipe.Expire(parent, userKey(userId), expireAt.Sub(s.now()))
+
+		return nil
+	})
+	if err != nil {
+		slog.Warn("session index failed", "trace_id", traces.FindTraceId(ctx), "error", err)
+	}
+}
+
+func (s *RedisStore) unindex(ctx *fasthttp.RequestCtx, userId string, ids ...any) {
+	if len(ids) == 0 {
+		return
+	}
+
+	if err := s.client.ZRem(traces.FindParentContext(ctx), userKey(userId), ids...).Err(); err != nil {
+		slog.Warn("session unindex failed", "trace_id", traces.FindTraceId(ctx), "error", err)
+	}
+}
+
+// touch records the request as the last time the session was seen.
+func (s *RedisStore) touch(ctx *fasthttp.RequestCtx, id, userId string) {
+	if userId == "" {
+		return
+	}
+
+	err := s.client.ZAddXX(traces.FindParentContext(ctx), userKey(userId), redis.Z{
+		Score:  float64(s.now().Unix()),
+		Member: id,
+	}).Err()
+	if err != nil {
+		slog.Warn("session touch failed", "trace_id", traces.FindTraceId(ctx), "error", err)
+	}
+}
+
+// userIdFromAccessToken reads the sub claim without verifying the token, which came
+// from the API through the gateway itself.
+func userIdFromAccessToken(accessToken string) string {
+	if accessToken == "" {
+		return ""
+	}
+
+	token, _, err := jwt.NewParser().ParseUnverified(accessToken, jwt.MapClaims{})
+	if err != nil {
+		return ""
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return ""
+	}
+
+	switch sub := claims["sub"].(type) {
+	case float64:
+		if sub == 0 {
+			return ""
+		}
+
+		return strconv.FormatFloat(sub, 'f', 0, 64)
+	case string:
+		return sub
+	default:
+		return ""
+	}
+}
+
+func handle(id string) string {
+	sum := sha256.Sum256([]byte(id))
+
+	return hex.EncodeToString(sum[:16])
+}
+
+func userKey(userId string) string {
+	return fmt.Sprintf("%s:%s", userKeyPrefix, userId)
+}
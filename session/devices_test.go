@@ -0,0 +1,282 @@
+package session
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cash-track/gateway/headers/cookie"
+)
+
+var testOtherId = "c" + testSessionId[1:]
+
+func newUserAuth(sub any) cookie.Auth {
+	accessToken, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": sub,
+		"iat": 1700000000,
+	}).SignedString([]byte("asd"))
+
+	auth := testAuth
+	auth.AccessToken = accessToken
+
+	return auth
+}
+
+func TestStartIndexesUserSession(t *testing.T) {
+	s, mock := newTestStore(t)
+	ctx := newTestCtx("")
+	auth := newUserAuth(42)
+
+	sess := Session{Auth: auth, UserId: "42", CreatedAt: testNow, ClientIp: "0.0.0.0", UserAgent: "test-agent"}
+	mock.ExpectSet("CT:session:"+testNewId, encode(t, sess), testExpireAt.Sub(testNow)).SetVal("OK")
+	mock.ExpectTxPipeline()
+	mock.ExpectZAdd("CT:session:user:42", redis.Z{Score: float64(testNow.Unix()), Member: testNewId}).SetVal(1)
+	mock.ExpectExpire("CT:session:user:42", testExpireAt.Sub(testNow)).SetVal(true)
+	mock.ExpectTxPipelineExec()
+
+	assert.NoError(t, s.Start(ctx, auth))
+}
+
+func TestResolveTouchesLastSeen(t *testing.T) {
+	s, mock := newTestStore(t)
+	auth := newUserAuth(42)
+
+	mock.ExpectGet("CT:session:" + testSessionId).SetVal(string(encode(t, Session{Auth: auth, UserId: "42"})))
+	mock.ExpectZAddXX("CT:session:user:42", redis.Z{Score: float64(testNow.Unix()), Member: testSessionId}).SetVal(0)
+
+	ctx := newTestCtx(testSessionId)
+	resolved, err := s.Resolve(ctx)
+
+	assert.NoError(t, err)
+	assert.Equal(t, auth, resolved)
+
+	id, ok := Id(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, testSessionId, id)
+}
+
+func TestResolveRevoked(t *testing.T) {
+	s, mock := newTestStore(t)
+	ctx := newTestCtx(testSessionId)
+
+	revokedAt := testNow
+	mock.ExpectGet("CT:session:" + testSessionId).SetVal(string(encode(t, Session{UserId: "42", RevokedAt: &revokedAt})))
+
+	auth, err := s.Resolve(ctx)
+
+	assert.ErrorIs(t, err, ErrRevoked)
+	assert.False(t, auth.IsLogged())
+	assert.True(t, IsRevoked(ctx))
+
+	_, ok := Id(ctx)
+	assert.False(t, ok)
+
+	c, ok := responseCookie(ctx, cookie.SessionCookieName)
+	assert.True(t, ok)
+	assert.Empty(t, string(c.Value()))
+
+	// cached for the rest of the request
+	_, err = s.Resolve(ctx)
+	assert.ErrorIs(t, err, ErrRevoked)
+
+	ClearRevoked(ctx)
+
+	auth, err = s.Resolve(ctx)
+	assert.NoError(t, err)
+	assert.False(t, auth.IsLogged())
+}
+
+func TestUpdateRevokedByTombstone(t *testing.T) {
+	s, mock := newTestStore(t)
+	ctx := newTestCtx(testSessionId)
+
+	revokedAt := testNow
+	mock.ExpectGet("CT:session:" + testSessionId).SetVal(string(encode(t, Session{UserId: "42", RevokedAt: &revokedAt})))
+	mock.ExpectDel("CT:session:" + testSessionId).SetVal(1)
+
+	assert.NoError(t, s.Update(ctx, testAuth))
+	assert.False(t, cookie.ReadAuthCookie(ctx).IsLogged())
+}
+
+func TestUpdateDoesNotIndexAgain(t *testing.T) {
+	s, mock := newTestStore(t)
+	ctx := newTestCtx(testSessionId)
+	auth := newUserAuth(42)
+
+	old := Session{Auth: testAuth, UserId: "42", CreatedAt: testNow}
+	mock.ExpectGet("CT:session:" + testSessionId).SetVal(string(encode(t, old)))
+	mock.ExpectEvalSha(updateScript.Hash(), []string{"CT:session:" + testSessionId},
+		encode(t, old), encode(t, Session{Auth: auth, UserId: "42", CreatedAt: testNow}),
+		testExpireAt.Sub(testNow).Milliseconds()).SetVal(int64(1))
+	// a session revoked right after its update must not come back to the list
+	mock.ExpectTxPipeline()
+	mock.ExpectZAddXX("CT:session:user:42", redis.Z{Score: float64(testNow.Unix()), Member: testSessionId}).SetVal(0)
+	mock.ExpectExpire("CT:session:user:42", testExpireAt.Sub(testNow)).SetVal(true)
+	mock.ExpectTxPipelineExec()
+
+	assert.NoError(t, s.Update(ctx, auth))
+}
+
+func TestListDropsStaleSessions(t *testing.T) {
+	s, mock := newTestStore(t)
+	ctx := newTestCtx(testSessionId)
+	cookie.SetSessionAuth(ctx, newUserAuth(42))
+
+	revokedAt := testNow
+	created := testNow.Add(-time.Hour)
+	staleId := "d" + testSessionId[1:]
+
+	mock.ExpectZRevRangeWithScores("CT:session:user:42", 0, -1).SetVal([]redis.Z{
+		{Score: float64(testNow.Unix()), Member: testSessionId},
+		{Score: float64(testNow.Unix() - 60), Member: testOtherId},
+		{Score: float64(testNow.Unix() - 120), Member: testNewId},
+		{Score: float64(testNow.Unix() - 180), Member: staleId},
+	})
+	mock.ExpectMGet("CT:session:"+testSessionId, "CT:session:"+testOtherId, "CT:session:"+testNewId, "CT:session:"+staleId).SetVal([]any{
+		string(encode(t, Session{UserId: "42", CreatedAt: created, ClientIp: "1.1.1.1", UserAgent: "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Safari/537.36"})),
+		string(encode(t, Session{UserId: "42", CreatedAt: created, ClientIp: "2.2.2.2", UserAgent: "curl/8.0"})),
+		string(encode(t, Session{UserId: "42", RevokedAt: &revokedAt})),
+		nil,
+	})
+	mock.ExpectZRem("CT:session:user:42", testNewId, staleId).SetVal(2)
+
+	devices, err := s.List(ctx)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []Device{
+		{
+			Id:         handle(testSessionId),
+			Current:    true,
+			Device:     "Chrome on macOS",
+			ClientIp:   "1.1.1.1",
+			UserAgent:  "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Safari/537.36",
+			CreatedAt:  created,
+			LastSeenAt: testNow,
+		},
+		{
+			Id:         handle(testOtherId),
+			Device:     "Unknown device",
+			ClientIp:   "2.2.2.2",
+			UserAgent:  "curl/8.0",
+			CreatedAt:  created,
+			LastSeenAt: testNow.Add(-time.Minute),
+		},
+	}, devices)
+	assert.NotContains(t, devices[0].Id, testSessionId)
+}
+
+func TestListNotSignedIn(t *testing.T) {
+	s, _ := newTestStore(t)
+
+	_, err := s.List(newTestCtx(""))
+
+	assert.ErrorIs(t, err, ErrNotSignedIn)
+}
+
+func TestListRedisError(t *testing.T) {
+	s, mock := newTestStore(t)
+	ctx := newTestCtx(testSessionId)
+	cookie.SetSessionAuth(ctx, newUserAuth(42))
+
+	mock.ExpectZRevRangeWithScores("CT:session:user:42", 0, -1).SetErr(errors.New("connection refused"))
+
+	_, err := s.List(ctx)
+
+	assert.ErrorContains(t, err, "session list: connection refused")
+}
+
+func TestRevokeOtherDeviceLeavesTombstone(t *testing.T) {
+	s, mock := newTestStore(t)
+	ctx := newTestCtx(testSessionId)
+	cookie.SetSessionAuth(ctx, newUserAuth(42))
+
+	created := testNow.Add(-time.Hour)
+	revokedAt := testNow
+	mock.ExpectZRange("CT:session:user:42", 0, -1).SetVal([]string{testSessionId, testOtherId})
+	mock.ExpectGet("CT:session:" + testOtherId).SetVal(string(encode(t, Session{Auth: testAuth, UserId: "42", CreatedAt: created})))
+	mock.ExpectSet("CT:session:"+testOtherId, encode(t, Session{UserId: "42", CreatedAt: created, RevokedAt: &revokedAt}), redis.KeepTTL).SetVal("OK")
+	mock.ExpectZRem("CT:session:user:42", testOtherId).SetVal(1)
+
+	assert.NoError(t, s.Revoke(ctx, handle(testOtherId)))
+
+	// the current session is untouched
+	_, ok := responseCookie(ctx, cookie.SessionCookieName)
+	assert.False(t, ok)
+}
+
+func TestRevokeCurrentDeviceSignsOut(t *testing.T) {
+	s, mock := newTestStore(t)
+	ctx := newTestCtx(testSessionId)
+	cookie.SetSessionAuth(ctx, newUserAuth(42))
+
+	mock.ExpectZRange("CT:session:user:42", 0, -1).SetVal([]string{testSessionId, testOtherId})
+	mock.ExpectZRem("CT:session:user:42", testSessionId).SetVal(1)
+	mock.ExpectDel("CT:session:" + testSessionId).SetVal(1)
+
+	assert.NoError(t, s.Revoke(ctx, handle(testSessionId)))
+
+	c, ok := responseCookie(ctx, cookie.SessionCookieName)
+	assert.True(t, ok)
+	assert.Empty(t, string(c.Value()))
+}
+
+// A handle of another user's session is never found in the index of this one.
+func TestRevokeUnknownDevice(t *testing.T) {
+	s, mock := newTestStore(t)
+	ctx := newTestCtx(testSessionId)
+	cookie.SetSessionAuth(ctx, newUserAuth(42))
+
+	mock.ExpectZRange("CT:session:user:42", 0, -1).SetVal([]string{testSessionId})
+
+	assert.ErrorIs(t, s.Revoke(ctx, handle(testOtherId)), ErrNotFound)
+}
+
+func TestRevokeOthers(t *testing.T) {
+	s, mock := newTestStore(t)
+	ctx := newTestCtx(testSessionId)
+	cookie.SetSessionAuth(ctx, newUserAuth(42))
+
+	revokedAt := testNow
+	mock.ExpectZRange("CT:session:user:42", 0, -1).SetVal([]string{testOtherId, testSessionId, testNewId})
+	mock.ExpectGet("CT:session:" + testOtherId).SetVal(string(encode(t, Session{Auth: testAuth, UserId: "42"})))
+	mock.ExpectSet("CT:session:"+testOtherId, encode(t, Session{UserId: "42", RevokedAt: &revokedAt}), redis.KeepTTL).SetVal("OK")
+	mock.ExpectGet("CT:session:" + testNewId).RedisNil()
+	mock.ExpectZRem("CT:session:user:42", testOtherId, testNewId).SetVal(2)
+
+	revoked, err := s.RevokeOthers(ctx)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, revoked)
+}
+
+func TestRevokeOthersNotSignedIn(t *testing.T) {
+	s, _ := newTestStore(t)
+	ctx := newTestCtx(testSessionId)
+	ctx.SetUserValue(revokedUserValue, true)
+	cookie.SetSessionAuth(ctx, cookie.Auth{})
+
+	_, err := s.RevokeOthers(ctx)
+
+	assert.ErrorIs(t, err, ErrNotSignedIn)
+}
+
+func TestUserIdFromAccessToken(t *testing.T) {
+	for name, test := range map[string]struct {
+		token  string
+		expect string
+	}{
+		"Numeric": {token: newUserAuth(42).AccessToken, expect: "42"},
+		"String":  {token: newUserAuth("user-42").AccessToken, expect: "user-42"},
+		"Zero":    {token: newUserAuth(0).AccessToken},
+		"Empty":   {},
+		"Invalid": {token: "access_token"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.expect, userIdFromAccessToken(test.token))
+		})
+	}
+}
@@ -0,0 +1,366 @@
+package session
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/redis/go-redis/v9"
+	"github.com/valyala/fasthttp"
+
+	"github.com/cash-track/gateway/headers"
+	"github.com/cash-track/gateway/headers/cookie"
+	"github.com/cash-track/gateway/router/response"
+	"github.com/cash-track/gateway/traces"
+)
+
+const (
+	keyPrefix = "CT:session"
+	// 256 bits of entropy, 43 characters once encoded.
+	idBytes = 32
+
+	// Update rereads a session changed between its read and write this many times at most.
+	updateAttempts = 3
+
+	metricsNamespace     = "gateway"
+	metricsSessionSubsys = "session"
+)
+
+var idLength = base64.RawURLEncoding.EncodedLen(idBytes)
+
+var sessionResolveFailedTotal = promauto.NewCounter(prometheus.CounterOpts{
+	Namespace: metricsNamespace,
+	Subsystem: metricsSessionSubsys,
+	Name:      "resolve_failed_total",
+	Help:      "Requests answered with 503 because their session could not be read from Redis.",
+})
+
+// updateScript replaces the session only while it still is the one read, so neither a
+// revocation nor another refresh landing in between is overwritten. Returns 1 when replaced.
+var updateScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) ~= ARGV[1] then
+	return 0
+end
+
+redis.call('SET', KEYS[1], ARGV[2], 'PX', ARGV[3])
+
+return 1
+`)
+
+// ErrRevoked is returned by Resolve for a session revoked from another device.
+var ErrRevoked = errors.New("session revoked")
+
+// revokedUserValue marks a request that came with a revoked session.
+const revokedUserValue = "session.revoked"
+
+// idUserValue keeps the ID of the signed in session of a request, see Id.
+const idUserValue = "session.id"
+
+// Session is the state kept in Redis under the opaque ID of the session cookie.
+type Session struct {
+	Auth      cookie.Auth `json:"auth"`
+	UserId    string      `json:"userId,omitempty"`
+	CreatedAt time.Time   `json:"createdAt"`
+	ClientIp  string      `json:"clientIp,omitempty"`
+	UserAgent string      `json:"userAgent,omitempty"`
+	// Set once revoked: the tokens are dropped, the key stays until it expires so the
+	// device holding the cookie learns why it was signed out.
+	RevokedAt *time.Time `json:"revokedAt,omitempty"`
+}
+
+// RedisStore keeps the token pair in Redis and gives the browser only a random session
+// ID, so the tokens never leave the gateway and deleting the key revokes the session
+// whoever holds the cookie. A session lives as long as its refresh token.
+type RedisStore struct {
+	client *redis.Client
+	now    func() time.Time
+	newId  func() (string, error)
+}
+
+func NewRedisStore(client *redis.Client) *RedisStore {
+	return &RedisStore{
+		client: client,
+		now:    time.Now,
+		newId:  newId,
+	}
+}
+
+// Handler resolves the session before csrf and the router read the request tokens. A
+// session that cannot be read fails the request: treating it as a guest would sign the
+// user out on a Redis hiccup. A revoked one goes on as a guest, ForwardRequest rejects it.
+func (s *RedisStore) Handler(h fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		if string(ctx.Request.Header.Method()) == fasthttp.MethodOptions {
+			h(ctx)
+
+			return
+		}
+
+		if _, err := s.Resolve(ctx); err != nil && !errors.Is(err, ErrRevoked) {
+			sessionResolveFailedTotal.Inc()
+			slog.Error("session resolve failed", "trace_id", traces.FindTraceId(ctx), "error", err)
+			response.New(response.CodeSessionUnavailable).Write(ctx)
+
+			return
+		}
+
+		h(ctx)
+	}
+}
+
+func (s *RedisStore) Resolve(ctx *fasthttp.RequestCtx) (cookie.Auth, error) {
+	if auth, ok := cookie.GetSessionAuth(ctx); ok {
+		if IsRevoked(ctx) {
+			return auth, ErrRevoked
+		}
+
+		return auth, nil
+	}
+
+	id := readId(ctx)
+	if id == "" {
+		cookie.SetSessionAuth(ctx, cookie.Auth{})
+
+		return cookie.Auth{}, nil
+	}
+
+	sess, err := s.load(ctx, id)
+	if errors.Is(err, redis.Nil) {
+		// expired or signed out: the cookie is of no use anymore
+		cookie.WriteSessionCookie(ctx, "", time.Time{})
+		cookie.SetSessionAuth(ctx, cookie.Auth{})
+
+		return cookie.Auth{}, nil
+	}
+	if err != nil {
+		return cookie.Auth{}, err
+	}
+
+	if sess.RevokedAt != nil {
+		cookie.WriteSessionCookie(ctx, "", time.Time{})
+		cookie.SetSessionAuth(ctx, cookie.Auth{})
+		ctx.SetUserValue(revokedUserValue, true)
+
+		return cookie.Auth{}, ErrRevoked
+	}
+
+	s.touch(ctx, id, sess.UserId)
+	cookie.SetSessionAuth(ctx, sess.Auth)
+	ctx.SetUserValue(idUserValue, id)
+
+	return sess.Auth, nil
+}
+
+func (s *RedisStore) Start(ctx *fasthttp.RequestCtx, auth cookie.Auth) error {
+	if !auth.IsLogged() {
+		return s.End(ctx)
+	}
+
+	expireAt, err := auth.GetRefreshTokenExpireDate()
+	if err != nil {
+		return fmt.Errorf("session expiry: %w", err)
+	}
+
+	id, err := s.newId()
+	if err != nil {
+		return fmt.Errorf("session id: %w", err)
+	}
+
+	sess := Session{
+		Auth:      auth,
+		UserId:    userIdFromAccessToken(auth.AccessToken),
+		CreatedAt: s.now().UTC(),
+		ClientIp:  headers.GetClientIPFromContext(ctx),
+		UserAgent: string(ctx.Request.Header.UserAgent()),
+	}
+
+	if err := s.save(ctx, id, sess, expireAt); err != nil {
+		return err
+	}
+
+	// a login never reuses the ID the browser came with, so it cannot be fixated
+	if old := readId(ctx); old != "" {
+		if err := s.client.Del(traces.FindParentContext(ctx), key(old)).Err(); err != nil {
+			slog.Warn("previous session delete failed", "trace_id", traces.FindTraceId(ctx), "error", err)
+		}
+	}
+
+	s.index(ctx, id, sess.UserId, expireAt)
+	cookie.WriteSessionCookie(ctx, id, expireAt)
+	// drop the token cookies a browser may still hold from before session mode
+	_ = cookie.Auth{}.WriteCookie(ctx)
+	cookie.SetSessionAuth(ctx, auth)
+	ctx.SetUserValue(idUserValue, id)
+
+	return nil
+}
+
+// Update keeps the refreshed tokens under the same ID. A session revoked meanwhile is
+// not brought back, even when revoked between its read and write here.
+func (s *RedisStore) Update(ctx *fasthttp.RequestCtx, auth cookie.Auth) error {
+	if !auth.IsLogged() {
+		return s.End(ctx)
+	}
+
+	id := readId(ctx)
+	if id == "" {
+		return s.Start(ctx, auth)
+	}
+
+	expireAt, err := auth.GetRefreshTokenExpireDate()
+	if err != nil {
+		return fmt.Errorf("session expiry: %w", err)
+	}
+
+	var sess Session
+	for attempt := 1; ; attempt++ {
+		var read []byte
+		read, sess, err = s.loadRaw(ctx, id)
+		if errors.Is(err, redis.Nil) || (err == nil && sess.RevokedAt != nil) {
+			return s.End(ctx)
+		}
+		if err != nil {
+			return err
+		}
+
+		sess.Auth = auth
+
+		replaced, err := s.replace(ctx, id, read, sess, expireAt)
+		if err != nil {
+			return err
+		}
+		if replaced {
+			break
+		}
+		if attempt == updateAttempts {
+			return fmt.Errorf("session update: changed %d times while updating", attempt)
+		}
+	}
+
+	s.reindex(ctx, id, sess.UserId, expireAt)
+	cookie.WriteSessionCookie(ctx, id, expireAt)
+	cookie.SetSessionAuth(ctx, auth)
+
+	return nil
+}
+
+func (s *RedisStore) End(ctx *fasthttp.RequestCtx) error {
+	var err error
+	if id := readId(ctx); id != "" {
+		if delErr := s.client.Del(traces.FindParentContext(ctx), key(id)).Err(); delErr != nil {
+			err = fmt.Errorf("session delete: %w", delErr)
+		}
+	}
+
+	cookie.WriteSessionCookie(ctx, "", time.Time{})
+	cookie.SetSessionAuth(ctx, cookie.Auth{})
+	ctx.SetUserValue(idUserValue, "")
+
+	return err
+}
+
+func (s *RedisStore) load(ctx *fasthttp.RequestCtx, id string) (Session, error) {
+	_, sess, err := s.loadRaw(ctx, id)
+
+	return sess, err
+}
+
+// loadRaw also returns the session as stored, for replace to tell whether it changed.
+func (s *RedisStore) loadRaw(ctx *fasthttp.RequestCtx, id string) ([]byte, Session, error) {
+	sess := Session{}
+
+	b, err := s.client.Get(traces.FindParentContext(ctx), key(id)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, sess, err
+	}
+	if err != nil {
+		return nil, sess, fmt.Errorf("session load: %w", err)
+	}
+
+	if err := json.Unmarshal(b, &sess); err != nil {
+		return nil, sess, fmt.Errorf("session decode: %w", err)
+	}
+
+	return b, sess, nil
+}
+
+// replace saves the session unless it is no longer stored as read, and reports whether it did.
+func (s *RedisStore) replace(ctx *fasthttp.RequestCtx, id string, read []byte, sess Session, expireAt time.Time) (bool, error) {
+	b, err := json.Marshal(sess)
+	if err != nil {
+		return false, fmt.Errorf("session encode: %w", err)
+	}
+
+	ttl := expireAt.Sub(s.now()).Milliseconds()
+
+	replaced, err := updateScript.Run(traces.FindParentContext(ctx), s.client, []string{key(id)}, read, b, ttl).Int()
+	if err != nil {
+		return false, fmt.Errorf("session save: %w", err)
+	}
+
+	return replaced == 1, nil
+}
+
+func (s *RedisStore) save(ctx *fasthttp.RequestCtx, id string, sess Session, expireAt time.Time) error {
+	b, err := json.Marshal(sess)
+	if err != nil {
+		return fmt.Errorf("session encode: %w", err)
+	}
+
+	if err := s.client.Set(traces.FindParentContext(ctx), key(id), b, expireAt.Sub(s.now())).Err(); err != nil {
+		return fmt.Errorf("session save: %w", err)
+	}
+
+	return nil
+}
+
+// Id returns the ID of the server-side session the request is signed in with, false for
+// guests and in cookie mode, where there is none. Unlike the cookie, it was found in Redis.
+func Id(ctx *fasthttp.RequestCtx) (string, bool) {
+	id, _ := ctx.UserValue(idUserValue).(string)
+
+	return id, id != ""
+}
+
+// IsRevoked reports whether the request came with a session revoked from another device.
+func IsRevoked(ctx *fasthttp.RequestCtx) bool {
+	revoked, _ := ctx.UserValue(revokedUserValue).(bool)
+
+	return revoked
+}
+
+// ClearRevoked lets the request go on as a plain guest, for the endpoints signing in
+// again, which are how a revoked device recovers.
+func ClearRevoked(ctx *fasthttp.RequestCtx) {
+	ctx.SetUserValue(revokedUserValue, false)
+}
+
+// readId returns the session ID of the request, empty when the cookie is missing or was
+// not issued by this gateway.
+func readId(ctx *fasthttp.RequestCtx) string {
+	id := cookie.ReadSessionCookie(ctx)
+	if len(id) != idLength {
+		return ""
+	}
+
+	return id
+}
+
+func newId() (string, error) {
+	b := make([]byte, idBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func key(id string) string {
+	return fmt.Sprintf("%s:%s", keyPrefix, id)
+}
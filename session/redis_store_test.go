@@ -0,0 +1,307 @@
+package session
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redismock/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+
+	"github.com/cash-track/gateway/headers/cookie"
+)
+
+var (
+	testNow       = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	testExpireAt  = time.Date(2099, 1, 1, 0, 0, 0, 0, time.UTC)
+	testSessionId = strings.Repeat("a", idLength)
+	testNewId     = strings.Repeat("b", idLength)
+	testAuth      = cookie.Auth{
+		AccessToken:           "access_token",
+		AccessTokenExpiredAt:  "2098-01-01T00:00:00Z",
+		RefreshToken:          "refresh_token",
+		RefreshTokenExpiredAt: "2099-01-01T00:00:00Z",
+	}
+)
+
+func newTestStore(t *testing.T) (*RedisStore, redismock.ClientMock) {
+	client, mock := redismock.NewClientMock()
+	s := NewRedisStore(client)
+	s.now = func() time.Time { return testNow }
+	s.newId = func() (string, error) { return testNewId, nil }
+
+	t.Cleanup(func() { assert.NoError(t, mock.ExpectationsWereMet()) })
+
+	return s, mock
+}
+
+func newTestCtx(sessionId string) *fasthttp.RequestCtx {
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod(fasthttp.MethodGet)
+	ctx.Request.Header.SetUserAgent("test-agent")
+	if sessionId != "" {
+		ctx.Request.Header.SetCookie(cookie.SessionCookieName, sessionId)
+	}
+
+	return ctx
+}
+
+func encode(t *testing.T, sess Session) []byte {
+	b, err := json.Marshal(sess)
+	assert.NoError(t, err)
+
+	return b
+}
+
+func responseCookie(ctx *fasthttp.RequestCtx, name string) (*fasthttp.Cookie, bool) {
+	c := &fasthttp.Cookie{}
+	c.SetKey(name)
+
+	return c, ctx.Response.Header.Cookie(c)
+}
+
+func TestResolveWithoutCookieIsGuest(t *testing.T) {
+	s, _ := newTestStore(t)
+	ctx := newTestCtx("")
+	ctx.Request.Header.SetCookie(cookie.AccessTokenCookieName, "cookie_token")
+
+	auth, err := s.Resolve(ctx)
+
+	assert.NoError(t, err)
+	assert.False(t, auth.IsLogged())
+	// the token cookies of cookie mode are ignored
+	assert.False(t, cookie.ReadAuthCookie(ctx).IsLogged())
+}
+
+func TestResolveForeignCookieIsGuest(t *testing.T) {
+	s, _ := newTestStore(t)
+
+	// no Redis lookup for an ID the gateway could not have issued
+	auth, err := s.Resolve(newTestCtx("CT:csrf:*"))
+
+	assert.NoError(t, err)
+	assert.False(t, auth.IsLogged())
+}
+
+func TestResolveFound(t *testing.T) {
+	s, mock := newTestStore(t)
+	ctx := newTestCtx(testSessionId)
+
+	mock.ExpectGet("CT:session:" + testSessionId).SetVal(string(encode(t, Session{Auth: testAuth, CreatedAt: testNow})))
+
+	auth, err := s.Resolve(ctx)
+
+	assert.NoError(t, err)
+	assert.Equal(t, testAuth, auth)
+	assert.Equal(t, testAuth, cookie.ReadAuthCookie(ctx))
+
+	// resolved once per request
+	auth, err = s.Resolve(ctx)
+
+	assert.NoError(t, err)
+	assert.Equal(t, testAuth, auth)
+}
+
+func TestResolveRevokedDeletesCookie(t *testing.T) {
+	s, mock := newTestStore(t)
+	ctx := newTestCtx(testSessionId)
+
+	mock.ExpectGet("CT:session:" + testSessionId).RedisNil()
+
+	auth, err := s.Resolve(ctx)
+
+	assert.NoError(t, err)
+	assert.False(t, auth.IsLogged())
+
+	c, ok := responseCookie(ctx, cookie.SessionCookieName)
+	assert.True(t, ok)
+	assert.Empty(t, string(c.Value()))
+}
+
+func TestResolveRedisError(t *testing.T) {
+	s, mock := newTestStore(t)
+
+	mock.ExpectGet("CT:session:" + testSessionId).SetErr(errors.New("connection refused"))
+
+	_, err := s.Resolve(newTestCtx(testSessionId))
+
+	assert.ErrorContains(t, err, "session load: connection refused")
+}
+
+func TestHandlerRedisErrorUnavailable(t *testing.T) {
+	s, mock := newTestStore(t)
+	ctx := newTestCtx(testSessionId)
+
+	mock.ExpectGet("CT:session:" + testSessionId).SetErr(errors.New("connection refused"))
+
+	called := false
+	s.Handler(func(ctx *fasthttp.RequestCtx) { called = true })(ctx)
+
+	assert.False(t, called)
+	assert.Equal(t, fasthttp.StatusServiceUnavailable, ctx.Response.StatusCode())
+}
+
+func TestHandlerResolvesBeforeInner(t *testing.T) {
+	s, mock := newTestStore(t)
+	ctx := newTestCtx(testSessionId)
+
+	mock.ExpectGet("CT:session:" + testSessionId).SetVal(string(encode(t, Session{Auth: testAuth, CreatedAt: testNow})))
+
+	var seen cookie.Auth
+	s.Handler(func(ctx *fasthttp.RequestCtx) { seen = cookie.ReadAuthCookie(ctx) })(ctx)
+
+	assert.Equal(t, testAuth, seen)
+}
+
+func TestHandlerSkipsOptions(t *testing.T) {
+	s, _ := newTestStore(t)
+	ctx := newTestCtx(testSessionId)
+	ctx.Request.Header.SetMethod(fasthttp.MethodOptions)
+
+	called := false
+	s.Handler(func(ctx *fasthttp.RequestCtx) { called = true })(ctx)
+
+	assert.True(t, called)
+}
+
+func TestStartReplacesPreviousSession(t *testing.T) {
+	s, mock := newTestStore(t)
+	ctx := newTestCtx(testSessionId)
+
+	sess := Session{Auth: testAuth, CreatedAt: testNow, ClientIp: "0.0.0.0", UserAgent: "test-agent"}
+	mock.ExpectSet("CT:session:"+testNewId, encode(t, sess), testExpireAt.Sub(testNow)).SetVal("OK")
+	mock.ExpectDel("CT:session:" + testSessionId).SetVal(1)
+
+	assert.NoError(t, s.Start(ctx, testAuth))
+
+	c, ok := responseCookie(ctx, cookie.SessionCookieName)
+	assert.True(t, ok)
+	assert.Equal(t, testNewId, string(c.Value()))
+	assert.Equal(t, testExpireAt.Unix(), c.Expire().Unix())
+
+	// the tokens themselves never reach the browser
+	c, ok = responseCookie(ctx, cookie.AccessTokenCookieName)
+	assert.True(t, ok)
+	assert.Empty(t, string(c.Value()))
+	assert.NotContains(t, ctx.Response.Header.String(), "access_token")
+
+	assert.Equal(t, testAuth, cookie.ReadAuthCookie(ctx))
+}
+
+func TestStartSaveError(t *testing.T) {
+	s, mock := newTestStore(t)
+	ctx := newTestCtx("")
+
+	sess := Session{Auth: testAuth, CreatedAt: testNow, ClientIp: "0.0.0.0", UserAgent: "test-agent"}
+	mock.ExpectSet("CT:session:"+testNewId, encode(t, sess), testExpireAt.Sub(testNow)).SetErr(errors.New("connection refused"))
+
+	assert.ErrorContains(t, s.Start(ctx, testAuth), "session save: connection refused")
+
+	_, ok := responseCookie(ctx, cookie.SessionCookieName)
+	assert.False(t, ok)
+}
+
+func TestStartInvalidExpiry(t *testing.T) {
+	s, _ := newTestStore(t)
+
+	err := s.Start(newTestCtx(""), cookie.Auth{AccessToken: "access_token", RefreshTokenExpiredAt: "nope"})
+
+	assert.ErrorContains(t, err, "session expiry")
+}
+
+func TestUpdateKeepsSessionMetadata(t *testing.T) {
+	s, mock := newTestStore(t)
+	ctx := newTestCtx(testSessionId)
+
+	created := testNow.Add(-time.Hour)
+	old := Session{Auth: cookie.Auth{AccessToken: "old", RefreshTokenExpiredAt: "2098-01-01T00:00:00Z"}, CreatedAt: created, ClientIp: "1.2.3.4", UserAgent: "login-agent"}
+	mock.ExpectGet("CT:session:" + testSessionId).SetVal(string(encode(t, old)))
+	mock.ExpectEvalSha(updateScript.Hash(), []string{"CT:session:" + testSessionId},
+		encode(t, old), encode(t, Session{Auth: testAuth, CreatedAt: created, ClientIp: "1.2.3.4", UserAgent: "login-agent"}),
+		testExpireAt.Sub(testNow).Milliseconds()).SetVal(int64(1))
+
+	assert.NoError(t, s.Update(ctx, testAuth))
+
+	c, ok := responseCookie(ctx, cookie.SessionCookieName)
+	assert.True(t, ok)
+	assert.Equal(t, testSessionId, string(c.Value()))
+	assert.Equal(t, testAuth, cookie.ReadAuthCookie(ctx))
+}
+
+func TestUpdateRevokedSessionNotRecreated(t *testing.T) {
+	s, mock := newTestStore(t)
+	ctx := newTestCtx(testSessionId)
+
+	mock.ExpectGet("CT:session:" + testSessionId).RedisNil()
+	mock.ExpectDel("CT:session:" + testSessionId).SetVal(0)
+
+	assert.NoError(t, s.Update(ctx, testAuth))
+
+	c, ok := responseCookie(ctx, cookie.SessionCookieName)
+	assert.True(t, ok)
+	assert.Empty(t, string(c.Value()))
+	assert.False(t, cookie.ReadAuthCookie(ctx).IsLogged())
+}
+
+func TestUpdateRevokedWhileUpdatingNotRecreated(t *testing.T) {
+	s, mock := newTestStore(t)
+	ctx := newTestCtx(testSessionId)
+
+	revokedAt := testNow
+	old := Session{Auth: cookie.Auth{AccessToken: "old"}, UserId: "7", CreatedAt: testNow}
+	tombstone := Session{UserId: "7", CreatedAt: testNow, RevokedAt: &revokedAt}
+
+	// revoke-others lands between the read and the write of the refreshed session
+	mock.ExpectGet("CT:session:" + testSessionId).SetVal(string(encode(t, old)))
+	mock.ExpectEvalSha(updateScript.Hash(), []string{"CT:session:" + testSessionId},
+		encode(t, old), encode(t, Session{Auth: testAuth, UserId: "7", CreatedAt: testNow}),
+		testExpireAt.Sub(testNow).Milliseconds()).SetVal(int64(0))
+	mock.ExpectGet("CT:session:" + testSessionId).SetVal(string(encode(t, tombstone)))
+	mock.ExpectDel("CT:session:" + testSessionId).SetVal(1)
+
+	assert.NoError(t, s.Update(ctx, testAuth))
+
+	c, ok := responseCookie(ctx, cookie.SessionCookieName)
+	assert.True(t, ok)
+	assert.Empty(t, string(c.Value()))
+	assert.False(t, cookie.ReadAuthCookie(ctx).IsLogged())
+}
+
+func TestUpdateNotLoggedEndsSession(t *testing.T) {
+	s, mock := newTestStore(t)
+	ctx := newTestCtx(testSessionId)
+
+	mock.ExpectDel("CT:session:" + testSessionId).SetVal(1)
+
+	assert.NoError(t, s.Update(ctx, cookie.Auth{}))
+
+	c, ok := responseCookie(ctx, cookie.SessionCookieName)
+	assert.True(t, ok)
+	assert.Empty(t, string(c.Value()))
+}
+
+func TestEndDeleteErrorStillClearsCookie(t *testing.T) {
+	s, mock := newTestStore(t)
+	ctx := newTestCtx(testSessionId)
+
+	mock.ExpectDel("CT:session:" + testSessionId).SetErr(errors.New("connection refused"))
+
+	assert.ErrorContains(t, s.End(ctx), "session delete: connection refused")
+
+	c, ok := responseCookie(ctx, cookie.SessionCookieName)
+	assert.True(t, ok)
+	assert.Empty(t, string(c.Value()))
+}
+
+func TestNewId(t *testing.T) {
+	a, err := newId()
+	assert.NoError(t, err)
+	b, err := newId()
+	assert.NoError(t, err)
+
+	assert.Len(t, a, idLength)
+	assert.NotEqual(t, a, b)
+}
@@ -0,0 +1,45 @@
+package session
+
+import (
+	"github.com/valyala/fasthttp"
+
+	"github.com/cash-track/gateway/headers/cookie"
+)
+
+// Store keeps the token pair of a signed-in user between requests.
+type Store interface {
+	// Resolve returns the tokens of the request, an empty Auth for a guest.
+	Resolve(ctx *fasthttp.RequestCtx) (cookie.Auth, error)
+	// Start keeps the tokens of a new login, replacing whatever the request had.
+	Start(ctx *fasthttp.RequestCtx, auth cookie.Auth) error
+	// Update keeps refreshed tokens. An auth that is not logged ends the session.
+	Update(ctx *fasthttp.RequestCtx, auth cookie.Auth) error
+	// End signs the request out.
+	End(ctx *fasthttp.RequestCtx) error
+	// Handler resolves the request tokens before h, or anything it wraps, reads them.
+	Handler(h fasthttp.RequestHandler) fasthttp.RequestHandler
+}
+
+// CookieStore hands the tokens to the browser in the cshtrka and cshtrkr cookies. It is
+// the default when SESSION_ENABLED is off.
+type CookieStore struct{}
+
+func (CookieStore) Resolve(ctx *fasthttp.RequestCtx) (cookie.Auth, error) {
+	return cookie.ReadAuthCookie(ctx), nil
+}
+
+func (CookieStore) Start(ctx *fasthttp.RequestCtx, auth cookie.Auth) error {
+	return auth.WriteCookie(ctx)
+}
+
+func (CookieStore) Update(ctx *fasthttp.RequestCtx, auth cookie.Auth) error {
+	return auth.WriteCookie(ctx)
+}
+
+func (CookieStore) End(ctx *fasthttp.RequestCtx) error {
+	return cookie.Auth{}.WriteCookie(ctx)
+}
+
+func (CookieStore) Handler(h fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return h
+}
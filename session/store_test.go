@@ -0,0 +1,34 @@
+package session
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+
+	"github.com/cash-track/gateway/headers/cookie"
+)
+
+func TestCookieStoreRoundTrip(t *testing.T) {
+	ctx := &fasthttp.RequestCtx{}
+
+	assert.NoError(t, CookieStore{}.Start(ctx, testAuth))
+
+	c, ok := responseCookie(ctx, cookie.AccessTokenCookieName)
+	assert.True(t, ok)
+	assert.Equal(t, "access_token", string(c.Value()))
+
+	ctx = &fasthttp.RequestCtx{}
+	ctx.Request.Header.SetCookie(cookie.AccessTokenCookieName, "access_token")
+	ctx.Request.Header.SetCookie(cookie.RefreshTokenCookieName, "refresh_token")
+
+	auth, err := CookieStore{}.Resolve(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "access_token", auth.AccessToken)
+
+	assert.NoError(t, CookieStore{}.End(ctx))
+
+	c, ok = responseCookie(ctx, cookie.AccessTokenCookieName)
+	assert.True(t, ok)
+	assert.Empty(t, string(c.Value()))
+}
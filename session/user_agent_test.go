@@ -0,0 +1,46 @@
+package session
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDescribeDevice(t *testing.T) {
+	for name, test := range map[string]struct {
+		userAgent string
+		expect    string
+	}{
+		"ChromeMac": {
+			userAgent: "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+			expect:    "Chrome on macOS",
+		},
+		"EdgeWindows": {
+			userAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 Edg/120.0.0.0",
+			expect:    "Edge on Windows",
+		},
+		"SafariIPhone": {
+			userAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Mobile/15E148 Safari/604.1",
+			expect:    "Safari on iOS",
+		},
+		"FirefoxAndroid": {
+			userAgent: "Mozilla/5.0 (Android 14; Mobile; rv:121.0) Gecko/121.0 Firefox/121.0",
+			expect:    "Firefox on Android",
+		},
+		"FirefoxLinux": {
+			userAgent: "Mozilla/5.0 (X11; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0",
+			expect:    "Firefox on Linux",
+		},
+		"Unknown": {
+			userAgent: "curl/8.0",
+			expect:    "Unknown device",
+		},
+		"Empty": {
+			expect: "Unknown device",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.expect, describeDevice(test.userAgent))
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// shutdownStepTimeout bounds each step on its own, they still run once the server used up
+// its whole timeout waiting for requests.
+const shutdownStepTimeout = 5 * time.Second
+
+// drainer is implemented by router.Router: once called, /ready starts failing.
+type drainer interface {
+	Drain()
+}
+
+// shutdownStep is a dependency released after the server has stopped serving requests.
+type shutdownStep struct {
+	name  string
+	close func(ctx context.Context) error
+}
+
+// gracefulShutdown stops the gateway without dropping in-flight requests. Readiness is
+// flipped to failing first and the drain period gives the load balancer time to notice,
+// while the server keeps answering. Then the server stops accepting connections and
+// waits for the active ones, and finally steps are closed in the given order — the
+// tracer last, so spans of the drained requests are still flushed.
+//
+// timeout bounds the wait for the active connections, each step then gets
+// shutdownStepTimeout of its own. A failing step does not prevent the following ones
+// from running.
+func gracefulShutdown(
+	s *fasthttp.Server,
+	d drainer,
+	drain time.Duration,
+	timeout time.Duration,
+	steps ...shutdownStep,
+) error {
+	d.Drain()
+
+	slog.Info("draining before shutdown", "drain_period", drain.String())
+	time.Sleep(drain)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var errs []error
+
+	if err := s.ShutdownWithContext(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("server shutdown: %w", err))
+	}
+
+	for _, step := range steps {
+		if err := closeStep(step); err != nil {
+			errs = append(errs, fmt.Errorf("%s close: %w", step.name, err))
+
+			continue
+		}
+
+		slog.Info("closed on shutdown", "dependency", step.name)
+	}
+
+	return errors.Join(errs...)
+}
+
+func closeStep(step shutdownStep) error {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownStepTimeout)
+	defer cancel()
+
+	return step.close(ctx)
+}
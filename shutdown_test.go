@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+	"go.uber.org/mock/gomock"
+
+	"github.com/cash-track/gateway/config"
+	"github.com/cash-track/gateway/mocks"
+	"github.com/cash-track/gateway/router"
+	apiHandler "github.com/cash-track/gateway/router/api"
+)
+
+func TestGracefulShutdownCompletesInFlightForward(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	service := mocks.NewApiServiceMock(ctrl)
+	csrf := mocks.NewCsrfHandlerMock(ctrl)
+
+	// probed by the readiness poll below until the drain starts
+	service.EXPECT().Healthcheck().Return(nil).AnyTimes()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	service.EXPECT().ForwardRequest(gomock.Any(), gomock.Nil()).DoAndReturn(func(ctx *fasthttp.RequestCtx, _ []byte) error {
+		close(started)
+		<-release
+
+		ctx.SetStatusCode(fasthttp.StatusOK)
+		ctx.SetBodyString(`{"data":"ok"}`)
+
+		return nil
+	})
+
+	api := apiHandler.NewHttp(config.Config{}, service, mocks.NewCaptchaProviderMock(ctrl), csrf)
+	r := router.New(api, csrf, nil, []router.Upstream{{Prefix: "/api", Handler: api}})
+	s := &fasthttp.Server{Handler: r.Handler, CloseOnShutdown: true}
+
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- s.Serve(ln) }()
+
+	type result struct {
+		status int
+		body   string
+		err    error
+	}
+	forwarded := make(chan result, 1)
+
+	go func() {
+		req := fasthttp.AcquireRequest()
+		resp := fasthttp.AcquireResponse()
+		defer fasthttp.ReleaseRequest(req)
+		defer fasthttp.ReleaseResponse(resp)
+
+		req.SetRequestURI("http://" + ln.Addr().String() + "/api/profile")
+		err := (&fasthttp.Client{}).Do(req, resp)
+		forwarded <- result{status: resp.StatusCode(), body: string(resp.Body()), err: err}
+	}()
+
+	<-started
+
+	var closed []string
+	shutdownErr := make(chan error, 1)
+	go func() {
+		shutdownErr <- gracefulShutdown(s, r, 50*time.Millisecond, 5*time.Second,
+			shutdownStep{name: "redis", close: func(context.Context) error {
+				closed = append(closed, "redis")
+				return nil
+			}},
+			shutdownStep{name: "tracer", close: func(context.Context) error {
+				closed = append(closed, "tracer")
+				return nil
+			}},
+		)
+	}()
+
+	// readiness flips while the request is still in flight
+	assert.Eventually(t, func() bool {
+		ctx := fasthttp.RequestCtx{}
+		r.ReadyHandler(&ctx)
+		return ctx.Response.StatusCode() == fasthttp.StatusServiceUnavailable
+	}, time.Second, 5*time.Millisecond)
+
+	select {
+	case <-shutdownErr:
+		t.Fatal("shutdown must wait for the in-flight request")
+	case <-time.After(150 * time.Millisecond):
+	}
+
+	close(release)
+
+	res := <-forwarded
+	assert.NoError(t, res.err)
+	assert.Equal(t, fasthttp.StatusOK, res.status)
+	assert.Equal(t, `{"data":"ok"}`, res.body)
+
+	assert.NoError(t, <-shutdownErr)
+	assert.NoError(t, <-serveErr)
+	assert.Equal(t, []string{"redis", "tracer"}, closed)
+}
+
+func TestGracefulShutdownRunsEveryStepAndJoinsErrors(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	r := router.New(mocks.NewApiHandlerMock(ctrl), mocks.NewCsrfHandlerMock(ctrl), nil, nil)
+	s := &fasthttp.Server{}
+
+	tracerClosed := false
+	err := gracefulShutdown(s, r, 0, time.Second,
+		shutdownStep{name: "redis", close: func(context.Context) error {
+			return errors.New("connection reset")
+		}},
+		shutdownStep{name: "tracer", close: func(context.Context) error {
+			tracerClosed = true
+			return nil
+		}},
+	)
+
+	assert.EqualError(t, err, "redis close: connection reset")
+	assert.True(t, tracerClosed, "a failing step must not skip the ones after it")
+}
+
+// The steps get their own deadline, the spans of a shutdown that waited out its whole
+// timeout are still flushed.
+func TestGracefulShutdownStepsOutliveServerTimeout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	r := router.New(mocks.NewApiHandlerMock(ctrl), mocks.NewCsrfHandlerMock(ctrl), nil, nil)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+
+	s := &fasthttp.Server{Handler: func(ctx *fasthttp.RequestCtx) {
+		close(started)
+		<-release
+	}}
+
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		return
+	}
+	go func() { _ = s.Serve(ln) }()
+	go func() { _, _, _ = fasthttp.Get(nil, "http://"+ln.Addr().String()+"/") }()
+
+	<-started
+
+	var stepErr error
+	err = gracefulShutdown(s, r, 0, 50*time.Millisecond,
+		shutdownStep{name: "tracer", close: func(ctx context.Context) error {
+			stepErr = ctx.Err()
+			return nil
+		}},
+	)
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.NoError(t, stepErr)
+}
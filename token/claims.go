@@ -0,0 +1,94 @@
+package token
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/valyala/fasthttp"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/cash-track/gateway/traces/semconv"
+)
+
+const claimsUserValue = "token.claims"
+
+// Claims are the verified claims of the access token of a request.
+type Claims struct {
+	UserId    string
+	Roles     []string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
+
+// ClaimsFromContext returns the claims Handler verified for the request. There are none
+// for guests and when verification is disabled.
+func ClaimsFromContext(ctx *fasthttp.RequestCtx) (Claims, bool) {
+	claims, ok := ctx.UserValue(claimsUserValue).(Claims)
+
+	return claims, ok
+}
+
+func setClaims(ctx *fasthttp.RequestCtx, claims Claims) {
+	ctx.SetUserValue(claimsUserValue, claims)
+}
+
+func (c Claims) GetOpenTelemetryAttributes() []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String(semconv.CashTrackAuthUserIdKey, c.UserId),
+		attribute.StringSlice(semconv.CashTrackAuthRolesKey, c.Roles),
+	}
+}
+
+func newClaims(claims jwt.MapClaims) Claims {
+	c := Claims{
+		UserId: userId(claims["sub"]),
+		Roles:  roles(claims["roles"]),
+	}
+
+	if iat, ok := claims["iat"].(float64); ok {
+		c.IssuedAt = time.Unix(int64(iat), 0)
+	}
+
+	if exp, ok := claims["exp"].(float64); ok {
+		c.ExpiresAt = time.Unix(int64(exp), 0)
+	}
+
+	return c
+}
+
+// userId accepts the numeric sub the API issues as well as a string one.
+func userId(sub any) string {
+	switch sub := sub.(type) {
+	case float64:
+		if sub == 0 {
+			return ""
+		}
+
+		return strconv.FormatFloat(sub, 'f', 0, 64)
+	case string:
+		return sub
+	default:
+		return ""
+	}
+}
+
+// roles accepts a list of names as well as a space separated string.
+func roles(claim any) []string {
+	switch claim := claim.(type) {
+	case []any:
+		list := make([]string, 0, len(claim))
+		for _, r := range claim {
+			if s, ok := r.(string); ok && s != "" {
+				list = append(list, s)
+			}
+		}
+
+		return list
+	case string:
+		return strings.Fields(claim)
+	default:
+		return []string{}
+	}
+}
@@ -0,0 +1,91 @@
+package token
+
+import (
+	"errors"
+	"log/slog"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/valyala/fasthttp"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/cash-track/gateway/headers/cookie"
+	"github.com/cash-track/gateway/router/response"
+	"github.com/cash-track/gateway/session"
+	"github.com/cash-track/gateway/traces"
+)
+
+const (
+	metricsNamespace   = "gateway"
+	metricsTokenSubsys = "token"
+)
+
+var tokenVerifyTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: metricsNamespace,
+	Subsystem: metricsTokenSubsys,
+	Name:      "verify_total",
+	Help:      "Access tokens verified at the edge by result: valid, expired (left to refresh) or rejected.",
+}, []string{"result"})
+
+// Handler verifies the access token of the request before anything reads its claims, so
+// a forged token never reaches csrf, the router or the upstreams.
+type Handler struct {
+	verifier Verifier
+	sessions session.Store
+}
+
+func NewHandler(verifier Verifier, sessions session.Store) *Handler {
+	return &Handler{
+		verifier: verifier,
+		sessions: sessions,
+	}
+}
+
+// Handler keeps the verified claims of the request, see ClaimsFromContext. An expired
+// token goes on when it can be refreshed, which the upstream forwarding does, and keeps
+// its claims: the signature holds and the refreshed token names the same user. Any
+// other token failing verification is rejected with 401 and dropped, so the client signs
+// in again instead of sending it over and over.
+func (h *Handler) Handler(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		if string(ctx.Request.Header.Method()) == fasthttp.MethodOptions {
+			next(ctx)
+
+			return
+		}
+
+		auth := cookie.ReadAuthCookie(ctx)
+		if !auth.IsLogged() {
+			next(ctx)
+
+			return
+		}
+
+		claims, err := h.verifier.Verify(auth.AccessToken)
+		expired := errors.Is(err, ErrExpired) && auth.CanRefresh()
+
+		if err == nil || expired {
+			result := "valid"
+			if expired {
+				result = "expired"
+			}
+
+			tokenVerifyTotal.WithLabelValues(result).Inc()
+			setClaims(ctx, claims)
+			trace.SpanFromContext(traces.FindParentContext(ctx)).SetAttributes(claims.GetOpenTelemetryAttributes()...)
+
+			next(ctx)
+
+			return
+		}
+
+		tokenVerifyTotal.WithLabelValues("rejected").Inc()
+		slog.Warn("access token rejected", "trace_id", traces.FindTraceId(ctx), "error", err)
+
+		if endErr := h.sessions.End(ctx); endErr != nil {
+			slog.Warn("session end failed", "trace_id", traces.FindTraceId(ctx), "error", endErr)
+		}
+
+		response.New(response.CodeTokenInvalid).Write(ctx)
+	}
+}
@@ -0,0 +1,112 @@
+package token
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+
+	"github.com/cash-track/gateway/headers/cookie"
+	"github.com/cash-track/gateway/session"
+)
+
+func TestHandler(t *testing.T) {
+	noExp := validClaims()
+	delete(noExp, "exp")
+
+	cases := map[string]struct {
+		method       string
+		accessToken  string
+		refreshToken string
+		next         bool
+		claims       bool
+		status       int
+	}{
+		"Guest": {
+			next:   true,
+			status: fasthttp.StatusOK,
+		},
+		"Valid": {
+			accessToken: sign(jwt.SigningMethodHS256, testSecret, validClaims(), ""),
+			next:        true,
+			claims:      true,
+			status:      fasthttp.StatusOK,
+		},
+		"ExpiredCanRefresh": {
+			accessToken:  sign(jwt.SigningMethodHS256, testSecret, expiredClaims(), ""),
+			refreshToken: "refresh",
+			next:         true,
+			claims:       true,
+			status:       fasthttp.StatusOK,
+		},
+		"ExpiredCannotRefresh": {
+			accessToken: sign(jwt.SigningMethodHS256, testSecret, expiredClaims(), ""),
+			status:      fasthttp.StatusUnauthorized,
+		},
+		"NoExpiryCanRefresh": {
+			accessToken:  sign(jwt.SigningMethodHS256, testSecret, noExp, ""),
+			refreshToken: "refresh",
+			status:       fasthttp.StatusUnauthorized,
+		},
+		"Forged": {
+			accessToken:  sign(jwt.SigningMethodHS256, []byte("forged"), validClaims(), ""),
+			refreshToken: "refresh",
+			status:       fasthttp.StatusUnauthorized,
+		},
+		"ForgedExpired": {
+			accessToken:  sign(jwt.SigningMethodHS256, []byte("forged"), expiredClaims(), ""),
+			refreshToken: "refresh",
+			status:       fasthttp.StatusUnauthorized,
+		},
+		"Preflight": {
+			method:      fasthttp.MethodOptions,
+			accessToken: "forged",
+			next:        true,
+			status:      fasthttp.StatusOK,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			ctx := &fasthttp.RequestCtx{}
+			if c.method != "" {
+				ctx.Request.Header.SetMethod(c.method)
+			}
+			if c.accessToken != "" {
+				ctx.Request.Header.SetCookie(cookie.AccessTokenCookieName, c.accessToken)
+			}
+			if c.refreshToken != "" {
+				ctx.Request.Header.SetCookie(cookie.RefreshTokenCookieName, c.refreshToken)
+			}
+
+			next := false
+			NewHandler(NewHmacVerifier(testSecret), session.CookieStore{}).Handler(func(ctx *fasthttp.RequestCtx) {
+				next = true
+			})(ctx)
+
+			assert.Equal(t, c.next, next)
+			assert.Equal(t, c.status, ctx.Response.StatusCode())
+
+			claims, ok := ClaimsFromContext(ctx)
+			assert.Equal(t, c.claims, ok)
+			if c.claims {
+				assert.Equal(t, "1", claims.UserId)
+				assert.Equal(t, []string{"user", "admin"}, claims.Roles)
+			}
+
+			if c.status == fasthttp.StatusUnauthorized {
+				body := map[string]string{}
+				assert.NoError(t, json.Unmarshal(ctx.Response.Body(), &body))
+				assert.Equal(t, "token_invalid", body["code"])
+
+				// the rejected tokens are dropped
+				c := fasthttp.Cookie{}
+				c.SetKey(cookie.AccessTokenCookieName)
+				assert.True(t, ctx.Response.Header.Cookie(&c))
+				assert.Empty(t, c.Value())
+			}
+		})
+	}
+}
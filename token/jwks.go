@@ -0,0 +1,316 @@
+package token
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/valyala/fasthttp"
+
+	"github.com/cash-track/gateway/http"
+)
+
+const (
+	// Lower bound between two fetches for a key ID not in the set, so tokens with made up
+	// key IDs cannot make the gateway hammer the JWKS endpoint.
+	jwksRefetchInterval = 30 * time.Second
+	jwksFileScheme      = "file://"
+)
+
+var jwksMethods = append(append(append([]string{}, rsaMethods...), ecdsaMethods...), eddsaMethods...)
+
+var jwksRefreshFailedTotal = promauto.NewCounter(prometheus.CounterOpts{
+	Namespace: metricsNamespace,
+	Subsystem: metricsTokenSubsys,
+	Name:      "jwks_refresh_failed_total",
+	Help:      "JWKS refreshes that failed and left the previously fetched keys in use.",
+})
+
+// JwksVerifier verifies tokens against the keys of a JSON Web Key Set, read from a URL
+// or a local file. The set is fetched again once refreshInterval passed, and early when
+// a token names a key ID it does not have yet, which is how a key the issuer rotated in
+// gets picked up. A failed refresh keeps the keys fetched before.
+type JwksVerifier struct {
+	keyVerifier
+
+	source          string
+	client          http.Client
+	refreshInterval time.Duration
+
+	mu        sync.RWMutex
+	keys      map[string]any
+	checkedAt time.Time
+	// held while fetching, so concurrent requests do not fetch the set again
+	fetching sync.Mutex
+}
+
+// NewJwksVerifier fetches the key set once, so a wrong source fails the start.
+func NewJwksVerifier(source string, client http.Client, refreshInterval time.Duration) (*JwksVerifier, error) {
+	v := &JwksVerifier{
+		source:          source,
+		client:          client,
+		refreshInterval: refreshInterval,
+	}
+	v.keyVerifier = keyVerifier{
+		methods: jwksMethods,
+		key:     v.key,
+		now:     time.Now,
+	}
+
+	keys, err := v.load()
+	if err != nil {
+		return nil, err
+	}
+
+	v.keys, v.checkedAt = keys, v.now()
+
+	return v, nil
+}
+
+func (v *JwksVerifier) key(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	// a refresh in progress is not waited for, the keys fetched before still apply
+	if v.stale(v.refreshInterval) && v.fetching.TryLock() {
+		v.refresh(v.refreshInterval)
+		v.fetching.Unlock()
+	}
+
+	if key, ok := v.lookup(kid); ok {
+		return key, nil
+	}
+
+	if v.stale(jwksRefetchInterval) {
+		v.fetching.Lock()
+		v.refresh(jwksRefetchInterval)
+		v.fetching.Unlock()
+
+		if key, ok := v.lookup(kid); ok {
+			return key, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no key with id %q in the key set", kid)
+}
+
+// lookup finds the key by ID. A token without one can only use a set of a single key.
+func (v *JwksVerifier) lookup(kid string) (any, bool) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	if key, ok := v.keys[kid]; ok {
+		return key, true
+	}
+
+	if kid == "" && len(v.keys) == 1 {
+		for _, key := range v.keys {
+			return key, true
+		}
+	}
+
+	return nil, false
+}
+
+func (v *JwksVerifier) stale(age time.Duration) bool {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	return v.now().Sub(v.checkedAt) >= age
+}
+
+// refresh fetches the set again unless another request did meanwhile. Must be called
+// holding fetching.
+func (v *JwksVerifier) refresh(age time.Duration) {
+	if !v.stale(age) {
+		return
+	}
+
+	keys, err := v.load()
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.checkedAt = v.now()
+
+	if err != nil {
+		jwksRefreshFailedTotal.Inc()
+		slog.Warn("JWKS refresh failed, keeping previous keys", "source", v.source, "error", err)
+
+		return
+	}
+
+	v.keys = keys
+}
+
+func (v *JwksVerifier) load() (map[string]any, error) {
+	var (
+		b   []byte
+		err error
+	)
+
+	if strings.HasPrefix(v.source, "http://") || strings.HasPrefix(v.source, "https://") {
+		b, err = v.fetch()
+	} else {
+		b, err = os.ReadFile(strings.TrimPrefix(v.source, jwksFileScheme))
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("JWKS read: %w", err)
+	}
+
+	return parseJwks(b)
+}
+
+func (v *JwksVerifier) fetch() ([]byte, error) {
+	req := fasthttp.AcquireRequest()
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseRequest(req)
+	defer fasthttp.ReleaseResponse(resp)
+
+	req.SetRequestURI(v.source)
+	req.Header.SetMethod(fasthttp.MethodGet)
+	req.Header.Set(fasthttp.HeaderAccept, "application/json")
+
+	if err := v.client.Do(req, resp); err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode() != fasthttp.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode())
+	}
+
+	return append([]byte(nil), resp.Body()...), nil
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// parseJwks returns the signing keys of the set by key ID. Keys of an unsupported type,
+// symmetric ones included, are skipped.
+func parseJwks(b []byte) (map[string]any, error) {
+	set := struct {
+		Keys []jwk `json:"keys"`
+	}{}
+
+	if err := json.Unmarshal(b, &set); err != nil {
+		return nil, fmt.Errorf("JWKS decode: %w", err)
+	}
+
+	keys := make(map[string]any, len(set.Keys))
+
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		key, err := k.publicKey()
+		if err != nil {
+			slog.Warn("skipping JWKS key", "kid", k.Kid, "error", err)
+
+			continue
+		}
+
+		keys[k.Kid] = key
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("JWKS has no usable signing key")
+	}
+
+	return keys, nil
+}
+
+func (k jwk) publicKey() (any, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("modulus: %w", err)
+		}
+
+		e, err := decodeBigInt(k.E)
+		if err != nil || !e.IsInt64() {
+			return nil, fmt.Errorf("exponent %q is invalid", k.E)
+		}
+
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		curve, err := ellipticCurve(k.Crv)
+		if err != nil {
+			return nil, err
+		}
+
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("x: %w", err)
+		}
+
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("y: %w", err)
+		}
+
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("x is not an Ed25519 public key")
+		}
+
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func ellipticCurve(crv string) (elliptic.Curve, error) {
+	switch crv {
+	case "P-256":
+		return elliptic.P256(), nil
+	case "P-384":
+		return elliptic.P384(), nil
+	case "P-521":
+		return elliptic.P521(), nil
+	default:
+		return nil, fmt.Errorf("unsupported curve %q", crv)
+	}
+}
+
+func decodeBigInt(v string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(v)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(b) == 0 {
+		return nil, errors.New("empty value")
+	}
+
+	return new(big.Int).SetBytes(b), nil
+}
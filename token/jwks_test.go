@@ -0,0 +1,198 @@
+package token
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+	"go.uber.org/mock/gomock"
+
+	httpmock "github.com/cash-track/gateway/mocks/http"
+)
+
+const jwksUrl = "https://auth.test.com/.well-known/jwks.json"
+
+func rsaJwk(kid string, key *rsa.PublicKey) map[string]string {
+	return map[string]string{
+		"kty": "RSA",
+		"kid": kid,
+		"use": "sig",
+		"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+func jwks(keys ...map[string]string) []byte {
+	b, _ := json.Marshal(map[string]any{"keys": keys})
+
+	return b
+}
+
+func writeJwks(t *testing.T, path string, keys ...map[string]string) {
+	assert.NoError(t, os.WriteFile(path, jwks(keys...), 0o600))
+}
+
+func TestJwksVerifierFile(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	writeJwks(t, path, rsaJwk("k1", &key.PublicKey))
+
+	v, err := NewJwksVerifier("file://"+path, nil, time.Hour)
+	assert.NoError(t, err)
+
+	claims, err := v.Verify(sign(jwt.SigningMethodRS256, key, validClaims(), "k1"))
+	assert.NoError(t, err)
+	assert.Equal(t, "1", claims.UserId)
+
+	// a single key also serves tokens without a key ID
+	_, err = v.Verify(sign(jwt.SigningMethodRS256, key, validClaims(), ""))
+	assert.NoError(t, err)
+
+	_, err = v.Verify(sign(jwt.SigningMethodRS256, key, expiredClaims(), "k1"))
+	assert.ErrorIs(t, err, ErrExpired)
+
+	_, err = v.Verify(sign(jwt.SigningMethodHS256, testSecret, validClaims(), "k1"))
+	assert.ErrorIs(t, err, ErrInvalid)
+}
+
+func TestJwksVerifierRotation(t *testing.T) {
+	oldKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	newKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	writeJwks(t, path, rsaJwk("old", &oldKey.PublicKey))
+
+	v, err := NewJwksVerifier(path, nil, time.Hour)
+	assert.NoError(t, err)
+	now := v.checkedAt
+	v.now = func() time.Time { return now }
+
+	writeJwks(t, path, rsaJwk("old", &oldKey.PublicKey), rsaJwk("new", &newKey.PublicKey))
+
+	// fetched too recently to look for the new key yet
+	_, err = v.Verify(sign(jwt.SigningMethodRS256, newKey, validClaims(), "new"))
+	assert.ErrorIs(t, err, ErrInvalid)
+
+	now = now.Add(jwksRefetchInterval)
+
+	_, err = v.Verify(sign(jwt.SigningMethodRS256, newKey, validClaims(), "new"))
+	assert.NoError(t, err)
+
+	_, err = v.Verify(sign(jwt.SigningMethodRS256, oldKey, validClaims(), "old"))
+	assert.NoError(t, err)
+
+	// the periodic refresh drops the key the issuer retired
+	writeJwks(t, path, rsaJwk("new", &newKey.PublicKey))
+	now = now.Add(time.Hour)
+
+	_, err = v.Verify(sign(jwt.SigningMethodRS256, newKey, validClaims(), "new"))
+	assert.NoError(t, err)
+
+	_, err = v.Verify(sign(jwt.SigningMethodRS256, oldKey, validClaims(), "old"))
+	assert.ErrorIs(t, err, ErrInvalid)
+}
+
+func TestJwksVerifierRefreshFailureKeepsKeys(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	writeJwks(t, path, rsaJwk("k1", &key.PublicKey))
+
+	v, err := NewJwksVerifier(path, nil, time.Minute)
+	assert.NoError(t, err)
+	now := v.checkedAt
+	v.now = func() time.Time { return now }
+
+	assert.NoError(t, os.WriteFile(path, []byte("{"), 0o600))
+	now = now.Add(time.Hour)
+
+	_, err = v.Verify(sign(jwt.SigningMethodRS256, key, validClaims(), "k1"))
+	assert.NoError(t, err)
+}
+
+func TestJwksVerifierUrl(t *testing.T) {
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	edPub, edKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	ctrl := gomock.NewController(t)
+	client := httpmock.NewClientMock(ctrl)
+	client.EXPECT().Do(gomock.Any(), gomock.Any()).DoAndReturn(func(req *fasthttp.Request, resp *fasthttp.Response) error {
+		assert.Equal(t, jwksUrl, req.URI().String())
+		assert.Equal(t, fasthttp.MethodGet, string(req.Header.Method()))
+
+		resp.SetStatusCode(fasthttp.StatusOK)
+		resp.SetBody(jwks(
+			map[string]string{
+				"kty": "EC",
+				"kid": "ec",
+				"crv": "P-256",
+				"x":   base64.RawURLEncoding.EncodeToString(ecKey.X.Bytes()),
+				"y":   base64.RawURLEncoding.EncodeToString(ecKey.Y.Bytes()),
+			},
+			map[string]string{
+				"kty": "OKP",
+				"kid": "ed",
+				"crv": "Ed25519",
+				"x":   base64.RawURLEncoding.EncodeToString(edPub),
+			},
+			map[string]string{"kty": "oct", "kid": "hmac", "k": "c2VjcmV0"},
+			map[string]string{"kty": "RSA", "kid": "enc", "use": "enc"},
+		))
+
+		return nil
+	})
+
+	v, err := NewJwksVerifier(jwksUrl, client, time.Hour)
+	assert.NoError(t, err)
+	assert.Len(t, v.keys, 2)
+
+	_, err = v.Verify(sign(jwt.SigningMethodES256, ecKey, validClaims(), "ec"))
+	assert.NoError(t, err)
+
+	_, err = v.Verify(sign(jwt.SigningMethodEdDSA, edKey, validClaims(), "ed"))
+	assert.NoError(t, err)
+
+	// a token naming one key but signed for another family is rejected
+	_, err = v.Verify(sign(jwt.SigningMethodES256, ecKey, validClaims(), "ed"))
+	assert.ErrorIs(t, err, ErrInvalid)
+}
+
+func TestJwksVerifierUrlFail(t *testing.T) {
+	cases := map[string]struct {
+		status int
+		body   string
+		err    error
+	}{
+		"Error":     {err: fmt.Errorf("connection refused")},
+		"Status":    {status: fasthttp.StatusNotFound},
+		"Malformed": {status: fasthttp.StatusOK, body: "{"},
+		"NoKeys":    {status: fasthttp.StatusOK, body: `{"keys":[]}`},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			client := httpmock.NewClientMock(ctrl)
+			client.EXPECT().Do(gomock.Any(), gomock.Any()).DoAndReturn(func(_ *fasthttp.Request, resp *fasthttp.Response) error {
+				resp.SetStatusCode(c.status)
+				resp.SetBodyString(c.body)
+
+				return c.err
+			})
+
+			_, err := NewJwksVerifier(jwksUrl, client, time.Hour)
+			assert.Error(t, err)
+		})
+	}
+}
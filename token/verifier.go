@@ -0,0 +1,156 @@
+package token
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+
+	"github.com/cash-track/gateway/config"
+	"github.com/cash-track/gateway/http"
+)
+
+var (
+	// ErrExpired is returned for a genuine access token past its exp claim, which a
+	// refresh token can still replace.
+	ErrExpired = errors.New("access token expired")
+	// ErrInvalid is returned for an access token not signed by a trusted key, or one
+	// missing the claims the gateway relies on.
+	ErrInvalid = errors.New("access token invalid")
+)
+
+var (
+	hmacMethods  = []string{"HS256", "HS384", "HS512"}
+	rsaMethods   = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512"}
+	ecdsaMethods = []string{"ES256", "ES384", "ES512"}
+	eddsaMethods = []string{"EdDSA"}
+)
+
+// Verifier checks the signature and expiry of access tokens issued by the API.
+type Verifier interface {
+	Verify(accessToken string) (Claims, error)
+}
+
+// NewVerifier creates the verifier for the key source set in the configuration, which
+// allows one at most. It returns nil when none is set and tokens are not verified.
+func NewVerifier(c config.Config, client http.Client) (Verifier, error) {
+	switch {
+	case c.JwtHmacSecret != "":
+		return NewHmacVerifier([]byte(c.JwtHmacSecret)), nil
+	case c.JwtPublicKeyFile != "":
+		b, err := os.ReadFile(c.JwtPublicKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("read public key: %w", err)
+		}
+
+		return NewPemVerifier(b)
+	case c.JwtJwksUrl != "":
+		return NewJwksVerifier(c.JwtJwksUrl, client, c.JwtJwksRefresh)
+	default:
+		return nil, nil
+	}
+}
+
+// NewHmacVerifier verifies tokens signed with the secret shared with the API.
+func NewHmacVerifier(secret []byte) Verifier {
+	return &keyVerifier{
+		methods: hmacMethods,
+		key: func(*jwt.Token) (any, error) {
+			return secret, nil
+		},
+		now: time.Now,
+	}
+}
+
+// NewPemVerifier verifies tokens signed with the private key of a PEM encoded RSA, ECDSA
+// or Ed25519 public key.
+func NewPemVerifier(pem []byte) (Verifier, error) {
+	key, err := parsePemPublicKey(pem)
+	if err != nil {
+		return nil, err
+	}
+
+	return &keyVerifier{
+		methods: methodsFor(key),
+		key: func(*jwt.Token) (any, error) {
+			return key, nil
+		},
+		now: time.Now,
+	}, nil
+}
+
+// keyVerifier verifies tokens against the key returned by key, which is only called once
+// the signing method is one of methods. Claims are checked here rather than by the jwt
+// parser, which reports an expired token with a bad signature as expired too.
+type keyVerifier struct {
+	methods []string
+	key     jwt.Keyfunc
+	now     func() time.Time
+}
+
+func (v *keyVerifier) Verify(accessToken string) (Claims, error) {
+	claims := jwt.MapClaims{}
+
+	parser := jwt.NewParser(jwt.WithValidMethods(v.methods), jwt.WithoutClaimsValidation())
+	if _, err := parser.ParseWithClaims(accessToken, claims, v.key); err != nil {
+		return Claims{}, fmt.Errorf("%w: %w", ErrInvalid, err)
+	}
+
+	now := v.now().Unix()
+
+	if !claims.VerifyNotBefore(now, false) {
+		return Claims{}, fmt.Errorf("%w: token is not valid yet", ErrInvalid)
+	}
+
+	c := newClaims(claims)
+	if c.UserId == "" {
+		return Claims{}, fmt.Errorf("%w: no user id", ErrInvalid)
+	}
+
+	// a token that never expires is not one the API issues, nor one to refresh
+	if _, ok := claims["exp"]; !ok {
+		return Claims{}, fmt.Errorf("%w: no expiry", ErrInvalid)
+	}
+
+	if !claims.VerifyExpiresAt(now, true) {
+		return c, ErrExpired
+	}
+
+	return c, nil
+}
+
+func parsePemPublicKey(pem []byte) (any, error) {
+	if key, err := jwt.ParseRSAPublicKeyFromPEM(pem); err == nil {
+		return key, nil
+	}
+
+	if key, err := jwt.ParseECPublicKeyFromPEM(pem); err == nil {
+		return key, nil
+	}
+
+	if key, err := jwt.ParseEdPublicKeyFromPEM(pem); err == nil {
+		return key, nil
+	}
+
+	return nil, errors.New("public key is not a PEM encoded RSA, ECDSA or Ed25519 key")
+}
+
+// methodsFor returns the signing methods a key verifies, so a token cannot pick one of
+// another family, e.g. HS256 keyed with the bytes of a public key.
+func methodsFor(key any) []string {
+	switch key.(type) {
+	case *rsa.PublicKey:
+		return rsaMethods
+	case *ecdsa.PublicKey:
+		return ecdsaMethods
+	case ed25519.PublicKey:
+		return eddsaMethods
+	default:
+		return []string{}
+	}
+}
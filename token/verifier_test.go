@@ -0,0 +1,212 @@
+package token
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cash-track/gateway/config"
+)
+
+var testSecret = []byte("test-secret")
+
+func sign(method jwt.SigningMethod, key any, claims jwt.MapClaims, kid string) string {
+	t := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		t.Header["kid"] = kid
+	}
+
+	s, err := t.SignedString(key)
+	if err != nil {
+		panic(err)
+	}
+
+	return s
+}
+
+func validClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"sub":   1,
+		"iat":   time.Now().Add(-time.Minute).Unix(),
+		"exp":   time.Now().Add(24 * time.Hour).Unix(),
+		"roles": []string{"user", "admin"},
+	}
+}
+
+func expiredClaims() jwt.MapClaims {
+	claims := validClaims()
+	claims["exp"] = time.Now().Add(-time.Minute).Unix()
+
+	return claims
+}
+
+func publicKeyPem(key any) []byte {
+	b, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		panic(err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: b})
+}
+
+func TestHmacVerifier(t *testing.T) {
+	v := NewHmacVerifier(testSecret)
+
+	claims := validClaims()
+	claims["nbf"] = time.Now().Add(time.Hour).Unix()
+	notYetValid := claims
+
+	noSub := validClaims()
+	delete(noSub, "sub")
+
+	noExp := validClaims()
+	delete(noExp, "exp")
+
+	cases := map[string]struct {
+		token  string
+		claims Claims
+		err    error
+	}{
+		"Valid": {
+			token:  sign(jwt.SigningMethodHS256, testSecret, validClaims(), ""),
+			claims: Claims{UserId: "1", Roles: []string{"user", "admin"}},
+		},
+		"ValidHS512": {
+			token:  sign(jwt.SigningMethodHS512, testSecret, validClaims(), ""),
+			claims: Claims{UserId: "1", Roles: []string{"user", "admin"}},
+		},
+		"Expired": {
+			token:  sign(jwt.SigningMethodHS256, testSecret, expiredClaims(), ""),
+			claims: Claims{UserId: "1", Roles: []string{"user", "admin"}},
+			err:    ErrExpired,
+		},
+		"ExpiredWrongSecret": {
+			token: sign(jwt.SigningMethodHS256, []byte("forged"), expiredClaims(), ""),
+			err:   ErrInvalid,
+		},
+		"WrongSecret": {
+			token: sign(jwt.SigningMethodHS256, []byte("forged"), validClaims(), ""),
+			err:   ErrInvalid,
+		},
+		"AlgNone": {
+			token: sign(jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, validClaims(), ""),
+			err:   ErrInvalid,
+		},
+		"NotYetValid": {
+			token: sign(jwt.SigningMethodHS256, testSecret, notYetValid, ""),
+			err:   ErrInvalid,
+		},
+		"NoUserId": {
+			token: sign(jwt.SigningMethodHS256, testSecret, noSub, ""),
+			err:   ErrInvalid,
+		},
+		"NoExpiry": {
+			token: sign(jwt.SigningMethodHS256, testSecret, noExp, ""),
+			err:   ErrInvalid,
+		},
+		"Garbage": {
+			token: "not.a.token",
+			err:   ErrInvalid,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			claims, err := v.Verify(c.token)
+
+			if c.err != nil {
+				assert.ErrorIs(t, err, c.err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			assert.Equal(t, c.claims.UserId, claims.UserId)
+			assert.Equal(t, c.claims.Roles, claims.Roles)
+		})
+	}
+}
+
+func TestHmacVerifierClaims(t *testing.T) {
+	claims := validClaims()
+	claims["sub"] = "42"
+	claims["roles"] = "user admin"
+
+	c, err := NewHmacVerifier(testSecret).Verify(sign(jwt.SigningMethodHS256, testSecret, claims, ""))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "42", c.UserId)
+	assert.Equal(t, []string{"user", "admin"}, c.Roles)
+	assert.Equal(t, time.Unix(claims["iat"].(int64), 0), c.IssuedAt)
+	assert.Equal(t, time.Unix(claims["exp"].(int64), 0), c.ExpiresAt)
+}
+
+func TestPemVerifier(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	edPub, edKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	cases := map[string]struct {
+		pem    []byte
+		method jwt.SigningMethod
+		key    any
+	}{
+		"RSA":     {pem: publicKeyPem(&rsaKey.PublicKey), method: jwt.SigningMethodRS256, key: rsaKey},
+		"ECDSA":   {pem: publicKeyPem(&ecKey.PublicKey), method: jwt.SigningMethodES256, key: ecKey},
+		"Ed25519": {pem: publicKeyPem(edPub), method: jwt.SigningMethodEdDSA, key: edKey},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			v, err := NewPemVerifier(c.pem)
+			assert.NoError(t, err)
+
+			claims, err := v.Verify(sign(c.method, c.key, validClaims(), ""))
+			assert.NoError(t, err)
+			assert.Equal(t, "1", claims.UserId)
+
+			// the public key bytes must not work as an HMAC secret
+			_, err = v.Verify(sign(jwt.SigningMethodHS256, c.pem, validClaims(), ""))
+			assert.ErrorIs(t, err, ErrInvalid)
+		})
+	}
+}
+
+func TestPemVerifierInvalidKey(t *testing.T) {
+	_, err := NewPemVerifier([]byte("not a key"))
+
+	assert.Error(t, err)
+}
+
+func TestNewVerifier(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	pemFile := filepath.Join(t.TempDir(), "public.pem")
+	assert.NoError(t, os.WriteFile(pemFile, publicKeyPem(&rsaKey.PublicKey), 0o600))
+
+	v, err := NewVerifier(config.Config{}, nil)
+	assert.NoError(t, err)
+	assert.Nil(t, v)
+
+	v, err = NewVerifier(config.Config{JwtHmacSecret: "secret"}, nil)
+	assert.NoError(t, err)
+	assert.NotNil(t, v)
+
+	v, err = NewVerifier(config.Config{JwtPublicKeyFile: pemFile}, nil)
+	assert.NoError(t, err)
+	_, err = v.Verify(sign(jwt.SigningMethodRS256, rsaKey, validClaims(), ""))
+	assert.NoError(t, err)
+
+	_, err = NewVerifier(config.Config{JwtPublicKeyFile: pemFile + ".missing"}, nil)
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+}
@@ -40,3 +40,9 @@ func (c *fastHttpCarrier) Keys() []string {
 
 	return keys
 }
+
+// ExtractContextFromRequest returns the trace context PropagateContextToRequest put in req,
+// for spans of the outgoing request to join the trace of the incoming one.
+func ExtractContextFromRequest(req *fasthttp.Request) context.Context {
+	return otel.GetTextMapPropagator().Extract(context.Background(), newFastHttpCarrier(&req.Header))
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/valyala/fasthttp"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func TestPropagateContextToRequest(t *testing.T) {
@@ -28,6 +29,17 @@ func TestPropagateContextToRequest(t *testing.T) {
 	assert.Equal(t, "value", carrier.Get("Existing-Header"))
 }
 
+func TestExtractContextFromRequest(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	req := &fasthttp.Request{}
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	spanCtx := trace.SpanContextFromContext(ExtractContextFromRequest(req))
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spanCtx.TraceID().String())
+	assert.True(t, spanCtx.IsRemote())
+}
+
 func TestFastHttpCarrier(t *testing.T) {
 	for name, test := range map[string]struct {
 		get    func() *fasthttp.RequestHeader
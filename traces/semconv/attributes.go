@@ -12,6 +12,8 @@ const (
 	CashTrackAuthCanRefreshKey           = "ct.auth.can_refresh"
 	CashTrackAuthAccessTokenExpireAtKey  = "ct.auth.access_token_expire_at"
 	CashTrackAuthRefreshTokenExpireAtKey = "ct.auth.refresh_token_expire_at"
+	CashTrackAuthUserIdKey               = "ct.auth.user_id"
+	CashTrackAuthRolesKey                = "ct.auth.roles"
 
 	CashTrackCSRFContextKey = "ct.csrf.context"
 	CashTrackCSRFIsValidKey = "ct.csrf.is_valid"